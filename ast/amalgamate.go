@@ -0,0 +1,242 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// amalgamationBanner marks the beginning or the end of the contents
+// of a file in amalgamated output
+type amalgamationBanner struct {
+	typ  string
+	name string
+	end  bool
+}
+
+func (b *amalgamationBanner) EmitStmt(ctx *EmitContext, w io.Writer) error {
+	if ctx.sourceMap {
+		if b.end {
+			fmt.Fprintf(w, "%c", markFilePop)
+		} else {
+			fmt.Fprintf(w, "%c%s%c", markFileStart, b.name, markFileEnd)
+		}
+	}
+	if b.end {
+		fmt.Fprintf(w, "\n// END %s %s\n", b.typ, b.name)
+	} else {
+		fmt.Fprintf(w, "\n\n// START %s %s\n", b.typ, b.name)
+	}
+	return nil
+}
+
+func toStmtList(stmt Stmt) []Stmt {
+	if list, ok := stmt.(Stmts); ok {
+		return list
+	}
+	return []Stmt{stmt}
+}
+
+// expandInclusions returns the top-level statements of stmt, with
+// `include` and `use` directives replaced by the statements that
+// they expand to.
+func expandInclusions(ctx *EmitContext, stmt Stmt) (Stmts, error) {
+	var ret Stmts
+	for _, child := range toStmtList(stmt) {
+		var directive *inclusionDirective
+		switch v := child.(type) {
+		case *Include:
+			directive = &v.inclusionDirective
+		case *Use:
+			directive = &v.inclusionDirective
+		default:
+			ret = append(ret, child)
+			continue
+		}
+
+		expanded, err := directive.expand(ctx)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, expanded...)
+	}
+	return ret, nil
+}
+
+// expand returns the statements that the directive is replaced with in
+// amalgamated output. Each file is only expanded once per directive type.
+func (i *inclusionDirective) expand(ctx *EmitContext) (Stmts, error) {
+	// A file may be both included and used, and the two produce
+	// different results. Keep track of them separately
+	key := i.typ + ` ` + i.name
+	if _, ok := ctx.amalgamated[key]; ok {
+		return nil, nil
+	}
+	ctx.amalgamated[key] = struct{}{}
+
+	if i.typ == `use` && ctx.plan != nil {
+		ctx = ctx.withUnit(ctx.plan.unitFor(i.name))
+	}
+	stmt, ok := ctx.lookupFile(i.name)
+	if !ok {
+		return nil, fmt.Errorf(`source file %q not found`, i.name)
+	}
+
+	var body Stmts
+	var err error
+	if i.typ == `use` {
+		body, err = expandUsedFile(ctx, i.name, stmt)
+	} else {
+		body, err = expandInclusions(ctx, stmt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(Stmts, 0, len(body)+2)
+	ret = append(ret, &amalgamationBanner{typ: i.typ, name: i.name})
+	ret = append(ret, body...)
+	ret = append(ret, &amalgamationBanner{typ: i.typ, name: i.name, end: true})
+	return ret, nil
+}
+
+// mangledPrefix creates an identifier prefix that is unique to the given
+// file name, such as `lib_gears__` for `lib/gears.scad`
+func mangledPrefix(filename string) string {
+	filename = strings.TrimSuffix(filename, `.scad`)
+	var sb strings.Builder
+	for i, r := range filename {
+		if i == 0 && unicode.IsDigit(r) {
+			sb.WriteRune('_')
+		}
+		if r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	sb.WriteString(`__`)
+	return sb.String()
+}
+
+// flattenIncludes returns the top-level statements of the file, with
+// all `include` directives (recursively) replaced by the contents of the
+// included file. This is the set of statements that make up the file-level
+// scope of a file.
+func flattenIncludes(ctx *EmitContext, stmt Stmt, visited map[string]struct{}) ([]Stmt, error) {
+	var ret []Stmt
+	for _, child := range toStmtList(stmt) {
+		include, ok := child.(*Include)
+		if !ok {
+			ret = append(ret, child)
+			continue
+		}
+
+		if _, ok := visited[include.name]; ok {
+			continue
+		}
+		visited[include.name] = struct{}{}
+
+		included, ok := ctx.lookupFile(include.name)
+		if !ok {
+			return nil, fmt.Errorf(`source file %q not found`, include.name)
+		}
+		flattened, err := flattenIncludes(ctx, included, visited)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, flattened...)
+	}
+	return ret, nil
+}
+
+// expandUsedFile returns the contents of a file pulled in via `use`.
+//
+// OpenSCAD only imports module and function definitions from files that
+// are `use`d. Top-level geometry and variable assignments are not executed,
+// but the definitions still see the variables of the file they were defined
+// in. To preserve this in a single file, only the definitions are emitted,
+// along with the file-level variables that they (transitively) refer to.
+// These variables are renamed using a prefix based on the file name, so
+// that they cannot be overwritten by variables of the same name in the
+// file that uses them. Calls to variables holding function literals are
+// renamed accordingly.
+func expandUsedFile(ctx *EmitContext, name string, stmt Stmt) (Stmts, error) {
+	flattened, err := flattenIncludes(ctx, stmt, map[string]struct{}{name: {}})
+	if err != nil {
+		return nil, fmt.Errorf(`failed to process used file %q: %w`, name, err)
+	}
+
+	var stmts Stmts
+
+	var definitions []Stmt
+	assignments := make(map[string][]*Variable)
+	functions := make(map[string]string)
+	for _, child := range flattened {
+		switch v := child.(type) {
+		case *Function:
+			// functions take precedence over variables holding
+			// function literals, and are never renamed
+			functions[v.name] = v.name
+			definitions = append(definitions, v)
+		case *Module:
+			definitions = append(definitions, v)
+		case *Variable:
+			if !isSpecialVariable(v.name) {
+				assignments[v.name] = append(assignments[v.name], v)
+			}
+		case *Use:
+			// files used from within the used file must also be available
+			expanded, err := v.expand(ctx)
+			if err != nil {
+				return nil, err
+			}
+			stmts = append(stmts, expanded...)
+		}
+	}
+
+	// Compute the set of file-level variables that the definitions need
+	needed := make(map[string]struct{})
+	var pending []string
+	require := func(names map[string]struct{}) {
+		for name := range names {
+			if _, ok := assignments[name]; !ok {
+				continue
+			}
+			if _, ok := needed[name]; ok {
+				continue
+			}
+			needed[name] = struct{}{}
+			pending = append(pending, name)
+		}
+	}
+	for _, def := range definitions {
+		require(referencedVariables(def, functions))
+	}
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		for _, v := range assignments[name] {
+			require(referencedVariables(v.value, functions))
+		}
+	}
+
+	prefix := mangledPrefix(name)
+	r := &renamer{variables: make(map[string]string, len(needed)), functions: functions}
+	for name := range needed {
+		r.variables[name] = prefix + name
+	}
+
+	for _, child := range flattened {
+		switch v := child.(type) {
+		case *Variable:
+			if _, ok := needed[v.name]; ok {
+				stmts = append(stmts, r.stmt(v, scope{}, true))
+			}
+		case *Module, *Function:
+			stmts = append(stmts, r.stmt(v, scope{}, true))
+		}
+	}
+	return stmts, nil
+}
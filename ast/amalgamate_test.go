@@ -0,0 +1,92 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmalgamateUse(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "use <lib.scad>\nsize = 5;\nbox();",
+		"lib.scad": `include <inc.scad>
+size = 10;
+wall = size / 10;
+unused = 3;
+module box(h = wall) { inner = 2; cube([size, inner, h]); }
+function twice(size) = size * 2;
+box();`,
+		"inc.scad": "base = 1;\nmodule base_plate() { cube([base, base, base]); }\nsphere(r=1);",
+	})
+
+	stmt, ok := registry.Lookup("main.scad")
+	require.True(t, ok, `lookup should succeed`)
+
+	out, err := ast.EmitString(stmt, ast.WithAmalgamation(), ast.WithRegistry(registry))
+	require.NoError(t, err, `emit should succeed`)
+	require.Equal(t, `
+
+// START use lib.scad
+
+lib__base = 1;
+module base_plate()
+{
+  cube([lib__base, lib__base, lib__base]);
+}
+
+lib__size = 10;
+lib__wall = lib__size / 10;
+module box(h=lib__wall)
+{
+  inner = 2;
+
+  cube([lib__size, inner, h]);
+}
+
+function twice(size) = size * 2;
+// END use lib.scad
+
+size = 5;
+box();`, out)
+}
+
+func TestAmalgamateIncludeAndUse(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "use <lib.scad>\ninclude <lib.scad>",
+		"lib.scad":  "x = 1;\nmodule m() { cube([x, x, x]); }\nm();",
+	})
+
+	stmt, ok := registry.Lookup("main.scad")
+	require.True(t, ok, `lookup should succeed`)
+
+	out, err := ast.EmitString(stmt, ast.WithAmalgamation(), ast.WithRegistry(registry))
+	require.NoError(t, err, `emit should succeed`)
+	require.Contains(t, out, "// START use lib.scad\n", `use should be amalgamated`)
+	require.Contains(t, out, "// START include lib.scad\n\nx = 1;", `include should be amalgamated in full`)
+	require.Contains(t, out, "\nm();", `top-level geometry of the included file should be kept`)
+}
+
+func TestAmalgamateUseFunctionLiterals(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "use <lib.scad>\nsq = 1;\ny = area(3);",
+		"lib.scad":  "sq = function(x) x * x;\nfunction area(x) = sq(x);\nfunction apply(sq, x) = sq(x);",
+	})
+
+	stmt, ok := registry.Lookup("main.scad")
+	require.True(t, ok, `lookup should succeed`)
+
+	out, err := ast.EmitString(stmt, ast.WithAmalgamation(), ast.WithRegistry(registry))
+	require.NoError(t, err, `emit should succeed`)
+	require.Equal(t, `
+
+// START use lib.scad
+
+lib__sq = function(x) x * x;
+function area(x) = lib__sq(x);
+function apply(sq, x) = sq(x);
+// END use lib.scad
+
+sq = 1;
+y = area(3);`, out)
+}
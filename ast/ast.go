@@ -1,9 +1,11 @@
 package ast
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
+	"math"
 	"reflect"
 	"strings"
 )
@@ -51,6 +53,7 @@ func (stmts *Stmts) Add(stmt Stmt) {
 func (stmts Stmts) EmitStmt(ctx *EmitContext, w io.Writer) error {
 	ctx = ctx.WithAllowAssignment(true)
 	for _, stmt := range stmts {
+		emitPosition(ctx, w, stmt)
 		if err := stmt.EmitStmt(ctx, w); err != nil {
 			return err
 		}
@@ -68,6 +71,11 @@ func NewDeclare(v *Variable) *Declare {
 	}
 }
 
+// Variable returns the variable being declared
+func (d *Declare) Variable() *Variable {
+	return d.v
+}
+
 func (d *Declare) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	return d.v.EmitExpr(ctx.WithAllowAssignment(true), w)
 }
@@ -80,6 +88,7 @@ func (d *Declare) EmitStmt(ctx *EmitContext, w io.Writer) error {
 // It can be assigned a value so that in appropriate contexts,
 // an assignment statement is emitted.
 type Variable struct {
+	position
 	name  string
 	value interface{}
 }
@@ -98,6 +107,16 @@ func (p *Variable) String() string {
 	return sb.String()
 }
 
+// Name returns the name of the variable
+func (p *Variable) Name() string {
+	return p.name
+}
+
+// ValueExpr returns the expression assigned to the variable, or nil
+func (p *Variable) ValueExpr() interface{} {
+	return p.value
+}
+
 func (p *Variable) HasValue() bool {
 	return p.value != nil
 }
@@ -113,7 +132,7 @@ func (p *Variable) emit(ctx *EmitContext, w io.Writer, isStmt bool) error {
 	// function call. The variable is used as statement in the former,
 	// and an expression in later
 	spacing := ""
-	if isStmt {
+	if isStmt || ctx.style.SpaceAroundEquals {
 		spacing = " "
 	}
 	if ctx.AllowAssignment() && p.value != nil {
@@ -142,6 +161,7 @@ func (p *Variable) EmitStmt(ctx *EmitContext, w io.Writer) error {
 }
 
 type Module struct {
+	position
 	name       string
 	parameters []*Variable
 	children   []Stmt
@@ -155,18 +175,20 @@ func emitChildren(ctx *EmitContext, w io.Writer, children []Stmt, forceBrace boo
 	}
 
 	ctx = ctx.IncrIndent()
-	if numc == 1 && !forceBrace {
+	if numc == 1 && !forceBrace && ctx.style.Braces != BracesAlways {
+		emitPosition(ctx, w, children[0])
 		return children[0].EmitStmt(ctx, w)
 	}
 
 	fmt.Fprintf(w, "\n%s{", indent)
-	prev := reflect.TypeOf(children[0]).Elem()
-	for _, c := range children {
-		cur := reflect.TypeOf(c).Elem()
-		if cur.Name() != prev.Name() {
+	prev := stmtKind(children[0])
+	for i, c := range children {
+		cur := stmtKind(c)
+		if i > 0 && ctx.style.blankLineBetween(prev, cur) {
 			fmt.Fprintf(w, "\n")
 		}
 		prev = cur
+		emitPosition(ctx, w, c)
 		if err := c.EmitStmt(ctx, w); err != nil {
 			return err
 		}
@@ -175,12 +197,38 @@ func emitChildren(ctx *EmitContext, w io.Writer, children []Stmt, forceBrace boo
 	return nil
 }
 
+// stmtKind returns the kind of statement, for the purpose of separating
+// statements of different kinds with blank lines
+func stmtKind(stmt Stmt) string {
+	switch stmt.(type) {
+	case *Translate, *Rotate, *LinearExtrude:
+		// these are emitted as calls
+		return `Call`
+	}
+	return reflect.TypeOf(stmt).Elem().Name()
+}
+
 func NewModule(name string) *Module {
 	return &Module{
 		name: name,
 	}
 }
 
+// Name returns the name of the module
+func (m *Module) Name() string {
+	return m.name
+}
+
+// Params returns the parameters of the module
+func (m *Module) Params() []*Variable {
+	return m.parameters
+}
+
+// Children returns the statements that make up the body of the module
+func (m *Module) Children() []Stmt {
+	return m.children
+}
+
 func (m *Module) Parameters(params ...*Variable) *Module {
 	m.parameters = append(m.parameters, params...)
 	return m
@@ -227,9 +275,11 @@ func (m *Module) EmitStmt(ctx *EmitContext, w io.Writer) error {
 }
 
 type Call struct {
+	position
 	name       string
 	parameters []interface{}
 	children   []Stmt
+	dependency *inclusionDirective
 }
 
 func NewCall(name string) *Call {
@@ -238,6 +288,23 @@ func NewCall(name string) *Call {
 	}
 }
 
+// Name returns the name of the module or function being called
+func (c *Call) Name() string {
+	return c.name
+}
+
+// Arguments returns the arguments of the call. Named arguments are
+// represented as variables that have a value.
+func (c *Call) Arguments() []interface{} {
+	return c.parameters
+}
+
+// Children returns the statements that are passed to the module as its
+// children
+func (c *Call) Children() []Stmt {
+	return c.children
+}
+
 func (c *Call) String() string {
 	var sb strings.Builder
 	if err := c.EmitExpr(newEmitContext(), &sb); err != nil {
@@ -256,6 +323,20 @@ func (c *Call) Add(children ...Stmt) *Call {
 	return c
 }
 
+// Use records that the module or function being called is defined in
+// the given file. When the call is emitted, a `use` directive for the file
+// is added to the top of the output, unless one is already there.
+func (c *Call) Use(name string) *Call {
+	c.dependency = &NewUse(name).inclusionDirective
+	return c
+}
+
+// Include is like Use, but the file is pulled in with `include`.
+func (c *Call) Include(name string) *Call {
+	c.dependency = &NewInclude(name).inclusionDirective
+	return c
+}
+
 func (c *Call) EmitStmt(ctx *EmitContext, w io.Writer) error {
 	fmt.Fprintf(w, "\n%s", ctx.Indent())
 	if err := c.EmitExpr(ctx, w); err != nil {
@@ -272,14 +353,51 @@ func (c *Call) EmitStmt(ctx *EmitContext, w io.Writer) error {
 }
 
 func (c *Call) EmitExpr(ctx *EmitContext, w io.Writer) error {
+	column := ctx.currentColumn(w)
 	fmt.Fprintf(w, `%s(`, c.name)
 
-	ctx = ctx.WithAllowAssignment(true)
+	pctx := ctx.WithAllowAssignment(true)
+	if ctx.style.MaxWidth > 0 && len(c.parameters) > 0 {
+		// emitArgs emits the arguments starting at column, either one
+		// after the other or each on a line of its own
+		emitArgs := func(column int, separateLine bool) ([]string, error) {
+			args := make([]string, len(c.parameters))
+			for i, p := range c.parameters {
+				var buf bytes.Buffer
+				if err := emitExpr(pctx.withColumn(column), &buf, p); err != nil {
+					return nil, err
+				}
+				args[i] = buf.String()
+				if !separateLine {
+					column += ctx.style.width(args[i] + `, `)
+				}
+			}
+			return args, nil
+		}
+		args, err := emitArgs(column+ctx.style.width(c.name+`(`), false)
+		if err != nil {
+			return err
+		}
+
+		// arguments that do not fit on the line are put on lines of their own
+		if !ctx.style.fits(column, c.name+`(`+strings.Join(args, `, `)+`)`) {
+			if args, err = emitArgs(ctx.style.width(ctx.Indent()+ctx.indentUnit()), true); err != nil {
+				return err
+			}
+			fmt.Fprintln(w)
+			if err := addIndent(w, strings.NewReader(strings.Join(args, ",\n")), ctx.Indent()+ctx.indentUnit()); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "\n%s)", ctx.Indent())
+			return nil
+		}
+	}
+
 	for i, p := range c.parameters {
 		if i > 0 {
 			fmt.Fprintf(w, `, `)
 		}
-		if err := emitExpr(ctx, w, p); err != nil {
+		if err := emitExpr(pctx, w, p); err != nil {
 			return err
 		}
 	}
@@ -288,31 +406,26 @@ func (c *Call) EmitExpr(ctx *EmitContext, w io.Writer) error {
 }
 
 type inclusionDirective struct {
+	position
 	typ  string
 	name string
 }
 
+// Name returns the name of the file being included or used.
+func (i *inclusionDirective) Name() string {
+	return i.name
+}
+
 func (i *inclusionDirective) EmitStmt(ctx *EmitContext, w io.Writer) error {
 	if ctx.Amalgamate() {
-		if _, ok := ctx.amalgamated[i.name]; ok {
-			return nil
-		}
-
-		ctx.amalgamated[i.name] = struct{}{}
-		stmts, ok := ctx.registry.Lookup(i.name)
-		if !ok {
-			return fmt.Errorf(`source file %q not found`, i.name)
-		}
-
-		fmt.Fprintf(w, "\n\n// START %s %s\n", i.typ, i.name)
-		if err := stmts.EmitStmt(ctx, w); err != nil {
+		stmts, err := i.expand(ctx)
+		if err != nil {
 			return err
 		}
-		fmt.Fprintf(w, "\n// END %s %s\n", i.typ, i.name)
-		return nil
+		return stmts.EmitStmt(ctx, w)
 	}
 
-	fmt.Fprintf(w, `%s <%s>`, i.typ, i.name)
+	fmt.Fprintf(w, "\n%s%s <%s>", ctx.Indent(), i.typ, i.name)
 	return nil
 }
 
@@ -354,6 +467,16 @@ func NewIndex(expr, index interface{}) *Index {
 	}
 }
 
+// Expr returns the expression being indexed
+func (i *Index) Expr() interface{} {
+	return i.expr
+}
+
+// Index returns the index expression
+func (i *Index) Index() interface{} {
+	return i.index
+}
+
 func (i *Index) String() string {
 	var sb strings.Builder
 	if err := i.EmitExpr(newEmitContext(), &sb); err != nil {
@@ -364,7 +487,7 @@ func (i *Index) String() string {
 }
 
 func (i *Index) EmitExpr(ctx *EmitContext, w io.Writer) error {
-	if err := emitExpr(ctx, w, i.expr); err != nil {
+	if err := emitOperand(ctx, w, i.expr, operandPrecedence(i.expr) < math.MaxInt); err != nil {
 		return err
 	}
 	fmt.Fprintf(w, "[")
@@ -385,6 +508,11 @@ func NewBareBlock(children ...Stmt) *BareBlock {
 	}
 }
 
+// Children returns the statements in the block
+func (b *BareBlock) Children() []Stmt {
+	return b.children
+}
+
 func (b *BareBlock) Add(children ...Stmt) *BareBlock {
 	b.children = append(b.children, children...)
 	return b
@@ -0,0 +1,245 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// bindingParam is a parameter of a module or function, as seen from Go
+type bindingParam struct {
+	name   string // name in OpenSCAD
+	goName string // exported Go name
+	arg    string // name of the argument in the Go function
+	def    string // default value as OpenSCAD code, if any
+}
+
+type binding struct {
+	kind     string // "module" or "function"
+	name     string
+	goName   string
+	required []*bindingParam
+	optional []*bindingParam
+}
+
+// goIdentifier converts an OpenSCAD identifier such as `tooth_number`
+// into an exported Go identifier such as `ToothNumber`
+func goIdentifier(name string) string {
+	var sb strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		sb.WriteRune(r)
+	}
+	ident := sb.String()
+	if ident != "" && unicode.IsDigit([]rune(ident)[0]) {
+		ident = `X` + ident
+	}
+	return ident
+}
+
+// goArgName converts an OpenSCAD identifier into the name of an argument
+// in a generated Go function
+func goArgName(name string, i int) string {
+	ident := goIdentifier(name)
+	if ident == "" {
+		return fmt.Sprintf(`arg%d`, i)
+	}
+	runes := []rune(ident)
+	runes[0] = unicode.ToLower(runes[0])
+	arg := string(runes)
+	switch arg {
+	case `ast`, `options`, `option`, `params`, `append`:
+		// these are used in the body of the generated function
+		return arg + `_`
+	}
+	if token.IsKeyword(arg) {
+		return arg + `_`
+	}
+	return arg
+}
+
+// goPackageName derives the name of a Go package from the name of a library
+func goPackageName(name string) string {
+	base := path.Base(name)
+	base = strings.TrimSuffix(base, path.Ext(base))
+
+	var sb strings.Builder
+	for _, r := range strings.ToLower(base) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			sb.WriteRune(r)
+		}
+	}
+	pkg := sb.String()
+	if pkg == "" || unicode.IsDigit([]rune(pkg)[0]) || token.IsKeyword(pkg) {
+		pkg = `scad` + pkg
+	}
+	return pkg
+}
+
+func newBinding(kind, name string, params []*Variable) *binding {
+	b := &binding{
+		kind:   kind,
+		name:   name,
+		goName: goIdentifier(name),
+	}
+	for i, param := range params {
+		p := &bindingParam{
+			name:   param.name,
+			goName: goIdentifier(param.name),
+			arg:    goArgName(param.name, i),
+		}
+		if param.value == nil {
+			b.required = append(b.required, p)
+			continue
+		}
+		var buf bytes.Buffer
+		if err := emitExpr(newEmitContext(), &buf, param.value); err == nil && !strings.ContainsRune(buf.String(), '\n') {
+			p.def = buf.String()
+		}
+		b.optional = append(b.optional, p)
+	}
+	return b
+}
+
+// GoBindings generates the source code of a Go package with typed
+// constructors for the modules and functions defined at the top level of
+// stmt, which is the content of the OpenSCAD library with the given name.
+//
+// Each constructor takes the parameters without default values as
+// arguments, and the rest as options. It returns an *ast.Call that
+// records its dependency on the library, so that a `use` directive for
+// name is emitted along with it (see Call.Use). Use WithGoInclude to
+// record an `include` dependency instead. By default, the name of the
+// package is derived from name.
+//
+// Modules and functions whose names start with an underscore are
+// considered private, and are skipped.
+func GoBindings(name string, stmt Stmt, options ...GoBindingsOption) ([]byte, error) {
+	pkg := goPackageName(name)
+	dependency := `Use`
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case optGoPackageKey{}:
+			pkg = option.Value().(string)
+		case optGoIncludeKey{}:
+			if option.Value().(bool) {
+				dependency = `Include`
+			}
+		}
+	}
+
+	var bindings []*binding
+	modules := make(map[string]struct{})
+	for _, child := range toStmtList(stmt) {
+		switch v := child.(type) {
+		case *Module:
+			if !strings.HasPrefix(v.name, `_`) {
+				bindings = append(bindings, newBinding(`module`, v.name, v.parameters))
+				modules[goIdentifier(v.name)] = struct{}{}
+			}
+		case *Function:
+			if !strings.HasPrefix(v.name, `_`) {
+				bindings = append(bindings, newBinding(`function`, v.name, v.parameters))
+			}
+		}
+	}
+
+	// OpenSCAD allows a module and a function with the same name
+	for _, b := range bindings {
+		if _, ok := modules[b.goName]; ok && b.kind == `function` {
+			b.goName += `Func`
+		}
+	}
+
+	// make sure that every generated identifier is unique
+	defined := map[string]string{`File`: `the library name`}
+	for _, b := range bindings {
+		idents := []string{b.goName}
+		if len(b.optional) > 0 {
+			idents = append(idents, b.goName+`Option`)
+		}
+		for _, p := range b.optional {
+			idents = append(idents, b.goName+p.goName)
+		}
+		for _, ident := range idents {
+			if prev, ok := defined[ident]; ok {
+				return nil, fmt.Errorf(`failed to generate bindings: %s %s() and %s both map to Go identifier %s`, b.kind, b.name, prev, ident)
+			}
+			defined[ident] = fmt.Sprintf(`%s %s()`, b.kind, b.name)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated from %s. DO NOT EDIT.\n\n", name)
+	fmt.Fprintf(&buf, "package %s\n\n", pkg)
+	fmt.Fprintf(&buf, "import \"github.com/lestrrat-go/openscad/ast\"\n\n")
+	fmt.Fprintf(&buf, "// File is the name of the library that the modules and functions are defined in\n")
+	fmt.Fprintf(&buf, "const File = %s\n", strconv.Quote(name))
+	for _, b := range bindings {
+		writeBinding(&buf, b, dependency)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to format generated code: %w`, err)
+	}
+	return src, nil
+}
+
+func writeBinding(buf *bytes.Buffer, b *binding, dependency string) {
+	option := b.goName + `Option`
+	if len(b.optional) > 0 {
+		fmt.Fprintf(buf, "\n// %s sets an optional parameter of %s.\n", option, b.goName)
+		fmt.Fprintf(buf, "type %s struct {\nname string\nvalue interface{}\n}\n", option)
+		for _, p := range b.optional {
+			fmt.Fprintf(buf, "\n// %s%s sets the %s parameter of %s %s().", b.goName, p.goName, p.name, b.kind, b.name)
+			if p.def != "" {
+				fmt.Fprintf(buf, " The default is %s.", p.def)
+			}
+			fmt.Fprintf(buf, "\nfunc %s%s(v interface{}) %s {\n", b.goName, p.goName, option)
+			fmt.Fprintf(buf, "return %s{name: %s, value: v}\n}\n", option, strconv.Quote(p.name))
+		}
+	}
+
+	args := make([]string, 0, len(b.required)+1)
+	for _, p := range b.required {
+		args = append(args, p.arg)
+	}
+	signature := ``
+	if len(args) > 0 {
+		signature = strings.Join(args, `, `) + ` interface{}`
+	}
+	if len(b.optional) > 0 {
+		if signature != `` {
+			signature += `, `
+		}
+		signature += `options ...` + option
+	}
+
+	fmt.Fprintf(buf, "\n// %s creates a call to %s %s().\n", b.goName, b.kind, b.name)
+	fmt.Fprintf(buf, "func %s(%s) *ast.Call {\n", b.goName, signature)
+	fmt.Fprintf(buf, "params := []interface{}{\n")
+	for _, p := range b.required {
+		fmt.Fprintf(buf, "ast.NewVariable(%s).Value(%s),\n", strconv.Quote(p.name), p.arg)
+	}
+	fmt.Fprintf(buf, "}\n")
+	if len(b.optional) > 0 {
+		fmt.Fprintf(buf, "for _, option := range options {\n")
+		fmt.Fprintf(buf, "params = append(params, ast.NewVariable(option.name).Value(option.value))\n}\n")
+	}
+	fmt.Fprintf(buf, "return ast.NewCall(%s).Parameters(params...).%s(File)\n}\n", strconv.Quote(b.name), dependency)
+}
@@ -0,0 +1,97 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoBindings(t *testing.T) {
+	const src = `module plate(size, thickness = 2, type = "round") { cube(size); }
+function plate(size) = size * 2;
+module _helper() { sphere(1); }
+`
+	stmts, err := openscad.Parse([]byte(src))
+	require.NoError(t, err, `openscad.Parse should succeed`)
+
+	code, err := ast.GoBindings(`lib/plates.scad`, stmts)
+	require.NoError(t, err, `ast.GoBindings should succeed`)
+	require.Equal(t, `// Code generated from lib/plates.scad. DO NOT EDIT.
+
+package plates
+
+import "github.com/lestrrat-go/openscad/ast"
+
+// File is the name of the library that the modules and functions are defined in
+const File = "lib/plates.scad"
+
+// PlateOption sets an optional parameter of Plate.
+type PlateOption struct {
+	name  string
+	value interface{}
+}
+
+// PlateThickness sets the thickness parameter of module plate(). The default is 2.
+func PlateThickness(v interface{}) PlateOption {
+	return PlateOption{name: "thickness", value: v}
+}
+
+// PlateType sets the type parameter of module plate(). The default is "round".
+func PlateType(v interface{}) PlateOption {
+	return PlateOption{name: "type", value: v}
+}
+
+// Plate creates a call to module plate().
+func Plate(size interface{}, options ...PlateOption) *ast.Call {
+	params := []interface{}{
+		ast.NewVariable("size").Value(size),
+	}
+	for _, option := range options {
+		params = append(params, ast.NewVariable(option.name).Value(option.value))
+	}
+	return ast.NewCall("plate").Parameters(params...).Use(File)
+}
+
+// PlateFunc creates a call to function plate().
+func PlateFunc(size interface{}) *ast.Call {
+	params := []interface{}{
+		ast.NewVariable("size").Value(size),
+	}
+	return ast.NewCall("plate").Parameters(params...).Use(File)
+}
+`, string(code))
+
+	t.Run("collision", func(t *testing.T) {
+		stmts, err := openscad.Parse([]byte("module a_b() { cube(1); }\nmodule aB() { cube(1); }\n"))
+		require.NoError(t, err, `openscad.Parse should succeed`)
+		_, err = ast.GoBindings(`lib.scad`, stmts, ast.WithGoPackage(`lib`))
+		require.Error(t, err, `ast.GoBindings should fail`)
+	})
+}
+
+func TestCallDependency(t *testing.T) {
+	stmts := ast.Stmts{
+		ast.NewUse(`existing.scad`),
+		ast.NewCall(`a`).Use(`existing.scad`),
+		ast.NewCall(`translate`).Parameters([]interface{}{1, 0, 0}).Add(
+			ast.NewCall(`b`).Use(`lib.scad`),
+			ast.NewCall(`c`).Use(`lib.scad`),
+			ast.NewCall(`d`).Include(`config.scad`),
+		),
+	}
+	out, err := ast.EmitString(stmts)
+	require.NoError(t, err, `ast.EmitString should succeed`)
+	require.Equal(t, `
+use <lib.scad>
+include <config.scad>
+use <existing.scad>
+a();
+translate([1, 0, 0])
+{
+  b();
+  c();
+  d();
+}`, out)
+}
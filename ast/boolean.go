@@ -69,7 +69,7 @@ func (u *Difference) Body(s ...Stmt) *Difference {
 func NewIntersection(children ...Stmt) *Intersection {
 	return &Intersection{
 		noArgBlock{
-			name:     "difference",
+			name:     "intersection",
 			children: children,
 		},
 	}
@@ -0,0 +1,108 @@
+package ast
+
+// ToCall converts statements that instantiate a built-in module, such as
+// *Cube or *Translate, to the equivalent *Call, so that code that
+// processes module instantiations only has to handle calls. *Call
+// statements are returned as is. The second return value is false for
+// any other statement.
+func ToCall(stmt Stmt) (*Call, bool) {
+	switch v := stmt.(type) {
+	case *Call:
+		return v, true
+	case *Cube:
+		params := []interface{}{[]interface{}{v.width, v.depth, v.height}}
+		params = appendBoolParam(params, `center`, v.center)
+		params = appendIntParam(params, `$fn`, v.fn)
+		return NewCall(`cube`).Parameters(params...), true
+	case *Cylinder:
+		params := []interface{}{NewVariable(`h`).Value(v.height)}
+		if v.radius2 == nil {
+			params = append(params, NewVariable(`r`).Value(v.radius1))
+		} else {
+			params = append(params, NewVariable(`r1`).Value(v.radius1), NewVariable(`r2`).Value(v.radius2))
+		}
+		params = appendBoolParam(params, `center`, v.center)
+		params = appendIntParam(params, `$fa`, v.fa)
+		params = appendIntParam(params, `$fs`, v.fs)
+		params = appendIntParam(params, `$fn`, v.fn)
+		return NewCall(`cylinder`).Parameters(params...), true
+	case *Sphere:
+		params := []interface{}{NewVariable(`r`).Value(v.radius)}
+		params = appendIntParam(params, `$fa`, v.fa)
+		params = appendIntParam(params, `$fs`, v.fs)
+		params = appendIntParam(params, `$fn`, v.fn)
+		return NewCall(`sphere`).Parameters(params...), true
+	case *Circle:
+		params := []interface{}{NewVariable(`r`).Value(v.radius)}
+		params = appendIntParam(params, `$fa`, v.fa)
+		params = appendIntParam(params, `$fs`, v.fs)
+		params = appendIntParam(params, `$fn`, v.fn)
+		return NewCall(`circle`).Parameters(params...), true
+	case *Polygon:
+		params := []interface{}{NewVariable(`points`).Value(v.points)}
+		if v.paths != nil {
+			params = append(params, NewVariable(`paths`).Value(v.paths))
+		}
+		return NewCall(`polygon`).Parameters(params...), true
+	case *Polyhedron:
+		params := []interface{}{NewVariable(`points`).Value(v.points), NewVariable(`faces`).Value(v.faces)}
+		if v.convexity != nil {
+			params = append(params, NewVariable(`convexity`).Value(v.convexity))
+		}
+		return NewCall(`polyhedron`).Parameters(params...), true
+	case *Children:
+		call := NewCall(`children`)
+		if v.idx != nil {
+			call.Parameters(*v.idx)
+		}
+		return call, true
+	case *Translate:
+		return v.makeCall(), true
+	case *Rotate:
+		return NewCall(`rotate`).Parameters(v.v).Add(v.children...), true
+	case *LinearExtrude:
+		params := []interface{}{NewVariable(`height`).Value(v.height)}
+		for _, p := range []struct {
+			name  string
+			value interface{}
+		}{
+			{`center`, v.center},
+			{`convexity`, v.convexity},
+			{`twist`, v.twist},
+			{`scale`, v.scale},
+		} {
+			if p.value != nil {
+				params = append(params, NewVariable(p.name).Value(p.value))
+			}
+		}
+		params = appendIntParam(params, `$fn`, v.fn)
+		return NewCall(`linear_extrude`).Parameters(params...).Add(v.children...), true
+	case *Union:
+		return v.noArgBlock.call(), true
+	case *Difference:
+		return v.noArgBlock.call(), true
+	case *Intersection:
+		return v.noArgBlock.call(), true
+	case *Hull:
+		return v.noArgBlock.call(), true
+	}
+	return nil, false
+}
+
+func (op *noArgBlock) call() *Call {
+	return NewCall(op.name).Add(op.children...)
+}
+
+func appendBoolParam(params []interface{}, name string, ptr *bool) []interface{} {
+	if ptr == nil {
+		return params
+	}
+	return append(params, NewVariable(name).Value(*ptr))
+}
+
+func appendIntParam(params []interface{}, name string, ptr *int) []interface{} {
+	if ptr == nil {
+		return params
+	}
+	return append(params, NewVariable(name).Value(*ptr))
+}
@@ -0,0 +1,376 @@
+package ast
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CollisionPolicy specifies what to do when amalgamating files that define
+// modules, functions or variables with the same name.
+type CollisionPolicy int
+
+const (
+	// CollisionIgnore emits all definitions as they are. As with OpenSCAD
+	// itself, the definition that appears last in the output wins.
+	CollisionIgnore CollisionPolicy = iota
+	// CollisionError makes amalgamation fail if any collision is found.
+	CollisionError
+	// CollisionRename renames colliding definitions by prefixing them
+	// with a name derived from the file that they are defined in, and
+	// rewrites all call sites to refer to the new names. Variables of
+	// used files are always renamed this way during amalgamation, so
+	// colliding variables need no further treatment.
+	CollisionRename
+)
+
+// unit is a set of files that share a single namespace: a file, and all of
+// the files that it (transitively) includes. The root file forms a unit,
+// as does every file that is pulled in via `use`.
+type unit struct {
+	name  string
+	files []string
+	uses  []string
+
+	// functions and modules map names referenced from within this unit
+	// to their new names
+	functions map[string]string
+	modules   map[string]string
+}
+
+func (u *unit) contains(file string) bool {
+	for _, f := range u.files {
+		if f == file {
+			return true
+		}
+	}
+	return false
+}
+
+type definitions struct {
+	functions map[string]struct{}
+	modules   map[string]struct{}
+	variables map[string]struct{}
+}
+
+// names returns the set of names defined for the given kind of definition
+func (d *definitions) names(kind string) map[string]struct{} {
+	switch kind {
+	case `function`:
+		return d.functions
+	case `variable`:
+		return d.variables
+	default:
+		return d.modules
+	}
+}
+
+type collision struct {
+	kind  string
+	name  string
+	files []string
+}
+
+// amalgamationPlan holds the information required to resolve collisions
+// across the files that take part in an amalgamation.
+type amalgamationPlan struct {
+	registry    *Registry
+	rootName    string
+	root        *unit
+	units       map[string]*unit
+	definitions map[string]*definitions
+	collisions  []*collision
+
+	// definedFunctions and definedModules map file names to the renames
+	// that should be applied to the definitions in that file
+	definedFunctions map[string]map[string]string
+	definedModules   map[string]map[string]string
+}
+
+func newAmalgamationPlan(registry *Registry, rootName string, root Stmt) *amalgamationPlan {
+	p := &amalgamationPlan{
+		registry:         registry,
+		rootName:         rootName,
+		units:            make(map[string]*unit),
+		definitions:      make(map[string]*definitions),
+		definedFunctions: make(map[string]map[string]string),
+		definedModules:   make(map[string]map[string]string),
+	}
+
+	p.root = p.buildUnit(rootName, root)
+	pending := append([]string(nil), p.root.uses...)
+	for len(pending) > 0 {
+		name := pending[0]
+		pending = pending[1:]
+		if _, ok := p.units[name]; ok {
+			continue
+		}
+		stmt, ok := registry.Lookup(name)
+		if !ok {
+			// missing files are reported when they are emitted
+			continue
+		}
+		u := p.buildUnit(name, stmt)
+		p.units[name] = u
+		pending = append(pending, u.uses...)
+	}
+
+	p.findCollisions()
+	return p
+}
+
+func (p *amalgamationPlan) buildUnit(name string, stmt Stmt) *unit {
+	u := &unit{name: name}
+	visited := make(map[string]struct{})
+	var visit func(string, Stmt)
+	visit = func(file string, stmt Stmt) {
+		visited[file] = struct{}{}
+		u.files = append(u.files, file)
+		p.collectDefinitions(file, stmt)
+
+		list, ok := stmt.(Stmts)
+		if !ok {
+			list = Stmts{stmt}
+		}
+		for _, child := range list {
+			switch v := child.(type) {
+			case *Include:
+				if _, ok := visited[v.name]; ok {
+					continue
+				}
+				included, ok := p.registry.Lookup(v.name)
+				if !ok {
+					continue
+				}
+				visit(v.name, included)
+			case *Use:
+				u.uses = append(u.uses, v.name)
+			}
+		}
+	}
+	visit(name, stmt)
+	return u
+}
+
+func (p *amalgamationPlan) collectDefinitions(file string, stmt Stmt) *definitions {
+	if defs, ok := p.definitions[file]; ok {
+		return defs
+	}
+
+	defs := &definitions{
+		functions: make(map[string]struct{}),
+		modules:   make(map[string]struct{}),
+		variables: make(map[string]struct{}),
+	}
+	list, ok := stmt.(Stmts)
+	if !ok {
+		list = Stmts{stmt}
+	}
+	for _, child := range list {
+		switch v := child.(type) {
+		case *Function:
+			defs.functions[v.name] = struct{}{}
+		case *Module:
+			defs.modules[v.name] = struct{}{}
+		case *Variable:
+			if !isSpecialVariable(v.name) {
+				defs.variables[v.name] = struct{}{}
+			}
+		}
+	}
+	p.definitions[file] = defs
+	return defs
+}
+
+func (p *amalgamationPlan) allUnits() []*unit {
+	units := []*unit{p.root}
+	names := make([]string, 0, len(p.units))
+	for name := range p.units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		units = append(units, p.units[name])
+	}
+	return units
+}
+
+// shareUnit returns true if there is a unit that contains both files.
+// Duplicate definitions within a single unit are part of the original
+// program's semantics, and are not considered collisions
+func (p *amalgamationPlan) shareUnit(a, b string) bool {
+	for _, u := range p.allUnits() {
+		if u.contains(a) && u.contains(b) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *amalgamationPlan) findCollisions() {
+	for _, kind := range []string{`module`, `function`, `variable`} {
+		// name -> files that define it, in the order they were discovered
+		defined := make(map[string][]string)
+		var names []string
+		seen := make(map[string]struct{})
+		for _, u := range p.allUnits() {
+			for _, file := range u.files {
+				if _, ok := seen[file]; ok {
+					continue
+				}
+				seen[file] = struct{}{}
+
+				set := p.definitions[file].names(kind)
+				sorted := make([]string, 0, len(set))
+				for name := range set {
+					sorted = append(sorted, name)
+				}
+				sort.Strings(sorted)
+				for _, name := range sorted {
+					if _, ok := defined[name]; !ok {
+						names = append(names, name)
+					}
+					defined[name] = append(defined[name], file)
+				}
+			}
+		}
+
+		for _, name := range names {
+			files := defined[name]
+			if !p.hasConflict(files) {
+				continue
+			}
+			p.collisions = append(p.collisions, &collision{kind: kind, name: name, files: files})
+			if kind == `variable` {
+				// expandUsedFile prefixes the variables of used files
+				// with the name of the file, so the root file keeps
+				// its variables and the others are already renamed
+				continue
+			}
+
+			for _, file := range files {
+				if p.root.contains(file) {
+					// definitions that are visible from the root file
+					// keep their names
+					continue
+				}
+				renames := p.definedModules
+				if kind == `function` {
+					renames = p.definedFunctions
+				}
+				if renames[file] == nil {
+					renames[file] = make(map[string]string)
+				}
+				renames[file][name] = mangledPrefix(file) + name
+			}
+		}
+	}
+
+	for _, u := range p.allUnits() {
+		u.functions = make(map[string]string)
+		u.modules = make(map[string]string)
+		for _, c := range p.collisions {
+			if c.kind == `variable` {
+				continue
+			}
+			file, ok := p.resolve(u, c.kind, c.name)
+			if !ok {
+				continue
+			}
+			if c.kind == `function` {
+				u.functions[c.name] = renameWith(p.definedFunctions[file], c.name)
+			} else {
+				u.modules[c.name] = renameWith(p.definedModules[file], c.name)
+			}
+		}
+	}
+}
+
+func (p *amalgamationPlan) hasConflict(files []string) bool {
+	for i := 0; i < len(files); i++ {
+		for j := i + 1; j < len(files); j++ {
+			if !p.shareUnit(files[i], files[j]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *amalgamationPlan) defines(file, kind, name string) bool {
+	defs, ok := p.definitions[file]
+	if !ok {
+		return false
+	}
+	_, ok = defs.names(kind)[name]
+	return ok
+}
+
+// resolve returns the file whose definition of name is visible from
+// within u. Definitions in the unit itself take precedence over those
+// in used files, and later definitions take precedence over earlier ones.
+func (p *amalgamationPlan) resolve(u *unit, kind, name string) (string, bool) {
+	for i := len(u.files) - 1; i >= 0; i-- {
+		if p.defines(u.files[i], kind, name) {
+			return u.files[i], true
+		}
+	}
+	for i := len(u.uses) - 1; i >= 0; i-- {
+		used, ok := p.units[u.uses[i]]
+		if !ok {
+			continue
+		}
+		for j := len(used.files) - 1; j >= 0; j-- {
+			if p.defines(used.files[j], kind, name) {
+				return used.files[j], true
+			}
+		}
+	}
+	return "", false
+}
+
+func (p *amalgamationPlan) displayName(file string) string {
+	if file == "" {
+		return `<root>`
+	}
+	return file
+}
+
+func (p *amalgamationPlan) collisionError() error {
+	if len(p.collisions) == 0 {
+		return nil
+	}
+	msgs := make([]string, len(p.collisions))
+	for i, c := range p.collisions {
+		files := make([]string, len(c.files))
+		for j, file := range c.files {
+			files[j] = p.displayName(file)
+		}
+		msgs[i] = fmt.Sprintf(`%s %q is defined in %s`, c.kind, c.name, strings.Join(files, `, `))
+	}
+	return fmt.Errorf(`duplicate definitions found: %s`, strings.Join(msgs, `; `))
+}
+
+// unitFor returns the unit that the contents of a file should be resolved in
+// when it is pulled in via `use`
+func (p *amalgamationPlan) unitFor(name string) *unit {
+	if u, ok := p.units[name]; ok {
+		return u
+	}
+	return p.root
+}
+
+// rename applies the collision renames to the contents of file, as seen
+// from within u.
+func (p *amalgamationPlan) rename(u *unit, file string, stmt Stmt) Stmt {
+	if len(p.collisions) == 0 {
+		return stmt
+	}
+	r := &renamer{
+		functions:        u.functions,
+		modules:          u.modules,
+		definedFunctions: p.definedFunctions[file],
+		definedModules:   p.definedModules[file],
+	}
+	return r.stmt(stmt, scope{}, true)
+}
@@ -0,0 +1,139 @@
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollisionPolicy(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "use <a.scad>\nuse <b.scad>\nrounded_box();\nwidget();",
+		"a.scad":    "module rounded_box() { cube([1, 1, 1]); }\nmodule widget() { rounded_box(); }\nfunction r() = 1;",
+		"b.scad":    "module rounded_box() { sphere(r=r()); }\nfunction r() = 2;",
+	})
+
+	t.Run("ignore", func(t *testing.T) {
+		var sb strings.Builder
+		require.NoError(t, ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry)), `emit should succeed`)
+		require.Equal(t, 2, strings.Count(sb.String(), "module rounded_box()"), `definitions should be left as is`)
+	})
+	t.Run("error", func(t *testing.T) {
+		var sb strings.Builder
+		err := ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry), ast.WithCollisionPolicy(ast.CollisionError))
+		require.Error(t, err, `emit should fail`)
+		require.Contains(t, err.Error(), `module "rounded_box" is defined in a.scad, b.scad`)
+		require.Contains(t, err.Error(), `function "r" is defined in a.scad, b.scad`)
+	})
+	t.Run("rename", func(t *testing.T) {
+		var sb strings.Builder
+		require.NoError(t, ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry), ast.WithCollisionPolicy(ast.CollisionRename)), `emit should succeed`)
+		require.Equal(t, `
+
+// START use a.scad
+
+module a__rounded_box()
+{
+  cube([1, 1, 1]);
+}
+
+module widget()
+{
+  a__rounded_box();
+}
+
+function a__r() = 1;
+// END use a.scad
+
+
+// START use b.scad
+
+module b__rounded_box()
+{
+  sphere(r=b__r());
+}
+
+function b__r() = 2;
+// END use b.scad
+
+b__rounded_box();
+widget();`, sb.String())
+	})
+}
+
+func TestCollisionPolicyRootDefinitions(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "use <a.scad>\nmodule rounded_box() { sphere(r=1); }\nrounded_box();\nwidget();",
+		"a.scad":    "module rounded_box() { cube([1, 1, 1]); }\nmodule widget() { rounded_box(); }",
+	})
+
+	var sb strings.Builder
+	require.NoError(t, ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry), ast.WithCollisionPolicy(ast.CollisionRename)), `emit should succeed`)
+	out := sb.String()
+	require.Contains(t, out, "module a__rounded_box()", `used definition should be renamed`)
+	require.Contains(t, out, "module widget()\n{\n  a__rounded_box();\n}", `call sites in the used file should be renamed`)
+	require.Contains(t, out, "module rounded_box()\n{\n  sphere(r=1);\n}", `root definition should keep its name`)
+	require.Contains(t, out, "\nrounded_box();", `root call sites should refer to the root definition`)
+}
+
+func TestCollisionPolicyVariables(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "include <inc.scad>\nuse <a.scad>\nuse <b.scad>\nsize = 5;\nbox();\nball();",
+		"inc.scad":  "size = 3;",
+		"a.scad":    "size = 1;\nmodule box() { cube(size); }",
+		"b.scad":    "size = 2;\nmodule ball() { sphere(r=size); }",
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var sb strings.Builder
+		err := ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry), ast.WithCollisionPolicy(ast.CollisionError))
+		require.Error(t, err, `emit should fail`)
+		require.Contains(t, err.Error(), `variable "size" is defined in main.scad, inc.scad, a.scad, b.scad`)
+	})
+	t.Run("rename", func(t *testing.T) {
+		var sb strings.Builder
+		require.NoError(t, ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry), ast.WithCollisionPolicy(ast.CollisionRename)), `emit should succeed`)
+		require.Equal(t, `
+
+// START include inc.scad
+
+size = 3;
+// END include inc.scad
+
+
+// START use a.scad
+
+a__size = 1;
+module box()
+{
+  cube(a__size);
+}
+
+// END use a.scad
+
+
+// START use b.scad
+
+b__size = 2;
+module ball()
+{
+  sphere(r=b__size);
+}
+
+// END use b.scad
+
+size = 5;
+box();
+ball();`, sb.String())
+	})
+	t.Run("same unit", func(t *testing.T) {
+		registry := registerSources(t, map[string]string{
+			"main.scad": "include <inc.scad>\nsize = 5;\ncube(size);",
+			"inc.scad":  "size = 3;",
+		})
+		var sb strings.Builder
+		require.NoError(t, ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry), ast.WithCollisionPolicy(ast.CollisionError)), `variables shared through include should not collide`)
+	})
+}
@@ -0,0 +1,45 @@
+package ast
+
+// addDependencies returns stmt with `use` and `include` directives added
+// to the top for the files that calls in the tree depend on (see Call.Use
+// and Call.Include). Files that are already pulled in by a directive at
+// the top level are not added again.
+func addDependencies(stmt Stmt) Stmt {
+	list := toStmtList(stmt)
+
+	seen := make(map[inclusionDirective]struct{})
+	for _, child := range list {
+		switch v := child.(type) {
+		case *Include:
+			seen[inclusionDirective{typ: v.typ, name: v.name}] = struct{}{}
+		case *Use:
+			seen[inclusionDirective{typ: v.typ, name: v.name}] = struct{}{}
+		}
+	}
+
+	var directives Stmts
+	Walk(stmt, func(node interface{}) bool {
+		call, ok := node.(*Call)
+		if !ok || call.dependency == nil {
+			return true
+		}
+		key := inclusionDirective{typ: call.dependency.typ, name: call.dependency.name}
+		if _, ok := seen[key]; ok {
+			return true
+		}
+		seen[key] = struct{}{}
+		if key.typ == `include` {
+			directives = append(directives, NewInclude(key.name))
+		} else {
+			directives = append(directives, NewUse(key.name))
+		}
+		return true
+	})
+	if len(directives) == 0 {
+		return stmt
+	}
+
+	ret := make(Stmts, 0, len(directives)+len(list))
+	ret = append(ret, directives...)
+	return append(ret, list...)
+}
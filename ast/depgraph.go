@@ -0,0 +1,396 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// DependencyKind describes how a file refers to another file.
+// A node in a DepGraph may be referred to in both ways, in which
+// case its kind has both bits set.
+type DependencyKind int
+
+const (
+	DependencyInclude DependencyKind = 1 << iota
+	DependencyUse
+)
+
+func (k DependencyKind) String() string {
+	var parts []string
+	if k&DependencyInclude != 0 {
+		parts = append(parts, `include`)
+	}
+	if k&DependencyUse != 0 {
+		parts = append(parts, `use`)
+	}
+	if len(parts) == 0 {
+		return `root`
+	}
+	return strings.Join(parts, `|`)
+}
+
+// DependencyNode is a single file in a DepGraph.
+type DependencyNode struct {
+	// Name is the name of the file, as registered in the Registry
+	Name string
+	// Kind is the union of the kinds of all edges pointing to this node.
+	// The root node has a zero Kind.
+	Kind DependencyKind
+	// Missing is true if the file could not be found in the Registry
+	Missing bool
+}
+
+// DependencyEdge represents a single `include` or `use` directive.
+type DependencyEdge struct {
+	From string
+	To   string
+	Kind DependencyKind
+}
+
+// MissingDependency describes an `include` or `use` directive that
+// points to a file that is not registered.
+type MissingDependency struct {
+	From string
+	Name string
+	Kind DependencyKind
+}
+
+// DepGraph is the dependency graph formed by `include` and `use`
+// directives, starting from a root file. Create one using DependencyGraph()
+type DepGraph struct {
+	root    string
+	nodes   []*DependencyNode
+	index   map[string]*DependencyNode
+	edges   []*DependencyEdge
+	missing []*MissingDependency
+	cycles  [][]string
+}
+
+// DependencyGraph computes the dependency graph for the file registered
+// as root in the registry. If registry is nil, the global registry is used.
+//
+// Missing files and cycles are not treated as errors: they are recorded
+// in the resulting graph so that all of them can be reported at once.
+// Use (*DepGraph).Validate() to turn them into an error.
+func DependencyGraph(registry *Registry, root string) (*DepGraph, error) {
+	if registry == nil {
+		registry = globalRegistry
+	}
+
+	stmt, ok := registry.Lookup(root)
+	if !ok {
+		return nil, fmt.Errorf(`failed to compute dependency graph: no such file: %s`, root)
+	}
+
+	g := &DepGraph{
+		root:  root,
+		index: make(map[string]*DependencyNode),
+	}
+	g.addNode(root, 0)
+
+	sources := map[string]Stmt{root: stmt}
+	queue := []string{root}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		for _, dep := range collectDependencies(sources[name]) {
+			g.edges = append(g.edges, &DependencyEdge{From: name, To: dep.name, Kind: dep.kind})
+
+			if node, ok := g.index[dep.name]; ok {
+				node.Kind |= dep.kind
+				if node.Missing {
+					g.missing = append(g.missing, &MissingDependency{From: name, Name: dep.name, Kind: dep.kind})
+				}
+				continue
+			}
+
+			node := g.addNode(dep.name, dep.kind)
+			depStmt, ok := registry.Lookup(dep.name)
+			if !ok {
+				node.Missing = true
+				g.missing = append(g.missing, &MissingDependency{From: name, Name: dep.name, Kind: dep.kind})
+				continue
+			}
+			sources[dep.name] = depStmt
+			queue = append(queue, dep.name)
+		}
+	}
+
+	g.cycles = g.findCycles()
+	return g, nil
+}
+
+type dependency struct {
+	name string
+	kind DependencyKind
+}
+
+// collectDependencies returns the list of `include` and `use` directives
+// found in stmt, in the order they appear
+func collectDependencies(stmt Stmt) []dependency {
+	var deps []dependency
+	Walk(stmt, func(node interface{}) bool {
+		switch v := node.(type) {
+		case *Include:
+			deps = append(deps, dependency{name: v.name, kind: DependencyInclude})
+		case *Use:
+			deps = append(deps, dependency{name: v.name, kind: DependencyUse})
+		}
+		return true
+	})
+	return deps
+}
+
+func (g *DepGraph) addNode(name string, kind DependencyKind) *DependencyNode {
+	node := &DependencyNode{Name: name, Kind: kind}
+	g.nodes = append(g.nodes, node)
+	g.index[name] = node
+	return node
+}
+
+// Root returns the name of the root file
+func (g *DepGraph) Root() string {
+	return g.root
+}
+
+// Nodes returns all files in the graph in the order they were discovered,
+// starting with the root file.
+func (g *DepGraph) Nodes() []*DependencyNode {
+	return g.nodes
+}
+
+// Node returns the node for the given file name
+func (g *DepGraph) Node(name string) (*DependencyNode, bool) {
+	node, ok := g.index[name]
+	return node, ok
+}
+
+// Edges returns all `include` and `use` directives in the graph, in the
+// order they were discovered.
+func (g *DepGraph) Edges() []*DependencyEdge {
+	return g.edges
+}
+
+// Missing returns every directive that refers to an unregistered file.
+func (g *DepGraph) Missing() []*MissingDependency {
+	return g.missing
+}
+
+// Cycles returns every elementary cycle in the graph. Each cycle is a path
+// of file names that starts and ends with the same file, and is reported
+// only once, starting from the file in the cycle that was discovered first.
+func (g *DepGraph) Cycles() [][]string {
+	return g.cycles
+}
+
+// Validate returns an error describing all missing files and cycles
+// in the graph, or nil if there are none.
+func (g *DepGraph) Validate() error {
+	if len(g.missing) == 0 && len(g.cycles) == 0 {
+		return nil
+	}
+
+	var msgs []string
+	for _, m := range g.missing {
+		msgs = append(msgs, fmt.Sprintf(`%s %q from %q: file not found`, m.Kind, m.Name, m.From))
+	}
+	for _, cycle := range g.cycles {
+		msgs = append(msgs, fmt.Sprintf(`dependency cycle: %s`, strings.Join(cycle, ` -> `)))
+	}
+	return fmt.Errorf(`invalid dependency graph for %q: %s`, g.root, strings.Join(msgs, `; `))
+}
+
+func (g *DepGraph) successors(name string) []string {
+	var ret []string
+	seen := make(map[string]struct{})
+	for _, e := range g.edges {
+		if e.From != name {
+			continue
+		}
+		if _, ok := seen[e.To]; ok {
+			continue
+		}
+		seen[e.To] = struct{}{}
+		ret = append(ret, e.To)
+	}
+	return ret
+}
+
+// findCycles enumerates the elementary cycles in the graph using Johnson's
+// algorithm. Each cycle is reported once, starting from the file in the
+// cycle that was discovered first.
+func (g *DepGraph) findCycles() [][]string {
+	position := make(map[string]int, len(g.nodes))
+	for i, node := range g.nodes {
+		position[node.Name] = i
+	}
+
+	var cycles [][]string
+	for i, start := range g.nodes {
+		// only look for cycles through start that do not visit any file
+		// discovered before it, as those have already been reported
+		blocked := make(map[string]bool)
+		blockedBy := make(map[string]map[string]struct{})
+		var stack []string
+
+		var unblock func(string)
+		unblock = func(name string) {
+			blocked[name] = false
+			for n := range blockedBy[name] {
+				delete(blockedBy[name], n)
+				if blocked[n] {
+					unblock(n)
+				}
+			}
+		}
+
+		var circuit func(string) bool
+		circuit = func(name string) bool {
+			found := false
+			stack = append(stack, name)
+			blocked[name] = true
+			for _, next := range g.successors(name) {
+				if position[next] < i {
+					continue
+				}
+				if next == start.Name {
+					cycle := make([]string, 0, len(stack)+1)
+					cycle = append(cycle, stack...)
+					cycle = append(cycle, next)
+					cycles = append(cycles, cycle)
+					found = true
+				} else if !blocked[next] && circuit(next) {
+					found = true
+				}
+			}
+
+			if found {
+				unblock(name)
+			} else {
+				for _, next := range g.successors(name) {
+					if position[next] < i {
+						continue
+					}
+					if blockedBy[next] == nil {
+						blockedBy[next] = make(map[string]struct{})
+					}
+					blockedBy[next][name] = struct{}{}
+				}
+			}
+			stack = stack[:len(stack)-1]
+			return found
+		}
+		circuit(start.Name)
+	}
+	return cycles
+}
+
+// TopologicalOrder returns the names of the files in the graph, ordered
+// such that every file comes after all of the files that it depends on.
+// The root file is therefore always last. Missing files are omitted.
+//
+// An error is returned if the graph contains cycles.
+func (g *DepGraph) TopologicalOrder() ([]string, error) {
+	if len(g.cycles) > 0 {
+		return nil, fmt.Errorf(`failed to compute topological order: dependency cycle: %s`, strings.Join(g.cycles[0], ` -> `))
+	}
+
+	var order []string
+	done := make(map[string]struct{})
+	var visit func(string)
+	visit = func(name string) {
+		if _, ok := done[name]; ok {
+			return
+		}
+		done[name] = struct{}{}
+		for _, next := range g.successors(name) {
+			visit(next)
+		}
+		if node := g.index[name]; !node.Missing {
+			order = append(order, name)
+		}
+	}
+	visit(g.root)
+	return order, nil
+}
+
+// WriteDOT writes the graph in Graphviz DOT format. Edges created by
+// `use` are drawn dashed, and missing files are drawn in red.
+func (g *DepGraph) WriteDOT(w io.Writer) error {
+	var sb strings.Builder
+	fmt.Fprint(&sb, "digraph dependencies {")
+	for _, node := range g.nodes {
+		fmt.Fprintf(&sb, "\n  %q", node.Name)
+		if node.Missing {
+			fmt.Fprint(&sb, ` [color=red, style=dashed]`)
+		}
+		fmt.Fprint(&sb, `;`)
+	}
+	for _, e := range g.sortedEdges() {
+		fmt.Fprintf(&sb, "\n  %q -> %q [label=%q", e.From, e.To, e.Kind.String())
+		if e.Kind == DependencyUse {
+			fmt.Fprint(&sb, `, style=dashed`)
+		}
+		fmt.Fprint(&sb, `];`)
+	}
+	fmt.Fprint(&sb, "\n}\n")
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf(`failed to write DOT graph: %w`, err)
+	}
+	return nil
+}
+
+// WriteMermaid writes the graph as a Mermaid flowchart. Edges created
+// by `use` are drawn as dotted arrows.
+func (g *DepGraph) WriteMermaid(w io.Writer) error {
+	ids := make(map[string]string, len(g.nodes))
+
+	var sb strings.Builder
+	fmt.Fprint(&sb, "graph TD")
+	for i, node := range g.nodes {
+		id := fmt.Sprintf(`n%d`, i)
+		ids[node.Name] = id
+		fmt.Fprintf(&sb, "\n  %s[\"%s\"]", id, strings.ReplaceAll(node.Name, `"`, `#quot;`))
+		if node.Missing {
+			fmt.Fprint(&sb, `:::missing`)
+		}
+	}
+	for _, e := range g.sortedEdges() {
+		arrow := `-->`
+		if e.Kind == DependencyUse {
+			arrow = `-.->`
+		}
+		fmt.Fprintf(&sb, "\n  %s %s|%s| %s", ids[e.From], arrow, e.Kind, ids[e.To])
+	}
+	fmt.Fprint(&sb, "\n  classDef missing stroke:#f00,stroke-dasharray:5 5\n")
+
+	if _, err := io.WriteString(w, sb.String()); err != nil {
+		return fmt.Errorf(`failed to write Mermaid graph: %w`, err)
+	}
+	return nil
+}
+
+// sortedEdges returns the edges with duplicates removed, in a stable order
+func (g *DepGraph) sortedEdges() []*DependencyEdge {
+	seen := make(map[DependencyEdge]struct{})
+	var edges []*DependencyEdge
+	for _, e := range g.edges {
+		if _, ok := seen[*e]; ok {
+			continue
+		}
+		seen[*e] = struct{}{}
+		edges = append(edges, e)
+	}
+	position := make(map[string]int, len(g.nodes))
+	for i, node := range g.nodes {
+		position[node.Name] = i
+	}
+	sort.SliceStable(edges, func(i, j int) bool {
+		return position[edges[i].From] < position[edges[j].From]
+	})
+	return edges
+}
@@ -0,0 +1,124 @@
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func registerSources(t *testing.T, sources map[string]string, options ...openscad.ParseOption) *ast.Registry {
+	t.Helper()
+	registry := ast.NewRegistry()
+	for name, src := range sources {
+		stmts, err := openscad.Parse([]byte(src), options...)
+		require.NoError(t, err, `parsing %q should succeed`, name)
+		require.NoError(t, registry.Register(name, stmts), `registering %q should succeed`, name)
+	}
+	return registry
+}
+
+func TestDependencyGraph(t *testing.T) {
+	t.Run("valid graph", func(t *testing.T) {
+		registry := registerSources(t, map[string]string{
+			"main.scad":   "include <a.scad>\nuse <b.scad>\ncube([1, 1, 1]);",
+			"a.scad":      "use <b.scad>\nx = 1;",
+			"b.scad":      "include <lib/c.scad>\nmodule b() { c(); }",
+			"lib/c.scad":  "module c() { cube([1, 1, 1]); }",
+			"unused.scad": "y = 2;",
+		})
+
+		g, err := ast.DependencyGraph(registry, "main.scad")
+		require.NoError(t, err, `DependencyGraph should succeed`)
+		require.NoError(t, g.Validate(), `graph should be valid`)
+
+		var names []string
+		for _, node := range g.Nodes() {
+			names = append(names, node.Name)
+		}
+		require.Equal(t, []string{"main.scad", "a.scad", "b.scad", "lib/c.scad"}, names)
+
+		node, ok := g.Node("b.scad")
+		require.True(t, ok, `b.scad should be in the graph`)
+		require.Equal(t, ast.DependencyUse, node.Kind)
+		require.Len(t, g.Edges(), 4)
+
+		order, err := g.TopologicalOrder()
+		require.NoError(t, err, `TopologicalOrder should succeed`)
+		require.Equal(t, []string{"lib/c.scad", "b.scad", "a.scad", "main.scad"}, order)
+	})
+	t.Run("missing files and cycles", func(t *testing.T) {
+		registry := registerSources(t, map[string]string{
+			"main.scad": "include <a.scad>\nuse <nope.scad>",
+			"a.scad":    "include <b.scad>\ninclude <gone.scad>",
+			"b.scad":    "use <a.scad>",
+		})
+
+		g, err := ast.DependencyGraph(registry, "main.scad")
+		require.NoError(t, err, `DependencyGraph should succeed`)
+
+		var missing []string
+		for _, m := range g.Missing() {
+			missing = append(missing, m.From+":"+m.Name)
+		}
+		require.Equal(t, []string{"main.scad:nope.scad", "a.scad:gone.scad"}, missing)
+		require.Equal(t, [][]string{{"a.scad", "b.scad", "a.scad"}}, g.Cycles())
+		require.Error(t, g.Validate(), `graph should be invalid`)
+
+		_, err = g.TopologicalOrder()
+		require.Error(t, err, `TopologicalOrder should fail`)
+	})
+	t.Run("overlapping cycles", func(t *testing.T) {
+		registry := registerSources(t, map[string]string{
+			"a.scad": "include <b.scad>\ninclude <c.scad>",
+			"b.scad": "include <c.scad>",
+			"c.scad": "include <a.scad>\nuse <b.scad>",
+		})
+
+		g, err := ast.DependencyGraph(registry, "a.scad")
+		require.NoError(t, err, `DependencyGraph should succeed`)
+		require.Equal(t, [][]string{
+			{"a.scad", "b.scad", "c.scad", "a.scad"},
+			{"a.scad", "c.scad", "a.scad"},
+			{"b.scad", "c.scad", "b.scad"},
+		}, g.Cycles())
+	})
+	t.Run("no such root", func(t *testing.T) {
+		_, err := ast.DependencyGraph(ast.NewRegistry(), "main.scad")
+		require.Error(t, err, `DependencyGraph should fail`)
+	})
+}
+
+func TestDependencyGraphExport(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "include <a.scad>\nuse <b.scad>",
+		"a.scad":    "x = 1;",
+	})
+
+	g, err := ast.DependencyGraph(registry, "main.scad")
+	require.NoError(t, err, `DependencyGraph should succeed`)
+
+	var dot strings.Builder
+	require.NoError(t, g.WriteDOT(&dot), `WriteDOT should succeed`)
+	require.Equal(t, `digraph dependencies {
+  "main.scad";
+  "a.scad";
+  "b.scad" [color=red, style=dashed];
+  "main.scad" -> "a.scad" [label="include"];
+  "main.scad" -> "b.scad" [label="use", style=dashed];
+}
+`, dot.String())
+
+	var mermaid strings.Builder
+	require.NoError(t, g.WriteMermaid(&mermaid), `WriteMermaid should succeed`)
+	require.Equal(t, `graph TD
+  n0["main.scad"]
+  n1["a.scad"]
+  n2["b.scad"]:::missing
+  n0 -->|include| n1
+  n0 -.->|use| n2
+  classDef missing stroke:#f00,stroke-dasharray:5 5
+`, mermaid.String())
+}
@@ -4,10 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 	"reflect"
 	"strings"
+
+	"github.com/lestrrat-go/openscad/internal/atomicfile"
 )
 
 const (
@@ -22,16 +23,24 @@ const (
 type EmitContext struct {
 	amalgamated     map[string]struct{}
 	registry        *Registry
+	plan            *amalgamationPlan
+	unit            *unit
 	indent          string
 	as              int
 	allowAssignment bool
 	amalgamate      bool
+	sourceMap       bool
+	style           *FormatStyle
+	// column is where the output starts on its line, when it is written
+	// to a buffer that is later placed after other text (see withColumn)
+	column int
 }
 
 func newEmitContext() *EmitContext {
 	return &EmitContext{
 		allowAssignment: true,
 		registry:        globalRegistry,
+		style:           &FormatStyle{},
 	}
 }
 
@@ -45,8 +54,13 @@ func (e *EmitContext) Copy() *EmitContext {
 		amalgamate:      e.amalgamate,
 		amalgamated:     e.amalgamated,
 		registry:        e.registry,
+		plan:            e.plan,
+		unit:            e.unit,
 		as:              e.as,
 		allowAssignment: e.allowAssignment,
+		sourceMap:       e.sourceMap,
+		style:           e.style,
+		column:          e.column,
 	}
 }
 
@@ -84,42 +98,90 @@ func (e *EmitContext) WithIndent(indent string) *EmitContext {
 	return e2
 }
 
+// withUnit returns a context for emitting the contents of a file
+// that belongs to the given amalgamation unit
+func (e *EmitContext) withUnit(u *unit) *EmitContext {
+	e2 := e.Copy()
+	e2.unit = u
+	return e2
+}
+
+// lookupFile looks up a file from the registry. If collisions are being
+// resolved, the returned statement has already been renamed accordingly.
+func (e *EmitContext) lookupFile(name string) (Stmt, bool) {
+	stmt, ok := e.registry.Lookup(name)
+	if !ok || e.plan == nil {
+		return stmt, ok
+	}
+	return e.plan.rename(e.unit, name, stmt), true
+}
+
 func (e *EmitContext) WithAllowAssignment(allowAssignment bool) *EmitContext {
 	e2 := e.Copy()
 	e2.allowAssignment = allowAssignment
 	return e2
 }
 
-const singleIndent = "  "
+// withColumn returns a context for emitting code into a buffer that is
+// later placed at the given column
+func (e *EmitContext) withColumn(column int) *EmitContext {
+	e2 := e.Copy()
+	e2.column = column
+	return e2
+}
+
+// currentColumn returns the column at which the next thing written to w
+// is placed. Output that has not started a new line yet is assumed to
+// start at the column given with withColumn, and at least at the current
+// indentation.
+func (e *EmitContext) currentColumn(w io.Writer) int {
+	var written []byte
+	if buf, ok := w.(*bytes.Buffer); ok {
+		written = buf.Bytes()
+	}
+	if i := bytes.LastIndexByte(written, '\n'); i >= 0 {
+		return e.style.width(string(written[i+1:]))
+	}
+	column := e.style.width(e.indent)
+	if e.column > column {
+		column = e.column
+	}
+	return column + e.style.width(string(written))
+}
+
+// indentUnit returns the string used for one level of indentation
+func (e *EmitContext) indentUnit() string {
+	return e.style.indentUnit()
+}
 
 func (e *EmitContext) IncrIndent() *EmitContext {
-	return e.WithIndent(e.indent + singleIndent)
+	return e.WithIndent(e.indent + e.indentUnit())
 }
 
 func (e *EmitContext) DecrIndent() *EmitContext {
+	unit := e.indentUnit()
 	if e.indent == "" {
 		return e
 	}
-	if len(e.indent) < len(singleIndent) {
+	if len(e.indent) < len(unit) {
 		return e.WithIndent("")
 	}
-	return e.WithIndent(e.indent[:len(e.indent)-len(singleIndent)])
+	return e.WithIndent(e.indent[:len(e.indent)-len(unit)])
 }
 
 func EmitFile(filename string, w io.Writer, options ...EmitFileOption) error {
 	registry := globalRegistry
 
-	emitOptions := make([]EmitOption, 0, len(options))
-	emitOptions = append(emitOptions, WithRegistry(registry))
+	emitOptions := make([]EmitOption, 0, len(options)+1)
 	//nolint:forcetypeassert
 	for _, option := range options {
 		switch option.Ident() {
 		case optRegistryKey{}:
 			registry = option.Value().(*Registry)
-		case optAmalgamationKey{}:
-			emitOptions = append(emitOptions, option)
 		}
+		emitOptions = append(emitOptions, option)
 	}
+	emitOptions = append(emitOptions, withRootName(filename))
 
 	stmt, ok := registry.Lookup(filename)
 	if !ok {
@@ -152,23 +214,10 @@ func WriteFile(filename string, options ...WriteFileOption) error {
 	if err := EmitFile(filename, &buf, emitFileOptions...); err != nil {
 		return err
 	}
-	f, err := os.CreateTemp("", "go-openscad-*")
-	if err != nil {
-		return err
-	}
-	if _, err := buf.WriteTo(f); err != nil {
-		return err
-	}
-	f.Close()
-	defer os.Remove(f.Name())
-
 	if dir != "" {
 		filename = filepath.Join(dir, filename)
 	}
-	if err := os.Rename(f.Name(), filename); err != nil {
-		return err
-	}
-	return nil
+	return atomicfile.WriteFile(filename, buf.Bytes(), 0o644)
 }
 
 func EmitString(stmt Stmt, options ...EmitOption) (string, error) {
@@ -179,6 +228,17 @@ func EmitString(stmt Stmt, options ...EmitOption) (string, error) {
 	return buf.String(), nil
 }
 
+// EmitExprString returns the OpenSCAD code for a single expression. v may
+// be an expression built with this package or the dsl package, or a Go
+// value such as a number, a string, a boolean or a slice of those.
+func EmitExprString(v interface{}) (string, error) {
+	var sb strings.Builder
+	if err := emitExpr(newEmitContext(), &sb, v); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
+
 // Emit takes a statement (or a list of statements) and emits them
 // into the writer.
 //
@@ -188,6 +248,12 @@ func EmitString(stmt Stmt, options ...EmitOption) (string, error) {
 func Emit(stmt Stmt, w io.Writer, options ...EmitOption) error {
 	ctx := newEmitContext()
 
+	var rootName string
+	var treeShaking bool
+	var sourceMap *SourceMap
+	var sourceMapFile string
+	var minified bool
+	collisions := CollisionIgnore
 	//nolint:forcetypeassert
 	for _, option := range options {
 		switch option.Ident() {
@@ -198,10 +264,81 @@ func Emit(stmt Stmt, w io.Writer, options ...EmitOption) error {
 			}
 		case optRegistryKey{}:
 			ctx.registry = option.Value().(*Registry)
+		case optCollisionPolicyKey{}:
+			collisions = option.Value().(CollisionPolicy)
+		case optRootNameKey{}:
+			rootName = option.Value().(string)
+		case optTreeShakingKey{}:
+			treeShaking = option.Value().(bool)
+		case optSourceMapKey{}:
+			target := option.Value().(sourceMapTarget)
+			sourceMap = target.dst
+			sourceMapFile = target.file
+		case optMinifyKey{}:
+			minified = option.Value().(bool)
+		case optFormatStyleKey{}:
+			style := option.Value().(FormatStyle)
+			ctx.style = &style
+		}
+	}
+
+	stmt = addDependencies(stmt)
+
+	if ctx.amalgamate && collisions != CollisionIgnore {
+		plan := newAmalgamationPlan(ctx.registry, rootName, stmt)
+		if collisions == CollisionError {
+			if err := plan.collisionError(); err != nil {
+				return err
+			}
+		} else {
+			ctx.plan = plan
+			ctx.unit = plan.root
+			stmt = plan.rename(plan.root, rootName, stmt)
 		}
 	}
 
-	return stmt.EmitStmt(ctx, w)
+	if ctx.amalgamate {
+		expanded, err := expandInclusions(ctx, stmt)
+		if err != nil {
+			return err
+		}
+		if treeShaking {
+			expanded = treeShake(expanded)
+		}
+		stmt = expanded
+	}
+
+	if minified {
+		stmt = minify(stmt)
+	}
+
+	if sourceMap == nil && !minified {
+		return stmt.EmitStmt(ctx, w)
+	}
+
+	// Emit the code with markers describing where each statement came
+	// from, then strip them while computing the output locations
+	var buf bytes.Buffer
+	if sourceMap != nil {
+		ctx.sourceMap = true
+		emitPosition(ctx, &buf, stmt)
+	}
+	if err := stmt.EmitStmt(ctx, &buf); err != nil {
+		return err
+	}
+
+	out := buf.Bytes()
+	if minified {
+		out = compact(out)
+	}
+	if sourceMap == nil {
+		if _, err := w.Write(out); err != nil {
+			return fmt.Errorf(`failed to write output: %w`, err)
+		}
+		return nil
+	}
+	*sourceMap = SourceMap{Version: 1, File: sourceMapFile}
+	return extractSourceMap(w, out, rootName, sourceMap)
 }
 
 func emitExpr(ctx *EmitContext, w io.Writer, v interface{}) error {
@@ -212,9 +349,33 @@ func emitStmt(ctx *EmitContext, w io.Writer, v interface{}) error {
 	return emitValue(ctx.ForceStmt(), w, v)
 }
 
-func emitListContent(ctx *EmitContext, w io.Writer, rv reflect.Value) error {
-	// lists are expressed as a single line if they contain 3 or fewer elements
+// emitListContent writes the elements of a list that is opened at the
+// given column
+func emitListContent(ctx *EmitContext, w io.Writer, rv reflect.Value, column int) error {
+	// lists are expressed as a single line if they contain 3 or fewer elements,
+	// or if they fit within the maximum width
 	separateLine := rv.Len() > 3
+	if ctx.style.MaxWidth > 0 {
+		var flat bytes.Buffer
+		if err := writeListContent(ctx, &flat, rv, false, column+1); err != nil {
+			return err
+		}
+		if ctx.style.fits(column, `[`+flat.String()+`]`) {
+			if _, err := flat.WriteTo(w); err != nil {
+				return fmt.Errorf(`failed to write list content: %w`, err)
+			}
+			return nil
+		}
+		separateLine = true
+	}
+	return writeListContent(ctx, w, rv, separateLine, column+1)
+}
+
+func writeListContent(ctx *EmitContext, w io.Writer, rv reflect.Value, separateLine bool, column int) error {
+	if separateLine {
+		// the elements are indented by one level when they are placed
+		column = ctx.style.width(ctx.Indent() + ctx.indentUnit())
+	}
 	var body bytes.Buffer
 	for i := 0; i < rv.Len(); i++ {
 		if i > 0 {
@@ -223,9 +384,17 @@ func emitListContent(ctx *EmitContext, w io.Writer, rv reflect.Value) error {
 		if separateLine {
 			fmt.Fprintln(&body)
 		}
-		if err := emitValue(ctx, &body, rv.Index(i).Interface()); err != nil {
+		elemColumn := column
+		if !separateLine {
+			elemColumn += ctx.style.width(body.String())
+		}
+		// each element is emitted on its own, as body does not hold the
+		// indentation that its lines are given later
+		var elem bytes.Buffer
+		if err := emitValue(ctx.withColumn(elemColumn), &elem, rv.Index(i).Interface()); err != nil {
 			return err
 		}
+		body.Write(elem.Bytes())
 	}
 
 	if _, err := body.WriteTo(w); err != nil {
@@ -235,14 +404,15 @@ func emitListContent(ctx *EmitContext, w io.Writer, rv reflect.Value) error {
 }
 
 func emitAny(ctx *EmitContext, w io.Writer, v interface{}) error {
-	childIndent := ctx.Indent() + singleIndent
+	childIndent := ctx.Indent() + ctx.indentUnit()
 	rv := reflect.ValueOf(v)
 	switch rv.Kind() {
 	case reflect.Slice:
+		column := ctx.currentColumn(w)
 		fmt.Fprint(w, "[")
 
 		var content bytes.Buffer
-		if err := emitListContent(ctx, &content, rv); err != nil {
+		if err := emitListContent(ctx, &content, rv, column); err != nil {
 			return err
 		}
 
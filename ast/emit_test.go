@@ -1,7 +1,55 @@
 package ast_test
 
-import "github.com/lestrrat-go/openscad/ast"
+import (
+	"testing"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
 
 var _ ast.EmitOption = ast.WithAmalgamation()
 var _ ast.EmitFileOption = ast.WithAmalgamation()
 var _ ast.WriteFileOption = ast.WithAmalgamation()
+
+func TestEmitIntersection(t *testing.T) {
+	out, err := ast.EmitString(ast.NewIntersection(ast.NewCube(1, 1, 1), ast.NewSphere(1)))
+	require.NoError(t, err, `ast.EmitString should succeed`)
+	require.Equal(t, "intersection()\n{\n  cube([1, 1, 1]);\n  sphere(r=1);\n}", out)
+}
+
+func TestEmitCenter(t *testing.T) {
+	out, err := ast.EmitString(ast.NewCylinder(2, 1, nil).Center(true))
+	require.NoError(t, err, `ast.EmitString should succeed`)
+	require.Equal(t, "cylinder(h=2, r=1, center=true);", out)
+}
+
+func TestEmitOperators(t *testing.T) {
+	testcases := []struct {
+		Expr     interface{}
+		Expected string
+	}{
+		{Expr: ast.NewBinaryOp("*", ast.NewBinaryOp("+", 1, 2), 3), Expected: "(1 + 2) * 3"},
+		{Expr: ast.NewBinaryOp("+", 1, ast.NewBinaryOp("*", 2, 3)), Expected: "1 + 2 * 3"},
+		{Expr: ast.NewBinaryOp("-", ast.NewBinaryOp("-", 1, 2), 3), Expected: "1 - 2 - 3"},
+		{Expr: ast.NewBinaryOp("-", 1, ast.NewBinaryOp("-", 2, 3)), Expected: "1 - (2 - 3)"},
+		{Expr: ast.NewBinaryOp("==", ast.NewBinaryOp("<", 1, 2), true), Expected: "1 < 2 == true"},
+		{Expr: ast.NewBinaryOp("<", ast.NewBinaryOp("==", 1, 2), true), Expected: "(1 == 2) < true"},
+		{Expr: ast.NewBinaryOp("^", ast.NewUnaryOp("-", 2), 2), Expected: "(-2) ^ 2"},
+		{Expr: ast.NewBinaryOp("^", -2, 2), Expected: "(-2) ^ 2"},
+		{Expr: ast.NewBinaryOp("^", 2, ast.NewBinaryOp("^", 3, 2)), Expected: "2 ^ 3 ^ 2"},
+		{Expr: ast.NewUnaryOp("-", ast.NewBinaryOp("+", ast.NewVariable("a"), 1)), Expected: "-(a + 1)"},
+		{Expr: ast.NewUnaryOp("-", ast.NewBinaryOp("^", ast.NewVariable("a"), 2)), Expected: "-a ^ 2"},
+		{Expr: ast.NewBinaryOp("+", ast.NewTernaryOp(ast.NewVariable("a"), 1, 2), 3), Expected: "(a ? 1 : 2) + 3"},
+		{Expr: ast.NewBinaryOp("*", 2, ast.NewLetExpr(ast.NewVariable("b").Value(1)).Expr(ast.NewVariable("b"))), Expected: "2 * (let( b=1)b)"},
+		{Expr: ast.NewIndex(ast.NewBinaryOp("+", ast.NewVariable("a"), ast.NewVariable("b")), 0), Expected: "(a + b)[0]"},
+		{Expr: ast.NewBinaryOp("*", ast.NewGroup(ast.NewBinaryOp("+", 1, 2)), 3), Expected: "(1 + 2) * 3"},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Expected, func(t *testing.T) {
+			out, err := ast.EmitString(ast.NewVariable("x").Value(tc.Expr))
+			require.NoError(t, err, `ast.EmitString should succeed`)
+			require.Equal(t, "\nx = "+tc.Expected+";", out)
+		})
+	}
+}
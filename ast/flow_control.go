@@ -37,7 +37,7 @@ func emitLetPreamble(ctx *EmitContext, w io.Writer, vars []*Variable) error {
 	}
 
 	if strings.ContainsRune(letVars.String(), '\n') {
-		if err := addIndent(w, &letVars, singleIndent); err != nil {
+		if err := addIndent(w, &letVars, ctx.indentUnit()); err != nil {
 			return fmt.Errorf(`failed to write let variables: %v`, err)
 		}
 		fmt.Fprintf(w, "\n)")
@@ -66,6 +66,16 @@ func (l *LetExpr) Expr(expr interface{}) *LetExpr {
 	return l
 }
 
+// Variables returns the variables that are bound by the let expression
+func (l *LetExpr) Variables() []*Variable {
+	return l.variables
+}
+
+// BodyExpr returns the expression that is evaluated with the variables bound
+func (l *LetExpr) BodyExpr() interface{} {
+	return l.expr
+}
+
 func (l *LetExpr) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	var preamble bytes.Buffer
 	if err := emitLetPreamble(ctx, &preamble, l.variables); err != nil {
@@ -84,7 +94,7 @@ func (l *LetExpr) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	}
 
 	if fmtAsBlock {
-		fmt.Fprintf(w, "\n%s", singleIndent)
+		fmt.Fprintf(w, "\n%s", ctx.indentUnit())
 	}
 
 	if _, err := body.WriteTo(w); err != nil {
@@ -95,6 +105,7 @@ func (l *LetExpr) EmitExpr(ctx *EmitContext, w io.Writer) error {
 }
 
 type LetBlock struct {
+	position
 	variables []*Variable
 	children  []Stmt
 }
@@ -105,6 +116,17 @@ func NewLetBlock(variables ...*Variable) *LetBlock {
 	}
 }
 
+// Variables returns the variables that are bound by the let block
+func (l *LetBlock) Variables() []*Variable {
+	return l.variables
+}
+
+// Children returns the statements that are executed with the variables
+// bound
+func (l *LetBlock) Children() []Stmt {
+	return l.children
+}
+
 func (l *LetBlock) Body(children ...Stmt) *LetBlock {
 	l.children = make([]Stmt, len(children))
 	copy(l.children, children)
@@ -146,6 +168,19 @@ func (fr *ForRange) Increment(incr interface{}) *ForRange {
 	return fr
 }
 
+func (fr *ForRange) Start() interface{} {
+	return fr.start
+}
+
+func (fr *ForRange) End() interface{} {
+	return fr.end
+}
+
+// Step returns the increment of the range, or nil if it was not specified
+func (fr *ForRange) Step() interface{} {
+	return fr.increment
+}
+
 func (fr *ForRange) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	fmt.Fprint(w, `[`)
 	if err := emitValue(ctx, w, fr.start); err != nil {
@@ -177,6 +212,16 @@ func NewLoopVar(variable *Variable, expr interface{}) *LoopVar {
 	}
 }
 
+// Variable returns the loop variable
+func (lv *LoopVar) Variable() *Variable {
+	return lv.variable
+}
+
+// Expr returns the expression that the loop variable iterates over
+func (lv *LoopVar) Expr() interface{} {
+	return lv.expr
+}
+
 func (lv *LoopVar) String() string {
 	var sb strings.Builder
 	if err := lv.EmitExpr(newEmitContext(), &sb); err != nil {
@@ -212,6 +257,15 @@ func (f *ForExpr) Body(expr interface{}) *ForExpr {
 	return f
 }
 
+func (f *ForExpr) LoopVars() []*LoopVar {
+	return f.loopVars
+}
+
+// BodyExpr returns the expression that is evaluated for each iteration
+func (f *ForExpr) BodyExpr() interface{} {
+	return f.expr
+}
+
 func emitForDecl(ctx *EmitContext, w io.Writer, loopVars []*LoopVar) error {
 	fmt.Fprint(w, "for (")
 	ctx = ctx.WithAllowAssignment(false)
@@ -234,7 +288,10 @@ func addIndent(dst io.Writer, src io.Reader, indent string) error {
 		if i > 0 {
 			fmt.Fprintf(dst, "\n")
 		}
-		fmt.Fprintf(dst, "%s%s", indent, scanner.Text())
+		// do not leave trailing whitespace on empty lines
+		if line := scanner.Text(); line != "" {
+			fmt.Fprintf(dst, "%s%s", indent, line)
+		}
 		i++
 	}
 	return scanner.Err()
@@ -253,7 +310,7 @@ func (f *ForExpr) EmitExpr(ctx *EmitContext, w io.Writer) error {
 		}
 
 		fmt.Fprintln(w)
-		if err := addIndent(w, &body, singleIndent); err != nil {
+		if err := addIndent(w, &body, ctx.indentUnit()); err != nil {
 			return fmt.Errorf(`failed to emit for expression: %w`, err)
 		}
 	} else {
@@ -273,6 +330,7 @@ func (f *ForExpr) EmitExpr(ctx *EmitContext, w io.Writer) error {
 // In OpenSCAD for can take two distinctive styles: one as an expression, and
 // one as a statement. ForBlock the expression, use ForExpr
 type ForBlock struct {
+	position
 	loopVars []*LoopVar
 	children []Stmt
 }
@@ -283,6 +341,16 @@ func NewFor(loopVars []*LoopVar) *ForBlock {
 	}
 }
 
+// LoopVars returns the loop variables of the block, outermost first
+func (f *ForBlock) LoopVars() []*LoopVar {
+	return f.loopVars
+}
+
+// Children returns the statements that are executed for each iteration
+func (f *ForBlock) Children() []Stmt {
+	return f.children
+}
+
 func (f *ForBlock) Body(stmts ...Stmt) *ForBlock {
 	f.children = make([]Stmt, len(stmts))
 	copy(f.children, stmts)
@@ -355,11 +423,11 @@ func (op *TernaryOp) EmitExpr(ctx *EmitContext, w io.Writer) error {
 			return fmt.Errorf(`failed to write ternary condition: %w`, err)
 		}
 		fmt.Fprint(w, " ?\n")
-		if err := addIndent(w, &trueExpr, ctx.Indent()+singleIndent); err != nil {
+		if err := addIndent(w, &trueExpr, ctx.Indent()+ctx.indentUnit()); err != nil {
 			return fmt.Errorf(`failed to write ternary true expression: %w`, err)
 		}
 		fmt.Fprint(w, " :\n")
-		if err := addIndent(w, &falseExpr, ctx.Indent()+singleIndent); err != nil {
+		if err := addIndent(w, &falseExpr, ctx.Indent()+ctx.indentUnit()); err != nil {
 			return fmt.Errorf(`failed to write ternary false expression: %w`, err)
 		}
 	} else {
@@ -396,7 +464,7 @@ func emitIfPreamble(ctx *EmitContext, w io.Writer, cond interface{}) error {
 		return fmt.Errorf(`failed to emit if condition: %w`, err)
 	}
 	if strings.ContainsRune(buf.String(), '\n') {
-		if err := addIndent(w, &buf, ctx.Indent()+singleIndent); err != nil {
+		if err := addIndent(w, &buf, ctx.Indent()+ctx.indentUnit()); err != nil {
 			return fmt.Errorf(`failed to write if condition: %w`, err)
 		}
 		fmt.Fprintf(w, "\n)")
@@ -410,8 +478,9 @@ func emitIfPreamble(ctx *EmitContext, w io.Writer, cond interface{}) error {
 }
 
 type IfExpr struct {
-	cond interface{}
-	body interface{}
+	cond     interface{}
+	body     interface{}
+	elseBody interface{}
 }
 
 func NewIfExpr(cond interface{}) *IfExpr {
@@ -425,6 +494,27 @@ func (ib *IfExpr) Body(body interface{}) *IfExpr {
 	return ib
 }
 
+func (ib *IfExpr) Condition() interface{} {
+	return ib.cond
+}
+
+// BodyExpr returns the expression that is used when the condition holds
+func (ib *IfExpr) BodyExpr() interface{} {
+	return ib.body
+}
+
+// Else sets the expression that is used when the condition does not hold
+func (ib *IfExpr) Else(expr interface{}) *IfExpr {
+	ib.elseBody = expr
+	return ib
+}
+
+// ElseExpr returns the expression that is used when the condition does
+// not hold, or nil
+func (ib *IfExpr) ElseExpr() interface{} {
+	return ib.elseBody
+}
+
 func (ib *IfExpr) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	if err := emitIfPreamble(ctx, w, ib.cond); err != nil {
 		return err
@@ -432,10 +522,42 @@ func (ib *IfExpr) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	if err := emitExpr(ctx, w, ib.body); err != nil {
 		return fmt.Errorf(`failed to emit if body: %w`, err)
 	}
+	if ib.elseBody != nil {
+		fmt.Fprint(w, ` else `)
+		if err := emitExpr(ctx, w, ib.elseBody); err != nil {
+			return fmt.Errorf(`failed to emit else body: %w`, err)
+		}
+	}
+	return nil
+}
+
+// EachExpr is an element of a list comprehension that adds each of the
+// elements of a list, rather than the list itself, such as `each [1, 2]`
+type EachExpr struct {
+	expr interface{}
+}
+
+func NewEachExpr(expr interface{}) *EachExpr {
+	return &EachExpr{
+		expr: expr,
+	}
+}
+
+// Expr returns the expression whose elements are added
+func (e *EachExpr) Expr() interface{} {
+	return e.expr
+}
+
+func (e *EachExpr) EmitExpr(ctx *EmitContext, w io.Writer) error {
+	fmt.Fprint(w, `each `)
+	if err := emitExpr(ctx, w, e.expr); err != nil {
+		return fmt.Errorf(`failed to emit each expression: %w`, err)
+	}
 	return nil
 }
 
 type IfStmt struct {
+	position
 	cond         interface{}
 	body         []Stmt
 	elseifBlocks []*ElseIfStmt
@@ -448,6 +570,27 @@ func NewIfStmt(cond interface{}) *IfStmt {
 	}
 }
 
+// Condition returns the condition of the if statement
+func (ib *IfStmt) Condition() interface{} {
+	return ib.cond
+}
+
+// Children returns the statements that are executed if the condition is
+// true
+func (ib *IfStmt) Children() []Stmt {
+	return ib.body
+}
+
+// ElseIfBlocks returns the `else if` blocks, in order
+func (ib *IfStmt) ElseIfBlocks() []*ElseIfStmt {
+	return ib.elseifBlocks
+}
+
+// ElseChildren returns the statements of the `else` block, or nil
+func (ib *IfStmt) ElseChildren() []Stmt {
+	return ib.elseBlock
+}
+
 func (ib *IfStmt) Body(stmts ...Stmt) *IfStmt {
 	ib.body = make([]Stmt, len(stmts))
 	copy(ib.body, stmts)
@@ -500,6 +643,17 @@ type ElseIfStmt struct {
 	body []Stmt
 }
 
+// Condition returns the condition of the block
+func (eb *ElseIfStmt) Condition() interface{} {
+	return eb.cond
+}
+
+// Children returns the statements that are executed if the condition is
+// true
+func (eb *ElseIfStmt) Children() []Stmt {
+	return eb.body
+}
+
 func (eb *ElseIfStmt) EmitStmt(ctx *EmitContext, w io.Writer) error {
 	fmt.Fprintf(w, "\n%selse if (", ctx.Indent())
 	if err := emitExpr(ctx, w, eb.cond); err != nil {
@@ -8,6 +8,7 @@ import (
 )
 
 type Function struct {
+	position
 	name       string
 	parameters []*Variable
 	body       interface{}
@@ -33,6 +34,16 @@ func (f *Function) Body(body interface{}) *Function {
 	return f
 }
 
+// Params returns the parameters of the function
+func (f *Function) Params() []*Variable {
+	return f.parameters
+}
+
+// BodyExpr returns the expression that the function evaluates to
+func (f *Function) BodyExpr() interface{} {
+	return f.body
+}
+
 func (f *Function) EmitStmt(ctx *EmitContext, w io.Writer) error {
 	fmt.Fprintf(w, "\n%s", ctx.Indent())
 	if err := f.EmitExpr(ctx, w); err != nil {
@@ -67,7 +78,7 @@ func (f *Function) EmitExpr(ctx *EmitContext, w io.Writer) error {
 
 	if strings.ContainsRune(body.String(), '\n') {
 		fmt.Fprint(w, "\n")
-		if err := addIndent(w, &body, ctx.Indent()+singleIndent); err != nil {
+		if err := addIndent(w, &body, ctx.Indent()+ctx.indentUnit()); err != nil {
 			return err
 		}
 	} else {
@@ -78,6 +89,62 @@ func (f *Function) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	return nil
 }
 
+// FunctionLiteral is an anonymous function that can be used as a value,
+// such as `function(x) x * 2`.
+type FunctionLiteral struct {
+	parameters []*Variable
+	body       interface{}
+}
+
+func NewFunctionLiteral(params ...*Variable) *FunctionLiteral {
+	return &FunctionLiteral{
+		parameters: params,
+	}
+}
+
+func (f *FunctionLiteral) Body(body interface{}) *FunctionLiteral {
+	f.body = body
+	return f
+}
+
+// Params returns the parameters of the function
+func (f *FunctionLiteral) Params() []*Variable {
+	return f.parameters
+}
+
+// BodyExpr returns the expression that the function evaluates to
+func (f *FunctionLiteral) BodyExpr() interface{} {
+	return f.body
+}
+
+func (f *FunctionLiteral) String() string {
+	var sb strings.Builder
+	if err := f.EmitExpr(newEmitContext(), &sb); err != nil {
+		return fmt.Sprintf(`#FunctionLiteral(error=%s)`, err)
+	}
+	return sb.String()
+}
+
+func (f *FunctionLiteral) EmitExpr(ctx *EmitContext, w io.Writer) error {
+	fmt.Fprint(w, `function(`)
+
+	pctx := ctx.WithAllowAssignment(true)
+	for i, p := range f.parameters {
+		if i > 0 {
+			fmt.Fprint(w, `, `)
+		}
+		if err := emitExpr(pctx, w, p); err != nil {
+			return err
+		}
+	}
+	fmt.Fprint(w, `) `)
+
+	if f.body == nil {
+		return fmt.Errorf(`expected a body`)
+	}
+	return emitExpr(ctx.WithAllowAssignment(false), w, f.body)
+}
+
 type LookupStmt struct {
 	key    interface{}
 	values interface{}
@@ -90,6 +157,16 @@ func NewLookup(key, values interface{}) *LookupStmt {
 	}
 }
 
+// Key returns the key that is looked up
+func (l *LookupStmt) Key() interface{} {
+	return l.key
+}
+
+// Values returns the table that the key is looked up in
+func (l *LookupStmt) Values() interface{} {
+	return l.values
+}
+
 func (l *LookupStmt) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	fmt.Fprint(w, "lookup(")
 	ctx = ctx.WithAllowAssignment(false)
@@ -0,0 +1,596 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strconv"
+	"strings"
+)
+
+// goLineWidth is the width that argument lists in generated Go code
+// should fit in. Longer lists are put on lines of their own.
+const goLineWidth = 80
+
+// dslBinaryOps maps binary operators to the dsl functions that create them
+var dslBinaryOps = map[string]string{
+	`+`:  `dsl.Add`,
+	`-`:  `dsl.Sub`,
+	`*`:  `dsl.Mul`,
+	`/`:  `dsl.Div`,
+	`%`:  `dsl.Mod`,
+	`==`: `dsl.EQ`,
+	`<`:  `dsl.LT`,
+	`<=`: `dsl.LE`,
+	`>`:  `dsl.GT`,
+	`>=`: `dsl.GE`,
+}
+
+// dslCalls maps calls to built-in functions to the dsl functions that
+// create them, along with the number of arguments they take (-1 for any)
+var dslCalls = map[string]struct {
+	fn    string
+	nargs int
+}{
+	`atan2`:  {`dsl.Atan2`, 2},
+	`ceil`:   {`dsl.Ceil`, 1},
+	`concat`: {`dsl.Concat`, -1},
+	`cos`:    {`dsl.Cos`, 1},
+	`floor`:  {`dsl.Floor`, 1},
+	`len`:    {`dsl.Len`, 1},
+	`render`: {`dsl.Render`, 0},
+	`sin`:    {`dsl.Sin`, 1},
+	`sqrt`:   {`dsl.Sqrt`, 1},
+	`tan`:    {`dsl.Tan`, 1},
+}
+
+// GoCode generates the source code of a Go file that builds stmt using the
+// dsl package. The generated function returns an equivalent tree, which
+// emits the same OpenSCAD code as stmt does.
+//
+// By default, the code is generated as a main package whose main()
+// function emits the tree to standard output. Use WithGoPackage and
+// WithGoFunc to change the names of the package and of the function.
+func GoCode(stmt Stmt, options ...GoCodeOption) ([]byte, error) {
+	pkg := `main`
+	funcName := `Model`
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case optGoPackageKey{}:
+			pkg = option.Value().(string)
+		case optGoFuncKey{}:
+			funcName = option.Value().(string)
+		}
+	}
+
+	stmts, ok := stmt.(Stmts)
+	if !ok {
+		stmts = Stmts{stmt}
+	}
+	body, err := goStmts(`dsl.Stmts`, stmts)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\nimport (\n", pkg)
+	if pkg == `main` {
+		fmt.Fprintf(&buf, "\t\"fmt\"\n\t\"os\"\n\n")
+	}
+	fmt.Fprintf(&buf, "\t\"github.com/lestrrat-go/openscad/ast\"\n")
+	if strings.Contains(body, `dsl.`) {
+		fmt.Fprintf(&buf, "\t\"github.com/lestrrat-go/openscad/dsl\"\n")
+	}
+	fmt.Fprintf(&buf, ")\n\n")
+	fmt.Fprintf(&buf, "// %s returns the statements that make up the model\n", funcName)
+	fmt.Fprintf(&buf, "func %s() ast.Stmts {\nreturn %s\n}\n", funcName, body)
+	if pkg == `main` {
+		fmt.Fprintf(&buf, "\nfunc main() {\n")
+		fmt.Fprintf(&buf, "if err := ast.Emit(%s(), os.Stdout); err != nil {\n", funcName)
+		fmt.Fprintf(&buf, "fmt.Fprintf(os.Stderr, \"failed to emit: %%s\\n\", err)\n")
+		fmt.Fprintf(&buf, "os.Exit(1)\n}\n}\n")
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf(`failed to format generated code: %w`, err)
+	}
+	return src, nil
+}
+
+// goCall generates a call to fn. The arguments are put on a single line
+// if they fit, or one per line otherwise.
+func goCall(fn string, args ...string) string {
+	oneLine := fn + `(` + strings.Join(args, `, `) + `)`
+	if len(oneLine) <= goLineWidth && !strings.ContainsRune(oneLine, '\n') {
+		return oneLine
+	}
+	return goCallLines(fn, args...)
+}
+
+// goCallLines generates a call to fn with each argument on its own line
+func goCallLines(fn string, args ...string) string {
+	if len(args) == 0 {
+		return fn + `()`
+	}
+	return fn + "(\n" + strings.Join(args, ",\n") + ",\n)"
+}
+
+// goChain appends method calls to base. Long chains are put on
+// separate lines.
+func goChain(base string, methods ...string) string {
+	if len(methods) == 0 {
+		return base
+	}
+	oneLine := base + `.` + strings.Join(methods, `.`)
+	if len(methods) == 1 || (len(oneLine) <= goLineWidth && !strings.ContainsRune(oneLine, '\n')) {
+		return oneLine
+	}
+	return base + ".\n" + strings.Join(methods, ".\n")
+}
+
+func goStmts(fn string, stmts []Stmt, leading ...string) (string, error) {
+	args := append([]string(nil), leading...)
+	for _, stmt := range stmts {
+		code, err := goValue(stmt)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, code)
+	}
+	if len(stmts) == 0 {
+		return goCall(fn, args...), nil
+	}
+	return goCallLines(fn, args...), nil
+}
+
+func goValues(values []interface{}) ([]string, error) {
+	args := make([]string, 0, len(values))
+	for _, v := range values {
+		code, err := goValue(v)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, code)
+	}
+	return args, nil
+}
+
+func goVariables(vars []*Variable) ([]string, error) {
+	args := make([]string, 0, len(vars))
+	for _, v := range vars {
+		code, err := goValue(v)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, code)
+	}
+	return args, nil
+}
+
+func goLoopVars(vars []*LoopVar) ([]string, error) {
+	args := make([]string, 0, len(vars))
+	for _, v := range vars {
+		code, err := goValue(v)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, code)
+	}
+	return args, nil
+}
+
+func goFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func goIntPtr(name string, ptr *int) []string {
+	if ptr == nil {
+		return nil
+	}
+	return []string{fmt.Sprintf(`%s(%d)`, name, *ptr)}
+}
+
+// goValue generates the Go expression that creates v
+func goValue(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return `nil`, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case float64:
+		return goFloat(v), nil
+	case string:
+		return strconv.Quote(v), nil
+	case []interface{}:
+		args, err := goValues(v)
+		if err != nil {
+			return "", err
+		}
+		return goCall(`dsl.List`, args...), nil
+	case Stmts:
+		return goStmts(`dsl.Stmts`, v)
+	case *Declare:
+		value, err := goValue(v.v.value)
+		if err != nil {
+			return "", err
+		}
+		return goCall(`dsl.Declare`, strconv.Quote(v.v.name), value), nil
+	case *Variable:
+		if v.value == nil {
+			if v.name == `PI` {
+				return `dsl.PI()`, nil
+			}
+			return goCall(`dsl.Variable`, strconv.Quote(v.name)), nil
+		}
+		value, err := goValue(v.value)
+		if err != nil {
+			return "", err
+		}
+		return goChain(goCall(`dsl.Variable`, strconv.Quote(v.name)), goCall(`Value`, value)), nil
+	case *Module:
+		params, err := goVariables(v.parameters)
+		if err != nil {
+			return "", err
+		}
+		var methods []string
+		if len(params) > 0 {
+			methods = append(methods, goCall(`Parameters`, params...))
+		}
+		if len(v.children) > 0 {
+			actions, err := goStmts(`Actions`, v.children)
+			if err != nil {
+				return "", err
+			}
+			methods = append(methods, actions)
+		}
+		return goChain(goCall(`dsl.Module`, strconv.Quote(v.name)), methods...), nil
+	case *Call:
+		return goCallStmt(v)
+	case *Function:
+		params, err := goVariables(v.parameters)
+		if err != nil {
+			return "", err
+		}
+		body, err := goValue(v.body)
+		if err != nil {
+			return "", err
+		}
+		var methods []string
+		if len(params) > 0 {
+			methods = append(methods, goCall(`Parameters`, params...))
+		}
+		methods = append(methods, goCall(`Body`, body))
+		return goChain(goCall(`dsl.Function`, strconv.Quote(v.name)), methods...), nil
+	case *FunctionLiteral:
+		params, err := goVariables(v.parameters)
+		if err != nil {
+			return "", err
+		}
+		body, err := goValue(v.body)
+		if err != nil {
+			return "", err
+		}
+		return goChain(goCall(`dsl.FunctionLiteral`, params...), goCall(`Body`, body)), nil
+	case *Include:
+		return goCall(`dsl.Include`, strconv.Quote(v.name)), nil
+	case *Use:
+		return goCall(`dsl.Use`, strconv.Quote(v.name)), nil
+	case *Index:
+		args, err := goValues([]interface{}{v.expr, v.index})
+		if err != nil {
+			return "", err
+		}
+		return goCall(`dsl.Index`, args...), nil
+	case *BareBlock:
+		return goStmts(`ast.NewBareBlock`, v.children)
+	case *LookupStmt:
+		args, err := goValues([]interface{}{v.key, v.values})
+		if err != nil {
+			return "", err
+		}
+		return goCall(`dsl.Lookup`, args...), nil
+	case *LetExpr:
+		vars, err := goVariables(v.variables)
+		if err != nil {
+			return "", err
+		}
+		expr, err := goValue(v.expr)
+		if err != nil {
+			return "", err
+		}
+		return goChain(goCall(`dsl.LetExpr`, vars...), goCall(`Expr`, expr)), nil
+	case *LetBlock:
+		vars, err := goVariables(v.variables)
+		if err != nil {
+			return "", err
+		}
+		body, err := goStmts(`Body`, v.children)
+		if err != nil {
+			return "", err
+		}
+		return goChain(goCall(`dsl.LetBlock`, vars...), body), nil
+	case *ForRange:
+		args, err := goValues([]interface{}{v.start, v.end})
+		if err != nil {
+			return "", err
+		}
+		code := goCall(`dsl.ForRange`, args...)
+		if v.increment == nil {
+			return code, nil
+		}
+		incr, err := goValue(v.increment)
+		if err != nil {
+			return "", err
+		}
+		return goChain(code, goCall(`Increment`, incr)), nil
+	case *LoopVar:
+		args, err := goValues([]interface{}{v.variable, v.expr})
+		if err != nil {
+			return "", err
+		}
+		return goCall(`dsl.LoopVar`, args...), nil
+	case *ForExpr:
+		vars, err := goLoopVars(v.loopVars)
+		if err != nil {
+			return "", err
+		}
+		expr, err := goValue(v.expr)
+		if err != nil {
+			return "", err
+		}
+		return goChain(goCall(`dsl.ForExpr`, vars...), goCall(`Body`, expr)), nil
+	case *ForBlock:
+		vars, err := goLoopVars(v.loopVars)
+		if err != nil {
+			return "", err
+		}
+		body, err := goStmts(`Body`, v.children)
+		if err != nil {
+			return "", err
+		}
+		return goChain(goCall(`dsl.For`, vars...), body), nil
+	case *TernaryOp:
+		args, err := goValues([]interface{}{v.condition, v.trueExpr, v.falseExpr})
+		if err != nil {
+			return "", err
+		}
+		return goCall(`dsl.Ternary`, args...), nil
+	case *IfExpr:
+		args, err := goValues([]interface{}{v.cond, v.body})
+		if err != nil {
+			return "", err
+		}
+		methods := []string{goCall(`Body`, args[1])}
+		if v.elseBody != nil {
+			elseBody, err := goValue(v.elseBody)
+			if err != nil {
+				return "", err
+			}
+			methods = append(methods, goCall(`Else`, elseBody))
+		}
+		return goChain(goCall(`ast.NewIfExpr`, args[0]), methods...), nil
+	case *EachExpr:
+		expr, err := goValue(v.expr)
+		if err != nil {
+			return "", err
+		}
+		return goCall(`ast.NewEachExpr`, expr), nil
+	case *IfStmt:
+		return goIfStmt(v)
+	case *Group:
+		expr, err := goValue(v.expr)
+		if err != nil {
+			return "", err
+		}
+		return goCall(`dsl.Group`, expr), nil
+	case *UnaryOp:
+		expr, err := goValue(v.expr)
+		if err != nil {
+			return "", err
+		}
+		if v.op == `-` {
+			return goCall(`dsl.Negative`, expr), nil
+		}
+		return goCall(`ast.NewUnaryOp`, strconv.Quote(v.op), expr), nil
+	case *BinaryOp:
+		args, err := goValues([]interface{}{v.left, v.right})
+		if err != nil {
+			return "", err
+		}
+		if fn, ok := dslBinaryOps[v.op]; ok {
+			return goCall(fn, args...), nil
+		}
+		return goCall(`ast.NewBinaryOp`, append([]string{strconv.Quote(v.op)}, args...)...), nil
+	case *Point2D:
+		args, err := goValues([]interface{}{v.x, v.y})
+		if err != nil {
+			return "", err
+		}
+		return goCall(`dsl.Point2D`, args...), nil
+	case Point2DList:
+		args := make([]string, 0, len(v))
+		for _, pt := range v {
+			code, err := goValue(pt)
+			if err != nil {
+				return "", err
+			}
+			args = append(args, code)
+		}
+		oneLine := `ast.Point2DList{` + strings.Join(args, `, `) + `}`
+		if len(oneLine) <= goLineWidth {
+			return oneLine, nil
+		}
+		return "ast.Point2DList{\n" + strings.Join(args, ",\n") + ",\n}", nil
+	case *Polygon:
+		args, err := goValues([]interface{}{v.points, v.paths})
+		if err != nil {
+			return "", err
+		}
+		return goCall(`dsl.Polygon`, args...), nil
+	case *Cube:
+		args, err := goValues([]interface{}{v.width, v.depth, v.height})
+		if err != nil {
+			return "", err
+		}
+		var methods []string
+		if v.center != nil {
+			methods = append(methods, fmt.Sprintf(`Center(%t)`, *v.center))
+		}
+		methods = append(methods, goIntPtr(`Fn`, v.fn)...)
+		return goChain(goCall(`dsl.Cube`, args...), methods...), nil
+	case *Cylinder:
+		args, err := goValues([]interface{}{v.height, v.radius1, v.radius2})
+		if err != nil {
+			return "", err
+		}
+		var methods []string
+		if v.center != nil {
+			methods = append(methods, fmt.Sprintf(`Center(%t)`, *v.center))
+		}
+		methods = append(methods, goIntPtr(`Fa`, v.fa)...)
+		methods = append(methods, goIntPtr(`Fs`, v.fs)...)
+		methods = append(methods, goIntPtr(`Fn`, v.fn)...)
+		return goChain(goCall(`dsl.Cylinder`, args...), methods...), nil
+	case *Sphere:
+		radius, err := goValue(v.radius)
+		if err != nil {
+			return "", err
+		}
+		var methods []string
+		methods = append(methods, goIntPtr(`Fa`, v.fa)...)
+		methods = append(methods, goIntPtr(`Fs`, v.fs)...)
+		methods = append(methods, goIntPtr(`Fn`, v.fn)...)
+		return goChain(goCall(`dsl.Sphere`, radius), methods...), nil
+	case *Circle:
+		radius, err := goValue(v.radius)
+		if err != nil {
+			return "", err
+		}
+		var methods []string
+		methods = append(methods, goIntPtr(`Fa`, v.fa)...)
+		methods = append(methods, goIntPtr(`Fn`, v.fn)...)
+		methods = append(methods, goIntPtr(`Fs`, v.fs)...)
+		return goChain(goCall(`dsl.Circle`, radius), methods...), nil
+	case *Polyhedron:
+		args, err := goValues([]interface{}{v.points, v.faces})
+		if err != nil {
+			return "", err
+		}
+		code := goCall(`dsl.Polyhedron`, args...)
+		if v.convexity == nil {
+			return code, nil
+		}
+		convexity, err := goValue(v.convexity)
+		if err != nil {
+			return "", err
+		}
+		return goChain(code, goCall(`Convexity`, convexity)), nil
+	case *Children:
+		if v.idx == nil {
+			return `dsl.Children()`, nil
+		}
+		return goChain(`dsl.Children()`, fmt.Sprintf(`Index(%d)`, *v.idx)), nil
+	case *Translate:
+		arg, err := goValue(v.v)
+		if err != nil {
+			return "", err
+		}
+		return goStmts(`dsl.Translate`, v.children, arg)
+	case *Rotate:
+		arg, err := goValue(v.v)
+		if err != nil {
+			return "", err
+		}
+		return goStmts(`dsl.Rotate`, v.children, arg)
+	case *LinearExtrude:
+		args, err := goValues([]interface{}{v.height, v.center, v.convexity, v.twist, v.scale})
+		if err != nil {
+			return "", err
+		}
+		var methods []string
+		methods = append(methods, goIntPtr(`Fn`, v.fn)...)
+		if len(v.children) > 0 {
+			children, err := goStmts(`Add`, v.children)
+			if err != nil {
+				return "", err
+			}
+			methods = append(methods, children)
+		}
+		return goChain(goCall(`dsl.LinearExtrude`, args...), methods...), nil
+	case *Union:
+		return goStmts(`dsl.Union`, v.children)
+	case *Difference:
+		return goStmts(`dsl.Difference`, v.children)
+	case *Intersection:
+		return goStmts(`dsl.Intersection`, v.children)
+	case *Hull:
+		return goStmts(`dsl.Hull`, v.children)
+	default:
+		return "", fmt.Errorf(`failed to generate Go code: unsupported node type %T`, v)
+	}
+}
+
+func goCallStmt(c *Call) (string, error) {
+	args, err := goValues(c.parameters)
+	if err != nil {
+		return "", err
+	}
+
+	if len(c.children) > 0 {
+		// translate() and rotate() with a single argument are expressed
+		// using their dsl counterparts, which take the children directly
+		if len(args) == 1 {
+			switch c.name {
+			case `translate`:
+				return goStmts(`dsl.Translate`, c.children, args[0])
+			case `rotate`:
+				return goStmts(`dsl.Rotate`, c.children, args[0])
+			}
+		}
+		children, err := goStmts(`Add`, c.children)
+		if err != nil {
+			return "", err
+		}
+		return goChain(goCall(`dsl.Call`, append([]string{strconv.Quote(c.name)}, args...)...), children), nil
+	}
+
+	if fn, ok := dslCalls[c.name]; ok && (fn.nargs < 0 || fn.nargs == len(args)) {
+		return goCall(fn.fn, args...), nil
+	}
+	return goCall(`dsl.Call`, append([]string{strconv.Quote(c.name)}, args...)...), nil
+}
+
+func goIfStmt(ib *IfStmt) (string, error) {
+	cond, err := goValue(ib.cond)
+	if err != nil {
+		return "", err
+	}
+	body, err := goStmts(`Body`, ib.body)
+	if err != nil {
+		return "", err
+	}
+	methods := []string{body}
+	for _, elseif := range ib.elseifBlocks {
+		cond, err := goValue(elseif.cond)
+		if err != nil {
+			return "", err
+		}
+		block, err := goStmts(`AddElseIf`, elseif.body, cond)
+		if err != nil {
+			return "", err
+		}
+		methods = append(methods, block)
+	}
+	if ib.elseBlock != nil {
+		block, err := goStmts(`Else`, ib.elseBlock)
+		if err != nil {
+			return "", err
+		}
+		methods = append(methods, block)
+	}
+	return goChain(goCall(`ast.NewIfStmt`, cond), methods...), nil
+}
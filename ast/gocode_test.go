@@ -0,0 +1,78 @@
+package ast_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoCode(t *testing.T) {
+	const src = `include <lib.scad>
+width = 30;
+module part(size, h = 2) {
+  translate([size, 0, 0]) cube(size);
+  if (h > 1 && size < 10) sphere(r = -h / 2); else %cylinder(h = h, r = PI);
+}
+function area(r) = PI * r * r;
+for (x = [0:2:10]) part(x);
+`
+	stmts, err := openscad.Parse([]byte(src))
+	require.NoError(t, err, `openscad.Parse should succeed`)
+
+	code, err := ast.GoCode(stmts, ast.WithGoPackage(`models`), ast.WithGoFunc(`Part`))
+	require.NoError(t, err, `ast.GoCode should succeed`)
+	require.Equal(t, `package models
+
+import (
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/dsl"
+)
+
+// Part returns the statements that make up the model
+func Part() ast.Stmts {
+	return dsl.Stmts(
+		dsl.Include("lib.scad"),
+		dsl.Variable("width").Value(30),
+		dsl.Module("part").
+			Parameters(dsl.Variable("size"), dsl.Variable("h").Value(2)).
+			Actions(
+				dsl.Translate(
+					dsl.List(dsl.Variable("size"), 0, 0),
+					dsl.Call("cube", dsl.Variable("size")),
+				),
+				ast.NewIfStmt(
+					ast.NewBinaryOp(
+						"&&",
+						dsl.GT(dsl.Variable("h"), 1),
+						dsl.LT(dsl.Variable("size"), 10),
+					),
+				).
+					Body(
+						dsl.Call(
+							"sphere",
+							dsl.Variable("r").Value(dsl.Div(dsl.Negative(dsl.Variable("h")), 2)),
+						),
+					).
+					Else(
+						ast.NewUnaryOp(
+							"%",
+							dsl.Call(
+								"cylinder",
+								dsl.Variable("h").Value(dsl.Variable("h")),
+								dsl.Variable("r").Value(dsl.PI()),
+							),
+						),
+					),
+			),
+		dsl.Function("area").
+			Parameters(dsl.Variable("r")).
+			Body(dsl.Mul(dsl.Mul(dsl.PI(), dsl.Variable("r")), dsl.Variable("r"))),
+		dsl.For(dsl.LoopVar(dsl.Variable("x"), dsl.ForRange(0, 10).Increment(2))).Body(
+			dsl.Call("part", dsl.Variable("x")),
+		),
+	)
+}
+`, string(code))
+}
@@ -0,0 +1,231 @@
+package ast
+
+import (
+	"bytes"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// reservedWords are words that shortened names must not collide with.
+// The parser recognizes keywords by their prefix, so shortened names must
+// not start with any of them either.
+var reservedWords = []string{
+	`module`, `function`, `include`, `use`, `if`, `else`, `for`, `let`,
+	`each`, `assert`, `echo`, `true`, `false`, `undef`,
+}
+
+// shortName returns the i-th name in the sequence a, b, ..., z, aa, ab, ...
+func shortName(i int) string {
+	var b []byte
+	for n := i + 1; n > 0; n /= 26 {
+		n--
+		b = append([]byte{byte('a' + n%26)}, b...)
+	}
+	return string(b)
+}
+
+// minify prepares stmt to be emitted in minified form: variables bound in
+// local scopes (parameters, loop variables, let() variables and assignments
+// within blocks) are given the shortest names possible. Amalgamation banners
+// are removed later along with all other comments (see compact).
+//
+// File-level variables are never renamed, as they are visible from other
+// files, and are used by the OpenSCAD customizer. The parameters of
+// file-level modules and functions keep their names as well, so that
+// callers in other files can still pass them as named arguments. Names
+// that appear as named arguments or as the name of a function call are not
+// renamed either, as they may refer to a parameter or to a function literal
+// held in a variable.
+func minify(stmt Stmt) Stmt {
+	// every name that appears in the tree is reserved, so that shortened
+	// names can never capture a reference to a variable in an outer scope
+	reserved := make(map[string]struct{})
+	keep := make(map[string]struct{})
+	Walk(stmt, func(node interface{}) bool {
+		switch v := node.(type) {
+		case *Variable:
+			reserved[v.name] = struct{}{}
+		case *Module:
+			reserved[v.name] = struct{}{}
+		case *Function:
+			reserved[v.name] = struct{}{}
+		case *Call:
+			reserved[v.name] = struct{}{}
+			keep[v.name] = struct{}{}
+			for _, param := range v.parameters {
+				if named, ok := param.(*Variable); ok && named.value != nil {
+					keep[named.name] = struct{}{}
+				}
+			}
+		}
+		return true
+	})
+	for _, child := range toStmtList(stmt) {
+		var params []*Variable
+		switch v := child.(type) {
+		case *Module:
+			params = v.parameters
+		case *Function:
+			params = v.parameters
+		}
+		for _, param := range params {
+			keep[param.name] = struct{}{}
+		}
+	}
+
+	r := &renamer{
+		local: func(name string, s scope) string {
+			if _, ok := keep[name]; ok {
+				return name
+			}
+
+			// names visible in the current scope can not be reused
+			taken := make(map[string]struct{}, len(s))
+			for _, v := range s {
+				taken[v] = struct{}{}
+			}
+		CANDIDATES:
+			for i := 0; ; i++ {
+				candidate := shortName(i)
+				if _, ok := reserved[candidate]; ok {
+					continue
+				}
+				if _, ok := taken[candidate]; ok {
+					continue
+				}
+				for _, word := range reservedWords {
+					if strings.HasPrefix(candidate, word) {
+						continue CANDIDATES
+					}
+				}
+				return candidate
+			}
+		},
+	}
+	return r.stmt(stmt, scope{}, true)
+}
+
+func isWordChar(r rune) bool {
+	return r == '_' || r == '$' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// tokenPairs are pairs of characters that would be read as a different
+// token if the whitespace between them was removed
+var tokenPairs = map[string]struct{}{
+	`--`: {}, `++`: {}, `//`: {}, `/*`: {}, `*/`: {}, `==`: {},
+	`<=`: {}, `>=`: {}, `!=`: {}, `&&`: {}, `||`: {},
+}
+
+func needsSpace(prev, next rune) bool {
+	if isWordChar(prev) && isWordChar(next) {
+		return true
+	}
+	_, ok := tokenPairs[string([]rune{prev, next})]
+	return ok
+}
+
+// compact removes comments, as well as all whitespace that is not required
+// to separate tokens, from emitted OpenSCAD code. String literals, file
+// names in include/use directives and source map markers are left intact.
+func compact(src []byte) []byte {
+	var out bytes.Buffer
+	out.Grow(len(src))
+
+	var prev rune
+	space := false
+	for len(src) > 0 {
+		r, n := utf8.DecodeRune(src)
+
+		switch {
+		case r == markPosStart || r == markFileStart:
+			end := markPosEnd
+			if r == markFileStart {
+				end = markFileEnd
+			}
+			i := bytes.IndexRune(src, end)
+			if i < 0 {
+				i = len(src)
+			} else {
+				i += utf8.RuneLen(end)
+			}
+			out.Write(src[:i])
+			src = src[i:]
+			continue
+		case r == markFilePop:
+			out.Write(src[:n])
+			src = src[n:]
+			continue
+		case unicode.IsSpace(r):
+			space = true
+			src = src[n:]
+			continue
+		case bytes.HasPrefix(src, []byte(`//`)):
+			if i := bytes.IndexByte(src, '\n'); i >= 0 {
+				src = src[i:]
+			} else {
+				src = nil
+			}
+			space = true
+			continue
+		case bytes.HasPrefix(src, []byte(`/*`)):
+			if i := bytes.Index(src[2:], []byte(`*/`)); i >= 0 {
+				src = src[i+4:]
+			} else {
+				src = nil
+			}
+			space = true
+			continue
+		}
+
+		if space && needsSpace(prev, r) {
+			out.WriteByte(' ')
+		}
+		space = false
+
+		var i int
+		switch {
+		case r == '"':
+			// copy the string literal verbatim, including escapes
+			for i = n; i < len(src); i++ {
+				if src[i] == '\\' {
+					i++
+					continue
+				}
+				if src[i] == '"' {
+					i++
+					break
+				}
+			}
+		case r == '<' && (hasWordSuffix(out.Bytes(), `include`) || hasWordSuffix(out.Bytes(), `use`)):
+			// file names may contain spaces
+			if i = bytes.IndexByte(src, '>'); i < 0 {
+				i = len(src)
+			} else {
+				i++
+			}
+		default:
+			i = n
+		}
+		if i > len(src) {
+			i = len(src)
+		}
+		out.Write(src[:i])
+		src = src[i:]
+		prev, _ = utf8.DecodeLastRune(out.Bytes())
+	}
+	return out.Bytes()
+}
+
+// hasWordSuffix returns true if buf ends with the given word
+func hasWordSuffix(buf []byte, word string) bool {
+	if !bytes.HasSuffix(buf, []byte(word)) {
+		return false
+	}
+	rest := buf[:len(buf)-len(word)]
+	if len(rest) == 0 {
+		return true
+	}
+	r, _ := utf8.DecodeLastRune(rest)
+	return !isWordChar(r)
+}
@@ -0,0 +1,137 @@
+package ast_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+const minifySource = `include <my lib.scad>
+width = 10;
+module box(size, height = 2) {
+  inner = size - 1;
+  for (i = [0 : 2]) {
+    translate([i * inner, 0, 0]) cube([size, width, height]);
+  }
+  if (size > 1) {
+    cube(inner);
+  } else {
+    sphere(r = size);
+  }
+}
+function area(w, h) = let(a = w * h) a - -1;
+label = "hello  world";
+box(3, height = width);
+echo(area(1, 2));
+`
+
+// shape describes the structure of a tree, ignoring the names of variables
+func shape(node interface{}) []string {
+	var ret []string
+	ast.Walk(node, func(node interface{}) bool {
+		switch v := node.(type) {
+		case *ast.Call:
+			ret = append(ret, `call `+v.Name())
+		case *ast.Module:
+			ret = append(ret, `module `+v.Name())
+		case *ast.Function:
+			ret = append(ret, `function `+v.Name())
+		case float64, string:
+			ret = append(ret, fmt.Sprintf(`%#v`, v))
+		default:
+			ret = append(ret, fmt.Sprintf(`%T`, v))
+		}
+		return true
+	})
+	return ret
+}
+
+func TestMinify(t *testing.T) {
+	stmts, err := openscad.Parse([]byte(minifySource))
+	require.NoError(t, err, `openscad.Parse should succeed`)
+
+	out, err := ast.EmitString(stmts, ast.WithMinify())
+	require.NoError(t, err, `ast.EmitString should succeed`)
+
+	const expected = `include<my lib.scad>width=10;module box(size,height=2){b=size-1;for(c=[0:2]){translate([c*b,0,0])cube([size,width,height]);}if(size>1)cube(b);else sphere(r=size);}function area(w,h)=let(b=w*h)b- -1;label="hello  world";box(3,height=width);echo(area(1,2));`
+	require.Equal(t, expected, out)
+
+	reparsed, err := openscad.Parse([]byte(out))
+	require.NoError(t, err, `minified output should parse`)
+	require.Equal(t, shape(stmts), shape(reparsed), `minified output should have the same structure`)
+}
+
+func TestMinifyScopes(t *testing.T) {
+	testcases := []struct {
+		Name     string
+		Src      string
+		Expected string
+	}{
+		{
+			Name:     "file-level variables are kept",
+			Src:      "x = 1;\ny = x + 1;",
+			Expected: "x=1;y=x+1;",
+		},
+		{
+			Name:     "shortened names do not capture outer references",
+			Src:      "a = 1;\nf = function(x) x + a;",
+			Expected: "a=1;f=function(b)b+a;",
+		},
+		{
+			Name:     "nested scopes use distinct names",
+			Src:      "function f(x) = let(y = x * 2) [for (z = [0:y]) z + x];",
+			Expected: "function f(x)=let(a=x*2)[for(b=[0:a])b+x];",
+		},
+		{
+			Name:     "sibling scopes reuse names",
+			Src:      "f = function(x) x;\ng = function(y) y;",
+			Expected: "f=function(a)a;g=function(a)a;",
+		},
+		{
+			Name:     "special variables are kept",
+			Src:      "module m() { module n($fn = 10, r) { sphere(r); } n(); }",
+			Expected: "module m(){module n($fn=10,a){sphere(a);}n();}",
+		},
+		{
+			Name:     "parameters passed by name are kept",
+			Src:      "module m() { module n(size, h) { cube(size); } n(size = 2, 3); }",
+			Expected: "module m(){module n(size,a){cube(size);}n(size=2,3);}",
+		},
+		{
+			Name:     "parameters of file-level definitions are kept",
+			Src:      "module m(size, h = 1) { cube(size); }\nfunction f(x) = x;",
+			Expected: "module m(size,h=1){cube(size);}function f(x)=x;",
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			stmts, err := openscad.Parse([]byte(tc.Src))
+			require.NoError(t, err, `openscad.Parse should succeed`)
+
+			out, err := ast.EmitString(stmts, ast.WithMinify())
+			require.NoError(t, err, `ast.EmitString should succeed`)
+			require.Equal(t, tc.Expected, out)
+
+			reparsed, err := openscad.Parse([]byte(out))
+			require.NoError(t, err, `minified output should parse`)
+			require.Equal(t, shape(stmts), shape(reparsed), `minified output should have the same structure`)
+		})
+	}
+}
+
+func TestMinifyAmalgamation(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "include <lib.scad>\npart(2);",
+		"lib.scad":  "module part(size) {\n  cube(size);\n}",
+	})
+
+	var buf strings.Builder
+	require.NoError(t, ast.EmitFile("main.scad", &buf, ast.WithRegistry(registry), ast.WithAmalgamation(), ast.WithMinify()), `ast.EmitFile should succeed`)
+	require.Equal(t, "module part(size){cube(size);}part(2);", buf.String(), `banners should be stripped`)
+}
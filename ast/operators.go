@@ -4,10 +4,57 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"math"
+	"reflect"
 	"strings"
 	"unicode"
 )
 
+// unaryPrecedence is how tightly unary operators bind: tighter than any
+// binary operator except for `^`
+const unaryPrecedence = 7
+
+// operandPrecedence returns how tightly expr binds when it is used as an
+// operand, so that it can be put in parentheses where OpenSCAD would
+// otherwise group it differently
+func operandPrecedence(expr interface{}) int {
+	switch expr := expr.(type) {
+	case *BinaryOp:
+		return expr.BindPrecedence()
+	case *UnaryOp:
+		return unaryPrecedence
+	case *TernaryOp, *LetExpr, *FunctionLiteral, *EachExpr:
+		// these extend as far to the right as possible
+		return 0
+	}
+
+	// negative numbers are emitted with a leading minus sign
+	switch rv := reflect.ValueOf(expr); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if rv.Int() < 0 {
+			return unaryPrecedence
+		}
+	case reflect.Float32, reflect.Float64:
+		if math.Signbit(rv.Float()) {
+			return unaryPrecedence
+		}
+	}
+	return math.MaxInt
+}
+
+// emitOperand emits expr, in parentheses if paren is true
+func emitOperand(ctx *EmitContext, w io.Writer, expr interface{}, paren bool) error {
+	if !paren {
+		return emitExpr(ctx, w, expr)
+	}
+	fmt.Fprint(w, `(`)
+	if err := emitExpr(ctx, w, expr); err != nil {
+		return err
+	}
+	fmt.Fprint(w, `)`)
+	return nil
+}
+
 type Group struct {
 	expr interface{}
 }
@@ -18,6 +65,11 @@ func NewGroup(expr interface{}) *Group {
 	}
 }
 
+// Expr returns the expression inside the parentheses
+func (g *Group) Expr() interface{} {
+	return g.expr
+}
+
 func (g *Group) String() string {
 	var sb strings.Builder
 	if err := g.EmitExpr(newEmitContext(), &sb); err != nil {
@@ -36,6 +88,7 @@ func (g *Group) EmitExpr(ctx *EmitContext, w io.Writer) error {
 }
 
 type UnaryOp struct {
+	position
 	op   string
 	expr interface{}
 }
@@ -47,9 +100,17 @@ func NewUnaryOp(op string, expr interface{}) *UnaryOp {
 	}
 }
 
+func (op *UnaryOp) Op() string {
+	return op.op
+}
+
+func (op *UnaryOp) Expr() interface{} {
+	return op.expr
+}
+
 func (op *UnaryOp) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	fmt.Fprintf(w, `%s`, op.op)
-	if err := emitExpr(ctx, w, op.expr); err != nil {
+	if err := emitOperand(ctx, w, op.expr, operandPrecedence(op.expr) < unaryPrecedence); err != nil {
 		return err
 	}
 	return nil
@@ -109,14 +170,16 @@ func (op *BinaryOp) BindPrecedence() int {
 		return 1
 	case "&&":
 		return 2
-	case "==", "!=", "<", "<=", ">", ">=":
+	case "==", "!=":
 		return 3
-	case "+", "-":
+	case "<", "<=", ">", ">=":
 		return 4
-	case "*", "/", "%":
+	case "+", "-":
 		return 5
-	case "^":
+	case "*", "/", "%":
 		return 6
+	case "^":
+		return 8
 	}
 	return 0
 }
@@ -133,12 +196,23 @@ func (op *BinaryOp) Left() interface{} {
 	return op.left
 }
 
+// EmitExpr emits the operation, putting operands in parentheses where
+// OpenSCAD would otherwise group them differently. `^` groups from the
+// right, and all other operators group from the left.
 func (op *BinaryOp) EmitExpr(ctx *EmitContext, w io.Writer) error {
-	if err := emitExpr(ctx, w, op.left); err != nil {
+	precedence := op.BindPrecedence()
+	leftParen := operandPrecedence(op.left) < precedence
+	rightParen := operandPrecedence(op.right) <= precedence
+	if op.op == "^" {
+		leftParen = operandPrecedence(op.left) <= precedence
+		rightParen = operandPrecedence(op.right) < precedence
+	}
+
+	if err := emitOperand(ctx, w, op.left, leftParen); err != nil {
 		return fmt.Errorf("failed to emit left side of binary op: %v", err)
 	}
 	fmt.Fprintf(w, ` %s `, op.op)
-	if err := emitExpr(ctx, w, op.right); err != nil {
+	if err := emitOperand(ctx, w, op.right, rightParen); err != nil {
 		return fmt.Errorf("failed to emit right side of binary op: %v", err)
 	}
 	return nil
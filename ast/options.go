@@ -30,6 +30,18 @@ type EmitOption interface {
 	option.Interface
 }
 
+// WriteAllOption is an option that can be passed to WriteAll()
+type WriteAllOption interface {
+	writeAllOption()
+	option.Interface
+}
+
+type writeAllOption struct {
+	option.Interface
+}
+
+func (writeAllOption) writeAllOption() {}
+
 type emitWriteFileOption struct {
 	option.Interface
 }
@@ -48,6 +60,14 @@ func (emitFileWriteFileOption) writeFileOption() {}
 type optAmalgamationKey struct{}
 type optRegistryKey struct{}
 type optOutputDirKey struct{}
+type optCollisionPolicyKey struct{}
+type optRootNameKey struct{}
+type optTreeShakingKey struct{}
+type optSourceMapKey struct{}
+type optMinifyKey struct{}
+type optFormatStyleKey struct{}
+type optEmitOptionsKey struct{}
+type optManifestKey struct{}
 
 func WithAmalgamation() EmitFileWriteFileOption {
 	return &emitFileWriteFileOption{option.New(optAmalgamationKey{}, true)}
@@ -61,3 +81,147 @@ func WithRegistry(r *Registry) EmitFileWriteFileOption {
 func WithOutputDir(dir string) WriteFileOption {
 	return &emitWriteFileOption{option.New(optOutputDirKey{}, dir)}
 }
+
+// WithCollisionPolicy specifies how modules, functions and file-level
+// variables with the same name, defined in different files, are handled
+// during amalgamation.
+//
+// Files pulled in via `include` share a single namespace with the file that
+// includes them, so duplicates among them are part of the original program
+// and are left alone. Files pulled in via `use` have their own namespace,
+// and flattening them into a single file can make one definition silently
+// replace another. CollisionError reports colliding modules, functions and
+// variables alike. CollisionRename renames colliding modules and functions;
+// colliding variables are left as they are, as the variables of used files
+// are always prefixed with the name of their file (see WithAmalgamation).
+//
+// The default is CollisionIgnore.
+func WithCollisionPolicy(p CollisionPolicy) EmitFileWriteFileOption {
+	return &emitFileWriteFileOption{option.New(optCollisionPolicyKey{}, p)}
+}
+
+// WithTreeShaking drops modules, functions and variables pulled in from
+// other files that are not reachable from the statements that are
+// executed. This option only has an effect when used along with
+// WithAmalgamation.
+func WithTreeShaking() EmitFileWriteFileOption {
+	return &emitFileWriteFileOption{option.New(optTreeShakingKey{}, true)}
+}
+
+// sourceMapTarget is the value of the WithSourceMap option
+type sourceMapTarget struct {
+	dst  *SourceMap
+	file string
+}
+
+// WithSourceMap fills dst with a source map that maps the lines of the
+// emitted code back to the files they came from. This is most useful
+// along with WithAmalgamation, in order to translate the locations in
+// warnings and errors reported by OpenSCAD (see SourceMap.TranslateMessage).
+//
+// file is the name of the file that the emitted code is going to be
+// written to, as OpenSCAD refers to it by that name. It is recorded as
+// the File of the source map. If it is empty, locations in all files
+// are translated.
+//
+// Original locations are only available for code parsed with
+// openscad.WithPositions.
+func WithSourceMap(dst *SourceMap, file string) EmitFileWriteFileOption {
+	return &emitFileWriteFileOption{option.New(optSourceMapKey{}, sourceMapTarget{dst: dst, file: file})}
+}
+
+// WithMinify emits code that is as small as possible: whitespace that is
+// not required to separate tokens is removed, amalgamation banners are
+// dropped, and variables that are local to a module, function, let() or
+// for() are given short names. Variables at the file level keep their
+// names, so that they can still be set from the OpenSCAD customizer, and
+// so do the parameters of file-level modules and functions, so that they
+// can still be passed by name from other files.
+func WithMinify() EmitFileWriteFileOption {
+	return &emitFileWriteFileOption{option.New(optMinifyKey{}, true)}
+}
+
+// WithFormatStyle sets the style used to lay out the emitted code.
+// See FormatStyle for the available settings.
+func WithFormatStyle(style FormatStyle) EmitFileWriteFileOption {
+	return &emitFileWriteFileOption{option.New(optFormatStyleKey{}, style)}
+}
+
+// WithEmitOptions specifies the options that WriteAll() passes to Emit()
+// when emitting each file, such as WithFormatStyle.
+func WithEmitOptions(options ...EmitOption) WriteAllOption {
+	return &writeAllOption{option.New(optEmitOptionsKey{}, options)}
+}
+
+// WithManifest sets the name of the manifest that WriteAll() creates in
+// the output directory. The default is "SHA256SUMS". An empty name
+// disables the manifest.
+func WithManifest(name string) WriteAllOption {
+	return &writeAllOption{option.New(optManifestKey{}, name)}
+}
+
+// withRootName tells Emit the registered name of the statement being emitted
+func withRootName(name string) EmitOption {
+	return &emitWriteFileOption{option.New(optRootNameKey{}, name)}
+}
+
+// GoCodeOption is an option that can be passed to GoCode()
+type GoCodeOption interface {
+	goCodeOption()
+	option.Interface
+}
+
+// GoBindingsOption is an option that can be passed to GoBindings()
+type GoBindingsOption interface {
+	goBindingsOption()
+	option.Interface
+}
+
+// GoCodeBindingsOption is an option that can be passed to GoCode() and GoBindings()
+type GoCodeBindingsOption interface {
+	GoCodeOption
+	GoBindingsOption
+}
+
+type goCodeOption struct {
+	option.Interface
+}
+
+func (goCodeOption) goCodeOption() {}
+
+type goBindingsOption struct {
+	option.Interface
+}
+
+func (goBindingsOption) goBindingsOption() {}
+
+type goCodeBindingsOption struct {
+	option.Interface
+}
+
+func (goCodeBindingsOption) goCodeOption()     {}
+func (goCodeBindingsOption) goBindingsOption() {}
+
+type optGoPackageKey struct{}
+type optGoFuncKey struct{}
+type optGoIncludeKey struct{}
+
+// WithGoPackage sets the name of the package of the generated Go code.
+// The default is "main".
+func WithGoPackage(name string) GoCodeBindingsOption {
+	return &goCodeBindingsOption{option.New(optGoPackageKey{}, name)}
+}
+
+// WithGoFunc sets the name of the function that builds the tree in the
+// generated Go code. The default is "Model".
+func WithGoFunc(name string) GoCodeOption {
+	return &goCodeOption{option.New(optGoFuncKey{}, name)}
+}
+
+// WithGoInclude makes the calls created by the generated bindings depend
+// on the library through `include` rather than `use`. This is required
+// if the library has file-level variables that its modules rely on being
+// set by the caller.
+func WithGoInclude() GoBindingsOption {
+	return &goBindingsOption{option.New(optGoIncludeKey{}, true)}
+}
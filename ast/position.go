@@ -0,0 +1,44 @@
+package ast
+
+import "fmt"
+
+// Position is a location in a source file. Both Line and Column start
+// at 1. The zero value represents an unknown location.
+type Position struct {
+	Line   int
+	Column int
+}
+
+// IsValid returns true if the position refers to an actual location
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return `-`
+	}
+	return fmt.Sprintf(`%d:%d`, p.Line, p.Column)
+}
+
+// Positioner is implemented by nodes that can record where they
+// appeared in the source code. The parser only records positions
+// when asked to (see openscad.WithPositions)
+type Positioner interface {
+	Pos() Position
+	SetPos(Position)
+}
+
+type position struct {
+	pos Position
+}
+
+// Pos returns the location of the node in the source code
+func (p *position) Pos() Position {
+	return p.pos
+}
+
+// SetPos sets the location of the node in the source code
+func (p *position) SetPos(pos Position) {
+	p.pos = pos
+}
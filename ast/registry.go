@@ -1,12 +1,11 @@
 package ast
 
 import (
+	"sort"
 	"sync"
 )
 
-var globalRegistry = &Registry{
-	storage: make(map[string]Stmt),
-}
+var globalRegistry = NewRegistry()
 
 func Register(name string, s Stmt) error {
 	return globalRegistry.Register(name, s)
@@ -23,9 +22,19 @@ type Registry struct {
 	storage map[string]Stmt
 }
 
+// NewRegistry creates a new, empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		storage: make(map[string]Stmt),
+	}
+}
+
 func (r *Registry) Register(name string, s Stmt) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	if r.storage == nil {
+		r.storage = make(map[string]Stmt)
+	}
 	r.storage[name] = s
 	return nil
 }
@@ -40,6 +49,19 @@ func (r *Registry) Lookup(name string) (Stmt, bool) {
 	return s, ok
 }
 
+// Names returns the names of all registered entries, sorted in
+// lexicographical order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.storage))
+	for name := range r.storage {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 /*
 // Code generates code for the given name. By default it generates
 // regular files, but if you set the XXXX option, it can generate
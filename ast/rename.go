@@ -0,0 +1,432 @@
+package ast
+
+import "strings"
+
+// scope maps the names of variables bound in the current scope (parameters,
+// loop variables, let variables and local assignments) to the names that
+// they should be emitted as.
+type scope map[string]string
+
+// bind returns a new scope with the given names bound in it
+func (r *renamer) bind(s scope, names ...string) scope {
+	s2 := make(scope, len(s)+len(names))
+	for k, v := range s {
+		s2[k] = v
+	}
+	for _, name := range names {
+		if r.local != nil && !isSpecialVariable(name) {
+			s2[name] = r.local(name, s2)
+		} else {
+			s2[name] = name
+		}
+	}
+	return s2
+}
+
+// renamer creates copies of the tree with identifiers replaced, taking
+// OpenSCAD's scoping rules into account: a reference to a variable that is
+// bound by an enclosing parameter list, let(), for() or local assignment
+// is never treated as a reference to a file-level variable.
+//
+// Special variables (those starting with `$`) are dynamically scoped, and
+// are never renamed.
+type renamer struct {
+	// variables maps file-level variable names to their new names.
+	// Both assignments at the file level and free references are renamed.
+	variables map[string]string
+	// freeVariable, if non-nil, is called for every reference to a variable
+	// that is not bound in the current scope, including special variables.
+	freeVariable func(string)
+	// calledFunction and calledModule, if non-nil, are called for every
+	// function call and module instantiation, respectively. Calls to names
+	// bound in the current scope (parameters holding function literals)
+	// are not reported.
+	calledFunction func(string)
+	calledModule   func(string)
+	// functions and modules map the names of functions and modules to
+	// their new names at call sites. Calls to names that are not listed in
+	// functions are renamed according to variables instead, as they may
+	// refer to a variable holding a function literal.
+	functions map[string]string
+	modules   map[string]string
+	// definedFunctions and definedModules map the names of functions and
+	// modules to their new names at their definitions.
+	definedFunctions map[string]string
+	definedModules   map[string]string
+	// local, if non-nil, returns the name that a variable bound in a
+	// local scope should be renamed to. s holds the bindings that are
+	// visible at that point.
+	local func(name string, s scope) string
+}
+
+func renameWith(m map[string]string, name string) string {
+	if newName, ok := m[name]; ok {
+		return newName
+	}
+	return name
+}
+
+// callee returns the name to emit for a call to a function that is not
+// bound in the current scope. Such a name refers to a function or, if there
+// is none, to a file-level variable holding a function literal.
+func (r *renamer) callee(name string) string {
+	if newName, ok := r.functions[name]; ok {
+		return newName
+	}
+	if newName, ok := r.variables[name]; ok {
+		return newName
+	}
+	return name
+}
+
+func isSpecialVariable(name string) bool {
+	return strings.HasPrefix(name, `$`)
+}
+
+func (r *renamer) ref(name string, s scope) string {
+	if newName, ok := s[name]; ok {
+		return newName
+	}
+	if r.freeVariable != nil {
+		r.freeVariable(name)
+	}
+	if isSpecialVariable(name) {
+		return name
+	}
+	if newName, ok := r.variables[name]; ok {
+		return newName
+	}
+	return name
+}
+
+// declare returns the name to use for an assignment to name. Assignments
+// at the top level of a file are file-level variables, all others have
+// already been bound to the local scope by the time this is called.
+func (r *renamer) declare(name string, s scope, top bool) string {
+	if isSpecialVariable(name) {
+		return name
+	}
+	if !top {
+		if newName, ok := s[name]; ok {
+			return newName
+		}
+		return name
+	}
+	if newName, ok := r.variables[name]; ok {
+		return newName
+	}
+	return name
+}
+
+// assignedNames returns the names of variables assigned to in the given
+// list of statements. These are visible throughout the entire block
+func assignedNames(stmts []Stmt) []string {
+	var names []string
+	for _, stmt := range stmts {
+		switch v := stmt.(type) {
+		case *Variable:
+			names = append(names, v.name)
+		case *Declare:
+			names = append(names, v.v.name)
+		}
+	}
+	return names
+}
+
+func (r *renamer) stmtList(stmts []Stmt, s scope, top bool) []Stmt {
+	if stmts == nil {
+		return nil
+	}
+	if !top {
+		s = r.bind(s, assignedNames(stmts)...)
+	}
+	ret := make([]Stmt, len(stmts))
+	for i, stmt := range stmts {
+		ret[i] = r.stmt(stmt, s, top)
+	}
+	return ret
+}
+
+// block renames a list of children, which forms its own scope
+func (r *renamer) block(stmts []Stmt, s scope) []Stmt {
+	return r.stmtList(stmts, s, false)
+}
+
+func (r *renamer) params(params []*Variable, s scope) ([]*Variable, scope) {
+	if params == nil {
+		return nil, s
+	}
+	names := make([]string, len(params))
+	for i, p := range params {
+		names[i] = p.name
+	}
+	inner := r.bind(s, names...)
+
+	ret := make([]*Variable, len(params))
+	for i, p := range params {
+		ret[i] = &Variable{position: p.position, name: inner[p.name], value: r.expr(p.value, s)}
+	}
+	return ret, inner
+}
+
+// sequential renames variables that are bound one after the other, such
+// as in let() and for(), where each variable can refer to the previous ones
+func (r *renamer) sequential(vars []*Variable, s scope) ([]*Variable, scope) {
+	if vars == nil {
+		return nil, s
+	}
+	ret := make([]*Variable, len(vars))
+	for i, v := range vars {
+		value := r.expr(v.value, s)
+		s = r.bind(s, v.name)
+		ret[i] = &Variable{position: v.position, name: s[v.name], value: value}
+	}
+	return ret, s
+}
+
+func (r *renamer) loopVars(loopVars []*LoopVar, s scope) ([]*LoopVar, scope) {
+	ret := make([]*LoopVar, len(loopVars))
+	for i, lv := range loopVars {
+		expr := r.expr(lv.expr, s)
+		s = r.bind(s, lv.variable.name)
+		ret[i] = &LoopVar{variable: &Variable{position: lv.variable.position, name: s[lv.variable.name]}, expr: expr}
+	}
+	return ret, s
+}
+
+func (r *renamer) stmt(stmt Stmt, s scope, top bool) Stmt {
+	switch v := stmt.(type) {
+	case Stmts:
+		return Stmts(r.stmtList(v, s, top))
+	case *BareBlock:
+		return &BareBlock{children: r.block(v.children, s)}
+	case *Variable:
+		return &Variable{position: v.position, name: r.declare(v.name, s, top), value: r.expr(v.value, s)}
+	case *Declare:
+		//nolint:forcetypeassert
+		return &Declare{v: r.stmt(v.v, s, top).(*Variable)}
+	case *Module:
+		params, inner := r.params(v.parameters, s)
+		return &Module{position: v.position, name: renameWith(r.definedModules, v.name), parameters: params, children: r.block(v.children, inner)}
+	case *Function:
+		params, inner := r.params(v.parameters, s)
+		return &Function{position: v.position, name: renameWith(r.definedFunctions, v.name), parameters: params, body: r.expr(v.body, inner)}
+	case *Call:
+		if r.calledModule != nil {
+			r.calledModule(v.name)
+		}
+		return &Call{position: v.position, name: renameWith(r.modules, v.name), parameters: r.exprList(v.parameters, s), children: r.block(v.children, s)}
+	case *LetBlock:
+		vars, inner := r.sequential(v.variables, s)
+		return &LetBlock{position: v.position, variables: vars, children: r.block(v.children, inner)}
+	case *ForBlock:
+		loopVars, inner := r.loopVars(v.loopVars, s)
+		return &ForBlock{position: v.position, loopVars: loopVars, children: r.block(v.children, inner)}
+	case *IfStmt:
+		ret := &IfStmt{
+			position:  v.position,
+			cond:      r.expr(v.cond, s),
+			body:      r.block(v.body, s),
+			elseBlock: r.block(v.elseBlock, s),
+		}
+		for _, elseif := range v.elseifBlocks {
+			ret.elseifBlocks = append(ret.elseifBlocks, &ElseIfStmt{
+				cond: r.expr(elseif.cond, s),
+				body: r.block(elseif.body, s),
+			})
+		}
+		return ret
+	case *UnaryOp:
+		if child, ok := v.expr.(Stmt); ok {
+			return &UnaryOp{position: v.position, op: v.op, expr: r.stmt(child, s, top)}
+		}
+		//nolint:forcetypeassert
+		return r.expr(v, s).(Stmt)
+	case *Translate:
+		return &Translate{v: r.expr(v.v, s), children: r.block(v.children, s)}
+	case *Rotate:
+		return &Rotate{v: r.expr(v.v, s), children: r.block(v.children, s)}
+	case *LinearExtrude:
+		return &LinearExtrude{
+			height:    r.expr(v.height, s),
+			center:    r.expr(v.center, s),
+			convexity: r.expr(v.convexity, s),
+			twist:     r.expr(v.twist, s),
+			scale:     r.expr(v.scale, s),
+			fn:        v.fn,
+			children:  r.block(v.children, s),
+		}
+	case *Union:
+		return &Union{r.noArgBlock(&v.noArgBlock, s)}
+	case *Difference:
+		return &Difference{r.noArgBlock(&v.noArgBlock, s)}
+	case *Intersection:
+		return &Intersection{r.noArgBlock(&v.noArgBlock, s)}
+	case *Hull:
+		return &Hull{r.noArgBlock(&v.noArgBlock, s)}
+	case *Polygon:
+		return &Polygon{points: r.expr(v.points, s), paths: r.expr(v.paths, s)}
+	case *Cube:
+		return &Cube{
+			width:  r.expr(v.width, s),
+			depth:  r.expr(v.depth, s),
+			height: r.expr(v.height, s),
+			center: v.center,
+			fn:     v.fn,
+		}
+	case *Cylinder:
+		return &Cylinder{
+			height:  r.expr(v.height, s),
+			radius1: r.expr(v.radius1, s),
+			radius2: r.expr(v.radius2, s),
+			center:  v.center,
+			fa:      v.fa,
+			fs:      v.fs,
+			fn:      v.fn,
+		}
+	case *Sphere:
+		return &Sphere{radius: r.expr(v.radius, s), fa: v.fa, fs: v.fs, fn: v.fn}
+	case *Circle:
+		return &Circle{radius: r.expr(v.radius, s), fa: v.fa, fs: v.fs, fn: v.fn}
+	case *Polyhedron:
+		return &Polyhedron{
+			points:    r.expr(v.points, s),
+			faces:     r.expr(v.faces, s),
+			convexity: r.expr(v.convexity, s),
+		}
+	case Expr:
+		if ret, ok := r.expr(v, s).(Stmt); ok {
+			return ret
+		}
+	}
+	return stmt
+}
+
+func (r *renamer) noArgBlock(b *noArgBlock, s scope) noArgBlock {
+	return noArgBlock{name: b.name, children: r.block(b.children, s)}
+}
+
+func (r *renamer) exprList(list []interface{}, s scope) []interface{} {
+	if list == nil {
+		return nil
+	}
+	ret := make([]interface{}, len(list))
+	for i, elem := range list {
+		ret[i] = r.expr(elem, s)
+	}
+	return ret
+}
+
+func (r *renamer) expr(expr interface{}, s scope) interface{} {
+	switch v := expr.(type) {
+	case nil:
+		return nil
+	case *Variable:
+		if v.value != nil {
+			// named argument: the name refers to the parameter of the
+			// callee, not to a variable in this scope
+			return &Variable{position: v.position, name: v.name, value: r.expr(v.value, s)}
+		}
+		return &Variable{position: v.position, name: r.ref(v.name, s)}
+	case *Declare:
+		return &Declare{v: &Variable{position: v.v.position, name: r.ref(v.v.name, s), value: r.expr(v.v.value, s)}}
+	case []interface{}:
+		return r.exprList(v, s)
+	case *Call:
+		name, ok := s[v.name]
+		if !ok {
+			if r.calledFunction != nil {
+				r.calledFunction(v.name)
+			}
+			name = r.callee(v.name)
+		}
+		return &Call{position: v.position, name: name, parameters: r.exprList(v.parameters, s), children: r.block(v.children, s)}
+	case *Index:
+		return &Index{expr: r.expr(v.expr, s), index: r.expr(v.index, s)}
+	case *Group:
+		return &Group{expr: r.expr(v.expr, s)}
+	case *UnaryOp:
+		return &UnaryOp{position: v.position, op: v.op, expr: r.expr(v.expr, s)}
+	case *BinaryOp:
+		return &BinaryOp{op: v.op, left: r.expr(v.left, s), right: r.expr(v.right, s)}
+	case *TernaryOp:
+		return &TernaryOp{
+			condition: r.expr(v.condition, s),
+			trueExpr:  r.expr(v.trueExpr, s),
+			falseExpr: r.expr(v.falseExpr, s),
+		}
+	case *IfExpr:
+		return &IfExpr{cond: r.expr(v.cond, s), body: r.expr(v.body, s), elseBody: r.expr(v.elseBody, s)}
+	case *EachExpr:
+		return &EachExpr{expr: r.expr(v.expr, s)}
+	case *ForRange:
+		return &ForRange{start: r.expr(v.start, s), end: r.expr(v.end, s), increment: r.expr(v.increment, s)}
+	case *LetExpr:
+		vars, inner := r.sequential(v.variables, s)
+		return &LetExpr{variables: vars, expr: r.expr(v.expr, inner)}
+	case *ForExpr:
+		loopVars, inner := r.loopVars(v.loopVars, s)
+		return &ForExpr{loopVars: loopVars, expr: r.expr(v.expr, inner)}
+	case *LookupStmt:
+		return &LookupStmt{key: r.expr(v.key, s), values: r.expr(v.values, s)}
+	case *FunctionLiteral:
+		params, inner := r.params(v.parameters, s)
+		return &FunctionLiteral{parameters: params, body: r.expr(v.body, inner)}
+	case *Function:
+		params, inner := r.params(v.parameters, s)
+		return &Function{position: v.position, name: renameWith(r.definedFunctions, v.name), parameters: params, body: r.expr(v.body, inner)}
+	case Stmt:
+		return r.stmt(v, s, false)
+	}
+	return expr
+}
+
+// references holds the names of the variables, functions and modules
+// that a piece of code refers to.
+type references struct {
+	variables map[string]struct{}
+	functions map[string]struct{}
+	modules   map[string]struct{}
+}
+
+// collectReferences returns the names of the variables that are referenced
+// in node but are not bound within it, along with the names of all functions
+// and modules that it calls.
+func collectReferences(node interface{}) *references {
+	refs := &references{
+		variables: make(map[string]struct{}),
+		functions: make(map[string]struct{}),
+		modules:   make(map[string]struct{}),
+	}
+	r := &renamer{
+		freeVariable: func(name string) {
+			refs.variables[name] = struct{}{}
+		},
+		calledFunction: func(name string) {
+			refs.functions[name] = struct{}{}
+		},
+		calledModule: func(name string) {
+			refs.modules[name] = struct{}{}
+		},
+	}
+	if stmt, ok := node.(Stmt); ok {
+		r.stmt(stmt, scope{}, true)
+	} else {
+		r.expr(node, scope{})
+	}
+	return refs
+}
+
+// referencedVariables returns the names of the file-level variables that
+// node may refer to. Besides free variable references, this includes the
+// names of called functions that are not listed in functions, as they may
+// refer to a variable holding a function literal.
+func referencedVariables(node interface{}, functions map[string]string) map[string]struct{} {
+	refs := collectReferences(node)
+	for name := range refs.functions {
+		if _, ok := functions[name]; !ok {
+			refs.variables[name] = struct{}{}
+		}
+	}
+	return refs.variables
+}
@@ -36,6 +36,16 @@ func NewPoint2D(x, y interface{}) *Point2D {
 	}
 }
 
+// X returns the X coordinate of the point
+func (p *Point2D) X() interface{} {
+	return p.x
+}
+
+// Y returns the Y coordinate of the point
+func (p *Point2D) Y() interface{} {
+	return p.y
+}
+
 func (p *Point2D) EmitExpr(ctx *EmitContext, w io.Writer) error {
 	fmt.Fprintf(w, `[%#v, %#v]`, p.x, p.y)
 	return nil
@@ -68,7 +78,7 @@ func (p *Polygon) EmitStmt(ctx *EmitContext, w io.Writer) error {
 
 func emitCenter(w io.Writer, ptr *bool) {
 	if ptr != nil && *ptr {
-		fmt.Fprintf(w, `, $fn=%t`, *ptr)
+		fmt.Fprintf(w, `, center=%t`, *ptr)
 	}
 }
 
@@ -0,0 +1,178 @@
+package ast
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Markers are embedded in the emitted code while generating a source map,
+// and are removed before the code is written out. They use characters from
+// the Unicode private use area, which do not appear in OpenSCAD code.
+const (
+	markPosStart  = '\uE000'
+	markPosEnd    = '\uE001'
+	markFileStart = '\uE002'
+	markFileEnd   = '\uE003'
+	markFilePop   = '\uE004'
+)
+
+// SourceMap maps lines in emitted OpenSCAD code back to the locations
+// in the source files that they were generated from.
+//
+// Locations in the source files are only known if the files were parsed
+// with position information (see openscad.WithPositions).
+type SourceMap struct {
+	Version int `json:"version"`
+	// File is the name of the file that the emitted code was written to.
+	// If it is empty, TranslateMessage translates locations in all files.
+	File     string     `json:"file"`
+	Mappings []*Mapping `json:"mappings"`
+}
+
+// Mapping associates a location in the emitted code with a location in
+// one of the source files. Source is the name that the file was registered
+// under. Mappings without an original location mark the beginning of a
+// region that was generated from the file, such as the banners emitted
+// during amalgamation.
+type Mapping struct {
+	Line           int    `json:"line"`
+	Column         int    `json:"column"`
+	Source         string `json:"source"`
+	OriginalLine   int    `json:"originalLine,omitempty"`
+	OriginalColumn int    `json:"originalColumn,omitempty"`
+}
+
+// Lookup returns the mapping that applies to the given line in the emitted
+// code: the first mapping on that line, or the closest mapping before it.
+// The second return value is false if the original location is not known.
+func (m *SourceMap) Lookup(line int) (*Mapping, bool) {
+	var found *Mapping
+	for _, mapping := range m.Mappings {
+		if mapping.Line > line {
+			break
+		}
+		if found != nil && found.Line == line && found.OriginalLine != 0 {
+			// already found the first mapping on this line
+			continue
+		}
+		found = mapping
+	}
+	if found == nil || found.OriginalLine == 0 {
+		return nil, false
+	}
+	return found, true
+}
+
+var locationPattern = regexp.MustCompile(`in file ("?)([^",]+)("?), line (\d+)`)
+
+// TranslateMessage rewrites the locations in messages produced by OpenSCAD,
+// such as `WARNING: ... in file out.scad, line 42`, so that they point to the
+// original file and line. Locations that refer to files other than File
+// (compared by base name), or that can not be mapped, are left untouched.
+func (m *SourceMap) TranslateMessage(msg string) string {
+	return locationPattern.ReplaceAllStringFunc(msg, func(s string) string {
+		parts := locationPattern.FindStringSubmatch(s)
+		if m.File != "" && filepath.Base(parts[2]) != filepath.Base(m.File) {
+			return s
+		}
+		line, err := strconv.Atoi(parts[4])
+		if err != nil {
+			return s
+		}
+		mapping, ok := m.Lookup(line)
+		if !ok {
+			return s
+		}
+		return fmt.Sprintf(`in file %s%s%s, line %d`, parts[1], mapping.Source, parts[3], mapping.OriginalLine)
+	})
+}
+
+// emitPosition writes a marker for the location of stmt, if a source
+// map is being generated
+func emitPosition(ctx *EmitContext, w io.Writer, stmt Stmt) {
+	if !ctx.sourceMap {
+		return
+	}
+	p, ok := stmt.(Positioner)
+	if !ok {
+		return
+	}
+	if pos := p.Pos(); pos.IsValid() {
+		fmt.Fprintf(w, "%c%d:%d%c", markPosStart, pos.Line, pos.Column, markPosEnd)
+	}
+}
+
+// extractSourceMap removes the markers from src, writes the result to
+// dst, and records the mappings that the markers describe in sm. Code
+// outside of any amalgamated file is attributed to root.
+func extractSourceMap(dst io.Writer, src []byte, root string, sm *SourceMap) error {
+	var out bytes.Buffer
+	out.Grow(len(src))
+
+	files := []string{root}
+	line, column := 1, 1
+	var pending *Mapping
+	for len(src) > 0 {
+		r, n := utf8.DecodeRune(src)
+		src = src[n:]
+
+		switch r {
+		case markPosStart:
+			end := bytes.IndexRune(src, markPosEnd)
+			if end < 0 {
+				return fmt.Errorf(`unterminated position marker`)
+			}
+			var origLine, origColumn int
+			if _, err := fmt.Sscanf(string(src[:end]), `%d:%d`, &origLine, &origColumn); err != nil {
+				return fmt.Errorf(`failed to parse position marker: %w`, err)
+			}
+			src = src[end+utf8.RuneLen(markPosEnd):]
+			// an enclosing statement takes precedence
+			if pending == nil || pending.OriginalLine == 0 {
+				pending = &Mapping{Source: files[len(files)-1], OriginalLine: origLine, OriginalColumn: origColumn}
+			}
+			continue
+		case markFileStart:
+			end := bytes.IndexRune(src, markFileEnd)
+			if end < 0 {
+				return fmt.Errorf(`unterminated file marker`)
+			}
+			files = append(files, string(src[:end]))
+			src = src[end+utf8.RuneLen(markFileEnd):]
+			pending = &Mapping{Source: files[len(files)-1]}
+			continue
+		case markFilePop:
+			if len(files) > 1 {
+				files = files[:len(files)-1]
+			}
+			pending = &Mapping{Source: files[len(files)-1]}
+			continue
+		}
+
+		if pending != nil && !unicode.IsSpace(r) {
+			pending.Line = line
+			pending.Column = column
+			sm.Mappings = append(sm.Mappings, pending)
+			pending = nil
+		}
+
+		out.WriteRune(r)
+		if r == '\n' {
+			line++
+			column = 1
+		} else {
+			column++
+		}
+	}
+
+	if _, err := out.WriteTo(dst); err != nil {
+		return fmt.Errorf(`failed to write output: %w`, err)
+	}
+	return nil
+}
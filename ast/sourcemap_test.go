@@ -0,0 +1,137 @@
+package ast_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceMap(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "include <lib.scad>\nuse <util.scad>\n\nx = 1;\nif (x > 0) {\n  part(x);\n}\n",
+		"lib.scad":  "module part(n) {\n  cube(n);\n  sphere(n);\n}\n",
+		"util.scad": "k = 3;\nfunction f(a) = a * k;\n",
+	}, openscad.WithPositions())
+
+	var sm ast.SourceMap
+	var buf strings.Builder
+	require.NoError(t, ast.EmitFile("main.scad", &buf, ast.WithRegistry(registry), ast.WithAmalgamation(), ast.WithSourceMap(&sm, "out.scad")), `ast.EmitFile should succeed`)
+
+	const expected = `
+
+// START include lib.scad
+
+module part(n)
+{
+  cube(n);
+  sphere(n);
+}
+
+// END include lib.scad
+
+
+// START use util.scad
+
+util__k = 3;
+function f(a) = a * util__k;
+// END use util.scad
+
+x = 1;
+if (x > 0)
+  part(x);`
+	require.Equal(t, expected, buf.String(), `markers should not appear in the output`)
+	require.Equal(t, "out.scad", sm.File)
+
+	testcases := []struct {
+		Line   int
+		Source string
+		Orig   int
+		Found  bool
+	}{
+		{Line: 1},
+		{Line: 3},
+		{Line: 5, Source: "lib.scad", Orig: 1, Found: true},
+		{Line: 6, Source: "lib.scad", Orig: 1, Found: true},
+		{Line: 8, Source: "lib.scad", Orig: 3, Found: true},
+		{Line: 11},
+		{Line: 16, Source: "util.scad", Orig: 1, Found: true},
+		{Line: 17, Source: "util.scad", Orig: 2, Found: true},
+		{Line: 20, Source: "main.scad", Orig: 4, Found: true},
+		{Line: 22, Source: "main.scad", Orig: 6, Found: true},
+	}
+	for _, tc := range testcases {
+		mapping, ok := sm.Lookup(tc.Line)
+		require.Equal(t, tc.Found, ok, `line %d should be mapped: %t`, tc.Line, tc.Found)
+		if !ok {
+			continue
+		}
+		require.Equal(t, tc.Source, mapping.Source, `source for line %d`, tc.Line)
+		require.Equal(t, tc.Orig, mapping.OriginalLine, `original line for line %d`, tc.Line)
+	}
+
+	t.Run("translate messages", func(t *testing.T) {
+		require.Equal(t,
+			`WARNING: Ignoring unknown variable "y" in file lib.scad, line 3`,
+			sm.TranslateMessage(`WARNING: Ignoring unknown variable "y" in file out.scad, line 8`))
+		require.Equal(t,
+			`ERROR: Parser error in file "util.scad", line 2: syntax error`,
+			sm.TranslateMessage(`ERROR: Parser error in file "/tmp/out.scad", line 17: syntax error`))
+		require.Equal(t,
+			`WARNING: something in file other.scad, line 8`,
+			sm.TranslateMessage(`WARNING: something in file other.scad, line 8`),
+			`other files should be left alone`)
+		require.Equal(t,
+			`WARNING: something in file main.scad, line 8`,
+			sm.TranslateMessage(`WARNING: something in file main.scad, line 8`),
+			`the root file is not the output file`)
+		require.Equal(t,
+			`WARNING: something in file out.scad, line 11`,
+			sm.TranslateMessage(`WARNING: something in file out.scad, line 11`),
+			`unmapped lines should be left alone`)
+	})
+
+	t.Run("json round trip", func(t *testing.T) {
+		buf, err := json.Marshal(&sm)
+		require.NoError(t, err, `json.Marshal should succeed`)
+
+		var decoded ast.SourceMap
+		require.NoError(t, json.Unmarshal(buf, &decoded), `json.Unmarshal should succeed`)
+		require.Equal(t, sm, decoded)
+	})
+}
+
+func TestSourceMapLookup(t *testing.T) {
+	sm := ast.SourceMap{
+		File: "main.scad",
+		Mappings: []*ast.Mapping{
+			{Line: 2, Column: 0},
+			{Line: 2, Column: 4, Source: "lib.scad", OriginalLine: 7},
+			{Line: 2, Column: 9, Source: "lib.scad", OriginalLine: 8},
+			{Line: 4, Column: 0, Source: "main.scad", OriginalLine: 1},
+		},
+	}
+
+	testcases := []struct {
+		Line  int
+		Orig  int
+		Found bool
+	}{
+		{Line: 1},
+		{Line: 2, Orig: 7, Found: true},
+		{Line: 3, Orig: 8, Found: true},
+		{Line: 4, Orig: 1, Found: true},
+		{Line: 5, Orig: 1, Found: true},
+	}
+	for _, tc := range testcases {
+		mapping, ok := sm.Lookup(tc.Line)
+		require.Equal(t, tc.Found, ok, `line %d should be mapped: %t`, tc.Line, tc.Found)
+		if !ok {
+			continue
+		}
+		require.Equal(t, tc.Orig, mapping.OriginalLine, `original line for line %d`, tc.Line)
+	}
+}
@@ -0,0 +1,96 @@
+package ast
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// BracePolicy specifies when the children of a statement are enclosed
+// in braces.
+type BracePolicy int
+
+const (
+	// BracesAsNeeded only uses braces when there is more than one child.
+	BracesAsNeeded BracePolicy = iota
+	// BracesAlways uses braces even for a single child.
+	BracesAlways
+)
+
+// BlankLinePolicy specifies when blank lines are inserted between the
+// statements in a block.
+type BlankLinePolicy int
+
+const (
+	// BlankLinesBetweenKinds inserts a blank line between statements of
+	// different kinds, such as between assignments and module calls.
+	BlankLinesBetweenKinds BlankLinePolicy = iota
+	// BlankLinesNever never inserts blank lines.
+	BlankLinesNever
+	// BlankLinesAlways inserts a blank line between every statement.
+	BlankLinesAlways
+)
+
+// FormatStyle controls the layout of emitted code. The zero value is the
+// default style.
+type FormatStyle struct {
+	// IndentWidth is the number of spaces used per level of indentation.
+	// Zero means 2.
+	IndentWidth int
+	// UseTabs indents with a single tab per level instead of spaces.
+	// For the purpose of MaxWidth, a tab is as wide as IndentWidth.
+	UseTabs bool
+	// MaxWidth is the line width that lists and call arguments should fit
+	// in. Those that do not fit are wrapped with one element per line.
+	// When zero, lists with more than 3 elements are always wrapped, and
+	// call arguments are never wrapped.
+	MaxWidth int
+	// Braces specifies when children are enclosed in braces. Module
+	// definitions always use braces.
+	Braces BracePolicy
+	// BlankLines specifies where blank lines go between statements in
+	// a block.
+	BlankLines BlankLinePolicy
+	// SpaceAroundEquals puts spaces around `=` in named arguments and
+	// default parameter values, as in `cube(size = 10)`.
+	SpaceAroundEquals bool
+}
+
+func (s *FormatStyle) indentWidth() int {
+	if s.IndentWidth <= 0 {
+		return 2
+	}
+	return s.IndentWidth
+}
+
+func (s *FormatStyle) indentUnit() string {
+	if s.UseTabs {
+		return "\t"
+	}
+	return strings.Repeat(` `, s.indentWidth())
+}
+
+// width returns the width of text, which does not span lines. Tabs are as
+// wide as IndentWidth.
+func (s *FormatStyle) width(text string) int {
+	return utf8.RuneCountInString(text) + strings.Count(text, "\t")*(s.indentWidth()-1)
+}
+
+// fits returns true if text can be placed at the given column without
+// going over the maximum width
+func (s *FormatStyle) fits(column int, text string) bool {
+	if strings.ContainsRune(text, '\n') {
+		return false
+	}
+	return column+s.width(text) <= s.MaxWidth
+}
+
+func (s *FormatStyle) blankLineBetween(prev, cur string) bool {
+	switch s.BlankLines {
+	case BlankLinesNever:
+		return false
+	case BlankLinesAlways:
+		return true
+	default:
+		return prev != cur
+	}
+}
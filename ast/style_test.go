@@ -0,0 +1,160 @@
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatStyle(t *testing.T) {
+	const src = `module part(size, h = 2) {
+  x = size * 2;
+  translate([x, 0, 0]) cube(size);
+  cylinder(r = size, h = h);
+}
+part(1, h = 3);
+points = [1, 2, 3, 4];
+polyhedron(points = [[0, 0, 0], [10, 0, 0], [0, 10, 0]], faces = [[0, 1, 2]]);
+`
+	testcases := []struct {
+		Name     string
+		Style    ast.FormatStyle
+		Expected string
+	}{
+		{
+			Name: "default",
+			Expected: `
+module part(size, h=2)
+{
+  x = size * 2;
+
+  translate([x, 0, 0])
+    cube(size);
+  cylinder(r=size, h=h);
+}
+
+part(1, h=3);
+points = [
+  1, 
+  2, 
+  3, 
+  4
+];
+polyhedron(points=[[0, 0, 0], [10, 0, 0], [0, 10, 0]], faces=[[0, 1, 2]]);`,
+		},
+		{
+			Name:  "tabs, braces, no blank lines, spaced equals",
+			Style: ast.FormatStyle{UseTabs: true, Braces: ast.BracesAlways, BlankLines: ast.BlankLinesNever, SpaceAroundEquals: true},
+			Expected: `
+module part(size, h = 2)
+{
+	x = size * 2;
+	translate([x, 0, 0])
+	{
+		cube(size);
+	}
+	cylinder(r = size, h = h);
+}
+
+part(1, h = 3);
+points = [
+	1, 
+	2, 
+	3, 
+	4
+];
+polyhedron(points = [[0, 0, 0], [10, 0, 0], [0, 10, 0]], faces = [[0, 1, 2]]);`,
+		},
+		{
+			Name:  "max width, four spaces, blank lines everywhere",
+			Style: ast.FormatStyle{IndentWidth: 4, MaxWidth: 50, BlankLines: ast.BlankLinesAlways},
+			Expected: `
+module part(size, h=2)
+{
+    x = size * 2;
+
+    translate([x, 0, 0])
+        cube(size);
+
+    cylinder(r=size, h=h);
+}
+
+part(1, h=3);
+points = [1, 2, 3, 4];
+polyhedron(
+    points=[[0, 0, 0], [10, 0, 0], [0, 10, 0]],
+    faces=[[0, 1, 2]]
+);`,
+		},
+	}
+
+	stmts, err := openscad.Parse([]byte(src))
+	require.NoError(t, err, `openscad.Parse should succeed`)
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			out, err := ast.EmitString(stmts, ast.WithFormatStyle(tc.Style))
+			require.NoError(t, err, `ast.EmitString should succeed`)
+			require.Equal(t, tc.Expected, out)
+
+			_, err = openscad.Parse([]byte(out))
+			require.NoError(t, err, `output should parse`)
+		})
+	}
+}
+
+func TestBlankLinesBetweenCalls(t *testing.T) {
+	// built-in transformations are emitted as calls, and should be
+	// grouped with other calls, regardless of how they were constructed
+	stmts := ast.NewModule("part").Actions(
+		ast.NewVariable("x").Value(1),
+		ast.NewTranslate([]interface{}{1, 0, 0}, ast.NewCall("cube").Parameters(1)),
+		ast.NewCall("cylinder").Parameters(ast.NewVariable("r").Value(1), ast.NewVariable("h").Value(2)),
+		ast.NewRotate([]interface{}{0, 90, 0}, ast.NewCall("sphere").Parameters(1)),
+	)
+
+	out, err := ast.EmitString(stmts)
+	require.NoError(t, err, `ast.EmitString should succeed`)
+	require.Equal(t, `
+module part()
+{
+  x = 1;
+
+  translate([1, 0, 0])
+    cube(1);
+  cylinder(r=1, h=2);
+  rotate([0, 90, 0])
+    sphere(1);
+}
+`, out)
+}
+
+func TestMaxWidthAfterPrefix(t *testing.T) {
+	// what precedes a list or a call on its line counts towards the width
+	const src = `very_long_variable_name = [1, 2, 3, 4];
+short = [1, 2, 3, 4];
+echo(long_argument_name = [10, 20], b = max(1000, 2000));
+`
+	stmts, err := openscad.Parse([]byte(src))
+	require.NoError(t, err, `openscad.Parse should succeed`)
+	out, err := ast.EmitString(stmts, ast.WithFormatStyle(ast.FormatStyle{MaxWidth: 30}))
+	require.NoError(t, err, `ast.EmitString should succeed`)
+	require.Equal(t, `
+very_long_variable_name = [
+  1, 
+  2, 
+  3, 
+  4
+];
+short = [1, 2, 3, 4];
+echo(
+  long_argument_name=[10, 20],
+  b=max(1000, 2000)
+);`, out)
+	for _, line := range strings.Split(out, "\n") {
+		require.LessOrEqual(t, len(strings.TrimRight(line, ` `)), 30, `line %q should fit`, line)
+	}
+}
@@ -0,0 +1,172 @@
+package ast
+
+import (
+	"strings"
+)
+
+// SymbolKind describes what kind of entity a Symbol refers to.
+type SymbolKind int
+
+const (
+	SymbolModule SymbolKind = iota + 1
+	SymbolFunction
+	SymbolVariable
+	SymbolParameter
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolModule:
+		return `module`
+	case SymbolFunction:
+		return `function`
+	case SymbolVariable:
+		return `variable`
+	case SymbolParameter:
+		return `parameter`
+	default:
+		return `unknown`
+	}
+}
+
+// Symbol is a module, function or variable defined in OpenSCAD code.
+type Symbol struct {
+	Kind SymbolKind
+	Name string
+	// Pos is the location of the definition. It is only valid if the
+	// code was parsed with positions (see openscad.WithPositions)
+	Pos Position
+	// Signature summarizes the definition, as in `module foo(a, b=1)`
+	// or `x = 10`
+	Signature string
+	// Children holds the parameters of a module or function, followed by
+	// everything that is defined in its body, including loop variables
+	// and let() assignments.
+	Children []*Symbol
+}
+
+// Symbols lists the modules, functions and variables defined at the top
+// level of stmt, in the order that they appear in.
+//
+// Variables that are assigned within top level statements such as for
+// loops are local to them, and are not listed.
+func Symbols(stmt Stmt) []*Symbol {
+	var symbols []*Symbol
+	for _, child := range toStmtList(stmt) {
+		switch v := child.(type) {
+		case *Module:
+			symbols = append(symbols, moduleSymbol(v))
+		case *Function:
+			symbols = append(symbols, functionSymbol(v))
+		case *Variable:
+			if v.value != nil {
+				symbols = append(symbols, variableSymbol(SymbolVariable, v.name, v.pos, v.value))
+			}
+		}
+	}
+	return symbols
+}
+
+func moduleSymbol(m *Module) *Symbol {
+	s := &Symbol{
+		Kind:      SymbolModule,
+		Name:      m.name,
+		Pos:       m.pos,
+		Signature: signature(`module`, m.name, m.parameters),
+		Children:  parameterSymbols(m.parameters),
+	}
+	for _, child := range m.children {
+		s.Children = append(s.Children, localSymbols(child)...)
+	}
+	return s
+}
+
+func functionSymbol(f *Function) *Symbol {
+	s := &Symbol{
+		Kind:      SymbolFunction,
+		Name:      f.name,
+		Pos:       f.pos,
+		Signature: signature(`function`, f.name, f.parameters),
+		Children:  parameterSymbols(f.parameters),
+	}
+	s.Children = append(s.Children, localSymbols(f.body)...)
+	return s
+}
+
+func variableSymbol(kind SymbolKind, name string, pos Position, value interface{}) *Symbol {
+	s := &Symbol{
+		Kind:      kind,
+		Name:      name,
+		Pos:       pos,
+		Signature: name,
+	}
+	if value != nil {
+		var sb strings.Builder
+		if err := emitExpr(newEmitContext(), &sb, value); err == nil {
+			s.Signature += ` = ` + sb.String()
+		}
+	}
+	return s
+}
+
+func parameterSymbols(params []*Variable) []*Symbol {
+	symbols := make([]*Symbol, 0, len(params))
+	for _, param := range params {
+		symbols = append(symbols, variableSymbol(SymbolParameter, param.name, param.pos, param.value))
+	}
+	return symbols
+}
+
+// localSymbols lists everything that is defined within node
+func localSymbols(node interface{}) []*Symbol {
+	var symbols []*Symbol
+	var visit func(interface{}) bool
+	visit = func(node interface{}) bool {
+		switch v := node.(type) {
+		case *Module:
+			symbols = append(symbols, moduleSymbol(v))
+			return false
+		case *Function:
+			symbols = append(symbols, functionSymbol(v))
+			return false
+		case *Call:
+			// named arguments are not definitions
+			for _, child := range v.children {
+				Walk(child, visit)
+			}
+			return false
+		case *LoopVar:
+			symbols = append(symbols, variableSymbol(SymbolVariable, v.variable.name, v.variable.pos, v.expr))
+			Walk(v.expr, visit)
+			return false
+		case *Variable:
+			if v.value != nil {
+				symbols = append(symbols, variableSymbol(SymbolVariable, v.name, v.pos, v.value))
+			}
+		}
+		return true
+	}
+	Walk(node, visit)
+	return symbols
+}
+
+// signature returns the first line of the definition of a module or
+// function, as in `module foo(a, b=1)`
+func signature(kind, name string, params []*Variable) string {
+	var sb strings.Builder
+	sb.WriteString(kind + ` ` + name + `(`)
+	ctx := newEmitContext()
+	for i, param := range params {
+		if i > 0 {
+			sb.WriteString(`, `)
+		}
+		var buf strings.Builder
+		if err := emitValue(ctx, &buf, param); err != nil {
+			buf.Reset()
+			buf.WriteString(param.name)
+		}
+		sb.WriteString(buf.String())
+	}
+	sb.WriteString(`)`)
+	return sb.String()
+}
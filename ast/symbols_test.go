@@ -0,0 +1,46 @@
+package ast_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymbols(t *testing.T) {
+	const src = `size = 10;
+module box(w, h=2) {
+  inner = w - 1;
+  for (i = [0:3])
+    translate([i, 0, 0]) cube(size=inner);
+}
+function area(r) = let(r2 = r * r) PI * r2;
+box(size);
+`
+	stmts, err := openscad.Parse([]byte(src), openscad.WithPositions())
+	require.NoError(t, err, `openscad.Parse should succeed`)
+
+	var describe func(symbols []*ast.Symbol, indent string) []string
+	describe = func(symbols []*ast.Symbol, indent string) []string {
+		var list []string
+		for _, s := range symbols {
+			list = append(list, fmt.Sprintf(`%s%s %s %s: %s`, indent, s.Pos, s.Kind, s.Name, s.Signature))
+			list = append(list, describe(s.Children, indent+`  `)...)
+		}
+		return list
+	}
+
+	require.Equal(t, []string{
+		`1:1 variable size: size = 10`,
+		`2:1 module box: module box(w, h=2)`,
+		`  2:12 parameter w: w`,
+		`  2:15 parameter h: h = 2`,
+		`  3:3 variable inner: inner = w - 1`,
+		`  4:8 variable i: i = [0:3]`,
+		`7:1 function area: function area(r)`,
+		`  7:15 parameter r: r`,
+		`  7:24 variable r2: r2 = r * r`,
+	}, describe(ast.Symbols(stmts), ``))
+}
@@ -0,0 +1,126 @@
+package ast
+
+// builtinSpecialVariables are special variables that OpenSCAD reads
+// implicitly, so assignments to them must never be dropped
+var builtinSpecialVariables = map[string]struct{}{
+	`$fn`:             {},
+	`$fa`:             {},
+	`$fs`:             {},
+	`$t`:              {},
+	`$vpr`:            {},
+	`$vpt`:            {},
+	`$vpd`:            {},
+	`$vpf`:            {},
+	`$preview`:        {},
+	`$children`:       {},
+	`$parent_modules`: {},
+}
+
+// treeShake removes module, function and variable definitions from
+// amalgamated output that can not be reached from the statements that
+// are actually executed.
+//
+// Every statement that belongs to the root file is kept, as well as
+// statements other than definitions in included files (such as module
+// instantiations), as OpenSCAD executes them. Starting from those,
+// definitions are marked as reachable by following variable references
+// (including special variables), function calls and module instantiations.
+func treeShake(stmts Stmts) Stmts {
+	const (
+		kindVariable = iota
+		kindFunction
+		kindModule
+	)
+	type definition struct {
+		kind int
+		name string
+	}
+
+	// definitions that may be dropped, indexed by position
+	droppable := make(map[int]definition)
+	byName := make(map[definition][]int)
+
+	var pending []interface{}
+	depth := 0
+	for i, stmt := range stmts {
+		if banner, ok := stmt.(*amalgamationBanner); ok {
+			if banner.end {
+				depth--
+			} else {
+				depth++
+			}
+			continue
+		}
+
+		var def *definition
+		if depth > 0 {
+			switch v := stmt.(type) {
+			case *Module:
+				def = &definition{kind: kindModule, name: v.name}
+			case *Function:
+				def = &definition{kind: kindFunction, name: v.name}
+			case *Variable:
+				if _, ok := builtinSpecialVariables[v.name]; !ok {
+					def = &definition{kind: kindVariable, name: v.name}
+				}
+			}
+		}
+		if def == nil {
+			pending = append(pending, stmt)
+			continue
+		}
+		droppable[i] = *def
+		byName[*def] = append(byName[*def], i)
+	}
+
+	reachable := make(map[definition]struct{})
+	mark := func(d definition) {
+		if _, ok := reachable[d]; ok {
+			return
+		}
+		reachable[d] = struct{}{}
+		for _, i := range byName[d] {
+			pending = append(pending, stmts[i])
+		}
+	}
+
+	for len(pending) > 0 {
+		node := pending[0]
+		pending = pending[1:]
+
+		refs := collectReferences(node)
+		for name := range refs.variables {
+			mark(definition{kind: kindVariable, name: name})
+		}
+		for name := range refs.functions {
+			mark(definition{kind: kindFunction, name: name})
+			// the name may also refer to a variable holding a
+			// function literal
+			mark(definition{kind: kindVariable, name: name})
+		}
+		for name := range refs.modules {
+			mark(definition{kind: kindModule, name: name})
+		}
+	}
+
+	ret := make(Stmts, 0, len(stmts))
+	for i, stmt := range stmts {
+		if def, ok := droppable[i]; ok {
+			if _, ok := reachable[def]; !ok {
+				continue
+			}
+		}
+
+		// drop banners that no longer surround anything
+		if banner, ok := stmt.(*amalgamationBanner); ok && banner.end {
+			if last := len(ret) - 1; last >= 0 {
+				if start, ok := ret[last].(*amalgamationBanner); ok && !start.end && start.name == banner.name && start.typ == banner.typ {
+					ret = ret[:last]
+					continue
+				}
+			}
+		}
+		ret = append(ret, stmt)
+	}
+	return ret
+}
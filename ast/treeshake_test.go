@@ -0,0 +1,77 @@
+package ast_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTreeShaking(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "include <lib.scad>\nuse <util.scad>\nbox();",
+		"lib.scad": `$fn = 32;
+$thickness = 2;
+size = 10;
+unused_size = 20;
+module box() { cube([size, size, thickness()]); }
+module unused_box() { cube([unused_size, 1, 1]); }
+function thickness() = $thickness;
+function unused() = 1;
+module plate() { helper(); }`,
+		"util.scad": "module helper() { cube([1, 1, 1]); }\nmodule never_called() { helper(); }",
+	})
+
+	var sb strings.Builder
+	require.NoError(t, ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry), ast.WithTreeShaking()), `emit should succeed`)
+	require.Equal(t, `
+
+// START include lib.scad
+
+$fn = 32;
+$thickness = 2;
+size = 10;
+module box()
+{
+  cube([size, size, thickness()]);
+}
+
+function thickness() = $thickness;
+// END include lib.scad
+
+box();`, sb.String())
+}
+
+func TestTreeShakingKeepsExecutedStatements(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "include <lib.scad>\nmodule own() { from_lib(); }",
+		"lib.scad":  "module from_lib() { cube([w, 1, 1]); }\nw = 3;\nmodule demo() { sphere(r=1); }\ndemo();",
+	})
+
+	var sb strings.Builder
+	require.NoError(t, ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry), ast.WithTreeShaking()), `emit should succeed`)
+	out := sb.String()
+	require.Contains(t, out, "module from_lib()", `modules called by root definitions should be kept`)
+	require.Contains(t, out, "w = 3;", `variables referenced transitively should be kept`)
+	require.Contains(t, out, "module demo()", `modules called by executed statements in included files should be kept`)
+	require.Contains(t, out, "\ndemo();", `executed statements in included files should be kept`)
+}
+
+func TestTreeShakingKeepsFunctionLiterals(t *testing.T) {
+	registry := registerSources(t, map[string]string{
+		"main.scad": "include <lib.scad>\ny = sq(3);",
+		"lib.scad":  "sq = function(x) x * x;\nunused = function(x) x;",
+	})
+
+	var sb strings.Builder
+	require.NoError(t, ast.EmitFile("main.scad", &sb, ast.WithAmalgamation(), ast.WithRegistry(registry), ast.WithTreeShaking()), `emit should succeed`)
+	require.Equal(t, `
+
+// START include lib.scad
+
+sq = function(x) x * x;
+// END include lib.scad
+
+y = sq(3);`, sb.String())
+}
@@ -0,0 +1,165 @@
+package ast
+
+// Walk traverses the tree rooted at node in depth-first order, calling
+// fn for each node that it encounters. Nodes include statements,
+// expressions, and literal values such as numbers, strings and lists.
+//
+// If fn returns false, the children of the current node are not visited.
+func Walk(node interface{}, fn func(interface{}) bool) {
+	if node == nil {
+		return
+	}
+	if !fn(node) {
+		return
+	}
+
+	switch v := node.(type) {
+	case Stmts:
+		for _, stmt := range v {
+			Walk(stmt, fn)
+		}
+	case []Stmt:
+		for _, stmt := range v {
+			Walk(stmt, fn)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			Walk(elem, fn)
+		}
+	case *Declare:
+		Walk(v.v, fn)
+	case *Variable:
+		Walk(v.value, fn)
+	case *Module:
+		walkVariables(v.parameters, fn)
+		walkStmts(v.children, fn)
+	case *Call:
+		for _, param := range v.parameters {
+			Walk(param, fn)
+		}
+		walkStmts(v.children, fn)
+	case *Index:
+		Walk(v.expr, fn)
+		Walk(v.index, fn)
+	case *BareBlock:
+		walkStmts(v.children, fn)
+	case *Function:
+		walkVariables(v.parameters, fn)
+		Walk(v.body, fn)
+	case *FunctionLiteral:
+		walkVariables(v.parameters, fn)
+		Walk(v.body, fn)
+	case *LookupStmt:
+		Walk(v.key, fn)
+		Walk(v.values, fn)
+	case *LetExpr:
+		walkVariables(v.variables, fn)
+		Walk(v.expr, fn)
+	case *LetBlock:
+		walkVariables(v.variables, fn)
+		walkStmts(v.children, fn)
+	case *ForRange:
+		Walk(v.start, fn)
+		Walk(v.increment, fn)
+		Walk(v.end, fn)
+	case *LoopVar:
+		Walk(v.variable, fn)
+		Walk(v.expr, fn)
+	case *ForExpr:
+		for _, lv := range v.loopVars {
+			Walk(lv, fn)
+		}
+		Walk(v.expr, fn)
+	case *ForBlock:
+		for _, lv := range v.loopVars {
+			Walk(lv, fn)
+		}
+		walkStmts(v.children, fn)
+	case *TernaryOp:
+		Walk(v.condition, fn)
+		Walk(v.trueExpr, fn)
+		Walk(v.falseExpr, fn)
+	case *IfExpr:
+		Walk(v.cond, fn)
+		Walk(v.body, fn)
+		Walk(v.elseBody, fn)
+	case *EachExpr:
+		Walk(v.expr, fn)
+	case *IfStmt:
+		Walk(v.cond, fn)
+		walkStmts(v.body, fn)
+		for _, elseif := range v.elseifBlocks {
+			Walk(elseif, fn)
+		}
+		walkStmts(v.elseBlock, fn)
+	case *ElseIfStmt:
+		Walk(v.cond, fn)
+		walkStmts(v.body, fn)
+	case *Group:
+		Walk(v.expr, fn)
+	case *UnaryOp:
+		Walk(v.expr, fn)
+	case *BinaryOp:
+		Walk(v.left, fn)
+		Walk(v.right, fn)
+	case *Point2D:
+		Walk(v.x, fn)
+		Walk(v.y, fn)
+	case Point2DList:
+		for _, pt := range v {
+			Walk(pt, fn)
+		}
+	case *Polygon:
+		Walk(v.points, fn)
+		Walk(v.paths, fn)
+	case *Cube:
+		Walk(v.width, fn)
+		Walk(v.depth, fn)
+		Walk(v.height, fn)
+	case *Cylinder:
+		Walk(v.height, fn)
+		Walk(v.radius1, fn)
+		Walk(v.radius2, fn)
+	case *Sphere:
+		Walk(v.radius, fn)
+	case *Circle:
+		Walk(v.radius, fn)
+	case *Polyhedron:
+		Walk(v.points, fn)
+		Walk(v.faces, fn)
+		Walk(v.convexity, fn)
+	case *Translate:
+		Walk(v.v, fn)
+		walkStmts(v.children, fn)
+	case *Rotate:
+		Walk(v.v, fn)
+		walkStmts(v.children, fn)
+	case *LinearExtrude:
+		Walk(v.height, fn)
+		Walk(v.center, fn)
+		Walk(v.convexity, fn)
+		Walk(v.twist, fn)
+		Walk(v.scale, fn)
+		walkStmts(v.children, fn)
+	case *Union:
+		walkStmts(v.children, fn)
+	case *Difference:
+		walkStmts(v.children, fn)
+	case *Intersection:
+		walkStmts(v.children, fn)
+	case *Hull:
+		walkStmts(v.children, fn)
+	}
+}
+
+func walkStmts(stmts []Stmt, fn func(interface{}) bool) {
+	for _, stmt := range stmts {
+		Walk(stmt, fn)
+	}
+}
+
+func walkVariables(vars []*Variable, fn func(interface{}) bool) {
+	for _, v := range vars {
+		Walk(v, fn)
+	}
+}
@@ -0,0 +1,93 @@
+package ast
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lestrrat-go/openscad/internal/atomicfile"
+)
+
+// WriteAll writes every entry of the registry into dir as a regular
+// OpenSCAD file, under its registered name. Names such as `lib/gears.scad`
+// are written into subdirectories, which are created as needed. As
+// `include` and `use` directives refer to registered names, dir can then
+// be used as a library folder in OPENSCADPATH.
+//
+// Each file is written to a temporary file in its final directory first,
+// and then renamed, so that readers never see partially written files.
+//
+// Once all files have been written, a manifest listing the SHA-256 checksum
+// of each of them is written to dir, in the format used by sha256sum(1)
+// (see WithManifest).
+func WriteAll(registry *Registry, dir string, options ...WriteAllOption) error {
+	manifest := `SHA256SUMS`
+	var emitOptions []EmitOption
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case optEmitOptionsKey{}:
+			emitOptions = append(emitOptions, option.Value().([]EmitOption)...)
+		case optManifestKey{}:
+			manifest = option.Value().(string)
+		}
+	}
+
+	names := registry.Names()
+	for _, name := range names {
+		if !isLocal(name) {
+			return fmt.Errorf(`failed to execute WriteAll: %q can not be written inside of %s`, name, dir)
+		}
+		if name == manifest {
+			return fmt.Errorf(`failed to execute WriteAll: %q is also the name of the manifest`, name)
+		}
+	}
+
+	var sums bytes.Buffer
+	for _, name := range names {
+		stmt, ok := registry.Lookup(name)
+		if !ok {
+			// unregistered in the meantime
+			continue
+		}
+
+		var buf bytes.Buffer
+		fileOptions := append([]EmitOption{WithRegistry(registry)}, emitOptions...)
+		fileOptions = append(fileOptions, withRootName(name))
+		if err := Emit(stmt, &buf, fileOptions...); err != nil {
+			return fmt.Errorf(`failed to emit %s: %w`, name, err)
+		}
+		data := append(bytes.Trim(buf.Bytes(), "\n"), '\n')
+
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+			return fmt.Errorf(`failed to create directory for %s: %w`, name, err)
+		}
+		if err := atomicfile.WriteFile(filename, data, 0o644); err != nil {
+			return err
+		}
+
+		sum := sha256.Sum256(data)
+		fmt.Fprintf(&sums, "%s  %s\n", hex.EncodeToString(sum[:]), name)
+	}
+
+	if manifest == `` {
+		return nil
+	}
+	return atomicfile.WriteFile(filepath.Join(dir, manifest), sums.Bytes(), 0o644)
+}
+
+// isLocal reports whether name, which uses slashes as separators, refers
+// to a file inside of the directory that it is relative to
+func isLocal(name string) bool {
+	name = filepath.FromSlash(name)
+	if name == `` || filepath.IsAbs(name) || filepath.VolumeName(name) != `` || strings.HasPrefix(name, string(filepath.Separator)) {
+		return false
+	}
+	name = filepath.Clean(name)
+	return name != `.` && name != `..` && !strings.HasPrefix(name, `..`+string(filepath.Separator))
+}
@@ -0,0 +1,96 @@
+package ast_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAll(t *testing.T) {
+	sources := map[string]string{
+		"lib/gears.scad":  "include <lib/common.scad>\nmodule gear(teeth) { cylinder(r=teeth*modul, h=5); }",
+		"lib/common.scad": "modul = 1;",
+		"parts.scad":      "use <lib/gears.scad>\nmodule pair() { gear(10); translate([20, 0, 0]) gear(5); }",
+	}
+
+	t.Run("files and manifest", func(t *testing.T) {
+		dir := t.TempDir()
+		registry := registerSources(t, sources)
+		require.NoError(t, ast.WriteAll(registry, dir), `WriteAll should succeed`)
+
+		var manifest []string
+		for _, name := range registry.Names() {
+			data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+			require.NoError(t, err, `%s should be written`, name)
+
+			stmt, _ := registry.Lookup(name)
+			expected, err := ast.EmitString(stmt)
+			require.NoError(t, err, `ast.EmitString should succeed`)
+			require.Equal(t, strings.Trim(expected, "\n")+"\n", string(data), `%s should contain the emitted code`, name)
+
+			sum := sha256.Sum256(data)
+			manifest = append(manifest, hex.EncodeToString(sum[:])+"  "+name+"\n")
+		}
+
+		data, err := os.ReadFile(filepath.Join(dir, "SHA256SUMS"))
+		require.NoError(t, err, `manifest should be written`)
+		require.Equal(t, strings.Join(manifest, ""), string(data), `manifest should list every file`)
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err)
+		var names []string
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		require.Equal(t, []string{"SHA256SUMS", "lib", "parts.scad"}, names, `no temporary files should be left behind`)
+	})
+	t.Run("usable as a library folder", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, ast.WriteAll(registerSources(t, sources), dir), `WriteAll should succeed`)
+
+		project := t.TempDir()
+		main := filepath.Join(project, "main.scad")
+		require.NoError(t, os.WriteFile(main, []byte("use <parts.scad>\npair();\n"), 0o644))
+
+		registry := ast.NewRegistry()
+		_, err := openscad.RegisterTree(main, openscad.WithRegistry(registry), openscad.WithSearchPaths(dir))
+		require.NoError(t, err, `files should be found through the search path`)
+		require.Equal(t, []string{"lib/common.scad", "lib/gears.scad", "main.scad", "parts.scad"}, registry.Names())
+	})
+	t.Run("options", func(t *testing.T) {
+		dir := t.TempDir()
+		registry := registerSources(t, map[string]string{"a.scad": "module a() { cube(1); }"})
+		require.NoError(t, ast.WriteAll(registry, dir,
+			ast.WithManifest(""),
+			ast.WithEmitOptions(ast.WithFormatStyle(ast.FormatStyle{IndentWidth: 4})),
+		), `WriteAll should succeed`)
+
+		data, err := os.ReadFile(filepath.Join(dir, "a.scad"))
+		require.NoError(t, err)
+		require.Equal(t, "module a()\n{\n    cube(1);\n}\n", string(data))
+
+		_, err = os.Stat(filepath.Join(dir, "SHA256SUMS"))
+		require.True(t, os.IsNotExist(err), `manifest should not be written`)
+	})
+	t.Run("names outside of the directory", func(t *testing.T) {
+		for _, name := range []string{"../escape.scad", "lib/../../escape.scad", "..", "/abs.scad"} {
+			dir := t.TempDir()
+			registry := registerSources(t, map[string]string{name: "x = 1;"})
+			require.Error(t, ast.WriteAll(registry, dir), `WriteAll should fail for %q`, name)
+		}
+	})
+	t.Run("names that are cleaned up", func(t *testing.T) {
+		dir := t.TempDir()
+		registry := registerSources(t, map[string]string{"lib/../a.scad": "x = 1;"})
+		require.NoError(t, ast.WriteAll(registry, dir), `WriteAll should succeed`)
+		_, err := os.Stat(filepath.Join(dir, "a.scad"))
+		require.NoError(t, err, `file should be written inside of the directory`)
+	})
+}
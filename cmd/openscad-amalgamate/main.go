@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/internal/atomicfile"
+)
+
+// openscad-amalgamate creates a single OpenSCAD file out of an entry file
+// and all of the files that it includes or uses.
+//
+//	openscad-amalgamate [flags] entry.scad
+//
+// Files are looked up relative to the file that refers to them, then in
+// the directories given with -I, and finally in OPENSCADPATH.
+//
+// With -watch, the output is written again every time any of the files
+// changes, until the command is interrupted.
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	status := _main(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(status)
+}
+
+// stringList is a flag that can be specified multiple times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, string(filepath.ListSeparator))
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+var collisionPolicies = map[string]ast.CollisionPolicy{
+	`ignore`: ast.CollisionIgnore,
+	`error`:  ast.CollisionError,
+	`rename`: ast.CollisionRename,
+}
+
+func _main(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if err := run(ctx, args, stdout, stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			// the usage has already been printed
+			return 0
+		}
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) error {
+	var includePaths stringList
+	var output, sourceMapFile, collisions string
+	var treeShaking, minify, watch bool
+	var interval time.Duration
+	var style ast.FormatStyle
+
+	flags := flag.NewFlagSet(`openscad-amalgamate`, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Var(&includePaths, `I`, `add a directory to search for included and used files (may be repeated)`)
+	flags.StringVar(&output, `o`, ``, `write the result to this file instead of stdout`)
+	flags.StringVar(&collisions, `collisions`, `ignore`, `how to handle duplicate definitions: ignore, error or rename`)
+	flags.BoolVar(&treeShaking, `tree-shake`, false, `drop definitions that are never used`)
+	flags.BoolVar(&minify, `minify`, false, `emit minified code`)
+	flags.StringVar(&sourceMapFile, `sourcemap`, ``, `write a source map to this file`)
+	flags.IntVar(&style.IndentWidth, `indent`, 2, `number of spaces per indentation level`)
+	flags.BoolVar(&style.UseTabs, `tabs`, false, `indent with tabs`)
+	flags.IntVar(&style.MaxWidth, `width`, 0, `maximum line width for lists and call arguments (0 disables wrapping)`)
+	flags.BoolVar(&watch, `watch`, false, `write the output again every time any of the files changes (requires -o)`)
+	flags.DurationVar(&interval, `interval`, 500*time.Millisecond, `how often to check for changes with -watch`)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: openscad-amalgamate [flags] entry.scad\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf(`expected exactly one entry file`)
+	}
+
+	policy, ok := collisionPolicies[collisions]
+	if !ok {
+		return fmt.Errorf(`invalid collision policy %q`, collisions)
+	}
+	if watch && output == "" {
+		return fmt.Errorf(`-watch requires -o`)
+	}
+	if watch && interval <= 0 {
+		return fmt.Errorf(`-interval must be positive`)
+	}
+
+	searchPaths := append([]string(nil), includePaths...)
+	if env := os.Getenv(`OPENSCADPATH`); env != "" {
+		searchPaths = append(searchPaths, filepath.SplitList(env)...)
+	}
+
+	registry := ast.NewRegistry()
+	treeOptions := []openscad.RegisterTreeOption{
+		openscad.WithRegistry(registry),
+		openscad.WithSearchPaths(searchPaths...),
+	}
+	if sourceMapFile != "" {
+		treeOptions = append(treeOptions, openscad.WithPositions())
+	}
+
+	emitOptions := []ast.EmitFileOption{
+		ast.WithCollisionPolicy(policy),
+		ast.WithFormatStyle(style),
+	}
+	if treeShaking {
+		emitOptions = append(emitOptions, ast.WithTreeShaking())
+	}
+	if minify {
+		emitOptions = append(emitOptions, ast.WithMinify())
+	}
+	var sm ast.SourceMap
+	if sourceMapFile != "" {
+		// OpenSCAD reports locations using the name of the output file
+		var file string
+		if output != "" {
+			file = filepath.Base(output)
+		}
+		emitOptions = append(emitOptions, ast.WithSourceMap(&sm, file))
+	}
+
+	writeSourceMap := func() error {
+		if sourceMapFile == "" {
+			return nil
+		}
+		encoded, err := json.MarshalIndent(&sm, "", "  ")
+		if err != nil {
+			return fmt.Errorf(`failed to encode source map: %w`, err)
+		}
+		return atomicfile.WriteFile(sourceMapFile, append(encoded, '\n'), 0o644)
+	}
+
+	if watch {
+		watchOptions := []openscad.WatchOption{
+			openscad.WithPollInterval(interval),
+			openscad.WithEmitOptions(emitOptions...),
+			openscad.WithErrorHandler(func(err error) {
+				fmt.Fprintf(stderr, "%s\n", err)
+			}),
+			openscad.WithUpdateHandler(func() {
+				if err := writeSourceMap(); err != nil {
+					fmt.Fprintf(stderr, "%s\n", err)
+					return
+				}
+				fmt.Fprintf(stderr, "wrote %s\n", output)
+			}),
+		}
+		for _, option := range treeOptions {
+			watchOptions = append(watchOptions, option)
+		}
+		return openscad.Watch(ctx, flags.Arg(0), output, watchOptions...)
+	}
+
+	name, err := openscad.RegisterTree(flags.Arg(0), treeOptions...)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	emitOptions = append(emitOptions, ast.WithRegistry(registry), ast.WithAmalgamation())
+	if err := ast.EmitFile(name, &buf, emitOptions...); err != nil {
+		return fmt.Errorf(`failed to amalgamate %q: %w`, flags.Arg(0), err)
+	}
+	buf.WriteByte('\n')
+
+	if output == "" {
+		if _, err := buf.WriteTo(stdout); err != nil {
+			return fmt.Errorf(`failed to write output: %w`, err)
+		}
+	} else if err := atomicfile.WriteFile(output, buf.Bytes(), 0o644); err != nil {
+		return err
+	}
+	return writeSourceMap()
+}
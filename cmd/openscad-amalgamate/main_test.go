@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAmalgamate(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"project/main.scad":    "use <parts.scad>\npart();",
+		"libraries/parts.scad": "module part() {\n  cube(1);\n}\nmodule unused() {\n  sphere(1);\n}",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755), `os.MkdirAll should succeed`)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644), `os.WriteFile should succeed`)
+	}
+	entry := filepath.Join(dir, "project", "main.scad")
+
+	t.Run("missing search path", func(t *testing.T) {
+		t.Setenv(`OPENSCADPATH`, ``)
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 1, _main(context.Background(), []string{entry}, &stdout, &stderr))
+		require.Contains(t, stderr.String(), `parts.scad`)
+	})
+	t.Run("-I", func(t *testing.T) {
+		t.Setenv(`OPENSCADPATH`, ``)
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, _main(context.Background(), []string{`-I`, filepath.Join(dir, "libraries"), `-tree-shake`, entry}, &stdout, &stderr), stderr.String())
+		require.Equal(t, "\n\n// START use parts.scad\n\nmodule part()\n{\n  cube(1);\n}\n\n// END use parts.scad\n\npart();\n", stdout.String())
+	})
+	t.Run("OPENSCADPATH, output files", func(t *testing.T) {
+		t.Setenv(`OPENSCADPATH`, filepath.Join(dir, "libraries"))
+		output := filepath.Join(dir, "out.scad")
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, _main(context.Background(), []string{`-o`, output, `-minify`, `-sourcemap`, output + `.map`, entry}, &stdout, &stderr), stderr.String())
+		require.Empty(t, stdout.String())
+
+		buf, err := os.ReadFile(output)
+		require.NoError(t, err, `os.ReadFile should succeed`)
+		require.Equal(t, "module part(){cube(1);}module unused(){sphere(1);}part();\n", string(buf))
+
+		buf, err = os.ReadFile(output + `.map`)
+		require.NoError(t, err, `os.ReadFile should succeed`)
+		var sm ast.SourceMap
+		require.NoError(t, json.Unmarshal(buf, &sm), `json.Unmarshal should succeed`)
+		require.Equal(t, "out.scad", sm.File)
+		require.Equal(t, "WARNING: oops in file parts.scad, line 1", sm.TranslateMessage("WARNING: oops in file out.scad, line 1"))
+	})
+	t.Run("-watch", func(t *testing.T) {
+		t.Setenv(`OPENSCADPATH`, filepath.Join(dir, "libraries"))
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 1, _main(context.Background(), []string{`-watch`, entry}, &stdout, &stderr))
+		require.Contains(t, stderr.String(), `-watch requires -o`)
+
+		stderr.Reset()
+		require.Equal(t, 1, _main(context.Background(), []string{`-watch`, `-interval`, `0`, `-o`, filepath.Join(dir, "out.scad"), entry}, &stdout, &stderr))
+		require.Contains(t, stderr.String(), `-interval must be positive`)
+
+		output := filepath.Join(dir, "watched.scad")
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan int, 1)
+		go func() {
+			var stdout, stderr bytes.Buffer
+			done <- _main(ctx, []string{`-watch`, `-interval`, `10ms`, `-o`, output, entry}, &stdout, &stderr)
+		}()
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(output)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond, `output should be written`)
+		cancel()
+		require.Equal(t, 0, <-done)
+	})
+}
+
+func TestHelp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, _main(context.Background(), []string{`-h`}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), `usage:`)
+	require.NotContains(t, stderr.String(), `help requested`)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/internal/atomicfile"
+)
+
+// openscad-bindgen generates a Go package with typed constructors for the
+// modules and functions defined in an OpenSCAD library. It is meant to be
+// used from go generate:
+//
+//	//go:generate openscad-bindgen -o gears_gen.go path/to/gears.scad
+//
+// Calls created through the generated constructors make the library be
+// pulled in with `use` (or `include`, with -include) when they are emitted.
+func main() {
+	os.Exit(_main(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func _main(args []string, stdout, stderr io.Writer) int {
+	if err := run(args, stdout, stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			// the usage has already been printed
+			return 0
+		}
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	var output, pkg, name string
+	var include bool
+
+	flags := flag.NewFlagSet(`openscad-bindgen`, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&output, `o`, ``, `write the result to this file instead of stdout`)
+	flags.StringVar(&pkg, `package`, ``, `name of the generated package (default: derived from the library name)`)
+	flags.StringVar(&name, `name`, ``, `name of the library in use/include directives (default: the base name of the file)`)
+	flags.BoolVar(&include, `include`, false, `pull in the library with include instead of use`)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: openscad-bindgen [flags] library.scad\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf(`expected exactly one library file`)
+	}
+
+	filename := flags.Arg(0)
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf(`failed to read %s: %w`, filename, err)
+	}
+	stmts, err := openscad.Parse(src)
+	if err != nil {
+		return fmt.Errorf(`failed to parse %s: %w`, filename, err)
+	}
+
+	if name == "" {
+		name = filepath.Base(filename)
+	}
+	var options []ast.GoBindingsOption
+	if pkg != "" {
+		options = append(options, ast.WithGoPackage(pkg))
+	}
+	if include {
+		options = append(options, ast.WithGoInclude())
+	}
+	code, err := ast.GoBindings(name, stmts, options...)
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		if _, err := stdout.Write(code); err != nil {
+			return fmt.Errorf(`failed to write output: %w`, err)
+		}
+		return nil
+	}
+	return atomicfile.WriteFile(output, code, 0o644)
+}
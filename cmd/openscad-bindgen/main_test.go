@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBindgen(t *testing.T) {
+	library := filepath.Join(`..`, `..`, `examples`, `gears`, `gears.scad`)
+
+	t.Run("stdout", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, _main([]string{`-include`, `-name`, `lib/gears.scad`, library}, &stdout, &stderr), stderr.String())
+		require.Contains(t, stdout.String(), "package gears\n")
+		require.Contains(t, stdout.String(), `const File = "lib/gears.scad"`)
+		require.Contains(t, stdout.String(), `func SpurGear(modul, toothNumber, width, bore interface{}, options ...SpurGearOption) *ast.Call`)
+		require.Contains(t, stdout.String(), `.Include(File)`)
+	})
+	t.Run("missing file", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 1, _main([]string{`nonexistent.scad`}, &stdout, &stderr))
+		require.Contains(t, stderr.String(), `nonexistent.scad`)
+	})
+
+	gobin, err := exec.LookPath(`go`)
+	if err != nil {
+		t.Skip(`go command is not available`)
+	}
+
+	// the generated code must be within this module in order to build
+	dir, err := os.MkdirTemp(`.`, `generated`)
+	require.NoError(t, err, `os.MkdirTemp should succeed`)
+	defer os.RemoveAll(dir)
+
+	require.NoError(t, os.Mkdir(filepath.Join(dir, `gears`), 0o755), `os.Mkdir should succeed`)
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, _main([]string{`-o`, filepath.Join(dir, `gears`, `gears_gen.go`), library}, &stdout, &stderr), stderr.String())
+
+	const program = `package main
+
+import (
+	"os"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/cmd/openscad-bindgen/%s/gears"
+)
+
+func main() {
+	stmts := ast.Stmts{
+		gears.SpurGear(1, 30, 5, 4, gears.SpurGearHelixAngle(20)),
+		gears.Rack(1, 30, 5, 5),
+	}
+	if err := ast.Emit(stmts, os.Stdout); err != nil {
+		panic(err)
+	}
+}
+`
+	src := []byte(fmt.Sprintf(program, filepath.Base(dir)))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, `main.go`), src, 0o644), `os.WriteFile should succeed`)
+
+	stdout.Reset()
+	stderr.Reset()
+	cmd := exec.Command(gobin, `run`, `./`+filepath.ToSlash(dir))
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Run(), stderr.String())
+	require.Equal(t, "\nuse <gears.scad>\nspur_gear(modul=1, tooth_number=30, width=5, bore=4, helix_angle=20);\nrack(modul=1, length=30, height=5, width=5);", stdout.String())
+}
+
+func TestHelp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, _main([]string{`-h`}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), `usage:`)
+	require.NotContains(t, stderr.String(), `help requested`)
+}
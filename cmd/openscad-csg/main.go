@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/internal/atomicfile"
+	"github.com/lestrrat-go/openscad/interp"
+)
+
+// openscad-csg evaluates an OpenSCAD design and writes it in OpenSCAD's
+// `.csg` format: a tree of primitives, boolean operations and multmatrix()
+// transformations with no variables, functions or modules left.
+//
+//	openscad-csg [flags] entry.scad
+//
+// As with OpenSCAD, -D name=value overrides a top-level variable of the
+// entry file. Files are looked up relative to the file that refers to
+// them, then in the directories given with -I, and finally in
+// OPENSCADPATH.
+func main() {
+	os.Exit(_main(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// stringList is a flag that can be specified multiple times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, `,`)
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func _main(args []string, stdout, stderr io.Writer) int {
+	if err := run(args, stdout, stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			// the usage has already been printed
+			return 0
+		}
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func run(args []string, stdout, stderr io.Writer) error {
+	var includePaths, defines stringList
+	var output string
+
+	flags := flag.NewFlagSet(`openscad-csg`, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Var(&includePaths, `I`, `add a directory to search for included and used files (may be repeated)`)
+	flags.Var(&defines, `D`, `override a top-level variable, as in name=value (may be repeated)`)
+	flags.StringVar(&output, `o`, ``, `write the result to this file instead of stdout`)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: openscad-csg [flags] entry.scad\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf(`expected exactly one entry file`)
+	}
+
+	searchPaths := append([]string(nil), includePaths...)
+	if env := os.Getenv(`OPENSCADPATH`); env != "" {
+		searchPaths = append(searchPaths, filepath.SplitList(env)...)
+	}
+
+	registry := ast.NewRegistry()
+	name, err := openscad.RegisterTree(flags.Arg(0), openscad.WithRegistry(registry), openscad.WithSearchPaths(searchPaths...))
+	if err != nil {
+		return err
+	}
+	stmt, _ := registry.Lookup(name)
+
+	// assignments that come last take effect at the place of the first
+	// assignment to the same variable, which is how OpenSCAD applies -D
+	var stmts ast.Stmts
+	if list, ok := stmt.(ast.Stmts); ok {
+		stmts = append(stmts, list...)
+	} else {
+		stmts = append(stmts, stmt)
+	}
+	for _, define := range defines {
+		parsed, err := openscad.Parse([]byte(define + `;`))
+		if err != nil {
+			return fmt.Errorf(`invalid definition %q: %w`, define, err)
+		}
+		if len(parsed) != 1 {
+			return fmt.Errorf(`invalid definition %q: expected name=value`, define)
+		}
+		if _, ok := parsed[0].(*ast.Variable); !ok {
+			return fmt.Errorf(`invalid definition %q: expected name=value`, define)
+		}
+		stmts = append(stmts, parsed[0])
+	}
+
+	p, err := interp.LoadStmt(registry, name, stmts)
+	if err != nil {
+		return err
+	}
+	root, err := p.Evaluate()
+	if err != nil {
+		return fmt.Errorf(`failed to evaluate %q: %w`, flags.Arg(0), err)
+	}
+
+	var buf bytes.Buffer
+	if err := csg.Emit(root, &buf); err != nil {
+		return err
+	}
+	if output == "" {
+		if _, err := buf.WriteTo(stdout); err != nil {
+			return fmt.Errorf(`failed to write output: %w`, err)
+		}
+		return nil
+	}
+	return atomicfile.WriteFile(output, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSG(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.scad":  "use <parts.scad>\nsize = 1;\npeg(size);\n",
+		"parts.scad": "module peg(h) {\n  translate([0, 0, 1]) cylinder(h = h, r = 0.5, $fn = 8);\n}\n",
+	}
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644), `os.WriteFile should succeed`)
+	}
+	entry := filepath.Join(dir, "main.scad")
+
+	t.Run("stdout", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, _main([]string{entry}, &stdout, &stderr), stderr.String())
+		require.Equal(t, "group() {\n\tgroup() {\n\t\tmultmatrix([[1, 0, 0, 0], [0, 1, 0, 0], [0, 0, 1, 1], [0, 0, 0, 1]]) {\n\t\t\tcylinder($fn = 8, $fa = 12, $fs = 2, h = 1, r1 = 0.5, r2 = 0.5, center = false);\n\t\t}\n\t}\n}\n", stdout.String())
+	})
+	t.Run("-D, -o", func(t *testing.T) {
+		output := filepath.Join(dir, "out.csg")
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, _main([]string{`-D`, `size=2.5`, `-o`, output, entry}, &stdout, &stderr), stderr.String())
+		require.Empty(t, stdout.String())
+
+		buf, err := os.ReadFile(output)
+		require.NoError(t, err, `os.ReadFile should succeed`)
+		require.Contains(t, string(buf), `h = 2.5,`)
+	})
+	t.Run("invalid definition", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 1, _main([]string{`-D`, `cube(1)`, entry}, &stdout, &stderr))
+		require.Contains(t, stderr.String(), `invalid definition`)
+	})
+}
+
+func TestHelp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, _main([]string{`-h`}, &stdout, &stderr))
+	require.Contains(t, stderr.String(), `usage:`)
+	require.NotContains(t, stderr.String(), `help requested`)
+}
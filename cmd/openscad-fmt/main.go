@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/internal/atomicfile"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// openscad-fmt formats OpenSCAD source code, much like gofmt.
+//
+//	openscad-fmt [flags] [path ...]
+//
+// Directories are processed recursively. Without paths, the source code
+// is read from standard input.
+func main() {
+	os.Exit(_main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// errUnformatted is reported when --check finds files that are not formatted
+var errUnformatted = errors.New(`not formatted`)
+
+type formatter struct {
+	style  ast.FormatStyle
+	write  bool
+	list   bool
+	diff   bool
+	check  bool
+	stdout io.Writer
+}
+
+func _main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	f := &formatter{stdout: stdout}
+
+	flags := flag.NewFlagSet(`openscad-fmt`, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.BoolVar(&f.write, `w`, false, `write result to (source) file instead of stdout`)
+	flags.BoolVar(&f.list, `l`, false, `list files whose formatting differs`)
+	flags.BoolVar(&f.diff, `d`, false, `display diffs instead of rewriting files`)
+	flags.BoolVar(&f.check, `check`, false, `exit with a non-zero status if any file is not formatted`)
+	flags.IntVar(&f.style.IndentWidth, `indent`, 2, `number of spaces per indentation level`)
+	flags.BoolVar(&f.style.UseTabs, `tabs`, false, `indent with tabs`)
+	flags.IntVar(&f.style.MaxWidth, `width`, 0, `maximum line width for lists and call arguments (0 disables wrapping)`)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: openscad-fmt [flags] [path ...]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			// the usage has already been printed
+			return 0
+		}
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if f.write {
+			fmt.Fprintf(stderr, "cannot use -w with standard input\n")
+			return 2
+		}
+		src, err := io.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "failed to read standard input: %s\n", err)
+			return 2
+		}
+		return f.report(stderr, f.process(`<standard input>`, src, 0))
+	}
+
+	var status int
+	for _, arg := range flags.Args() {
+		err := filepath.WalkDir(arg, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || (path != arg && filepath.Ext(path) != `.scad`) {
+				return nil
+			}
+			if s := f.report(stderr, f.processFile(path)); s > status {
+				status = s
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "%s\n", err)
+			status = 2
+		}
+	}
+	return status
+}
+
+// report prints err, if any, and returns the exit status that it warrants
+func (f *formatter) report(stderr io.Writer, err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUnformatted):
+		return 1
+	default:
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 2
+	}
+}
+
+func (f *formatter) processFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf(`failed to read %s: %w`, path, err)
+	}
+	return f.process(path, src, info.Mode().Perm())
+}
+
+func (f *formatter) process(path string, src []byte, perm fs.FileMode) error {
+	formatted, err := openscad.Format(src, f.style)
+	if err != nil {
+		return fmt.Errorf(`%s: %w`, path, err)
+	}
+
+	changed := !bytes.Equal(src, formatted)
+	if !f.list && !f.diff && !f.write && !f.check {
+		_, err := f.stdout.Write(formatted)
+		return err
+	}
+	if !changed {
+		return nil
+	}
+
+	if f.list {
+		fmt.Fprintln(f.stdout, path)
+	}
+	if f.diff {
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(string(src)),
+			B:        difflib.SplitLines(string(formatted)),
+			FromFile: path + `.orig`,
+			ToFile:   path,
+			Context:  3,
+		})
+		if err != nil {
+			return fmt.Errorf(`%s: failed to compute diff: %w`, path, err)
+		}
+		fmt.Fprint(f.stdout, diff)
+	}
+	if f.write {
+		if err := atomicfile.WriteFile(path, formatted, perm); err != nil {
+			return err
+		}
+	}
+	if f.check {
+		return fmt.Errorf(`%s: %w`, path, errUnformatted)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	unformatted = "module m(){cube(1);}\n"
+	formatted   = "module m()\n{\n  cube(1);\n}\n"
+)
+
+func setupFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755), `os.MkdirAll should succeed`)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644), `os.WriteFile should succeed`)
+	}
+	return dir
+}
+
+func run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := _main(args, strings.NewReader(unformatted), &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestFormat(t *testing.T) {
+	t.Run("stdin", func(t *testing.T) {
+		status, stdout, _ := run()
+		require.Equal(t, 0, status)
+		require.Equal(t, formatted, stdout)
+	})
+	t.Run("list, recursively", func(t *testing.T) {
+		dir := setupFiles(t, map[string]string{
+			"a.scad":       unformatted,
+			"sub/b.scad":   formatted,
+			"sub/c.scad":   unformatted,
+			"sub/notes.md": unformatted,
+		})
+		status, stdout, _ := run(`-l`, dir)
+		require.Equal(t, 0, status)
+		require.Equal(t, filepath.Join(dir, "a.scad")+"\n"+filepath.Join(dir, "sub", "c.scad")+"\n", stdout)
+	})
+	t.Run("diff", func(t *testing.T) {
+		dir := setupFiles(t, map[string]string{"a.scad": unformatted})
+		status, stdout, _ := run(`-d`, dir)
+		require.Equal(t, 0, status)
+		require.Contains(t, stdout, "-module m(){cube(1);}\n+module m()\n+{\n+  cube(1);\n+}\n")
+	})
+	t.Run("write and check", func(t *testing.T) {
+		dir := setupFiles(t, map[string]string{"a.scad": unformatted})
+
+		status, _, stderr := run(`--check`, dir)
+		require.Equal(t, 1, status, `--check should fail for unformatted files`)
+		require.Empty(t, stderr)
+
+		status, _, _ = run(`-w`, dir)
+		require.Equal(t, 0, status)
+		buf, err := os.ReadFile(filepath.Join(dir, "a.scad"))
+		require.NoError(t, err, `os.ReadFile should succeed`)
+		require.Equal(t, formatted, string(buf))
+
+		entries, err := os.ReadDir(dir)
+		require.NoError(t, err, `os.ReadDir should succeed`)
+		require.Len(t, entries, 1, `temporary files should be cleaned up`)
+
+		status, _, _ = run(`--check`, dir)
+		require.Equal(t, 0, status, `--check should pass after formatting`)
+	})
+	t.Run("refuse comments", func(t *testing.T) {
+		const src = "// hello\ncube(1);\n"
+		dir := setupFiles(t, map[string]string{"a.scad": src})
+		status, _, stderr := run(`-w`, dir)
+		require.Equal(t, 2, status)
+		require.Contains(t, stderr, `comments`)
+
+		buf, err := os.ReadFile(filepath.Join(dir, "a.scad"))
+		require.NoError(t, err, `os.ReadFile should succeed`)
+		require.Equal(t, src, string(buf), `file should be left alone`)
+	})
+	t.Run("refuse lossy formatting", func(t *testing.T) {
+		// nested blocks are not preserved by the emitter
+		const src = "{ cube(1); }\n"
+		dir := setupFiles(t, map[string]string{"a.scad": src})
+		status, _, stderr := run(`-w`, dir)
+		require.Equal(t, 2, status)
+		require.Contains(t, stderr, `does not parse back`)
+
+		buf, err := os.ReadFile(filepath.Join(dir, "a.scad"))
+		require.NoError(t, err, `os.ReadFile should succeed`)
+		require.Equal(t, src, string(buf), `file should be left alone`)
+	})
+}
+
+func TestHelp(t *testing.T) {
+	status, _, stderr := run(`-h`)
+	require.Equal(t, 0, status)
+	require.Contains(t, stderr, `usage:`)
+	require.NotContains(t, stderr, `help requested`)
+}
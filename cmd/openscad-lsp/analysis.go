@@ -0,0 +1,336 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+const diagnosticSource = `openscad`
+
+// builtins are the modules and functions provided by OpenSCAD itself
+var builtins = map[string]struct{}{}
+
+func init() {
+	for _, name := range strings.Fields(`
+		children circle color cube cylinder difference echo group hull
+		import intersection linear_extrude minkowski mirror multmatrix
+		offset parent_module polygon polyhedron projection render resize
+		rotate rotate_extrude scale sphere square surface text translate
+		union assert
+		abs acos asin atan atan2 ceil chr concat cos cross exp floor
+		is_bool is_function is_list is_num is_string is_undef len ln log
+		lookup max min norm ord pow rands round search sign sin sqrt str
+		tan version version_num
+	`) {
+		builtins[name] = struct{}{}
+	}
+}
+
+// scope is a file whose definitions are visible from another file
+type scope struct {
+	file    *file
+	symbols []*ast.Symbol
+	// used is true if the file is only visible through a `use` directive,
+	// which makes its modules and functions visible, but not its variables
+	used bool
+}
+
+// visible returns true if sym can be referred to from other files
+func (s *scope) visible(sym *ast.Symbol) bool {
+	return !s.used || sym.Kind == ast.SymbolModule || sym.Kind == ast.SymbolFunction
+}
+
+// resolve finds the file that an include or use directive in from
+// refers to. Directives are resolved relative to the directory of the
+// file first, and then relative to each of the search paths.
+func (s *server) resolve(from *file, name string) (string, bool) {
+	if filepath.IsAbs(name) {
+		return name, fileExists(name)
+	}
+	var dirs []string
+	if from.path != `` {
+		dirs = append(dirs, filepath.Dir(from.path))
+	}
+	dirs = append(dirs, s.searchPaths...)
+	for _, dir := range dirs {
+		if candidate := filepath.Join(dir, name); fileExists(candidate) {
+			return candidate, true
+		}
+	}
+	return ``, false
+}
+
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+// dependency loads the file that an include or use directive refers to.
+// Files that are open in the editor take precedence over those on disk.
+func (s *server) dependency(from *file, name string) (*file, error) {
+	path, ok := s.resolve(from, name)
+	if !ok {
+		return nil, fmt.Errorf(`cannot find %q`, name)
+	}
+	if f, ok := s.files[pathToURI(path)]; ok {
+		return f, nil
+	}
+	src, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read %q: %w`, name, err)
+	}
+	return newFile(pathToURI(path), 0, string(src)), nil
+}
+
+// scopes lists the files whose definitions are visible from f, starting
+// with f itself. Files that f includes are visible in their entirety,
+// along with the files that they include or use in turn. Used files only
+// make their own modules and functions visible, and those of the files
+// that they include.
+func (s *server) scopes(f *file) []*scope {
+	var scopes []*scope
+	seen := make(map[string]struct{})
+	var visit func(*file, bool)
+	visit = func(f *file, used bool) {
+		key := fmt.Sprintf(`%s:%t`, f.uri, used)
+		if _, ok := seen[key]; ok {
+			return
+		}
+		seen[key] = struct{}{}
+		scopes = append(scopes, &scope{file: f, symbols: ast.Symbols(f.stmts), used: used})
+
+		for _, stmt := range f.stmts {
+			switch v := stmt.(type) {
+			case *ast.Include:
+				if dep, err := s.dependency(f, v.Name()); err == nil {
+					visit(dep, used)
+				}
+			case *ast.Use:
+				if used {
+					continue
+				}
+				if dep, err := s.dependency(f, v.Name()); err == nil {
+					visit(dep, true)
+				}
+			}
+		}
+	}
+	visit(f, false)
+	return scopes
+}
+
+// definition is where a symbol is defined
+type definition struct {
+	file   *file
+	symbol *ast.Symbol
+}
+
+func (d *definition) location() location {
+	return location{
+		URI:   d.file.uri,
+		Range: d.file.nameRange(d.symbol.Pos, d.symbol.Name),
+	}
+}
+
+// matches returns true if sym is what name refers to. Calls refer to
+// modules and functions, and everything else refers to variables.
+func matches(sym *ast.Symbol, name string, call bool) bool {
+	if sym.Name != name {
+		return false
+	}
+	switch sym.Kind {
+	case ast.SymbolModule, ast.SymbolFunction:
+		return call
+	default:
+		return !call
+	}
+}
+
+// enclosing returns the top level module or function that p is in
+func (f *file) enclosing(p position) *ast.Symbol {
+	stmts, ranges := f.statements()
+	for i, stmt := range stmts {
+		if p.before(ranges[i].Start) || !p.before(ranges[i].End) {
+			continue
+		}
+		for _, sym := range ast.Symbols(stmt) {
+			if sym.Kind == ast.SymbolModule || sym.Kind == ast.SymbolFunction {
+				return sym
+			}
+		}
+	}
+	return nil
+}
+
+// lookup finds the definition of name, as referred to at p in f
+func (s *server) lookup(f *file, p position, name string, call bool) *definition {
+	// parameters and local definitions shadow everything else. If there
+	// are several, the closest one defined before p wins.
+	if parent := f.enclosing(p); parent != nil {
+		var found *ast.Symbol
+		for _, sym := range parent.Children {
+			if !matches(sym, name, call) {
+				continue
+			}
+			if found == nil || !p.before(f.position(sym.Pos)) {
+				found = sym
+			}
+		}
+		if found != nil {
+			return &definition{file: f, symbol: found}
+		}
+	}
+
+	for _, sc := range s.scopes(f) {
+		for _, sym := range sc.symbols {
+			if matches(sym, name, call) && sc.visible(sym) {
+				return &definition{file: sc.file, symbol: sym}
+			}
+		}
+	}
+	return nil
+}
+
+// directive returns the file name and the position of an include or
+// use directive
+func directive(stmt ast.Stmt) (string, ast.Position, bool) {
+	switch v := stmt.(type) {
+	case *ast.Include:
+		return v.Name(), v.Pos(), true
+	case *ast.Use:
+		return v.Name(), v.Pos(), true
+	default:
+		return ``, ast.Position{}, false
+	}
+}
+
+// directiveAt returns the include or use directive at p, if any
+func (f *file) directiveAt(p position) (string, bool) {
+	for _, stmt := range f.stmts {
+		name, pos, ok := directive(stmt)
+		if !ok {
+			continue
+		}
+		rng := f.directiveRange(pos)
+		if !p.before(rng.Start) && p.before(rng.End) {
+			return name, true
+		}
+	}
+	return ``, false
+}
+
+// diagnose reports the errors in f, along with code that is valid, but
+// likely to be a mistake
+func (s *server) diagnose(f *file) []diagnostic {
+	diagnostics := []diagnostic{}
+	if f.err != nil {
+		d := diagnostic{Severity: severityError, Source: diagnosticSource, Message: f.err.Error()}
+		var perr *openscad.ParseError
+		if errors.As(f.err, &perr) {
+			d.Range = f.tokenRange(ast.Position{Line: perr.Line, Column: perr.Column})
+			d.Message = errors.Unwrap(perr).Error()
+		}
+		return append(diagnostics, d)
+	}
+
+	// references can only be checked if every dependency is available
+	complete := true
+	for _, stmt := range f.stmts {
+		name, pos, ok := directive(stmt)
+		if !ok {
+			continue
+		}
+		dep, err := s.dependency(f, name)
+		if err == nil && dep.err != nil {
+			err = fmt.Errorf(`%q can not be parsed`, name)
+		}
+		if err != nil {
+			complete = false
+			diagnostics = append(diagnostics, diagnostic{
+				Range:    f.directiveRange(pos),
+				Severity: severityError,
+				Source:   diagnosticSource,
+				Message:  err.Error(),
+			})
+		}
+	}
+
+	diagnostics = append(diagnostics, duplicates(f)...)
+	if complete {
+		diagnostics = append(diagnostics, s.undefined(f)...)
+	}
+	return diagnostics
+}
+
+// duplicates reports modules, functions and variables that are defined
+// more than once at the top level. OpenSCAD silently uses the last one.
+func duplicates(f *file) []diagnostic {
+	var diagnostics []diagnostic
+	defined := make(map[ast.SymbolKind]map[string]*ast.Symbol)
+	for _, sym := range ast.Symbols(f.stmts) {
+		if defined[sym.Kind] == nil {
+			defined[sym.Kind] = make(map[string]*ast.Symbol)
+		}
+		if prev, ok := defined[sym.Kind][sym.Name]; ok {
+			diagnostics = append(diagnostics, diagnostic{
+				Range:    f.nameRange(sym.Pos, sym.Name),
+				Severity: severityWarning,
+				Source:   diagnosticSource,
+				Message:  fmt.Sprintf(`%s %q is already defined on line %d`, sym.Kind, sym.Name, prev.Pos.Line),
+			})
+		}
+		defined[sym.Kind][sym.Name] = sym
+	}
+	return diagnostics
+}
+
+// undefined reports calls to modules and functions that are not defined
+// anywhere. Names of variables and parameters are allowed as well, as
+// they may hold function literals.
+func (s *server) undefined(f *file) []diagnostic {
+	known := make(map[string]struct{})
+	var add func([]*ast.Symbol)
+	add = func(symbols []*ast.Symbol) {
+		for _, sym := range symbols {
+			known[sym.Name] = struct{}{}
+			add(sym.Children)
+		}
+	}
+	for _, sc := range s.scopes(f) {
+		for _, sym := range sc.symbols {
+			if sc.file == f {
+				add([]*ast.Symbol{sym})
+			} else if sc.visible(sym) {
+				known[sym.Name] = struct{}{}
+			}
+		}
+	}
+
+	var diagnostics []diagnostic
+	ast.Walk(f.stmts, func(node interface{}) bool {
+		call, ok := node.(*ast.Call)
+		if !ok {
+			return true
+		}
+		if _, ok := builtins[call.Name()]; ok {
+			return true
+		}
+		if _, ok := known[call.Name()]; ok {
+			return true
+		}
+		diagnostics = append(diagnostics, diagnostic{
+			Range:    f.nameRange(call.Pos(), call.Name()),
+			Severity: severityWarning,
+			Source:   diagnosticSource,
+			Message:  fmt.Sprintf(`unknown module or function %q`, call.Name()),
+		})
+		return true
+	})
+	return diagnostics
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+// file is an OpenSCAD source file, either open in the editor or read
+// from disk
+type file struct {
+	uri     string
+	path    string // empty if the file is not on the local file system
+	version int
+	text    string
+	lines   []string
+	stmts   ast.Stmts
+	err     error // the error from parsing text, if any
+	// lastParsed is the previous version of the document that could be
+	// parsed, if text can not be
+	lastParsed *file
+}
+
+func newFile(uri string, version int, text string) *file {
+	f := &file{
+		uri:     uri,
+		path:    uriToPath(uri),
+		version: version,
+		text:    text,
+		lines:   strings.Split(text, "\n"),
+	}
+	f.stmts, f.err = openscad.Parse([]byte(text), openscad.WithPositions())
+	return f
+}
+
+// parsed returns the most recent version of the document that could be
+// parsed
+func (f *file) parsed() *file {
+	if f.err != nil && f.lastParsed != nil {
+		return f.lastParsed
+	}
+	return f
+}
+
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != `file` {
+		return ``
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+func pathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: `file`, Path: filepath.ToSlash(path)}).String()
+}
+
+func isIdentRune(r rune) bool {
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// utf16Len returns the length of runes in UTF-16 code units, which is
+// what LSP positions are expressed in
+func utf16Len(runes []rune) int {
+	var n int
+	for _, r := range runes {
+		if r >= 0x10000 {
+			n += 2
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// line returns the runes of the given line, without the line terminator
+func (f *file) line(n int) []rune {
+	if n < 0 || n >= len(f.lines) {
+		return nil
+	}
+	return []rune(strings.TrimSuffix(f.lines[n], "\r"))
+}
+
+// end returns the position of the end of the file
+func (f *file) end() position {
+	last := len(f.lines) - 1
+	return position{Line: last, Character: utf16Len(f.line(last))}
+}
+
+// position converts a position recorded by the parser
+func (f *file) position(pos ast.Position) position {
+	if pos.Line-1 >= len(f.lines) {
+		return f.end()
+	}
+	if !pos.IsValid() {
+		return position{}
+	}
+	runes := f.line(pos.Line - 1)
+	col := pos.Column - 1
+	if col > len(runes) {
+		col = len(runes)
+	}
+	return position{Line: pos.Line - 1, Character: utf16Len(runes[:col])}
+}
+
+// column converts an LSP position into an index into the runes of its line
+func (f *file) column(p position) int {
+	var n int
+	for i, r := range f.line(p.Line) {
+		if n >= p.Character {
+			return i
+		}
+		n += utf16Len([]rune{r})
+	}
+	return len(f.line(p.Line))
+}
+
+// wordAt returns the identifier under p, and whether it is followed by
+// an opening parenthesis, which makes it the name of a module or function
+func (f *file) wordAt(p position) (string, lspRange, bool, bool) {
+	runes := f.line(p.Line)
+	start := f.column(p)
+	end := start
+	for start > 0 && isIdentRune(runes[start-1]) {
+		start--
+	}
+	for end < len(runes) && isIdentRune(runes[end]) {
+		end++
+	}
+	if start == end {
+		return ``, lspRange{}, false, false
+	}
+
+	call := false
+	for i := end; i < len(runes); i++ {
+		if !unicode.IsSpace(runes[i]) {
+			call = runes[i] == '('
+			break
+		}
+	}
+	rng := lspRange{
+		Start: position{Line: p.Line, Character: utf16Len(runes[:start])},
+		End:   position{Line: p.Line, Character: utf16Len(runes[:end])},
+	}
+	return string(runes[start:end]), rng, call, true
+}
+
+// indexWord returns the index of the first occurrence of the identifier
+// word in runes at or after from, or -1
+func indexWord(runes []rune, from int, word string) int {
+	w := []rune(word)
+	for i := from; i+len(w) <= len(runes); i++ {
+		if string(runes[i:i+len(w)]) != word {
+			continue
+		}
+		if (i > 0 && isIdentRune(runes[i-1])) || (i+len(w) < len(runes) && isIdentRune(runes[i+len(w)])) {
+			continue
+		}
+		return i
+	}
+	return -1
+}
+
+// nameRange returns the range of name, looking for it from the position
+// that the parser recorded for its definition. For modules and functions
+// that is the position of the keyword that precedes the name.
+func (f *file) nameRange(pos ast.Position, name string) lspRange {
+	start := f.position(pos)
+	runes := f.line(start.Line)
+	if i := indexWord(runes, f.column(start), name); i >= 0 {
+		start.Character = utf16Len(runes[:i])
+	}
+	end := start
+	end.Character += utf16Len([]rune(name))
+	return lspRange{Start: start, End: end}
+}
+
+// directiveRange returns the range of the include or use directive at pos
+func (f *file) directiveRange(pos ast.Position) lspRange {
+	start := f.position(pos)
+	runes := f.line(start.Line)
+	end := len(runes)
+	for i := f.column(start); i < len(runes); i++ {
+		if runes[i] == '>' {
+			end = i + 1
+			break
+		}
+	}
+	return lspRange{Start: start, End: position{Line: start.Line, Character: utf16Len(runes[:end])}}
+}
+
+// tokenRange returns the range of the token at pos, for reporting errors
+func (f *file) tokenRange(pos ast.Position) lspRange {
+	start := f.position(pos)
+	if _, rng, _, ok := f.wordAt(start); ok && rng.Start == start {
+		return rng
+	}
+	end := start
+	if runes, col := f.line(start.Line), f.column(start); col < len(runes) {
+		end.Character += utf16Len(runes[col : col+1])
+	}
+	return lspRange{Start: start, End: end}
+}
+
+// statements returns the top level statements of f along with the range
+// that each of them spans, which extends up to the next statement
+func (f *file) statements() ([]ast.Stmt, []lspRange) {
+	var stmts []ast.Stmt
+	var ranges []lspRange
+	for _, stmt := range f.stmts {
+		p, ok := stmt.(ast.Positioner)
+		if !ok || !p.Pos().IsValid() {
+			continue
+		}
+		start := f.position(p.Pos())
+		if n := len(ranges); n > 0 {
+			ranges[n-1].End = start
+		}
+		stmts = append(stmts, stmt)
+		ranges = append(ranges, lspRange{Start: start, End: f.end()})
+	}
+	return stmts, ranges
+}
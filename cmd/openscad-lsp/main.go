@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// openscad-lsp is a language server for OpenSCAD. It speaks the Language
+// Server Protocol over standard input and output.
+//
+//	openscad-lsp [flags]
+//
+// It reports parse errors and likely mistakes as diagnostics, formats
+// documents the same way as openscad-fmt, and provides document symbols,
+// hover information and go-to-definition across included and used files.
+//
+// Files are looked up relative to the file that refers to them, then in
+// the directories given with -I, and finally in OPENSCADPATH.
+func main() {
+	os.Exit(_main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// stringList is a flag that can be specified multiple times
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, string(filepath.ListSeparator))
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func _main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if err := run(args, stdin, stdout, stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			// the usage has already been printed
+			return 0
+		}
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var includePaths stringList
+
+	flags := flag.NewFlagSet(`openscad-lsp`, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Var(&includePaths, `I`, `add a directory to search for included and used files (may be repeated)`)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: openscad-lsp [flags]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return fmt.Errorf(`unexpected arguments`)
+	}
+
+	searchPaths := append([]string(nil), includePaths...)
+	if env := os.Getenv(`OPENSCADPATH`); env != "" {
+		searchPaths = append(searchPaths, filepath.SplitList(env)...)
+	}
+
+	if err := newServer(stdin, stdout, searchPaths).serve(); err != nil {
+		return fmt.Errorf(`failed to serve: %w`, err)
+	}
+	return nil
+}
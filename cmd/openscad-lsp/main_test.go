@@ -0,0 +1,371 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// client is an in-process LSP client, talking to a server over pipes
+type client struct {
+	t        *testing.T
+	conn     *conn
+	nextID   int
+	messages chan *message
+	done     chan error
+	// notifications that arrived while waiting for a response
+	pending []*message
+}
+
+func newClient(t *testing.T, searchPaths ...string) *client {
+	t.Helper()
+
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{
+		t:        t,
+		conn:     newConn(clientIn, clientOut),
+		messages: make(chan *message, 16),
+		done:     make(chan error, 1),
+	}
+	go func() {
+		c.done <- newServer(serverIn, serverOut, searchPaths).serve()
+		serverOut.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		for {
+			msg, err := c.conn.read()
+			if err != nil {
+				return
+			}
+			c.messages <- msg
+		}
+	}()
+	t.Cleanup(func() {
+		clientOut.Close()
+		<-c.done
+	})
+	return c
+}
+
+func (c *client) receive() *message {
+	c.t.Helper()
+	select {
+	case msg, ok := <-c.messages:
+		require.True(c.t, ok, `connection should not be closed`)
+		return msg
+	case <-time.After(5 * time.Second):
+		require.FailNow(c.t, `timed out waiting for a message from the server`)
+		return nil
+	}
+}
+
+// call sends a request, and decodes the result into result
+func (c *client) call(method string, params, result interface{}) *responseError {
+	c.t.Helper()
+	c.nextID++
+	id := json.RawMessage(fmt.Sprintf(`%d`, c.nextID))
+	require.NoError(c.t, c.conn.write(&struct {
+		JSONRPC string          `json:"jsonrpc"`
+		ID      json.RawMessage `json:"id"`
+		Method  string          `json:"method"`
+		Params  interface{}     `json:"params"`
+	}{`2.0`, id, method, params}), `request should be sent`)
+
+	for {
+		msg := c.receive()
+		if msg.isNotification() {
+			c.pending = append(c.pending, msg)
+			continue
+		}
+		require.Equal(c.t, string(id), string(msg.ID), `response should match the request`)
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			require.NoError(c.t, json.Unmarshal(msg.Result, result), `result should be decoded`)
+		}
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	c.t.Helper()
+	require.NoError(c.t, c.conn.notify(method, params), `notification should be sent`)
+}
+
+// diagnostics waits for the next diagnostics to be published
+func (c *client) diagnostics() *publishDiagnosticsParams {
+	c.t.Helper()
+	var msg *message
+	if len(c.pending) > 0 {
+		msg, c.pending = c.pending[0], c.pending[1:]
+	} else {
+		msg = c.receive()
+	}
+	require.Equal(c.t, `textDocument/publishDiagnostics`, msg.Method, `diagnostics should be published`)
+	var params publishDiagnosticsParams
+	require.NoError(c.t, json.Unmarshal(msg.Params, &params), `diagnostics should be decoded`)
+	return &params
+}
+
+func (c *client) initialize() {
+	c.t.Helper()
+	var res initializeResult
+	require.Nil(c.t, c.call(`initialize`, map[string]interface{}{}, &res), `initialize should succeed`)
+	require.Equal(c.t, textDocumentSyncFull, res.Capabilities.TextDocumentSync)
+	c.notify(`initialized`, map[string]interface{}{})
+}
+
+func (c *client) open(uri, text string) *publishDiagnosticsParams {
+	c.t.Helper()
+	c.notify(`textDocument/didOpen`, &didOpenTextDocumentParams{
+		TextDocument: textDocumentItem{URI: uri, LanguageID: `openscad`, Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func (c *client) change(uri string, version int, text string) *publishDiagnosticsParams {
+	c.t.Helper()
+	c.notify(`textDocument/didChange`, &didChangeTextDocumentParams{
+		TextDocument:   versionedTextDocumentIdentifier{URI: uri, Version: version},
+		ContentChanges: []textDocumentContentChangeEvent{{Text: text}},
+	})
+	return c.diagnostics()
+}
+
+func at(uri string, line, character int) *textDocumentPositionParams {
+	return &textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Position:     position{Line: line, Character: character},
+	}
+}
+
+func span(line, start, end int) lspRange {
+	return lspRange{
+		Start: position{Line: line, Character: start},
+		End:   position{Line: line, Character: end},
+	}
+}
+
+const (
+	libSource = `module gear(teeth, width=5) {
+  cylinder(r=teeth, h=width);
+}
+function pitch(teeth) = teeth * 2;
+`
+	partsSource = "size = 10;\n"
+	mainSource  = `use <lib.scad>
+include <parts.scad>
+
+module box(w, h=2) {
+  cube([w, size, h]);
+}
+
+box(pitch(3));
+gear(12);
+`
+)
+
+func setupFiles(t *testing.T) (string, map[string]string) {
+	t.Helper()
+	dir := t.TempDir()
+	uris := make(map[string]string)
+	for name, src := range map[string]string{
+		`lib.scad`:   libSource,
+		`parts.scad`: partsSource,
+		`main.scad`:  mainSource,
+	} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte(src), 0o644), `os.WriteFile should succeed`)
+		uris[name] = pathToURI(path)
+	}
+	return dir, uris
+}
+
+func TestServer(t *testing.T) {
+	_, uris := setupFiles(t)
+	main := uris[`main.scad`]
+
+	c := newClient(t)
+	c.initialize()
+	diagnostics := c.open(main, mainSource)
+	require.Equal(t, main, diagnostics.URI)
+	require.Empty(t, diagnostics.Diagnostics, `valid code should have no diagnostics`)
+
+	t.Run("document symbols", func(t *testing.T) {
+		var symbols []*documentSymbol
+		require.Nil(t, c.call(`textDocument/documentSymbol`, &documentSymbolParams{
+			TextDocument: textDocumentIdentifier{URI: main},
+		}, &symbols))
+		require.Len(t, symbols, 1)
+		require.Equal(t, `box`, symbols[0].Name)
+		require.Equal(t, `module box(w, h=2)`, symbols[0].Detail)
+		require.Equal(t, symbolKindModule, symbols[0].Kind)
+		require.Equal(t, span(3, 7, 10), symbols[0].SelectionRange)
+		require.Equal(t, lspRange{Start: position{Line: 3}, End: position{Line: 7}}, symbols[0].Range)
+		require.Len(t, symbols[0].Children, 2)
+		require.Equal(t, `w`, symbols[0].Children[0].Name)
+		require.Equal(t, `h = 2`, symbols[0].Children[1].Detail)
+	})
+	t.Run("definition of a used module", func(t *testing.T) {
+		var loc location
+		require.Nil(t, c.call(`textDocument/definition`, at(main, 8, 1), &loc))
+		require.Equal(t, location{URI: uris[`lib.scad`], Range: span(0, 7, 11)}, loc)
+	})
+	t.Run("definition of a used function", func(t *testing.T) {
+		var loc location
+		require.Nil(t, c.call(`textDocument/definition`, at(main, 7, 6), &loc))
+		require.Equal(t, location{URI: uris[`lib.scad`], Range: span(3, 9, 14)}, loc)
+	})
+	t.Run("definition of an included variable", func(t *testing.T) {
+		var loc location
+		require.Nil(t, c.call(`textDocument/definition`, at(main, 4, 12), &loc))
+		require.Equal(t, location{URI: uris[`parts.scad`], Range: span(0, 0, 4)}, loc)
+	})
+	t.Run("definition of a parameter", func(t *testing.T) {
+		var loc location
+		require.Nil(t, c.call(`textDocument/definition`, at(main, 4, 8), &loc))
+		require.Equal(t, location{URI: main, Range: span(3, 11, 12)}, loc)
+	})
+	t.Run("definition of a directive", func(t *testing.T) {
+		var loc location
+		require.Nil(t, c.call(`textDocument/definition`, at(main, 1, 12), &loc))
+		require.Equal(t, location{URI: uris[`parts.scad`]}, loc)
+	})
+	t.Run("definition of a builtin", func(t *testing.T) {
+		var loc *location
+		require.Nil(t, c.call(`textDocument/definition`, at(main, 4, 3), &loc))
+		require.Nil(t, loc, `builtins have no definition`)
+	})
+	t.Run("hover", func(t *testing.T) {
+		var h hover
+		require.Nil(t, c.call(`textDocument/hover`, at(main, 8, 2), &h))
+		require.Equal(t, markupKindMarkdown, h.Contents.Kind)
+		require.Equal(t, "```openscad\nmodule gear(teeth, width=5)\n```\n\nDefined in `lib.scad`", h.Contents.Value)
+		require.Equal(t, span(8, 0, 4), *h.Range)
+
+		require.Nil(t, c.call(`textDocument/hover`, at(main, 7, 1), &h))
+		require.Equal(t, "```openscad\nmodule box(w, h=2)\n```", h.Contents.Value)
+	})
+	t.Run("formatting", func(t *testing.T) {
+		const unformatted = "module m(){cube(1);}\n"
+		uri := uris[`main.scad`] + `.tmp`
+		c.open(uri, unformatted)
+
+		var edits []textEdit
+		require.Nil(t, c.call(`textDocument/formatting`, &documentFormattingParams{
+			TextDocument: textDocumentIdentifier{URI: uri},
+			Options:      formattingOptions{TabSize: 4, InsertSpaces: true},
+		}, &edits))
+		require.Equal(t, []textEdit{{
+			Range:   lspRange{End: position{Line: 1}},
+			NewText: "module m()\n{\n    cube(1);\n}\n",
+		}}, edits)
+
+		c.change(uri, 2, "// a comment\n"+unformatted)
+		rerr := c.call(`textDocument/formatting`, &documentFormattingParams{
+			TextDocument: textDocumentIdentifier{URI: uri},
+			Options:      formattingOptions{TabSize: 2, InsertSpaces: true},
+		}, nil)
+		require.NotNil(t, rerr, `formatting code with comments should fail`)
+		require.Equal(t, codeRequestFailed, rerr.Code)
+		require.Contains(t, rerr.Message, `comments`)
+
+		c.notify(`textDocument/didClose`, &didCloseTextDocumentParams{TextDocument: textDocumentIdentifier{URI: uri}})
+		require.Empty(t, c.diagnostics().Diagnostics, `diagnostics should be cleared on close`)
+	})
+	t.Run("diagnostics", func(t *testing.T) {
+		diagnostics := c.change(main, 2, "x = 1;\n\n\n\n\n\n\nbox(x;\n")
+		require.Equal(t, 2, diagnostics.Version)
+		require.Len(t, diagnostics.Diagnostics, 1)
+		require.Equal(t, severityError, diagnostics.Diagnostics[0].Severity)
+		require.Equal(t, span(7, 5, 6), diagnostics.Diagnostics[0].Range)
+
+		// definitions from the last version that parsed are still available
+		var loc location
+		require.Nil(t, c.call(`textDocument/definition`, at(main, 7, 1), &loc))
+		require.Equal(t, location{URI: main, Range: span(3, 7, 10)}, loc)
+
+		diagnostics = c.change(main, 3, `include <missing.scad>
+use <lib.scad>
+x = 1;
+module m() { gear(1); }
+module m() { sprocket(1); }
+x = 2;
+`)
+		var messages []string
+		for _, d := range diagnostics.Diagnostics {
+			messages = append(messages, fmt.Sprintf(`%d:%d-%d %d %s`, d.Range.Start.Line, d.Range.Start.Character, d.Range.End.Character, d.Severity, d.Message))
+		}
+		require.Equal(t, []string{
+			`0:0-22 1 cannot find "missing.scad"`,
+			`4:7-8 2 module "m" is already defined on line 4`,
+			`5:0-1 2 variable "x" is already defined on line 3`,
+		}, messages, `references should not be checked while dependencies are missing`)
+
+		diagnostics = c.change(main, 4, "use <lib.scad>\nmodule m() { gear(1); }\nsprocket(1);\n")
+		require.Len(t, diagnostics.Diagnostics, 1)
+		require.Equal(t, span(2, 0, 8), diagnostics.Diagnostics[0].Range)
+		require.Equal(t, severityWarning, diagnostics.Diagnostics[0].Severity)
+		require.Equal(t, `unknown module or function "sprocket"`, diagnostics.Diagnostics[0].Message)
+	})
+	t.Run("shutdown", func(t *testing.T) {
+		require.Nil(t, c.call(`shutdown`, nil, nil))
+		c.notify(`exit`, nil)
+		require.NoError(t, <-c.done, `server should exit cleanly`)
+		c.done <- nil
+	})
+}
+
+func TestServerErrors(t *testing.T) {
+	t.Run("not initialized", func(t *testing.T) {
+		c := newClient(t)
+		rerr := c.call(`textDocument/hover`, at(`file:///a.scad`, 0, 0), nil)
+		require.NotNil(t, rerr)
+		require.Equal(t, codeServerNotInitialized, rerr.Code)
+	})
+	t.Run("unsupported method", func(t *testing.T) {
+		c := newClient(t)
+		c.initialize()
+		rerr := c.call(`textDocument/rename`, map[string]interface{}{}, nil)
+		require.NotNil(t, rerr)
+		require.Equal(t, codeMethodNotFound, rerr.Code)
+	})
+	t.Run("search paths", func(t *testing.T) {
+		dir, _ := setupFiles(t)
+		c := newClient(t, dir)
+		c.initialize()
+		// the document is not saved, so directives are resolved using
+		// the search paths only
+		diagnostics := c.open(`untitled:Untitled-1`, "use <lib.scad>\ngear(1);\n")
+		require.Empty(t, diagnostics.Diagnostics)
+	})
+	t.Run("exit without shutdown", func(t *testing.T) {
+		c := newClient(t)
+		c.initialize()
+		c.notify(`exit`, nil)
+		require.Error(t, <-c.done, `server should report that it was not shut down`)
+		c.done <- nil
+	})
+}
+
+func TestHelp(t *testing.T) {
+	stderr, err := os.CreateTemp(t.TempDir(), `stderr`)
+	require.NoError(t, err, `os.CreateTemp should succeed`)
+	defer stderr.Close()
+
+	require.Equal(t, 0, _main([]string{`-h`}, nil, io.Discard, stderr))
+	out, err := os.ReadFile(stderr.Name())
+	require.NoError(t, err, `os.ReadFile should succeed`)
+	require.Contains(t, string(out), `usage:`)
+	require.NotContains(t, string(out), `help requested`)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+type server struct {
+	conn        *conn
+	searchPaths []string
+	files       map[string]*file // documents open in the editor, by URI
+	initialized bool
+	shutdown    bool
+}
+
+func newServer(r io.Reader, w io.Writer, searchPaths []string) *server {
+	return &server{
+		conn:        newConn(r, w),
+		searchPaths: searchPaths,
+		files:       make(map[string]*file),
+	}
+}
+
+// serve processes messages until the client sends the exit notification,
+// or closes the connection
+func (s *server) serve() error {
+	for {
+		msg, err := s.conn.read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var rerr *responseError
+			if errors.As(err, &rerr) {
+				if err := s.conn.replyError(nil, rerr); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+
+		if msg.Method == `exit` {
+			if !s.shutdown {
+				return fmt.Errorf(`received exit notification before shutdown request`)
+			}
+			return nil
+		}
+
+		res, err := s.dispatch(msg)
+		var rerr *responseError
+		if err != nil && !errors.As(err, &rerr) {
+			return err
+		}
+		if msg.isNotification() {
+			continue
+		}
+		if rerr != nil {
+			err = s.conn.replyError(msg.ID, rerr)
+		} else {
+			err = s.conn.reply(msg.ID, res)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func decode(msg *message, v interface{}) error {
+	if err := json.Unmarshal(msg.Params, v); err != nil {
+		return &responseError{Code: codeInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
+
+// dispatch handles a single request or notification. Errors that are not
+// of type *responseError are fatal.
+func (s *server) dispatch(msg *message) (res interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &responseError{Code: codeInternalError, Message: fmt.Sprintf(`%s: %v`, msg.Method, r)}
+		}
+	}()
+
+	switch {
+	case msg.Method == ``:
+		return nil, &responseError{Code: codeInvalidRequest, Message: `missing method`}
+	case !s.initialized && msg.Method != `initialize`:
+		return nil, &responseError{Code: codeServerNotInitialized, Message: `server is not initialized`}
+	case s.shutdown:
+		return nil, &responseError{Code: codeInvalidRequest, Message: `server is shutting down`}
+	}
+
+	switch msg.Method {
+	case `initialize`:
+		s.initialized = true
+		return &initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync:           textDocumentSyncFull,
+				DocumentFormattingProvider: true,
+				DocumentSymbolProvider:     true,
+				DefinitionProvider:         true,
+				HoverProvider:              true,
+			},
+			ServerInfo: serverInfo{Name: `openscad-lsp`},
+		}, nil
+	case `initialized`:
+		return nil, nil
+	case `shutdown`:
+		s.shutdown = true
+		return nil, nil
+	case `textDocument/didOpen`:
+		var params didOpenTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		doc := params.TextDocument
+		return nil, s.update(newFile(doc.URI, doc.Version, doc.Text))
+	case `textDocument/didChange`:
+		var params didChangeTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		if len(params.ContentChanges) == 0 {
+			return nil, nil
+		}
+		// only full synchronization is supported, so the last change
+		// holds the entire document
+		text := params.ContentChanges[len(params.ContentChanges)-1].Text
+		return nil, s.update(newFile(params.TextDocument.URI, params.TextDocument.Version, text))
+	case `textDocument/didSave`:
+		return nil, nil
+	case `textDocument/didClose`:
+		var params didCloseTextDocumentParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		delete(s.files, params.TextDocument.URI)
+		return nil, s.conn.notify(`textDocument/publishDiagnostics`, &publishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []diagnostic{},
+		})
+	case `textDocument/formatting`:
+		var params documentFormattingParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.formatting(&params)
+	case `textDocument/documentSymbol`:
+		var params documentSymbolParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.documentSymbol(&params)
+	case `textDocument/definition`:
+		var params textDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.definition(&params)
+	case `textDocument/hover`:
+		var params textDocumentPositionParams
+		if err := decode(msg, &params); err != nil {
+			return nil, err
+		}
+		return s.hover(&params)
+	default:
+		if strings.HasPrefix(msg.Method, `$/`) {
+			// optional notifications and requests may be ignored
+			return nil, nil
+		}
+		return nil, &responseError{Code: codeMethodNotFound, Message: fmt.Sprintf(`unsupported method %q`, msg.Method)}
+	}
+}
+
+// update records the new content of a document, and publishes its
+// diagnostics
+func (s *server) update(f *file) error {
+	if prev, ok := s.files[f.uri]; ok && f.err != nil {
+		// keep the last version that could be parsed, so that navigation
+		// keeps working while typing
+		f.lastParsed = prev.parsed()
+	}
+	s.files[f.uri] = f
+	return s.conn.notify(`textDocument/publishDiagnostics`, &publishDiagnosticsParams{
+		URI:         f.uri,
+		Version:     f.version,
+		Diagnostics: s.diagnose(f),
+	})
+}
+
+// file returns the document with the given URI. Documents that are not
+// open in the editor are read from disk.
+func (s *server) file(uri string) (*file, error) {
+	if f, ok := s.files[uri]; ok {
+		return f, nil
+	}
+	path := uriToPath(uri)
+	if path == `` {
+		return nil, &responseError{Code: codeRequestFailed, Message: fmt.Sprintf(`unknown document %q`, uri)}
+	}
+	f, err := s.dependency(&file{}, path)
+	if err != nil {
+		return nil, &responseError{Code: codeRequestFailed, Message: err.Error()}
+	}
+	return f, nil
+}
+
+func (s *server) formatting(params *documentFormattingParams) ([]textEdit, error) {
+	f, err := s.file(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	style := ast.FormatStyle{
+		IndentWidth: params.Options.TabSize,
+		UseTabs:     !params.Options.InsertSpaces,
+	}
+	formatted, err := openscad.Format([]byte(f.text), style)
+	if err != nil {
+		return nil, &responseError{Code: codeRequestFailed, Message: err.Error()}
+	}
+	if string(formatted) == f.text {
+		return []textEdit{}, nil
+	}
+	return []textEdit{{
+		Range:   lspRange{End: f.end()},
+		NewText: string(formatted),
+	}}, nil
+}
+
+func (s *server) documentSymbol(params *documentSymbolParams) ([]*documentSymbol, error) {
+	f, err := s.file(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	var convert func(sym *ast.Symbol) *documentSymbol
+	convert = func(sym *ast.Symbol) *documentSymbol {
+		kind := symbolKindVariable
+		switch sym.Kind {
+		case ast.SymbolModule:
+			kind = symbolKindModule
+		case ast.SymbolFunction:
+			kind = symbolKindFunction
+		}
+		rng := f.nameRange(sym.Pos, sym.Name)
+		ds := &documentSymbol{
+			Name:           sym.Name,
+			Detail:         sym.Signature,
+			Kind:           kind,
+			Range:          rng,
+			SelectionRange: rng,
+		}
+		for _, child := range sym.Children {
+			ds.Children = append(ds.Children, convert(child))
+		}
+		return ds
+	}
+
+	symbols := []*documentSymbol{}
+	stmts, ranges := f.statements()
+	for i, stmt := range stmts {
+		for _, sym := range ast.Symbols(stmt) {
+			ds := convert(sym)
+			// top level symbols span their entire definition
+			ds.Range = ranges[i]
+			symbols = append(symbols, ds)
+		}
+	}
+	return symbols, nil
+}
+
+func (s *server) definition(params *textDocumentPositionParams) (*location, error) {
+	f, err := s.file(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	if name, ok := f.directiveAt(params.Position); ok {
+		// directives that can not be resolved are reported as diagnostics
+		path, ok := s.resolve(f, name)
+		if !ok {
+			return nil, nil
+		}
+		return &location{URI: pathToURI(path)}, nil
+	}
+
+	name, _, call, ok := f.wordAt(params.Position)
+	if !ok {
+		return nil, nil
+	}
+	def := s.lookup(f.parsed(), params.Position, name, call)
+	if def == nil {
+		return nil, nil
+	}
+	loc := def.location()
+	return &loc, nil
+}
+
+func (s *server) hover(params *textDocumentPositionParams) (*hover, error) {
+	f, err := s.file(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	name, rng, call, ok := f.wordAt(params.Position)
+	if !ok {
+		return nil, nil
+	}
+	def := s.lookup(f.parsed(), params.Position, name, call)
+	if def == nil {
+		return nil, nil
+	}
+
+	value := "```openscad\n" + def.symbol.Signature + "\n```"
+	if def.file.uri != f.uri {
+		value += fmt.Sprintf("\n\nDefined in `%s`", filepath.Base(def.file.path))
+	}
+	return &hover{
+		Contents: markupContent{Kind: markupKindMarkdown, Value: value},
+		Range:    &rng,
+	}, nil
+}
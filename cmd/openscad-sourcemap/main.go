@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+// openscad-sourcemap reads the output of OpenSCAD from stdin, and writes
+// it to stdout with the locations in warnings and errors translated back to
+// the original files, using a source map created during amalgamation.
+//
+//	openscad -o out.stl out.scad 2>&1 | openscad-sourcemap out.scad.map
+func main() {
+	if err := _main(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
+
+func _main(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: openscad-sourcemap <sourcemap>")
+	}
+
+	buf, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("failed to read source map: %s", err)
+	}
+
+	var sm ast.SourceMap
+	if err := json.Unmarshal(buf, &sm); err != nil {
+		return fmt.Errorf("failed to parse source map: %s", err)
+	}
+
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		fmt.Fprintln(stdout, sm.TranslateMessage(scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read input: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceMap(t *testing.T) {
+	dir := t.TempDir()
+	mapFile := filepath.Join(dir, "out.scad.map")
+	require.NoError(t, os.WriteFile(mapFile, []byte(`{
+  "version": 1,
+  "file": "out.scad",
+  "mappings": [
+    {"line": 1, "column": 1, "source": "main.scad", "originalLine": 1, "originalColumn": 1},
+    {"line": 4, "column": 1, "source": "lib.scad", "originalLine": 7, "originalColumn": 3}
+  ]
+}`), 0o644), `os.WriteFile should succeed`)
+
+	t.Run("translate", func(t *testing.T) {
+		input := strings.Join([]string{
+			`ECHO: 1`,
+			`WARNING: Ignoring unknown variable "y" in file out.scad, line 5`,
+			`ERROR: Parser error in file "/tmp/out.scad", line 2: syntax error`,
+			`WARNING: something in file other.scad, line 5`,
+		}, "\n")
+
+		var stdout strings.Builder
+		require.NoError(t, _main([]string{mapFile}, strings.NewReader(input), &stdout), `_main should succeed`)
+		require.Equal(t, `ECHO: 1
+WARNING: Ignoring unknown variable "y" in file lib.scad, line 7
+ERROR: Parser error in file "main.scad", line 1: syntax error
+WARNING: something in file other.scad, line 5
+`, stdout.String())
+	})
+	t.Run("usage", func(t *testing.T) {
+		var stdout strings.Builder
+		require.Error(t, _main(nil, strings.NewReader(""), &stdout), `_main should fail without a source map`)
+	})
+	t.Run("invalid source map", func(t *testing.T) {
+		invalid := filepath.Join(dir, "invalid.map")
+		require.NoError(t, os.WriteFile(invalid, []byte(`{`), 0o644), `os.WriteFile should succeed`)
+
+		var stdout strings.Builder
+		require.Error(t, _main([]string{invalid}, strings.NewReader(""), &stdout), `_main should fail`)
+		require.Error(t, _main([]string{filepath.Join(dir, "missing.map")}, strings.NewReader(""), &stdout), `_main should fail`)
+	})
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/internal/atomicfile"
+)
+
+// scad2go generates Go code that builds an OpenSCAD file using the dsl
+// package, so that hand-written models can be moved to Go.
+//
+//	scad2go [flags] [file.scad]
+//
+// Without a file, the source code is read from standard input.
+func main() {
+	os.Exit(_main(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func _main(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if err := run(args, stdin, stdout, stderr); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			// the usage has already been printed
+			return 0
+		}
+		fmt.Fprintf(stderr, "%s\n", err)
+		return 1
+	}
+	return 0
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	var output, pkg, funcName string
+
+	flags := flag.NewFlagSet(`scad2go`, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.StringVar(&output, `o`, ``, `write the result to this file instead of stdout`)
+	flags.StringVar(&pkg, `package`, `main`, `name of the generated package`)
+	flags.StringVar(&funcName, `func`, `Model`, `name of the generated function`)
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: scad2go [flags] [file.scad]\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var src []byte
+	var err error
+	switch flags.NArg() {
+	case 0:
+		src, err = io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf(`failed to read standard input: %w`, err)
+		}
+	case 1:
+		src, err = os.ReadFile(flags.Arg(0))
+		if err != nil {
+			return fmt.Errorf(`failed to read %s: %w`, flags.Arg(0), err)
+		}
+	default:
+		flags.Usage()
+		return fmt.Errorf(`expected at most one file`)
+	}
+
+	stmts, err := openscad.Parse(src)
+	if err != nil {
+		return fmt.Errorf(`failed to parse: %w`, err)
+	}
+
+	code, err := ast.GoCode(stmts, ast.WithGoPackage(pkg), ast.WithGoFunc(funcName))
+	if err != nil {
+		return err
+	}
+
+	if output == "" {
+		if _, err := stdout.Write(code); err != nil {
+			return fmt.Errorf(`failed to write output: %w`, err)
+		}
+		return nil
+	}
+	return atomicfile.WriteFile(output, code, 0o644)
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScad2Go(t *testing.T) {
+	t.Run("stdin", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 0, _main([]string{`-package`, `models`}, bytes.NewBufferString(`cube(1);`), &stdout, &stderr), stderr.String())
+		require.Contains(t, stdout.String(), "package models\n")
+		require.Contains(t, stdout.String(), `dsl.Call("cube", 1)`)
+	})
+	t.Run("parse error", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, 1, _main(nil, bytes.NewBufferString(`module (`), &stdout, &stderr))
+		require.Contains(t, stderr.String(), `failed to parse`)
+	})
+
+	gobin, err := exec.LookPath(`go`)
+	if err != nil {
+		t.Skip(`go command is not available`)
+	}
+
+	// the generated code must be within this module in order to build
+	files, err := filepath.Glob(filepath.Join(`..`, `..`, `examples`, `*`, `*.scad`))
+	require.NoError(t, err, `filepath.Glob should succeed`)
+	require.NotEmpty(t, files)
+	for _, file := range files {
+		file := file
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			src, err := os.ReadFile(file)
+			require.NoError(t, err, `os.ReadFile should succeed`)
+			stmts, err := openscad.Parse(src)
+			require.NoError(t, err, `openscad.Parse should succeed`)
+			expected, err := ast.EmitString(stmts)
+			require.NoError(t, err, `ast.EmitString should succeed`)
+
+			dir, err := os.MkdirTemp(`.`, `generated`)
+			require.NoError(t, err, `os.MkdirTemp should succeed`)
+			defer os.RemoveAll(dir)
+
+			output := filepath.Join(dir, `main.go`)
+			var stdout, stderr bytes.Buffer
+			require.Equal(t, 0, _main([]string{`-o`, output, file}, nil, &stdout, &stderr), stderr.String())
+
+			stdout.Reset()
+			stderr.Reset()
+			cmd := exec.Command(gobin, `run`, `./`+filepath.ToSlash(dir))
+			cmd.Stdout = &stdout
+			cmd.Stderr = &stderr
+			require.NoError(t, cmd.Run(), stderr.String())
+			require.Equal(t, expected, stdout.String())
+		})
+	}
+}
+
+func TestHelp(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, _main([]string{`-h`}, nil, &stdout, &stderr))
+	require.Contains(t, stderr.String(), `usage:`)
+	require.NotContains(t, stderr.String(), `help requested`)
+}
@@ -0,0 +1,250 @@
+// Package csg describes the CSG tree that an OpenSCAD design evaluates to:
+// primitive shapes with concrete parameters, under boolean operations and
+// transformations. This is the same structure as OpenSCAD's `.csg` output.
+//
+// Trees are produced by the interp package, which executes the modules
+// of a design.
+package csg
+
+import (
+	"math"
+
+	"github.com/lestrrat-go/openscad/eval"
+)
+
+// Node is a node of a CSG tree. It is one of the types defined in this
+// package.
+type Node interface {
+	csgNode()
+}
+
+// Group is a group of nodes, such as the instance of a user-defined
+// module, or the result of a for loop
+type Group struct {
+	Children []Node
+}
+
+type Union struct {
+	Children []Node
+}
+
+// Difference subtracts all but the first child from the first child
+type Difference struct {
+	Children []Node
+}
+
+type Intersection struct {
+	Children []Node
+}
+
+type Hull struct {
+	Children []Node
+}
+
+type Minkowski struct {
+	Convexity int
+	Children  []Node
+}
+
+// Transform applies Matrix to its children. translate(), rotate(),
+// scale(), mirror() and multmatrix() all result in a Transform.
+type Transform struct {
+	Matrix   Matrix
+	Children []Node
+}
+
+// Color sets the color of its children. Each component is in the range
+// [0, 1]. Colors that are not valid are represented by -1 for all
+// components.
+type Color struct {
+	RGBA     [4]float64
+	Children []Node
+}
+
+// Modifier is a node that is prefixed with a modifier character: `#` to
+// highlight it, or `%` to render it transparently without it being part of
+// the model
+type Modifier struct {
+	Op    string
+	Child Node
+}
+
+// Fragments holds the values of the special variables $fn, $fa and $fs
+// that control how many segments curved shapes are made of
+type Fragments struct {
+	Fn float64
+	Fa float64
+	Fs float64
+}
+
+// minAngle and minSize are the smallest values of $fa and $fs. As in
+// OpenSCAD, smaller values are raised to them.
+const (
+	minAngle = 0.01
+	minSize  = 0.01
+)
+
+// maxFragments is the largest number of segments that Count returns. It is
+// what the smallest $fa results in, and $fn is capped to it as well.
+const maxFragments = 360 / minAngle
+
+// Count returns the number of segments that a circle with radius r is
+// made of, the same way that OpenSCAD computes it. $fa and $fs are raised
+// to 0.01 if they are smaller, a $fn that is not finite is treated as
+// unset, and the result is never more than 36000.
+func (f Fragments) Count(r float64) int {
+	const gridFine = 0.00000095367431640625
+	if r < gridFine || math.IsInf(r, 0) || math.IsNaN(r) {
+		return 3
+	}
+	if f.Fn > 0 && !math.IsInf(f.Fn, 0) {
+		return int(math.Min(math.Max(f.Fn, 3), maxFragments))
+	}
+	fa, fs := f.Fa, f.Fs
+	if !(fa >= minAngle) {
+		fa = minAngle
+	}
+	if !(fs >= minSize) {
+		fs = minSize
+	}
+	return int(math.Min(math.Ceil(math.Max(math.Min(360/fa, r*2*math.Pi/fs), 5)), maxFragments))
+}
+
+type Cube struct {
+	Size   Vec3
+	Center bool
+}
+
+type Sphere struct {
+	R float64
+	Fragments
+}
+
+type Cylinder struct {
+	H      float64
+	R1     float64
+	R2     float64
+	Center bool
+	Fragments
+}
+
+// Polyhedron is a 3D shape made of Faces, which are lists of indices into
+// Points
+type Polyhedron struct {
+	Points    []Vec3
+	Faces     [][]int
+	Convexity int
+}
+
+type Square struct {
+	Size   Vec2
+	Center bool
+}
+
+type Circle struct {
+	R float64
+	Fragments
+}
+
+// Polygon is a 2D shape. Paths are lists of indices into Points, the first
+// of which is the outline and the rest holes. If Paths is empty, all of the
+// points make up the outline in order.
+type Polygon struct {
+	Points    []Vec2
+	Paths     [][]int
+	Convexity int
+}
+
+// LinearExtrude extrudes its 2D children along the Z axis. Slices is zero
+// if it was not specified.
+type LinearExtrude struct {
+	Height    float64
+	Center    bool
+	Convexity int
+	Twist     float64
+	Slices    int
+	Scale     Vec2
+	Fragments
+	Children []Node
+}
+
+// RotateExtrude rotates its 2D children around the Z axis
+type RotateExtrude struct {
+	Angle     float64
+	Convexity int
+	Fragments
+	Children []Node
+}
+
+// Operation is any other built-in module, such as offset(), projection()
+// or text(), with its arguments as they were passed. Fragments is set for
+// the operations that make curves out of segments, and is nil otherwise.
+type Operation struct {
+	Name      string
+	Args      []eval.Argument
+	Fragments *Fragments
+	Children  []Node
+}
+
+func (*Group) csgNode()         {}
+func (*Union) csgNode()         {}
+func (*Difference) csgNode()    {}
+func (*Intersection) csgNode()  {}
+func (*Hull) csgNode()          {}
+func (*Minkowski) csgNode()     {}
+func (*Transform) csgNode()     {}
+func (*Color) csgNode()         {}
+func (*Modifier) csgNode()      {}
+func (*Cube) csgNode()          {}
+func (*Sphere) csgNode()        {}
+func (*Cylinder) csgNode()      {}
+func (*Polyhedron) csgNode()    {}
+func (*Square) csgNode()        {}
+func (*Circle) csgNode()        {}
+func (*Polygon) csgNode()       {}
+func (*LinearExtrude) csgNode() {}
+func (*RotateExtrude) csgNode() {}
+func (*Operation) csgNode()     {}
+
+// Children returns the children of n, or nil if it does not have any
+func Children(n Node) []Node {
+	switch n := n.(type) {
+	case *Group:
+		return n.Children
+	case *Union:
+		return n.Children
+	case *Difference:
+		return n.Children
+	case *Intersection:
+		return n.Children
+	case *Hull:
+		return n.Children
+	case *Minkowski:
+		return n.Children
+	case *Transform:
+		return n.Children
+	case *Color:
+		return n.Children
+	case *Modifier:
+		return []Node{n.Child}
+	case *LinearExtrude:
+		return n.Children
+	case *RotateExtrude:
+		return n.Children
+	case *Operation:
+		return n.Children
+	}
+	return nil
+}
+
+// Walk traverses the tree rooted at n in depth-first order, calling fn
+// for each node. If fn returns false, the children of the node are not
+// visited.
+func Walk(n Node, fn func(Node) bool) {
+	if n == nil || !fn(n) {
+		return
+	}
+	for _, child := range Children(n) {
+		Walk(child, fn)
+	}
+}
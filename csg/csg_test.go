@@ -0,0 +1,71 @@
+package csg_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/eval"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFragments(t *testing.T) {
+	require.Equal(t, 6, csg.Fragments{Fn: 6, Fa: 12, Fs: 2}.Count(10), `$fn should take precedence`)
+	require.Equal(t, 30, csg.Fragments{Fa: 12, Fs: 2}.Count(10), `$fa should limit large circles`)
+	require.Equal(t, 5, csg.Fragments{Fa: 12, Fs: 2}.Count(1), `small circles should have at least 5 fragments`)
+	require.Equal(t, 3, csg.Fragments{Fn: 6, Fa: 12, Fs: 2}.Count(0), `degenerate circles should have 3 fragments`)
+	require.Equal(t, 629, csg.Fragments{}.Count(1), `$fa and $fs should be raised to 0.01`)
+	require.Equal(t, 36000, csg.Fragments{}.Count(1000), `zero values should be limited by the smallest $fa`)
+	require.Equal(t, 30, csg.Fragments{Fn: math.Inf(1), Fa: 12, Fs: 2}.Count(10), `infinite $fn should be treated as unset`)
+	require.Equal(t, 30, csg.Fragments{Fn: math.NaN(), Fa: 12, Fs: 2}.Count(10), `NaN $fn should be treated as unset`)
+	require.Equal(t, 36000, csg.Fragments{Fn: 1e300}.Count(10), `huge $fn should be capped`)
+}
+
+func TestMatrix(t *testing.T) {
+	m := csg.Translation(csg.Vec3{1, 2, 3}).Mul(csg.Scaling(csg.Vec3{2, 2, 2}))
+	require.Equal(t, csg.Vec3{3, 4, 5}, m.Apply(csg.Vec3{1, 1, 1}), `scaling should be applied before translation`)
+	require.Equal(t, csg.Vec3{0, 1, 0}, csg.Rotation(csg.Vec3{0, 0, 90}).Apply(csg.Vec3{1, 0, 0}), `rotation around Z should be exact`)
+	require.Equal(t, csg.Rotation(csg.Vec3{90, 0, 0}), csg.AxisRotation(90, csg.Vec3{2, 0, 0}), `rotation around an axis should match rotation by angles`)
+	require.Equal(t, csg.Vec3{-1, 2, 3}, csg.Mirroring(csg.Vec3{1, 0, 0}).Apply(csg.Vec3{1, 2, 3}), `mirroring should flip X`)
+	require.True(t, csg.Mirroring(csg.Vec3{}).IsIdentity(), `mirroring across a zero normal should do nothing`)
+}
+
+func TestEmit(t *testing.T) {
+	tree := &csg.Group{Children: []csg.Node{
+		&csg.Difference{Children: []csg.Node{
+			&csg.Cube{Size: csg.Vec3{10, 10, 10}, Center: true},
+			&csg.Modifier{Op: `#`, Child: &csg.Transform{
+				Matrix:   csg.Translation(csg.Vec3{0, 0, 0.1}),
+				Children: []csg.Node{&csg.Cylinder{H: 12, R1: 3, R2: 3, Center: true, Fragments: csg.Fragments{Fn: 32, Fa: 12, Fs: 2}}},
+			}},
+		}},
+		&csg.Color{RGBA: [4]float64{1, 0, 0, 0.5}, Children: []csg.Node{
+			&csg.LinearExtrude{Height: 2, Convexity: 1, Twist: 90, Scale: csg.Vec2{1, 1}, Fragments: csg.Fragments{Fa: 12, Fs: 2}, Children: []csg.Node{
+				&csg.Polygon{Points: []csg.Vec2{{0, 0}, {1, 0}, {0, 1}}, Convexity: 1},
+			}},
+		}},
+		&csg.Operation{Name: `text`, Args: []eval.Argument{{Value: eval.String(`a "b"`)}, {Name: `size`, Value: eval.Number(4)}}, Fragments: &csg.Fragments{Fn: 64, Fa: 12, Fs: 2}},
+		&csg.Operation{Name: `render`, Args: []eval.Argument{{Name: `convexity`, Value: eval.Number(2)}}},
+		&csg.Group{},
+	}}
+
+	s, err := csg.EmitString(tree)
+	require.NoError(t, err, `csg.EmitString should succeed`)
+	require.Equal(t, `group() {
+	difference() {
+		cube(size = [10, 10, 10], center = true);
+		#multmatrix([[1, 0, 0, 0], [0, 1, 0, 0], [0, 0, 1, 0.1], [0, 0, 0, 1]]) {
+			cylinder($fn = 32, $fa = 12, $fs = 2, h = 12, r1 = 3, r2 = 3, center = true);
+		}
+	}
+	color([1, 0, 0, 0.5]) {
+		linear_extrude(height = 2, center = false, convexity = 1, twist = 90, scale = [1, 1], $fn = 0, $fa = 12, $fs = 2) {
+			polygon(points = [[0, 0], [1, 0], [0, 1]], paths = undef, convexity = 1);
+		}
+	}
+	text("a \"b\"", size = 4, $fn = 64, $fa = 12, $fs = 2);
+	render(convexity = 2);
+	group();
+}
+`, s, `emitted CSG should match`)
+}
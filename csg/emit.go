@@ -0,0 +1,206 @@
+package csg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/openscad/eval"
+	"github.com/lestrrat-go/openscad/internal/number"
+)
+
+// Emit writes the tree rooted at n in OpenSCAD's `.csg` format: every
+// node is written as a call to a built-in module with all of its
+// parameters spelled out, and transformations are written as
+// multmatrix(). The result can be opened by OpenSCAD itself.
+//
+// Numbers are written with as many digits as are needed to read them back
+// exactly, so that emitting a tree is lossless.
+func Emit(n Node, w io.Writer) error {
+	var buf bytes.Buffer
+	if err := emitNode(&buf, n, ``); err != nil {
+		return err
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return fmt.Errorf(`failed to write CSG: %w`, err)
+	}
+	return nil
+}
+
+// EmitString returns the tree rooted at n in OpenSCAD's `.csg` format
+func EmitString(n Node) (string, error) {
+	var sb strings.Builder
+	if err := Emit(n, &sb); err != nil {
+		return ``, err
+	}
+	return sb.String(), nil
+}
+
+func emitNode(buf *bytes.Buffer, n Node, indent string) error {
+	buf.WriteString(indent)
+	for {
+		m, ok := n.(*Modifier)
+		if !ok {
+			break
+		}
+		buf.WriteString(m.Op)
+		n = m.Child
+	}
+
+	switch n := n.(type) {
+	case *Group:
+		buf.WriteString(`group()`)
+	case *Union:
+		buf.WriteString(`union()`)
+	case *Difference:
+		buf.WriteString(`difference()`)
+	case *Intersection:
+		buf.WriteString(`intersection()`)
+	case *Hull:
+		buf.WriteString(`hull()`)
+	case *Minkowski:
+		fmt.Fprintf(buf, `minkowski(convexity = %d)`, n.Convexity)
+	case *Transform:
+		buf.WriteString(`multmatrix([`)
+		for i, row := range n.Matrix {
+			if i > 0 {
+				buf.WriteString(`, `)
+			}
+			buf.WriteString(formatNumbers(row[:]))
+		}
+		buf.WriteString(`])`)
+	case *Color:
+		fmt.Fprintf(buf, `color(%s)`, formatNumbers(n.RGBA[:]))
+	case *Cube:
+		fmt.Fprintf(buf, `cube(size = %s, center = %t)`, formatNumbers(n.Size[:]), n.Center)
+	case *Sphere:
+		fmt.Fprintf(buf, `sphere(%s, r = %s)`, formatFragments(n.Fragments), formatNumber(n.R))
+	case *Cylinder:
+		fmt.Fprintf(buf, `cylinder(%s, h = %s, r1 = %s, r2 = %s, center = %t)`, formatFragments(n.Fragments), formatNumber(n.H), formatNumber(n.R1), formatNumber(n.R2), n.Center)
+	case *Polyhedron:
+		points := make([]string, len(n.Points))
+		for i, p := range n.Points {
+			points[i] = formatNumbers(p[:])
+		}
+		fmt.Fprintf(buf, `polyhedron(points = [%s], faces = %s, convexity = %d)`, strings.Join(points, `, `), formatIndices(n.Faces), n.Convexity)
+	case *Square:
+		fmt.Fprintf(buf, `square(size = %s, center = %t)`, formatNumbers(n.Size[:]), n.Center)
+	case *Circle:
+		fmt.Fprintf(buf, `circle(%s, r = %s)`, formatFragments(n.Fragments), formatNumber(n.R))
+	case *Polygon:
+		points := make([]string, len(n.Points))
+		for i, p := range n.Points {
+			points[i] = formatNumbers(p[:])
+		}
+		paths := `undef`
+		if len(n.Paths) > 0 {
+			paths = formatIndices(n.Paths)
+		}
+		fmt.Fprintf(buf, `polygon(points = [%s], paths = %s, convexity = %d)`, strings.Join(points, `, `), paths, n.Convexity)
+	case *LinearExtrude:
+		fmt.Fprintf(buf, `linear_extrude(height = %s, center = %t, convexity = %d`, formatNumber(n.Height), n.Center, n.Convexity)
+		if n.Twist != 0 {
+			fmt.Fprintf(buf, `, twist = %s`, formatNumber(n.Twist))
+		}
+		if n.Slices > 0 {
+			fmt.Fprintf(buf, `, slices = %d`, n.Slices)
+		}
+		fmt.Fprintf(buf, `, scale = %s, %s)`, formatNumbers(n.Scale[:]), formatFragments(n.Fragments))
+	case *RotateExtrude:
+		fmt.Fprintf(buf, `rotate_extrude(angle = %s, convexity = %d, %s)`, formatNumber(n.Angle), n.Convexity, formatFragments(n.Fragments))
+	case *Operation:
+		args := make([]string, len(n.Args))
+		for i, arg := range n.Args {
+			if arg.Name == `` {
+				args[i] = formatValue(arg.Value)
+			} else {
+				args[i] = arg.Name + ` = ` + formatValue(arg.Value)
+			}
+		}
+		if n.Fragments != nil {
+			args = append(args, formatFragments(*n.Fragments))
+		}
+		fmt.Fprintf(buf, `%s(%s)`, n.Name, strings.Join(args, `, `))
+	default:
+		return fmt.Errorf(`failed to emit CSG: unknown node %T`, n)
+	}
+
+	children := Children(n)
+	if len(children) == 0 {
+		buf.WriteString(";\n")
+		return nil
+	}
+	buf.WriteString(" {\n")
+	for _, child := range children {
+		if err := emitNode(buf, child, indent+"\t"); err != nil {
+			return err
+		}
+	}
+	buf.WriteString(indent)
+	buf.WriteString("}\n")
+	return nil
+}
+
+// formatNumber formats f like number.Format, but spells infinities and NaN
+// the way OpenSCAD does
+func formatNumber(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return `nan`
+	case math.IsInf(f, 1):
+		return `inf`
+	case math.IsInf(f, -1):
+		return `-inf`
+	}
+	return number.Format(f)
+}
+
+func formatNumbers(list []float64) string {
+	s := make([]string, len(list))
+	for i, f := range list {
+		s[i] = formatNumber(f)
+	}
+	return `[` + strings.Join(s, `, `) + `]`
+}
+
+func formatIndices(lists [][]int) string {
+	s := make([]string, len(lists))
+	for i, list := range lists {
+		indices := make([]string, len(list))
+		for j, n := range list {
+			indices[j] = strconv.Itoa(n)
+		}
+		s[i] = `[` + strings.Join(indices, `, `) + `]`
+	}
+	return `[` + strings.Join(s, `, `) + `]`
+}
+
+func formatFragments(f Fragments) string {
+	return fmt.Sprintf(`$fn = %s, $fa = %s, $fs = %s`, formatNumber(f.Fn), formatNumber(f.Fa), formatNumber(f.Fs))
+}
+
+var stringEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+
+// formatValue formats v as an OpenSCAD literal
+func formatValue(v eval.Value) string {
+	switch v := v.(type) {
+	case eval.Number:
+		return formatNumber(float64(v))
+	case eval.String:
+		return `"` + stringEscaper.Replace(string(v)) + `"`
+	case eval.Vector:
+		s := make([]string, len(v))
+		for i, elem := range v {
+			s[i] = formatValue(elem)
+		}
+		return `[` + strings.Join(s, `, `) + `]`
+	case eval.Range:
+		return fmt.Sprintf(`[%s : %s : %s]`, formatNumber(v.Begin), formatNumber(v.Step), formatNumber(v.End))
+	case nil:
+		return `undef`
+	}
+	return v.String()
+}
@@ -0,0 +1,121 @@
+package csg
+
+import (
+	"math"
+
+	"github.com/lestrrat-go/openscad/internal/degrees"
+)
+
+// Vec2 is a 2D point or vector
+type Vec2 [2]float64
+
+// Vec3 is a 3D point or vector
+type Vec3 [3]float64
+
+// Matrix is a 4x4 affine transformation matrix, in row-major order. Points
+// are transformed as column vectors, so that A.Mul(B) applies B first.
+type Matrix [4][4]float64
+
+// Identity returns the identity matrix
+func Identity() Matrix {
+	return Matrix{
+		{1, 0, 0, 0},
+		{0, 1, 0, 0},
+		{0, 0, 1, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Translation returns a matrix that translates by v
+func Translation(v Vec3) Matrix {
+	m := Identity()
+	m[0][3], m[1][3], m[2][3] = v[0], v[1], v[2]
+	return m
+}
+
+// Scaling returns a matrix that scales by v
+func Scaling(v Vec3) Matrix {
+	m := Identity()
+	m[0][0], m[1][1], m[2][2] = v[0], v[1], v[2]
+	return m
+}
+
+// Rotation returns a matrix that rotates by a[0] degrees around the X
+// axis, then a[1] degrees around the Y axis, then a[2] degrees around the
+// Z axis, like OpenSCAD's rotate([x, y, z])
+func Rotation(a Vec3) Matrix {
+	cx, sx := degrees.Cos(a[0]), degrees.Sin(a[0])
+	cy, sy := degrees.Cos(a[1]), degrees.Sin(a[1])
+	cz, sz := degrees.Cos(a[2]), degrees.Sin(a[2])
+	return Matrix{
+		{cy * cz, cz*sx*sy - cx*sz, cx*cz*sy + sx*sz, 0},
+		{cy * sz, cx*cz + sx*sy*sz, -cz*sx + cx*sy*sz, 0},
+		{-sy, cy * sx, cx * cy, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// AxisRotation returns a matrix that rotates by angle degrees around axis,
+// like OpenSCAD's rotate(a=angle, v=axis). A zero axis results in the
+// identity matrix.
+func AxisRotation(angle float64, axis Vec3) Matrix {
+	n := math.Sqrt(axis[0]*axis[0] + axis[1]*axis[1] + axis[2]*axis[2])
+	if n == 0 || math.IsNaN(n) || math.IsInf(n, 0) {
+		return Identity()
+	}
+	x, y, z := axis[0]/n, axis[1]/n, axis[2]/n
+	c, s := degrees.Cos(angle), degrees.Sin(angle)
+	t := 1 - c
+	return Matrix{
+		{t*x*x + c, t*x*y - s*z, t*x*z + s*y, 0},
+		{t*x*y + s*z, t*y*y + c, t*y*z - s*x, 0},
+		{t*x*z - s*y, t*y*z + s*x, t*z*z + c, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Mirroring returns a matrix that mirrors across the plane through the
+// origin whose normal is n, like OpenSCAD's mirror(n). A zero normal
+// results in the identity matrix.
+func Mirroring(n Vec3) Matrix {
+	l := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+	if l == 0 || math.IsNaN(l) || math.IsInf(l, 0) {
+		return Identity()
+	}
+	x, y, z := n[0]/l, n[1]/l, n[2]/l
+	return Matrix{
+		{1 - 2*x*x, -2 * x * y, -2 * x * z, 0},
+		{-2 * x * y, 1 - 2*y*y, -2 * y * z, 0},
+		{-2 * x * z, -2 * y * z, 1 - 2*z*z, 0},
+		{0, 0, 0, 1},
+	}
+}
+
+// Mul returns the product m * o, which applies o and then m
+func (m Matrix) Mul(o Matrix) Matrix {
+	var ret Matrix
+	for i := 0; i < 4; i++ {
+		for j := 0; j < 4; j++ {
+			var sum float64
+			for k := 0; k < 4; k++ {
+				sum += m[i][k] * o[k][j]
+			}
+			ret[i][j] = sum
+		}
+	}
+	return ret
+}
+
+// Apply transforms the point p
+func (m Matrix) Apply(p Vec3) Vec3 {
+	var ret Vec3
+	for i := 0; i < 3; i++ {
+		ret[i] = m[i][0]*p[0] + m[i][1]*p[1] + m[i][2]*p[2] + m[i][3]
+	}
+	return ret
+}
+
+// IsIdentity reports whether m is the identity matrix
+func (m Matrix) IsIdentity() bool {
+	return m == Identity()
+}
@@ -44,6 +44,10 @@ func Function(name string) *ast.Function {
 	return ast.NewFunction(name)
 }
 
+func FunctionLiteral(params ...*ast.Variable) *ast.FunctionLiteral {
+	return ast.NewFunctionLiteral(params...)
+}
+
 func Group(expr interface{}) *ast.Group {
 	return ast.NewGroup(expr)
 }
@@ -0,0 +1,136 @@
+// Package dxf writes 2D regions as DXF files. As in OpenSCAD's DXF export,
+// each outline and hole is a closed LWPOLYLINE entity. Files are written
+// in the AutoCAD 2000 (AC1015) version of the format, which is the first
+// that has both LWPOLYLINE entities and the $INSUNITS header variable.
+package dxf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/internal/number"
+	"github.com/lestrrat-go/openscad/mesh"
+)
+
+// units maps the names of units to the values of $INSUNITS
+var units = map[string]int{
+	`in`: 1,
+	`ft`: 2,
+	`mm`: 4,
+	`cm`: 5,
+	`m`:  6,
+}
+
+// Write writes regions to w as a DXF file. The header declares the unit
+// of the coordinates in $INSUNITS, and whether it is metric in
+// $MEASUREMENT, along with the extents of the drawing.
+//
+// Outlines are counter-clockwise and holes clockwise, as in the regions
+// that the mesh package produces.
+func Write(w io.Writer, regions []mesh.Region, options ...WriteOption) error {
+	unit := `mm`
+	layer := `0`
+	for _, option := range options {
+		switch option.Ident() {
+		case optUnitKey{}:
+			unit = option.Value().(string)
+		case optLayerKey{}:
+			layer = option.Value().(string)
+		}
+	}
+	insunits, ok := units[unit]
+	if !ok {
+		return fmt.Errorf(`unknown unit %q`, unit)
+	}
+	measurement := 1
+	if unit == `in` || unit == `ft` {
+		measurement = 0
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, r := range regions {
+		for _, p := range r.Outline {
+			minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+			minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+		}
+	}
+	if minX > maxX {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+
+	bw := bufio.NewWriter(w)
+	e := &encoder{w: bw}
+	e.group(0, `SECTION`)
+	e.group(2, `HEADER`)
+	e.group(9, `$ACADVER`)
+	e.group(1, `AC1015`)
+	e.group(9, `$INSUNITS`)
+	e.group(70, strconv.Itoa(insunits))
+	e.group(9, `$MEASUREMENT`)
+	e.group(70, strconv.Itoa(measurement))
+	e.group(9, `$EXTMIN`)
+	e.point(minX, minY)
+	e.group(9, `$EXTMAX`)
+	e.point(maxX, maxY)
+	e.group(0, `ENDSEC`)
+
+	e.group(0, `SECTION`)
+	e.group(2, `ENTITIES`)
+	for _, r := range regions {
+		if len(r.Outline) < 3 {
+			continue
+		}
+		rings := append([][]csg.Vec2{r.Outline}, r.Holes...)
+		for _, ring := range rings {
+			e.group(0, `LWPOLYLINE`)
+			e.group(100, `AcDbEntity`)
+			e.group(8, layer)
+			e.group(100, `AcDbPolyline`)
+			e.group(90, strconv.Itoa(len(ring)))
+			e.group(70, `1`) // closed
+			for _, p := range ring {
+				e.point(p[0], p[1])
+			}
+		}
+	}
+	e.group(0, `ENDSEC`)
+	e.group(0, `EOF`)
+
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf(`failed to write DXF: %w`, err)
+	}
+	return nil
+}
+
+// encoder writes group codes and their values. Errors are left for the
+// caller to pick up when flushing w.
+type encoder struct {
+	w   *bufio.Writer
+	buf []byte
+}
+
+func (e *encoder) group(code int, value string) {
+	// codes are right-aligned in three columns
+	e.buf = e.buf[:0]
+	switch {
+	case code < 10:
+		e.buf = append(e.buf, `  `...)
+	case code < 100:
+		e.buf = append(e.buf, ' ')
+	}
+	e.buf = strconv.AppendInt(e.buf, int64(code), 10)
+	e.buf = append(e.buf, '\n')
+	e.buf = append(e.buf, value...)
+	e.buf = append(e.buf, '\n')
+	e.w.Write(e.buf)
+}
+
+func (e *encoder) point(x, y float64) {
+	e.group(10, number.Format(x))
+	e.group(20, number.Format(y))
+}
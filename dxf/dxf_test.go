@@ -0,0 +1,72 @@
+package dxf_test
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/dxf"
+	"github.com/lestrrat-go/openscad/mesh"
+	"github.com/stretchr/testify/require"
+)
+
+type pair struct {
+	code  int
+	value string
+}
+
+// readPairs reads the group codes and values of a DXF file
+func readPairs(t *testing.T, s string) []pair {
+	t.Helper()
+	var ret []pair
+	sc := bufio.NewScanner(strings.NewReader(s))
+	for sc.Scan() {
+		code, err := strconv.Atoi(strings.TrimSpace(sc.Text()))
+		require.NoError(t, err, `group code should be a number`)
+		require.True(t, sc.Scan(), `group code should have a value`)
+		ret = append(ret, pair{code, sc.Text()})
+	}
+	return ret
+}
+
+func TestWrite(t *testing.T) {
+	regions := []mesh.Region{{
+		Outline: []csg.Vec2{{0, 0}, {10, 0}, {10, 5}, {0, 5}},
+		Holes:   [][]csg.Vec2{{{2, 2}, {2, 3}, {3, 3}, {3, 2}}},
+	}}
+
+	var sb strings.Builder
+	require.NoError(t, dxf.Write(&sb, regions, dxf.WithLayer(`cut`)), `dxf.Write should succeed`)
+	require.True(t, strings.HasPrefix(sb.String(), "  0\nSECTION\n  2\nHEADER\n"), `codes should be right-aligned`)
+
+	pairs := readPairs(t, sb.String())
+	require.Equal(t, pair{0, `EOF`}, pairs[len(pairs)-1], `file should end with EOF`)
+
+	var header []pair
+	var polylines [][]csg.Vec2
+	for i, p := range pairs {
+		switch {
+		case p.code == 9:
+			header = append(header, p, pairs[i+1])
+		case p == pair{0, `LWPOLYLINE`}:
+			require.Equal(t, []pair{{100, `AcDbEntity`}, {8, `cut`}, {100, `AcDbPolyline`}}, pairs[i+1:i+4], `polyline should have subclass markers`)
+			polylines = append(polylines, nil)
+		case len(polylines) > 0 && p.code == 8:
+			require.Equal(t, `cut`, p.value, `polyline should be on the layer`)
+		case len(polylines) > 0 && p.code == 70:
+			require.Equal(t, `1`, p.value, `polyline should be closed`)
+		case len(polylines) > 0 && p.code == 10:
+			x, _ := strconv.ParseFloat(p.value, 64)
+			y, _ := strconv.ParseFloat(pairs[i+1].value, 64)
+			polylines[len(polylines)-1] = append(polylines[len(polylines)-1], csg.Vec2{x, y})
+		}
+	}
+	require.Contains(t, header, pair{1, `AC1015`}, `version should be AutoCAD 2000`)
+	require.Contains(t, header, pair{70, `4`}, `unit should be millimeters`)
+	require.Equal(t, [][]csg.Vec2{regions[0].Outline, regions[0].Holes[0]}, polylines, `outline and hole should be written`)
+
+	require.Error(t, dxf.Write(io.Discard, regions, dxf.WithUnit(`px`)), `unknown units should be rejected`)
+}
@@ -0,0 +1,29 @@
+package dxf
+
+import "github.com/lestrrat-go/option"
+
+type optUnitKey struct{}
+type optLayerKey struct{}
+
+// WriteOption is an option that can be passed to Write()
+type WriteOption interface {
+	writeOption()
+	option.Interface
+}
+
+type writeOption struct {
+	option.Interface
+}
+
+func (writeOption) writeOption() {}
+
+// WithUnit sets the unit that coordinates are in: one of "mm", "cm", "m",
+// "in" or "ft". The default is "mm".
+func WithUnit(unit string) WriteOption {
+	return &writeOption{option.New(optUnitKey{}, unit)}
+}
+
+// WithLayer sets the layer that the polylines are on. The default is "0".
+func WithLayer(name string) WriteOption {
+	return &writeOption{option.New(optLayerKey{}, name)}
+}
@@ -0,0 +1,440 @@
+package eval
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lestrrat-go/openscad/internal/degrees"
+)
+
+// builtinVariables are the variables that OpenSCAD defines before any
+// code is run
+var builtinVariables = map[string]Value{
+	`PI`:  Number(math.Pi),
+	`$fn`: Number(0),
+	`$fa`: Number(12),
+	`$fs`: Number(2),
+	`$t`:  Number(0),
+}
+
+// builtins are OpenSCAD's built-in functions. As in OpenSCAD, they take
+// their arguments by position, regardless of the names they are given.
+var builtins map[string]Function
+
+func init() {
+	builtins = map[string]Function{
+		// math
+		`abs`:   numberFunc(math.Abs),
+		`sign`:  numberFunc(sign),
+		`sin`:   numberFunc(degrees.Sin),
+		`cos`:   numberFunc(degrees.Cos),
+		`tan`:   numberFunc(degrees.Tan),
+		`asin`:  numberFunc(func(x float64) float64 { return rad2deg(math.Asin(x)) }),
+		`acos`:  numberFunc(func(x float64) float64 { return rad2deg(math.Acos(x)) }),
+		`atan`:  numberFunc(func(x float64) float64 { return rad2deg(math.Atan(x)) }),
+		`atan2`: numbersFunc(func(y, x float64) float64 { return rad2deg(math.Atan2(y, x)) }),
+		`pow`:   numbersFunc(math.Pow),
+		`sqrt`:  numberFunc(math.Sqrt),
+		`exp`:   numberFunc(math.Exp),
+		`ln`:    numberFunc(math.Log),
+		`log`:   FunctionFunc(builtinLog),
+		`floor`: numberFunc(math.Floor),
+		`ceil`:  numberFunc(math.Ceil),
+		`round`: numberFunc(math.Round),
+		`min`:   minmaxFunc(func(a, b float64) bool { return a < b }),
+		`max`:   minmaxFunc(func(a, b float64) bool { return a > b }),
+		`norm`:  FunctionFunc(builtinNorm),
+		`cross`: FunctionFunc(builtinCross),
+		`rands`: FunctionFunc(builtinRands),
+
+		// lists
+		`len`:    FunctionFunc(builtinLen),
+		`concat`: FunctionFunc(builtinConcat),
+		`lookup`: FunctionFunc(builtinLookup),
+		`search`: FunctionFunc(builtinSearch),
+
+		// strings
+		`str`: FunctionFunc(builtinStr),
+		`chr`: FunctionFunc(builtinChr),
+		`ord`: FunctionFunc(builtinOrd),
+
+		// type tests
+		`is_undef`:    typeTest(func(v Value) bool { return v.Type() == TypeUndef }),
+		`is_bool`:     typeTest(func(v Value) bool { return v.Type() == TypeBool }),
+		`is_num`:      typeTest(isNum),
+		`is_string`:   typeTest(func(v Value) bool { return v.Type() == TypeString }),
+		`is_list`:     typeTest(func(v Value) bool { return v.Type() == TypeVector }),
+		`is_function`: typeTest(func(v Value) bool { return v.Type() == TypeFunction }),
+	}
+}
+
+// arg returns the i-th argument, or undef
+func arg(args []Argument, i int) Value {
+	if i >= len(args) || args[i].Value == nil {
+		return Undef
+	}
+	return args[i].Value
+}
+
+// numberFunc creates a function of one number. Other arguments result in
+// undef.
+func numberFunc(fn func(float64) float64) Function {
+	return FunctionFunc(func(_ *Env, args []Argument) (Value, error) {
+		x, ok := arg(args, 0).(Number)
+		if !ok {
+			return Undef, nil
+		}
+		return Number(fn(float64(x))), nil
+	})
+}
+
+// numbersFunc creates a function of two numbers. Other arguments result
+// in undef.
+func numbersFunc(fn func(float64, float64) float64) Function {
+	return FunctionFunc(func(_ *Env, args []Argument) (Value, error) {
+		x, ok := arg(args, 0).(Number)
+		if !ok {
+			return Undef, nil
+		}
+		y, ok := arg(args, 1).(Number)
+		if !ok {
+			return Undef, nil
+		}
+		return Number(fn(float64(x), float64(y))), nil
+	})
+}
+
+func typeTest(fn func(Value) bool) Function {
+	return FunctionFunc(func(_ *Env, args []Argument) (Value, error) {
+		return Bool(fn(arg(args, 0))), nil
+	})
+}
+
+func isNum(v Value) bool {
+	n, ok := v.(Number)
+	return ok && !math.IsNaN(float64(n))
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
+
+func rad2deg(x float64) float64 {
+	return x * 180 / math.Pi
+}
+
+// builtinLog computes log(x) in base 10, or log(b, x) in base b
+func builtinLog(_ *Env, args []Argument) (Value, error) {
+	if len(args) < 2 {
+		x, ok := arg(args, 0).(Number)
+		if !ok {
+			return Undef, nil
+		}
+		return Number(math.Log10(float64(x))), nil
+	}
+	b, ok := arg(args, 0).(Number)
+	if !ok {
+		return Undef, nil
+	}
+	x, ok := arg(args, 1).(Number)
+	if !ok {
+		return Undef, nil
+	}
+	return Number(math.Log(float64(x)) / math.Log(float64(b))), nil
+}
+
+// minmaxFunc creates min() or max(), which take either a single vector of
+// numbers, or any number of numbers. Anything else results in undef.
+func minmaxFunc(better func(a, b float64) bool) Function {
+	return FunctionFunc(func(_ *Env, args []Argument) (Value, error) {
+		values := make([]Value, len(args))
+		for i, a := range args {
+			values[i] = a.Value
+		}
+		if len(args) == 1 {
+			if v, ok := args[0].Value.(Vector); ok {
+				values = v
+			}
+		}
+		if len(values) == 0 {
+			return Undef, nil
+		}
+
+		var ret float64
+		for i, v := range values {
+			n, ok := v.(Number)
+			if !ok {
+				return Undef, nil
+			}
+			if i == 0 || better(float64(n), ret) {
+				ret = float64(n)
+			}
+		}
+		return Number(ret), nil
+	})
+}
+
+// numbers converts a vector of numbers to float64s
+func numbers(v Value) ([]float64, bool) {
+	vec, ok := v.(Vector)
+	if !ok {
+		return nil, false
+	}
+	ret := make([]float64, len(vec))
+	for i, elem := range vec {
+		n, ok := elem.(Number)
+		if !ok {
+			return nil, false
+		}
+		ret[i] = float64(n)
+	}
+	return ret, true
+}
+
+func builtinNorm(_ *Env, args []Argument) (Value, error) {
+	v, ok := numbers(arg(args, 0))
+	if !ok {
+		return Undef, nil
+	}
+	var sum float64
+	for _, x := range v {
+		sum += x * x
+	}
+	return Number(math.Sqrt(sum)), nil
+}
+
+// builtinCross computes the cross product of two 3D vectors. The cross
+// product of 2D vectors is the z component of their 3D cross product.
+func builtinCross(_ *Env, args []Argument) (Value, error) {
+	a, ok := numbers(arg(args, 0))
+	if !ok {
+		return Undef, nil
+	}
+	b, ok := numbers(arg(args, 1))
+	if !ok || len(a) != len(b) {
+		return Undef, nil
+	}
+	switch len(a) {
+	case 2:
+		return Number(a[0]*b[1] - a[1]*b[0]), nil
+	case 3:
+		return Vector{
+			Number(a[1]*b[2] - a[2]*b[1]),
+			Number(a[2]*b[0] - a[0]*b[2]),
+			Number(a[0]*b[1] - a[1]*b[0]),
+		}, nil
+	}
+	return Undef, nil
+}
+
+// builtinLen returns the number of elements of a vector, or the number of
+// characters of a string
+func builtinLen(_ *Env, args []Argument) (Value, error) {
+	switch v := arg(args, 0).(type) {
+	case Vector:
+		return Number(len(v)), nil
+	case String:
+		return Number(utf8.RuneCountInString(string(v))), nil
+	}
+	return Undef, nil
+}
+
+// builtinConcat concatenates vectors. Arguments that are not vectors are
+// added as single elements.
+func builtinConcat(_ *Env, args []Argument) (Value, error) {
+	ret := Vector{}
+	for _, a := range args {
+		if v, ok := a.Value.(Vector); ok {
+			ret = append(ret, v...)
+			continue
+		}
+		ret = append(ret, a.Value)
+	}
+	return ret, nil
+}
+
+// builtinLookup interpolates linearly between the entries of a table of
+// [key, value] pairs. Keys outside of the table result in the value of
+// the nearest entry.
+func builtinLookup(_ *Env, args []Argument) (Value, error) {
+	key, ok := arg(args, 0).(Number)
+	if !ok || math.IsNaN(float64(key)) || math.IsInf(float64(key), 0) {
+		return Undef, nil
+	}
+	table, ok := arg(args, 1).(Vector)
+	if !ok || len(table) == 0 {
+		return Undef, nil
+	}
+
+	p := float64(key)
+	lowP, lowV, ok := lookupEntry(table[0])
+	if !ok {
+		return Undef, nil
+	}
+	highP, highV := lowP, lowV
+	for _, entry := range table[1:] {
+		thisP, thisV, ok := lookupEntry(entry)
+		if !ok {
+			continue
+		}
+		if thisP <= p && (thisP > lowP || lowP > p) {
+			lowP, lowV = thisP, thisV
+		}
+		if thisP >= p && (thisP < highP || highP < p) {
+			highP, highV = thisP, thisV
+		}
+	}
+	if p <= lowP {
+		return Number(highV), nil
+	}
+	if p >= highP {
+		return Number(lowV), nil
+	}
+	f := (p - lowP) / (highP - lowP)
+	return Number(highV*f + lowV*(1-f)), nil
+}
+
+func lookupEntry(v Value) (float64, float64, bool) {
+	pair, ok := numbers(v)
+	if !ok || len(pair) != 2 {
+		return 0, 0, false
+	}
+	return pair[0], pair[1], true
+}
+
+// builtinSearch implements search(match, table, num_returns_per_match=1,
+// index_col_num=0), which returns the indices of the elements of table
+// that match. See OpenSCAD's documentation for the shape of the result,
+// which depends on the types of the arguments.
+func builtinSearch(_ *Env, args []Argument) (Value, error) {
+	match := arg(args, 0)
+	table := arg(args, 1)
+	limit := 1
+	if n, ok := arg(args, 2).(Number); ok {
+		limit = int(n)
+		if limit < 0 {
+			limit = 0
+		}
+	}
+	column := 0
+	if n, ok := arg(args, 3).(Number); ok && n >= 0 {
+		column = int(n)
+	}
+
+	var elements []Value
+	switch table := table.(type) {
+	case Vector:
+		elements = table
+	case String:
+		for _, r := range string(table) {
+			elements = append(elements, String(r))
+		}
+	default:
+		return Undef, nil
+	}
+
+	// find returns the indices of the elements that match v
+	find := func(v Value) Vector {
+		ret := Vector{}
+		for i, elem := range elements {
+			if row, ok := elem.(Vector); ok && !(column == 0 && Equal(elem, v)) {
+				if column >= len(row) {
+					continue
+				}
+				elem = row[column]
+			}
+			if !Equal(elem, v) {
+				continue
+			}
+			ret = append(ret, Number(i))
+			if limit > 0 && len(ret) >= limit {
+				break
+			}
+		}
+		return ret
+	}
+
+	switch match := match.(type) {
+	case Number:
+		return find(match), nil
+	case String, Vector:
+		var terms []Value
+		if s, ok := match.(String); ok {
+			for _, r := range string(s) {
+				terms = append(terms, String(r))
+			}
+		} else {
+			terms = match.(Vector) //nolint:forcetypeassert
+		}
+
+		ret := Vector{}
+		for _, term := range terms {
+			found := find(term)
+			switch {
+			case limit != 1:
+				ret = append(ret, found)
+			case len(found) == 1:
+				ret = append(ret, found[0])
+			case match.Type() == TypeVector:
+				// unmatched elements of a vector result in an empty
+				// vector, but unmatched characters are left out
+				ret = append(ret, Vector{})
+			}
+		}
+		return ret, nil
+	}
+	return Undef, nil
+}
+
+// builtinStr concatenates its arguments, formatted as strings
+func builtinStr(_ *Env, args []Argument) (Value, error) {
+	var sb strings.Builder
+	for _, a := range args {
+		sb.WriteString(arg([]Argument{a}, 0).String())
+	}
+	return String(sb.String()), nil
+}
+
+// builtinChr converts numbers, and vectors or ranges of numbers, to the
+// characters with those code points. Invalid code points are skipped.
+func builtinChr(_ *Env, args []Argument) (Value, error) {
+	var sb strings.Builder
+	add := func(v Value) {
+		n, ok := v.(Number)
+		if !ok || !(n >= 1 && n <= utf8.MaxRune) {
+			return
+		}
+		if r := rune(n); utf8.ValidRune(r) {
+			sb.WriteRune(r)
+		}
+	}
+	for _, a := range args {
+		switch v := a.Value.(type) {
+		case Vector, Range:
+			_ = Iterate(v, func(elem Value) error {
+				add(elem)
+				return nil
+			})
+		default:
+			add(v)
+		}
+	}
+	return String(sb.String()), nil
+}
+
+// builtinOrd returns the code point of a single character string
+func builtinOrd(_ *Env, args []Argument) (Value, error) {
+	s, ok := arg(args, 0).(String)
+	if !ok || utf8.RuneCountInString(string(s)) != 1 {
+		return Undef, nil
+	}
+	r, _ := utf8.DecodeRuneInString(string(s))
+	return Number(r), nil
+}
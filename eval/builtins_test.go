@@ -0,0 +1,161 @@
+package eval_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/eval"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltins(t *testing.T) {
+	// expected values are formatted as with str(), and are the results
+	// that OpenSCAD produces
+	testcases := []struct {
+		Expr     string
+		Expected string
+	}{
+		// trigonometry works in degrees, with exact results for common angles
+		{Expr: `sin(30)`, Expected: `0.5`},
+		{Expr: `sin(45)`, Expected: `0.707107`},
+		{Expr: `sin(180)`, Expected: `0`},
+		{Expr: `sin(270)`, Expected: `-1`},
+		{Expr: `sin(-90)`, Expected: `-1`},
+		{Expr: `sin(390)`, Expected: `0.5`},
+		{Expr: `sin(10)`, Expected: `0.173648`},
+		{Expr: `cos(60)`, Expected: `0.5`},
+		{Expr: `cos(90)`, Expected: `0`},
+		{Expr: `cos(180)`, Expected: `-1`},
+		{Expr: `cos(120)`, Expected: `-0.5`},
+		{Expr: `tan(45)`, Expected: `1`},
+		{Expr: `tan(90)`, Expected: `inf`},
+		{Expr: `tan(135)`, Expected: `-1`},
+		{Expr: `asin(1)`, Expected: `90`},
+		{Expr: `acos(0.5)`, Expected: `60`},
+		{Expr: `atan(1)`, Expected: `45`},
+		{Expr: `atan2(1, 1)`, Expected: `45`},
+		{Expr: `atan2(1, -1)`, Expected: `135`},
+		{Expr: `sin("a")`, Expected: `undef`},
+		{Expr: `sin(1 / 0)`, Expected: `nan`},
+
+		// other math
+		{Expr: `abs(-3)`, Expected: `3`},
+		{Expr: `sign(-3)`, Expected: `-1`},
+		{Expr: `sign(0)`, Expected: `0`},
+		{Expr: `sign(2.5)`, Expected: `1`},
+		{Expr: `pow(2, 10)`, Expected: `1024`},
+		{Expr: `pow(2, 0.5)`, Expected: `1.41421`},
+		{Expr: `pow(2, "a")`, Expected: `undef`},
+		{Expr: `sqrt(16)`, Expected: `4`},
+		{Expr: `sqrt(-1)`, Expected: `nan`},
+		{Expr: `exp(1)`, Expected: `2.71828`},
+		{Expr: `ln(1)`, Expected: `0`},
+		{Expr: `ln(0)`, Expected: `-inf`},
+		{Expr: `log(1000)`, Expected: `3`},
+		{Expr: `log(2, 8)`, Expected: `3`},
+		{Expr: `round(2.5)`, Expected: `3`},
+		{Expr: `round(-2.5)`, Expected: `-3`},
+		{Expr: `round(2.4)`, Expected: `2`},
+		{Expr: `floor(-1.5)`, Expected: `-2`},
+		{Expr: `ceil(-1.5)`, Expected: `-1`},
+		{Expr: `min(3, 1, 2)`, Expected: `1`},
+		{Expr: `max(3, 7, 5)`, Expected: `7`},
+		{Expr: `min([3, 1, 2])`, Expected: `1`},
+		{Expr: `max([3, 7, 5])`, Expected: `7`},
+		{Expr: `max(4)`, Expected: `4`},
+		{Expr: `min([])`, Expected: `undef`},
+		{Expr: `min(1, "a")`, Expected: `undef`},
+		{Expr: `max([1, [2]])`, Expected: `undef`},
+		{Expr: `norm([3, 4])`, Expected: `5`},
+		{Expr: `norm([1, 2, 2])`, Expected: `3`},
+		{Expr: `norm([])`, Expected: `0`},
+		{Expr: `norm([1, "a"])`, Expected: `undef`},
+		{Expr: `norm(3)`, Expected: `undef`},
+		{Expr: `cross([1, 0, 0], [0, 1, 0])`, Expected: `[0, 0, 1]`},
+		{Expr: `cross([2, 3, 4], [5, 6, 7])`, Expected: `[-3, 6, -3]`},
+		{Expr: `cross([1, 2], [3, 4])`, Expected: `-2`},
+		{Expr: `cross([1, 2], [3, 4, 5])`, Expected: `undef`},
+		{Expr: `PI`, Expected: `3.14159`},
+
+		// lists
+		{Expr: `len([1, 2, 3])`, Expected: `3`},
+		{Expr: `len("héllo")`, Expected: `5`},
+		{Expr: `len([])`, Expected: `0`},
+		{Expr: `len(5)`, Expected: `undef`},
+		{Expr: `concat([1, 2], 3, [[4]], "ab")`, Expected: `[1, 2, 3, [4], "ab"]`},
+		{Expr: `concat()`, Expected: `[]`},
+		{Expr: `lookup(1.5, [[1, 10], [2, 20]])`, Expected: `15`},
+		{Expr: `lookup(0, [[1, 10], [2, 20]])`, Expected: `10`},
+		{Expr: `lookup(5, [[1, 10], [2, 20]])`, Expected: `20`},
+		{Expr: `lookup(2.5, [[3, 30], [1, 10], [2, 20]])`, Expected: `25`},
+		{Expr: `lookup("a", [[1, 10]])`, Expected: `undef`},
+		{Expr: `search(3, [1, 2, 3, 3])`, Expected: `[2]`},
+		{Expr: `search(3, [1, 2, 3, 3], 0)`, Expected: `[2, 3]`},
+		{Expr: `search(9, [1, 2, 3])`, Expected: `[]`},
+		{Expr: `search("a", "abcdabcd")`, Expected: `[0]`},
+		{Expr: `search("e", "abcdabcd")`, Expected: `[]`},
+		{Expr: `search("a", "abcdabcd", 10)`, Expected: `[[0, 4]]`},
+		{Expr: `search("abe", "abcdabcd", 0)`, Expected: `[[0, 4], [1, 5], []]`},
+		{Expr: `search("ab", [["a", 1], ["b", 2], ["a", 3]])`, Expected: `[0, 1]`},
+		{Expr: `search([2, 5], [[1, 2], [3, 5]], 1, 1)`, Expected: `[0, 1]`},
+		{Expr: `search([1, 9], [1, 2, 3])`, Expected: `[0, []]`},
+		{Expr: `search(["b", "c"], [["a", 1], ["b", 2], ["c", 3], ["b", 4]], 0)`, Expected: `[[1, 3], [2]]`},
+		{Expr: `[for (i = [len("abc") - 1:-1:0]) "abc"[i]]`, Expected: `["c", "b", "a"]`},
+
+		// strings
+		{Expr: `str("a", 1, [1, "b"], undef, true, 2.5)`, Expected: `a1[1, "b"]undeftrue2.5`},
+		{Expr: `str()`, Expected: ``},
+		{Expr: `chr(65, [66, 67])`, Expected: `ABC`},
+		{Expr: `chr(233)`, Expected: `é`},
+		{Expr: `chr(0, -1, "a")`, Expected: ``},
+		{Expr: `ord("A")`, Expected: `65`},
+		{Expr: `ord("é")`, Expected: `233`},
+		{Expr: `ord("ab")`, Expected: `undef`},
+		{Expr: `ord(65)`, Expected: `undef`},
+
+		// type tests
+		{Expr: `is_num(1)`, Expected: `true`},
+		{Expr: `is_num(0 / 0)`, Expected: `false`},
+		{Expr: `is_num("1")`, Expected: `false`},
+		{Expr: `is_list([])`, Expected: `true`},
+		{Expr: `is_list("abc")`, Expected: `false`},
+		{Expr: `is_undef(undef)`, Expected: `true`},
+		{Expr: `is_undef(0)`, Expected: `false`},
+		{Expr: `is_bool(false)`, Expected: `true`},
+		{Expr: `is_string("")`, Expected: `true`},
+		{Expr: `is_function(function(x) x)`, Expected: `true`},
+		{Expr: `is_function(1)`, Expected: `false`},
+
+		// seeded random numbers match OpenSCAD's
+		{Expr: `rands(0, 10, 3, 42)`, Expected: `[6.2293, 7.20117, 2.26903]`},
+		{Expr: `rands(10, 0, 1, 42)`, Expected: `[6.2293]`},
+		{Expr: `rands(0, 10, 2, 3.5)`, Expected: `[0.372335, 7.08692]`},
+		{Expr: `rands(0, 10, 2, 0)`, Expected: `[5.92845, 8.44266]`},
+		{Expr: `rands(5, 5, 2, 0)`, Expected: `[5, 5]`},
+		{Expr: `rands(0, 1, 0, 1)`, Expected: `[]`},
+		{Expr: `rands(0, 1)`, Expected: `undef`},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Expr, func(t *testing.T) {
+			v, err := eval.Eval(parseExpr(t, tc.Expr), nil)
+			require.NoError(t, err, `eval.Eval should succeed`)
+			require.Equal(t, tc.Expected, v.String(), `value should match`)
+		})
+	}
+}
+
+func TestBuiltinShadowing(t *testing.T) {
+	env := eval.NewEnv()
+	env.SetFunction(`sin`, eval.FunctionFunc(func(*eval.Env, []eval.Argument) (eval.Value, error) {
+		return eval.Number(42), nil
+	}))
+	v, err := eval.Eval(ast.NewCall(`sin`).Parameters(30), env)
+	require.NoError(t, err, `eval.Eval should succeed`)
+	require.Equal(t, eval.Number(42), v, `functions that are defined should shadow built-in functions`)
+
+	v, err = eval.Eval(ast.NewLookup(2, []interface{}{[]interface{}{0, 0}, []interface{}{4, 8}}), nil)
+	require.NoError(t, err, `eval.Eval should succeed`)
+	require.Equal(t, eval.Number(4), v, `ast.LookupStmt should call lookup()`)
+}
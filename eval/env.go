@@ -0,0 +1,196 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MaxCallDepth is the deepest that function calls may be nested. Tail
+// calls, such as those in the branches of a ternary operator at the end
+// of a recursive function, do not count towards the limit.
+const MaxCallDepth = 10000
+
+// Argument is an argument passed to a function. Name is empty for
+// positional arguments.
+type Argument struct {
+	Name  string
+	Value Value
+}
+
+// Function is a function that can be called from expressions. env is the
+// Env of the caller.
+type Function interface {
+	Call(env *Env, args []Argument) (Value, error)
+}
+
+// FunctionFunc is a Function implemented as a Go function
+type FunctionFunc func(env *Env, args []Argument) (Value, error)
+
+func (fn FunctionFunc) Call(env *Env, args []Argument) (Value, error) {
+	return fn(env, args)
+}
+
+// Env holds the variables and functions that are visible to an expression.
+// Envs are nested: names that are not found in an Env are looked up in
+// its parent. Names that are not found anywhere are looked up in OpenSCAD's
+// built-in functions and variables, such as sin() and PI.
+//
+// Special variables, whose names start with `$`, are scoped dynamically
+// instead: they are looked up through the callers of functions, rather than
+// through the places where functions were defined.
+type Env struct {
+	parent    *Env
+	caller    *Env
+	depth     int
+	variables map[string]Value
+	functions map[string]Function
+	modules   map[string]*Module
+	uses      []*Env
+}
+
+// NewEnv creates an Env that only has access to the built-in functions and
+// variables
+func NewEnv() *Env {
+	return &Env{}
+}
+
+// NewChild creates an Env that can see everything in e, and whose own
+// variables shadow those of e
+func (e *Env) NewChild() *Env {
+	return &Env{parent: e, depth: e.depth}
+}
+
+// NewFrame creates an Env for evaluating the body of a function or module
+// defined in e, called from caller. Special variables that are not set in
+// the frame itself are looked up through caller.
+func (e *Env) NewFrame(caller *Env) (*Env, error) {
+	depth := 1
+	if caller != nil {
+		depth = caller.depth + 1
+	}
+	if depth > MaxCallDepth {
+		return nil, fmt.Errorf(`recursion is too deep (more than %d nested calls)`, MaxCallDepth)
+	}
+	return e.newFrame(caller, depth), nil
+}
+
+func (e *Env) newFrame(caller *Env, depth int) *Env {
+	return &Env{parent: e, caller: caller, depth: depth}
+}
+
+// Parent returns the Env that e was created from, or nil
+func (e *Env) Parent() *Env {
+	return e.parent
+}
+
+// Use makes the functions and modules defined in other visible in e, after
+// the ones that e defines itself, as OpenSCAD's `use` statement does.
+// Functions and modules that other itself uses, and variables, are not made
+// visible.
+func (e *Env) Use(other *Env) {
+	e.uses = append(e.uses, other)
+}
+
+// Set sets the value of a variable in e
+func (e *Env) Set(name string, v Value) {
+	if e.variables == nil {
+		e.variables = make(map[string]Value)
+	}
+	e.variables[name] = v
+}
+
+// Get looks up the value of a variable
+func (e *Env) Get(name string) (Value, bool) {
+	special := strings.HasPrefix(name, `$`)
+	for cur := e; cur != nil; {
+		if v, ok := cur.variables[name]; ok {
+			return v, true
+		}
+		if special && cur.caller != nil {
+			cur = cur.caller
+		} else {
+			cur = cur.parent
+		}
+	}
+	v, ok := builtinVariables[name]
+	return v, ok
+}
+
+// SetFunction defines a function in e
+func (e *Env) SetFunction(name string, fn Function) {
+	if e.functions == nil {
+		e.functions = make(map[string]Function)
+	}
+	e.functions[name] = fn
+}
+
+// Function looks up a function declared in e or its parents, or in the
+// Envs that they use
+func (e *Env) Function(name string) (Function, bool) {
+	for cur := e; cur != nil; cur = cur.parent {
+		if fn, ok := cur.functions[name]; ok {
+			return fn, true
+		}
+		for _, used := range cur.uses {
+			if fn, ok := used.functions[name]; ok {
+				return fn, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// LookupFunction looks up the function that a call to name refers to.
+// Scopes are searched from the innermost outward, and the first one that
+// declares a function with that name, or has a variable with that name
+// that holds a function value, wins. Within a scope, declared functions
+// take precedence over variables. Built-in functions come last.
+func (e *Env) LookupFunction(name string) (Function, bool) {
+	for cur := e; cur != nil; cur = cur.parent {
+		if fn, ok := cur.functions[name]; ok {
+			return fn, true
+		}
+		if fn, ok := cur.variables[name].(*FunctionValue); ok {
+			return fn.Function, true
+		}
+		for _, used := range cur.uses {
+			if fn, ok := used.functions[name]; ok {
+				return fn, true
+			}
+		}
+	}
+	if strings.HasPrefix(name, `$`) {
+		// special variables are looked up through the callers
+		if v, ok := e.Get(name); ok {
+			if fn, ok := v.(*FunctionValue); ok {
+				return fn.Function, true
+			}
+		}
+	}
+	fn, ok := builtins[name]
+	return fn, ok
+}
+
+// SetModule defines a module in e
+func (e *Env) SetModule(m *Module) {
+	if e.modules == nil {
+		e.modules = make(map[string]*Module)
+	}
+	e.modules[m.Name] = m
+}
+
+// Module looks up a module declared in e or its parents, or in the Envs
+// that they use
+func (e *Env) Module(name string) (*Module, bool) {
+	for cur := e; cur != nil; cur = cur.parent {
+		if m, ok := cur.modules[name]; ok {
+			return m, true
+		}
+		for _, used := range cur.uses {
+			if m, ok := used.modules[name]; ok {
+				return m, true
+			}
+		}
+	}
+	return nil, false
+}
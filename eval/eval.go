@@ -0,0 +1,393 @@
+// Package eval evaluates OpenSCAD expressions, such as those built with
+// the ast package or read by the parser, to values.
+//
+// Evaluation follows OpenSCAD's semantics: operations on values of the
+// wrong type, unknown variables and out of bounds indices result in undef
+// rather than errors, vectors support arithmetic, and ranges are lazy.
+// Errors are only returned for expressions that can not be evaluated at
+// all.
+//
+// OpenSCAD's built-in functions, such as sin(), len() and search(), and
+// built-in variables such as PI, are available in every Env.
+package eval
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+// Eval evaluates expr to a value. Variables and functions are looked up
+// in env, which may be nil.
+func Eval(expr interface{}, env *Env) (Value, error) {
+	if env == nil {
+		env = NewEnv()
+	}
+	switch expr := expr.(type) {
+	case nil:
+		return Undef, nil
+	case Value:
+		return expr, nil
+	case *ast.Group:
+		return Eval(expr.Expr(), env)
+	case *ast.Variable:
+		return lookupVariable(expr.Name(), env), nil
+	case *ast.Index:
+		v, err := Eval(expr.Expr(), env)
+		if err != nil {
+			return nil, err
+		}
+		index, err := Eval(expr.Index(), env)
+		if err != nil {
+			return nil, err
+		}
+		return Index(v, index), nil
+	case *ast.ForRange:
+		return evalRange(expr, env)
+	case *ast.Call:
+		return evalCall(expr, env)
+	case *ast.FunctionLiteral:
+		return evalFunctionLiteral(expr, env), nil
+	case *ast.LookupStmt:
+		return evalCall(ast.NewCall(`lookup`).Parameters(expr.Key(), expr.Values()), env)
+	case *ast.LetExpr:
+		child, err := bind(expr.Variables(), env)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to evaluate let expression: %w`, err)
+		}
+		return Eval(expr.BodyExpr(), child)
+	case *ast.UnaryOp:
+		return evalUnaryOp(expr, env)
+	case *ast.BinaryOp:
+		return evalBinaryOp(expr, env)
+	case *ast.TernaryOp:
+		cond, err := Eval(expr.Condition(), env)
+		if err != nil {
+			return nil, err
+		}
+		if Truthy(cond) {
+			return Eval(expr.TrueExpr(), env)
+		}
+		return Eval(expr.FalseExpr(), env)
+	case *ast.ForExpr:
+		return nil, fmt.Errorf(`for expressions are only allowed in list comprehensions`)
+	case *ast.IfExpr:
+		return nil, fmt.Errorf(`if expressions are only allowed in list comprehensions`)
+	case *ast.EachExpr:
+		return nil, fmt.Errorf(`each is only allowed in list comprehensions`)
+	case *ast.Point2D:
+		return evalList([]interface{}{expr.X(), expr.Y()}, env)
+	case []interface{}:
+		return evalList(expr, env)
+	}
+
+	if rv := reflect.ValueOf(expr); rv.Kind() == reflect.Slice {
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			list[i] = rv.Index(i).Interface()
+		}
+		return evalList(list, env)
+	}
+	if _, ok := expr.(ast.Expr); ok {
+		return nil, fmt.Errorf(`can not evaluate %T`, expr)
+	}
+	return ValueOf(expr)
+}
+
+func evalUnaryOp(op *ast.UnaryOp, env *Env) (Value, error) {
+	v, err := Eval(op.Expr(), env)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op() {
+	case `-`:
+		return Neg(v), nil
+	case `+`:
+		return v, nil
+	case `!`:
+		return Bool(!Truthy(v)), nil
+	}
+	return nil, fmt.Errorf(`unknown unary operator %q`, op.Op())
+}
+
+func evalBinaryOp(op *ast.BinaryOp, env *Env) (Value, error) {
+	left, err := Eval(op.Left(), env)
+	if err != nil {
+		return nil, err
+	}
+
+	// logical operators only evaluate the right hand side when needed
+	switch op.Op() {
+	case `&&`:
+		if !Truthy(left) {
+			return Bool(false), nil
+		}
+		right, err := Eval(op.Right(), env)
+		if err != nil {
+			return nil, err
+		}
+		return Bool(Truthy(right)), nil
+	case `||`:
+		if Truthy(left) {
+			return Bool(true), nil
+		}
+		right, err := Eval(op.Right(), env)
+		if err != nil {
+			return nil, err
+		}
+		return Bool(Truthy(right)), nil
+	}
+
+	right, err := Eval(op.Right(), env)
+	if err != nil {
+		return nil, err
+	}
+	switch op.Op() {
+	case `+`:
+		return Add(left, right), nil
+	case `-`:
+		return Sub(left, right), nil
+	case `*`:
+		return Mul(left, right), nil
+	case `/`:
+		return Div(left, right), nil
+	case `%`:
+		return Mod(left, right), nil
+	case `^`:
+		return Pow(left, right), nil
+	case `==`:
+		return Bool(Equal(left, right)), nil
+	case `!=`:
+		return Bool(!Equal(left, right)), nil
+	case `<`:
+		return Less(left, right), nil
+	case `<=`:
+		return LessEqual(left, right), nil
+	case `>`:
+		return Greater(left, right), nil
+	case `>=`:
+		return GreaterEqual(left, right), nil
+	}
+	return nil, fmt.Errorf(`unknown operator %q`, op.Op())
+}
+
+func lookupVariable(name string, env *Env) Value {
+	switch name {
+	case `true`:
+		return Bool(true)
+	case `false`:
+		return Bool(false)
+	case `undef`:
+		return Undef
+	}
+	if v, ok := env.Get(name); ok {
+		return v
+	}
+	return Undef
+}
+
+func evalRange(r *ast.ForRange, env *Env) (Value, error) {
+	begin, err := Eval(r.Start(), env)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to evaluate start of range: %w`, err)
+	}
+	end, err := Eval(r.End(), env)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to evaluate end of range: %w`, err)
+	}
+	step := Value(Number(1))
+	if r.Step() != nil {
+		step, err = Eval(r.Step(), env)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to evaluate step of range: %w`, err)
+		}
+	}
+
+	b, ok := begin.(Number)
+	if !ok {
+		return Undef, nil
+	}
+	e, ok := end.(Number)
+	if !ok {
+		return Undef, nil
+	}
+	s, ok := step.(Number)
+	if !ok {
+		return Undef, nil
+	}
+	if r.Step() == nil && b > e {
+		// [begin:end] with begin > end counts up from end to begin
+		b, e = e, b
+	}
+	return NewRange(float64(b), float64(s), float64(e)), nil
+}
+
+// EvalArguments evaluates the arguments of a call. Assignments such as
+// `r=2` are named arguments.
+func EvalArguments(call *ast.Call, env *Env) ([]Argument, error) {
+	params := call.Arguments()
+	args := make([]Argument, 0, len(params))
+	for i, param := range params {
+		if v, ok := param.(*ast.Variable); ok && v.HasValue() {
+			value, err := Eval(v.ValueExpr(), env)
+			if err != nil {
+				return nil, fmt.Errorf(`failed to evaluate argument %s: %w`, v.Name(), err)
+			}
+			args = append(args, Argument{Name: v.Name(), Value: value})
+			continue
+		}
+		value, err := Eval(param, env)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to evaluate argument %d: %w`, i, err)
+		}
+		args = append(args, Argument{Value: value})
+	}
+	return args, nil
+}
+
+// evalCall calls a function. Unknown functions result in undef.
+func evalCall(call *ast.Call, env *Env) (Value, error) {
+	fn, ok := env.LookupFunction(call.Name())
+	if !ok {
+		return Undef, nil
+	}
+	args, err := EvalArguments(call, env)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to call %s: %w`, call.Name(), err)
+	}
+	// errors are not wrapped here, as they would be wrapped once for
+	// each level of recursion
+	return fn.Call(env, args)
+}
+
+// evalList evaluates a list literal, which may contain list comprehensions
+func evalList(list []interface{}, env *Env) (Value, error) {
+	ret := make(Vector, 0, len(list))
+	for i, elem := range list {
+		err := generate(elem, env, func(v Value) {
+			ret = append(ret, v)
+		})
+		if err != nil {
+			return nil, fmt.Errorf(`failed to evaluate list element %d: %w`, i, err)
+		}
+	}
+	return ret, nil
+}
+
+// generate evaluates an element of a list, which may be a list
+// comprehension that produces any number of values
+func generate(expr interface{}, env *Env, yield func(Value)) error {
+	switch expr := expr.(type) {
+	case *ast.ForExpr:
+		return generateFor(expr.LoopVars(), expr.BodyExpr(), env, yield)
+	case *ast.IfExpr:
+		cond, err := Eval(expr.Condition(), env)
+		if err != nil {
+			return fmt.Errorf(`failed to evaluate condition: %w`, err)
+		}
+		if Truthy(cond) {
+			return generate(expr.BodyExpr(), env, yield)
+		}
+		if expr.ElseExpr() != nil {
+			return generate(expr.ElseExpr(), env, yield)
+		}
+		return nil
+	case *ast.EachExpr:
+		// each adds the elements of every value that its operand produces
+		var iterErr error
+		err := generate(expr.Expr(), env, func(v Value) {
+			if iterErr == nil {
+				iterErr = Iterate(v, func(elem Value) error {
+					yield(elem)
+					return nil
+				})
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return iterErr
+	case *ast.LetExpr:
+		switch expr.BodyExpr().(type) {
+		case *ast.ForExpr, *ast.IfExpr, *ast.LetExpr, *ast.EachExpr:
+			child, err := bind(expr.Variables(), env)
+			if err != nil {
+				return err
+			}
+			return generate(expr.BodyExpr(), child, yield)
+		}
+	}
+
+	v, err := Eval(expr, env)
+	if err != nil {
+		return err
+	}
+	yield(v)
+	return nil
+}
+
+func generateFor(loopVars []*ast.LoopVar, body interface{}, env *Env, yield func(Value)) error {
+	if len(loopVars) == 0 {
+		return generate(body, env, yield)
+	}
+
+	lv := loopVars[0]
+	values, err := Eval(lv.Expr(), env)
+	if err != nil {
+		return fmt.Errorf(`failed to evaluate values of %s: %w`, lv.Variable().Name(), err)
+	}
+	return Iterate(values, func(v Value) error {
+		child := env.NewChild()
+		child.Set(lv.Variable().Name(), v)
+		return generateFor(loopVars[1:], body, child, yield)
+	})
+}
+
+// Iterate calls fn for each element of v, as a for loop over v would.
+// Vectors produce their elements, ranges their numbers and strings their
+// characters. undef produces nothing, and any other value produces itself.
+func Iterate(v Value, fn func(Value) error) error {
+	switch v := v.(type) {
+	case Vector:
+		for _, elem := range v {
+			if err := fn(elem); err != nil {
+				return err
+			}
+		}
+	case Range:
+		n := v.Len()
+		if n > maxRangeElements {
+			return fmt.Errorf(`range %s has too many elements`, v)
+		}
+		for i := 0; i < n; i++ {
+			if err := fn(Number(v.At(i))); err != nil {
+				return err
+			}
+		}
+	case String:
+		for _, r := range string(v) {
+			if err := fn(String(r)); err != nil {
+				return err
+			}
+		}
+	case undef:
+	default:
+		return fn(v)
+	}
+	return nil
+}
+
+// bind creates a child of env with the given variables assigned, in
+// order, so that each can refer to the ones before it
+func bind(variables []*ast.Variable, env *Env) (*Env, error) {
+	child := env.NewChild()
+	for _, v := range variables {
+		value, err := Eval(v.ValueExpr(), child)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to evaluate %s: %w`, v.Name(), err)
+		}
+		child.Set(v.Name(), value)
+	}
+	return child, nil
+}
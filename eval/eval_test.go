@@ -0,0 +1,261 @@
+package eval_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/eval"
+	"github.com/stretchr/testify/require"
+)
+
+// parseExpr parses `x = <src>;` and returns the expression
+func parseExpr(t *testing.T, src string) interface{} {
+	t.Helper()
+	stmts, err := openscad.Parse([]byte(`x = ` + src + `;`))
+	require.NoError(t, err, `parsing %q should succeed`, src)
+	require.Len(t, stmts, 1)
+	v, ok := stmts[0].(*ast.Variable)
+	require.True(t, ok, `statement should be an assignment`)
+	return v.ValueExpr()
+}
+
+func TestEval(t *testing.T) {
+	env := eval.NewEnv()
+	env.Set(`a`, eval.Number(3))
+	env.Set(`v`, eval.Vector{eval.Number(1), eval.Number(2), eval.Number(3)})
+	env.Set(`s`, eval.String(`héllo`))
+
+	// expected values are formatted as with str(), and were checked
+	// against OpenSCAD
+	testcases := []struct {
+		Expr     string
+		Expected string
+	}{
+		// numbers and precedence
+		{Expr: `1 + 2 * 3`, Expected: `7`},
+		{Expr: `(1 + 2) * 3`, Expected: `9`},
+		{Expr: `1 - 2 - 3`, Expected: `-4`},
+		{Expr: `8 / 4 / 2`, Expected: `1`},
+		{Expr: `10 - 2 * 3 + 1`, Expected: `5`},
+		{Expr: `7 % 3`, Expected: `1`},
+		{Expr: `-7 % 3`, Expected: `-1`},
+		{Expr: `1 / 3`, Expected: `0.333333`},
+		{Expr: `1000000`, Expected: `1e+06`},
+		{Expr: `123456.7`, Expected: `123457`},
+		{Expr: `0.00001`, Expected: `1e-05`},
+		{Expr: `1 / 0`, Expected: `inf`},
+		{Expr: `-1 / 0`, Expected: `-inf`},
+		{Expr: `0 / 0`, Expected: `nan`},
+		{Expr: `-a`, Expected: `-3`},
+		{Expr: `a * -2`, Expected: `-6`},
+		{Expr: `2 ^ 3 ^ 2`, Expected: `512`},
+		{Expr: `-2 ^ 2`, Expected: `-4`},
+		{Expr: `2 ^ -1`, Expected: `0.5`},
+		{Expr: `1e6`, Expected: `1e+06`},
+		{Expr: `2.5E-3`, Expected: `0.0025`},
+		{Expr: `-[1, 2]`, Expected: `[-1, -2]`},
+		{Expr: `+a`, Expected: `3`},
+		// comparisons and logic
+		{Expr: `1 < 2 == true`, Expected: `true`},
+		{Expr: `a > 2 && a < 4`, Expected: `true`},
+		{Expr: `a < 2 || a > 2`, Expected: `true`},
+		{Expr: `a != 3`, Expected: `false`},
+		{Expr: `!0`, Expected: `true`},
+		{Expr: `!a || false`, Expected: `false`},
+		{Expr: `"abc" < "abd"`, Expected: `true`},
+		{Expr: `1 < "a"`, Expected: `undef`},
+		{Expr: `[1, 2] < [1, 3]`, Expected: `undef`},
+		{Expr: `1 == true`, Expected: `false`},
+		{Expr: `undef == undef`, Expected: `true`},
+		{Expr: `0 / 0 == 0 / 0`, Expected: `false`},
+		{Expr: `[1, [2, "x"]] == [1, [2, "x"]]`, Expected: `true`},
+		{Expr: `[1, 2] == [1, 2, 3]`, Expected: `false`},
+		{Expr: `[] ? 1 : 2`, Expected: `2`},
+		{Expr: `"" ? 1 : 2`, Expected: `2`},
+		{Expr: `[0] ? 1 : 2`, Expected: `1`},
+		{Expr: `a > 2 ? "big" : "small"`, Expected: `big`},
+		{Expr: `true ? 1 : false ? 2 : 3`, Expected: `1`},
+		{Expr: `false ? 1 : false ? 2 : 3`, Expected: `3`},
+		// vectors
+		{Expr: `[1, 2, 3] + [10, 20]`, Expected: `[11, 22]`},
+		{Expr: `[1, [2, 3]] - [1, [1, 1]]`, Expected: `[0, [1, 2]]`},
+		{Expr: `[1, 2] + 1`, Expected: `undef`},
+		{Expr: `2 * [1, [2, 3]]`, Expected: `[2, [4, 6]]`},
+		{Expr: `[2, 4] / 2`, Expected: `[1, 2]`},
+		{Expr: `12 / [2, 4]`, Expected: `[6, 3]`},
+		{Expr: `[1, 2, 3] * [4, 5, 6]`, Expected: `32`},
+		{Expr: `[1, 2] * [4, 5, 6]`, Expected: `undef`},
+		{Expr: `[[1, 2], [3, 4]] * [1, 1]`, Expected: `[3, 7]`},
+		{Expr: `[1, 1] * [[1, 2], [3, 4]]`, Expected: `[4, 6]`},
+		{Expr: `[[1, 2], [3, 4]] * [[1, 0], [0, 1]]`, Expected: `[[1, 2], [3, 4]]`},
+		{Expr: `["a", 1] * 2`, Expected: `[undef, 2]`},
+		{Expr: `"a" + "b"`, Expected: `undef`},
+		{Expr: `true + 1`, Expected: `undef`},
+		{Expr: `undef + 1`, Expected: `undef`},
+		// indexing
+		{Expr: `v[1]`, Expected: `2`},
+		{Expr: `v[1.9]`, Expected: `2`},
+		{Expr: `v[3]`, Expected: `undef`},
+		{Expr: `v[-1]`, Expected: `undef`},
+		{Expr: `v["a"]`, Expected: `undef`},
+		{Expr: `[[1, 2], [3, 4]][1][0]`, Expected: `3`},
+		{Expr: `s[1]`, Expected: `é`},
+		{Expr: `s[5]`, Expected: `undef`},
+		{Expr: `a[0]`, Expected: `undef`},
+		{Expr: `unknown`, Expected: `undef`},
+		{Expr: `unknown(1)`, Expected: `undef`},
+		// strings in vectors are quoted
+		{Expr: `["a", 1, true, undef]`, Expected: `["a", 1, true, undef]`},
+		// let
+		{Expr: `let (b = a * 2, c = b + 1) [b, c]`, Expected: `[6, 7]`},
+		{Expr: `let (a = 10) a + 1`, Expected: `11`},
+		// list comprehensions
+		{Expr: `[for (i = [0:3]) i * i]`, Expected: `[0, 1, 4, 9]`},
+		{Expr: `[for (i = [0:2:7]) i]`, Expected: `[0, 2, 4, 6]`},
+		{Expr: `[for (i = [3:-1:0]) i]`, Expected: `[3, 2, 1, 0]`},
+		{Expr: `[for (i = [3:0]) i]`, Expected: `[0, 1, 2, 3]`},
+		{Expr: `[for (i = [0:-1:3]) i]`, Expected: `[]`},
+		{Expr: `[for (i = [0:0.25:1]) i]`, Expected: `[0, 0.25, 0.5, 0.75, 1]`},
+		{Expr: `[for (i = [0:3]) if (i % 2 == 1) i]`, Expected: `[1, 3]`},
+		{Expr: `[for (i = [1:2], j = [0:i]) [i, j]]`, Expected: `[[1, 0], [1, 1], [2, 0], [2, 1], [2, 2]]`},
+		{Expr: `[for (x = v) x + a]`, Expected: `[4, 5, 6]`},
+		{Expr: `[for (c = "ab") c]`, Expected: `["a", "b"]`},
+		{Expr: `[for (x = 5) x]`, Expected: `[5]`},
+		{Expr: `[for (x = undef) x]`, Expected: `[]`},
+		{Expr: `[0, for (i = [1:2]) let (j = i * 10) j, 30]`, Expected: `[0, 10, 20, 30]`},
+		{Expr: `[for (i = [0:1]) for (j = [0:1]) i + j]`, Expected: `[0, 1, 1, 2]`},
+		{Expr: `[for (i = [0:3]) if (i % 2 == 0) i else -i]`, Expected: `[0, -1, 2, -3]`},
+		{Expr: `[for (i = [0:1]) if (i == 0) if (false) 1 else 2]`, Expected: `[2]`},
+		{Expr: `[if (a > 5) "big" else "small"]`, Expected: `["small"]`},
+		{Expr: `[each v, 4]`, Expected: `[1, 2, 3, 4]`},
+		{Expr: `[each "ab"]`, Expected: `["a", "b"]`},
+		{Expr: `[for (i = [1:2]) each [i, i * 10]]`, Expected: `[1, 10, 2, 20]`},
+		{Expr: `[each for (i = [1:2]) [i, -i]]`, Expected: `[1, -1, 2, -2]`},
+		{Expr: `[for (i = [1:2]) let (j = [i, i]) each j]`, Expected: `[1, 1, 2, 2]`},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Expr, func(t *testing.T) {
+			v, err := eval.Eval(parseExpr(t, tc.Expr), env)
+			require.NoError(t, err, `eval.Eval should succeed`)
+			require.Equal(t, tc.Expected, v.String(), `value should match`)
+		})
+	}
+}
+
+func TestEvalAST(t *testing.T) {
+	t.Run("trees are evaluated as built", func(t *testing.T) {
+		testcases := []struct {
+			Expr     interface{}
+			Emitted  string
+			Expected eval.Value
+		}{
+			{Expr: ast.NewBinaryOp(`*`, ast.NewBinaryOp(`+`, 1, 2), 3), Emitted: `(1 + 2) * 3`, Expected: eval.Number(9)},
+			{Expr: ast.NewBinaryOp(`+`, 1, ast.NewBinaryOp(`*`, 2, 3)), Emitted: `1 + 2 * 3`, Expected: eval.Number(7)},
+			{Expr: ast.NewBinaryOp(`-`, 1, ast.NewBinaryOp(`-`, 2, 3)), Emitted: `1 - (2 - 3)`, Expected: eval.Number(2)},
+			{Expr: ast.NewBinaryOp(`-`, ast.NewBinaryOp(`-`, 1, 2), 3), Emitted: `1 - 2 - 3`, Expected: eval.Number(-4)},
+			{Expr: ast.NewBinaryOp(`^`, ast.NewUnaryOp(`-`, 2), 2), Emitted: `(-2) ^ 2`, Expected: eval.Number(4)},
+			{Expr: ast.NewBinaryOp(`^`, ast.NewBinaryOp(`^`, 2, 3), 2), Emitted: `(2 ^ 3) ^ 2`, Expected: eval.Number(64)},
+			{Expr: ast.NewBinaryOp(`^`, 2, ast.NewBinaryOp(`^`, 3, 2)), Emitted: `2 ^ 3 ^ 2`, Expected: eval.Number(512)},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Emitted, func(t *testing.T) {
+				require.Equal(t, tc.Emitted, fmt.Sprint(tc.Expr), `emitted code should match`)
+				v, err := eval.Eval(tc.Expr, nil)
+				require.NoError(t, err, `eval.Eval should succeed`)
+				require.Equal(t, tc.Expected, v, `value should match`)
+			})
+		}
+	})
+	t.Run("operators", func(t *testing.T) {
+		env := eval.NewEnv()
+		env.Set(`a`, eval.Number(3))
+		testcases := []struct {
+			Name     string
+			Expr     interface{}
+			Expected string
+		}{
+			// `!a || false`
+			{Name: `or`, Expr: ast.NewBinaryOp(`||`, ast.NewUnaryOp(`!`, ast.NewVariable(`a`)), false), Expected: `false`},
+			// `true || undefined_function()` does not evaluate the right side
+			{Name: `short circuit`, Expr: ast.NewBinaryOp(`||`, true, ast.NewCall(`f`)), Expected: `true`},
+			{Name: `not equal`, Expr: ast.NewBinaryOp(`!=`, []interface{}{1, 2}, []interface{}{1, 3}), Expected: `true`},
+			{Name: `negate vector`, Expr: ast.NewUnaryOp(`-`, []interface{}{1, []interface{}{2, -3}}), Expected: `[-1, [-2, 3]]`},
+			{Name: `quoted strings`, Expr: []interface{}{"a\"b\\", "c"}, Expected: `["a\"b\\", "c"]`},
+			// `(let (b = 1) b) + (1 ? 10 : 20)`
+			{Name: `let body`, Expr: ast.NewBinaryOp(`+`, ast.NewLetExpr(ast.NewVariable(`b`).Value(1)).Expr(ast.NewVariable(`b`)), ast.NewTernaryOp(1, 10, 20)), Expected: `11`},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				v, err := eval.Eval(tc.Expr, env)
+				require.NoError(t, err, `eval.Eval should succeed`)
+				require.Equal(t, tc.Expected, v.String(), `value should match`)
+			})
+		}
+	})
+	t.Run("Go values", func(t *testing.T) {
+		v, err := eval.Eval([]interface{}{1, 2.5, "x", true, nil, []int{3}}, nil)
+		require.NoError(t, err, `eval.Eval should succeed`)
+		require.Equal(t, eval.Vector{eval.Number(1), eval.Number(2.5), eval.String("x"), eval.Bool(true), eval.Undef, eval.Vector{eval.Number(3)}}, v)
+	})
+	t.Run("ranges", func(t *testing.T) {
+		v, err := eval.Eval(ast.NewForRange(0, 10).Increment(2.5), nil)
+		require.NoError(t, err, `eval.Eval should succeed`)
+		require.Equal(t, eval.NewRange(0, 2.5, 10), v)
+		require.Equal(t, `[0 : 2.5 : 10]`, v.String())
+		require.Equal(t, 5, v.(eval.Range).Len())
+		require.Equal(t, eval.Number(2.5), eval.Index(v, eval.Number(1)), `range[1] is the step`)
+
+		v, err = eval.Eval(ast.NewForRange(`a`, 10), nil)
+		require.NoError(t, err, `eval.Eval should succeed`)
+		require.Equal(t, eval.Undef, v, `ranges of non-numbers are undef`)
+
+		_, err = eval.Eval([]interface{}{ast.NewForExpr([]*ast.LoopVar{ast.NewLoopVar(ast.NewVariable(`i`), ast.NewForRange(0, 1).Increment(0))}).Body(ast.NewVariable(`i`))}, nil)
+		require.Error(t, err, `iterating over an infinite range should fail`)
+		require.Equal(t, math.MaxInt, eval.NewRange(0, 0, 1).Len())
+	})
+	t.Run("functions", func(t *testing.T) {
+		env := eval.NewEnv()
+		env.SetFunction(`add`, eval.FunctionFunc(func(_ *eval.Env, args []eval.Argument) (eval.Value, error) {
+			sum := eval.Value(eval.Number(0))
+			for _, arg := range args {
+				if arg.Name == `` || arg.Name == `extra` {
+					sum = eval.Add(sum, arg.Value)
+				}
+			}
+			return sum, nil
+		}))
+		child := env.NewChild()
+		child.Set(`x`, eval.Number(2))
+
+		v, err := eval.Eval(parseExpr(t, `add(1, x, extra = x * 10)`), child)
+		require.NoError(t, err, `eval.Eval should succeed`)
+		require.Equal(t, eval.Number(23), v)
+	})
+	t.Run("errors", func(t *testing.T) {
+		_, err := eval.Eval(ast.NewForExpr(nil).Body(1), nil)
+		require.Error(t, err, `for expressions outside of lists should fail`)
+		_, err = eval.Eval(ast.NewBinaryOp(`<>`, 1, 2), nil)
+		require.Error(t, err, `unknown operators should fail`)
+		_, err = eval.Eval(ast.NewCube(1, 1, 1), nil)
+		require.Error(t, err, `statements should fail`)
+	})
+}
+
+func TestValueOf(t *testing.T) {
+	v, err := eval.ValueOf(map[string]int{})
+	require.Error(t, err, `maps can not be converted`)
+	require.Nil(t, v)
+
+	v, err = eval.ValueOf([2]float32{1, 2})
+	require.NoError(t, err, `eval.ValueOf should succeed`)
+	require.Equal(t, eval.Vector{eval.Number(1), eval.Number(2)}, v)
+	require.Equal(t, eval.TypeVector, v.Type())
+	require.Equal(t, `vector`, v.Type().String())
+}
@@ -0,0 +1,167 @@
+package eval
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+// closure is a function defined in OpenSCAD code, along with the Env that
+// it was defined in
+type closure struct {
+	name   string
+	params []*ast.Variable
+	body   interface{}
+	env    *Env
+}
+
+// NewClosure creates a function whose body is evaluated in a frame of env
+// (see Env.NewFrame), such as a function declared with `function name(...)`
+// in a file whose variables are held by env.
+//
+// Arguments are bound to params following OpenSCAD's rules: positional
+// arguments are assigned in order, named arguments by name, and missing
+// ones take their default values, which are evaluated in env. Named
+// arguments for special variables are set even if they are not
+// parameters. Any other arguments are ignored.
+func NewClosure(name string, params []*ast.Variable, body interface{}, env *Env) Function {
+	return &closure{
+		name:   name,
+		params: params,
+		body:   body,
+		env:    env,
+	}
+}
+
+func (c *closure) Call(caller *Env, args []Argument) (Value, error) {
+	depth := 1
+	if caller != nil {
+		depth = caller.depth + 1
+	}
+	if depth > MaxCallDepth {
+		return nil, fmt.Errorf(`recursion is too deep calling %s (more than %d nested calls)`, c.name, MaxCallDepth)
+	}
+
+	// tail calls replace the current call instead of nesting, so that
+	// tail recursive functions can recurse indefinitely
+	fn := c
+	for {
+		frame, err := fn.bind(caller, args, depth)
+		if err != nil {
+			return nil, err
+		}
+		v, tail, err := evalTail(fn.body, frame)
+		if err != nil {
+			return nil, err
+		}
+		if tail == nil {
+			return v, nil
+		}
+		fn, caller, args = tail.fn, tail.caller, tail.args
+	}
+}
+
+func (c *closure) bind(caller *Env, args []Argument, depth int) (*Env, error) {
+	frame := c.env.newFrame(caller, depth)
+	if err := bindArguments(frame, c.name, c.params, args); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+// bindArguments sets the parameters of a function or module in frame,
+// whose parent is the Env that the function or module was defined in
+func bindArguments(frame *Env, name string, params []*ast.Variable, args []Argument) error {
+	assigned := make(map[string]struct{}, len(params))
+	position := 0
+	for _, arg := range args {
+		argName := arg.Name
+		if argName == `` {
+			if position >= len(params) {
+				continue
+			}
+			argName = params[position].Name()
+			position++
+		} else if !strings.HasPrefix(argName, `$`) && !hasParam(params, argName) {
+			continue
+		}
+		frame.Set(argName, arg.Value)
+		assigned[argName] = struct{}{}
+	}
+
+	for _, param := range params {
+		if _, ok := assigned[param.Name()]; ok {
+			continue
+		}
+		v := Undef
+		if param.HasValue() {
+			var err error
+			v, err = Eval(param.ValueExpr(), frame.parent)
+			if err != nil {
+				return fmt.Errorf(`failed to evaluate default value of %s in %s: %w`, param.Name(), name, err)
+			}
+		}
+		frame.Set(param.Name(), v)
+	}
+	return nil
+}
+
+func hasParam(params []*ast.Variable, name string) bool {
+	for _, param := range params {
+		if param.Name() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// tailCall is a call to a closure in tail position, which is left to the
+// caller to perform
+type tailCall struct {
+	fn     *closure
+	caller *Env
+	args   []Argument
+}
+
+// evalTail works like Eval, but calls to closures in tail position are
+// returned rather than performed
+func evalTail(expr interface{}, env *Env) (Value, *tailCall, error) {
+	switch expr := expr.(type) {
+	case *ast.LetExpr:
+		child, err := bind(expr.Variables(), env)
+		if err != nil {
+			return nil, nil, fmt.Errorf(`failed to evaluate let expression: %w`, err)
+		}
+		return evalTail(expr.BodyExpr(), child)
+	case *ast.TernaryOp:
+		cond, err := Eval(expr.Condition(), env)
+		if err != nil {
+			return nil, nil, err
+		}
+		if Truthy(cond) {
+			return evalTail(expr.TrueExpr(), env)
+		}
+		return evalTail(expr.FalseExpr(), env)
+	case *ast.Group:
+		return evalTail(expr.Expr(), env)
+	case *ast.Call:
+		fn, ok := env.LookupFunction(expr.Name())
+		if c, isClosure := fn.(*closure); ok && isClosure {
+			args, err := EvalArguments(expr, env)
+			if err != nil {
+				return nil, nil, fmt.Errorf(`failed to call %s: %w`, expr.Name(), err)
+			}
+			return nil, &tailCall{fn: c, caller: env, args: args}, nil
+		}
+	}
+	v, err := Eval(expr, env)
+	return v, nil, err
+}
+
+func evalFunctionLiteral(f *ast.FunctionLiteral, env *Env) Value {
+	return &FunctionValue{
+		Function: NewClosure(`function literal`, f.Params(), f.BodyExpr(), env),
+		Literal:  f.String(),
+	}
+}
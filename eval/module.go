@@ -0,0 +1,42 @@
+package eval
+
+import (
+	"fmt"
+
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+// Module is a module declared with `module name(...) { ... }`, along with
+// the Env that it was declared in. Modules are only kept track of by this
+// package: they are instantiated by the interp package.
+type Module struct {
+	Name   string
+	Params []*ast.Variable
+	Body   []ast.Stmt
+	Env    *Env
+}
+
+// NewModule creates a module declared in env
+func NewModule(decl *ast.Module, env *Env) *Module {
+	return &Module{
+		Name:   decl.Name(),
+		Params: decl.Params(),
+		Body:   decl.Children(),
+		Env:    env,
+	}
+}
+
+// Bind creates the Env that the body of the module is executed in, when
+// it is instantiated from caller with args. Arguments are bound to the
+// parameters of the module the same way as they are for functions (see
+// NewClosure).
+func (m *Module) Bind(caller *Env, args []Argument) (*Env, error) {
+	frame, err := m.Env.NewFrame(caller)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to instantiate %s: %w`, m.Name, err)
+	}
+	if err := bindArguments(frame, m.Name, m.Params, args); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
@@ -0,0 +1,349 @@
+package eval
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// Add returns a + b. Numbers are added, and vectors are added element by
+// element, up to the length of the shorter one. Anything else is undef.
+func Add(a, b Value) Value {
+	return elementwise(a, b, func(x, y float64) float64 { return x + y }, Add)
+}
+
+// Sub returns a - b, following the same rules as Add
+func Sub(a, b Value) Value {
+	return elementwise(a, b, func(x, y float64) float64 { return x - y }, Sub)
+}
+
+func elementwise(a, b Value, fn func(x, y float64) float64, recurse func(a, b Value) Value) Value {
+	switch a := a.(type) {
+	case Number:
+		if b, ok := b.(Number); ok {
+			return Number(fn(float64(a), float64(b)))
+		}
+	case Vector:
+		if b, ok := b.(Vector); ok {
+			n := len(a)
+			if len(b) < n {
+				n = len(b)
+			}
+			ret := make(Vector, n)
+			for i := 0; i < n; i++ {
+				ret[i] = recurse(a[i], b[i])
+			}
+			return ret
+		}
+	}
+	return Undef
+}
+
+// Mul returns a * b. Besides numbers, this handles scaling vectors by a
+// number, dot products of vectors, and the products of matrices with
+// vectors and other matrices.
+func Mul(a, b Value) Value {
+	switch a := a.(type) {
+	case Number:
+		switch b := b.(type) {
+		case Number:
+			return a * b
+		case Vector:
+			return scale(b, a)
+		}
+	case Vector:
+		switch b := b.(type) {
+		case Number:
+			return scale(a, b)
+		case Vector:
+			return mulVectors(a, b)
+		}
+	}
+	return Undef
+}
+
+func scale(v Vector, n Number) Value {
+	ret := make(Vector, len(v))
+	for i, elem := range v {
+		ret[i] = Mul(elem, n)
+	}
+	return ret
+}
+
+func mulVectors(a, b Vector) Value {
+	if len(a) == 0 || len(b) == 0 {
+		return Undef
+	}
+	_, aMatrix := a[0].(Vector)
+	_, bMatrix := b[0].(Vector)
+	switch {
+	case !aMatrix && !bMatrix:
+		return dot(a, b)
+	case aMatrix && !bMatrix:
+		ret := make(Vector, len(a))
+		for i, row := range a {
+			row, ok := row.(Vector)
+			if !ok {
+				return Undef
+			}
+			v := dot(row, b)
+			if v == Undef {
+				return Undef
+			}
+			ret[i] = v
+		}
+		return ret
+	case !aMatrix && bMatrix:
+		return mulVectorMatrix(a, b)
+	default:
+		ret := make(Vector, len(a))
+		for i, row := range a {
+			row, ok := row.(Vector)
+			if !ok {
+				return Undef
+			}
+			v := mulVectorMatrix(row, b)
+			if v == Undef {
+				return Undef
+			}
+			ret[i] = v
+		}
+		return ret
+	}
+}
+
+// dot returns the dot product of two vectors of numbers
+func dot(a, b Vector) Value {
+	if len(a) != len(b) {
+		return Undef
+	}
+	var sum float64
+	for i := range a {
+		x, ok := a[i].(Number)
+		if !ok {
+			return Undef
+		}
+		y, ok := b[i].(Number)
+		if !ok {
+			return Undef
+		}
+		sum += float64(x) * float64(y)
+	}
+	return Number(sum)
+}
+
+func mulVectorMatrix(v, m Vector) Value {
+	if len(v) != len(m) {
+		return Undef
+	}
+	rows := make([]Vector, len(m))
+	for i, row := range m {
+		row, ok := row.(Vector)
+		if !ok {
+			return Undef
+		}
+		if i > 0 && len(row) != len(rows[0]) {
+			return Undef
+		}
+		rows[i] = row
+	}
+	ret := make(Vector, len(rows[0]))
+	for j := range ret {
+		var sum float64
+		for i, row := range rows {
+			x, ok := v[i].(Number)
+			if !ok {
+				return Undef
+			}
+			y, ok := row[j].(Number)
+			if !ok {
+				return Undef
+			}
+			sum += float64(x) * float64(y)
+		}
+		ret[j] = Number(sum)
+	}
+	return ret
+}
+
+// Div returns a / b. Vectors can be divided by a number, and a number by
+// a vector, element by element.
+func Div(a, b Value) Value {
+	switch a := a.(type) {
+	case Number:
+		switch b := b.(type) {
+		case Number:
+			return a / b
+		case Vector:
+			ret := make(Vector, len(b))
+			for i, elem := range b {
+				ret[i] = Div(a, elem)
+			}
+			return ret
+		}
+	case Vector:
+		if b, ok := b.(Number); ok {
+			ret := make(Vector, len(a))
+			for i, elem := range a {
+				ret[i] = Div(elem, b)
+			}
+			return ret
+		}
+	}
+	return Undef
+}
+
+// Mod returns a % b, which has the sign of a. It is only defined for
+// numbers.
+func Mod(a, b Value) Value {
+	x, ok := a.(Number)
+	if !ok {
+		return Undef
+	}
+	y, ok := b.(Number)
+	if !ok {
+		return Undef
+	}
+	return Number(math.Mod(float64(x), float64(y)))
+}
+
+// Pow returns a ^ b. It is only defined for numbers.
+func Pow(a, b Value) Value {
+	x, ok := a.(Number)
+	if !ok {
+		return Undef
+	}
+	y, ok := b.(Number)
+	if !ok {
+		return Undef
+	}
+	return Number(math.Pow(float64(x), float64(y)))
+}
+
+// Neg returns -v. Vectors are negated element by element.
+func Neg(v Value) Value {
+	switch v := v.(type) {
+	case Number:
+		return -v
+	case Vector:
+		ret := make(Vector, len(v))
+		for i, elem := range v {
+			ret[i] = Neg(elem)
+		}
+		return ret
+	}
+	return Undef
+}
+
+// Less returns a < b. Numbers, strings and booleans can be compared with
+// values of the same type; comparing anything else results in undef.
+func Less(a, b Value) Value {
+	return ordered(a, b, func(c int) bool { return c < 0 })
+}
+
+// LessEqual returns a <= b, following the same rules as Less
+func LessEqual(a, b Value) Value {
+	return ordered(a, b, func(c int) bool { return c <= 0 })
+}
+
+// Greater returns a > b, following the same rules as Less
+func Greater(a, b Value) Value {
+	return ordered(a, b, func(c int) bool { return c > 0 })
+}
+
+// GreaterEqual returns a >= b, following the same rules as Less
+func GreaterEqual(a, b Value) Value {
+	return ordered(a, b, func(c int) bool { return c >= 0 })
+}
+
+// unordered is the result of comparing NaN to anything
+const unordered = 2
+
+func ordered(a, b Value, fn func(c int) bool) Value {
+	c, ok := compare(a, b)
+	if !ok {
+		return Undef
+	}
+	if c == unordered {
+		return Bool(false)
+	}
+	return Bool(fn(c))
+}
+
+// compare returns -1, 0 or 1, or unordered if either number is NaN
+func compare(a, b Value) (int, bool) {
+	switch a := a.(type) {
+	case Number:
+		b, ok := b.(Number)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a < b:
+			return -1, true
+		case a > b:
+			return 1, true
+		case a == b:
+			return 0, true
+		}
+		return unordered, true
+	case String:
+		b, ok := b.(String)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(string(a), string(b)), true
+	case Bool:
+		b, ok := b.(Bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case a == b:
+			return 0, true
+		case !bool(a):
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+// Index returns v[index]. Vectors and strings are indexed by number, with
+// fractions truncated; strings are indexed by character. The elements of
+// a range are its begin, step and end. Indices that are out of bounds
+// result in undef.
+func Index(v, index Value) Value {
+	n, ok := index.(Number)
+	if !ok || math.IsNaN(float64(n)) || n < 0 {
+		return Undef
+	}
+	switch v := v.(type) {
+	case Vector:
+		if float64(n) >= float64(len(v)) {
+			return Undef
+		}
+		return v[int(n)]
+	case String:
+		if float64(n) >= float64(utf8.RuneCountInString(string(v))) {
+			return Undef
+		}
+		i := int(n)
+		for _, r := range string(v) {
+			if i == 0 {
+				return String(r)
+			}
+			i--
+		}
+	case Range:
+		switch int(n) {
+		case 0:
+			return Number(v.Begin)
+		case 1:
+			return Number(v.Step)
+		case 2:
+			return Number(v.End)
+		}
+	}
+	return Undef
+}
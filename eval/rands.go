@@ -0,0 +1,138 @@
+package eval
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// mt19937 is the 32-bit Mersenne Twister, as implemented by C++'s
+// std::mt19937, which OpenSCAD uses for rands()
+type mt19937 struct {
+	state [624]uint32
+	index int
+}
+
+func newMT19937(seed uint32) *mt19937 {
+	var mt mt19937
+	mt.seed(seed)
+	return &mt
+}
+
+func (mt *mt19937) seed(seed uint32) {
+	mt.state[0] = seed
+	for i := 1; i < len(mt.state); i++ {
+		prev := mt.state[i-1]
+		mt.state[i] = 1812433253*(prev^(prev>>30)) + uint32(i)
+	}
+	mt.index = len(mt.state)
+}
+
+func (mt *mt19937) next() uint32 {
+	const n, m = 624, 397
+	if mt.index >= n {
+		for i := 0; i < n; i++ {
+			y := mt.state[i]&0x80000000 | mt.state[(i+1)%n]&0x7fffffff
+			v := mt.state[(i+m)%n] ^ (y >> 1)
+			if y&1 != 0 {
+				v ^= 0x9908b0df
+			}
+			mt.state[i] = v
+		}
+		mt.index = 0
+	}
+	y := mt.state[mt.index]
+	mt.index++
+	y ^= y >> 11
+	y ^= (y << 7) & 0x9d2c5680
+	y ^= (y << 15) & 0xefc60000
+	y ^= y >> 18
+	return y
+}
+
+// canonical returns a number in [0, 1), computed from two outputs like
+// std::generate_canonical<double, 53> in libstdc++
+func (mt *mt19937) canonical() float64 {
+	lo := float64(mt.next())
+	hi := float64(mt.next())
+	ret := (lo + hi*(1<<32)) / (1 << 64)
+	if ret >= 1 {
+		ret = math.Nextafter(1, 0)
+	}
+	return ret
+}
+
+// hashFloat64 hashes a number like std::hash<double> in libstdc++, which
+// OpenSCAD uses to turn the seed passed to rands() into a seed for its
+// random number generator
+func hashFloat64(f float64) uint64 {
+	if f == 0 {
+		return 0
+	}
+	const mul = 0xc6a4a7935bd1e995
+	shiftMix := func(v uint64) uint64 { return v ^ (v >> 47) }
+
+	length := uint64(8)
+	hash := uint64(0xc70f6907) ^ (length * mul)
+	hash ^= shiftMix(math.Float64bits(f)*mul) * mul
+	hash *= mul
+	hash = shiftMix(hash) * mul
+	return shiftMix(hash)
+}
+
+// randomness is shared by all calls to rands(). As in OpenSCAD, passing a
+// seed reseeds it, which also determines the results of later calls to
+// rands() without a seed.
+var randomness = struct {
+	mu sync.Mutex
+	mt *mt19937
+}{
+	mt: newMT19937(uint32(time.Now().Unix())),
+}
+
+// builtinRands implements rands(min, max, count, seed), which returns count
+// random numbers in [min, max)
+func builtinRands(_ *Env, args []Argument) (Value, error) {
+	if len(args) < 3 || len(args) > 4 {
+		return Undef, nil
+	}
+	lo, ok := arg(args, 0).(Number)
+	if !ok || math.IsInf(float64(lo), 0) || math.IsNaN(float64(lo)) {
+		return Undef, nil
+	}
+	hi, ok := arg(args, 1).(Number)
+	if !ok || math.IsInf(float64(hi), 0) || math.IsNaN(float64(hi)) {
+		return Undef, nil
+	}
+	if hi < lo {
+		lo, hi = hi, lo
+	}
+	count, ok := arg(args, 2).(Number)
+	if !ok || math.IsInf(float64(count), 0) || math.IsNaN(float64(count)) {
+		return Undef, nil
+	}
+
+	randomness.mu.Lock()
+	defer randomness.mu.Unlock()
+	if len(args) == 4 {
+		seed, ok := arg(args, 3).(Number)
+		if !ok {
+			return Undef, nil
+		}
+		randomness.mt.seed(uint32(hashFloat64(float64(seed))))
+	}
+
+	n := int(math.Max(0, float64(count)))
+	if n > maxRangeElements {
+		n = maxRangeElements
+	}
+	ret := make(Vector, n)
+	for i := range ret {
+		if lo == hi {
+			ret[i] = lo
+			continue
+		}
+		ret[i] = Number(randomness.mt.canonical()*float64(hi-lo) + float64(lo))
+	}
+	return ret, nil
+}
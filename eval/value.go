@@ -0,0 +1,309 @@
+package eval
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Type is the type of a Value, as reported by OpenSCAD
+type Type int
+
+const (
+	TypeUndef Type = iota
+	TypeBool
+	TypeNumber
+	TypeString
+	TypeVector
+	TypeRange
+	TypeFunction
+)
+
+func (t Type) String() string {
+	switch t {
+	case TypeUndef:
+		return `undefined`
+	case TypeBool:
+		return `bool`
+	case TypeNumber:
+		return `number`
+	case TypeString:
+		return `string`
+	case TypeVector:
+		return `vector`
+	case TypeRange:
+		return `range`
+	case TypeFunction:
+		return `function`
+	}
+	return `unknown`
+}
+
+// Value is the result of evaluating an OpenSCAD expression. It is one of
+// Undef, Bool, Number, String, Vector, Range or *FunctionValue.
+//
+// String returns the value formatted the same way as OpenSCAD's str()
+// function does.
+type Value interface {
+	Type() Type
+	String() string
+}
+
+type undef struct{}
+
+// Undef is the undefined value
+var Undef Value = undef{}
+
+func (undef) Type() Type       { return TypeUndef }
+func (undef) String() string   { return `undef` }
+func (undef) GoString() string { return `eval.Undef` }
+
+type Bool bool
+
+func (Bool) Type() Type { return TypeBool }
+
+func (b Bool) String() string {
+	return strconv.FormatBool(bool(b))
+}
+
+type Number float64
+
+func (Number) Type() Type { return TypeNumber }
+
+// String formats the number with 6 significant digits, like OpenSCAD does.
+// Negative zero is formatted as 0.
+func (n Number) String() string {
+	f := float64(n)
+	switch {
+	case f == 0:
+		return `0`
+	case math.IsNaN(f):
+		return `nan`
+	case math.IsInf(f, 1):
+		return `inf`
+	case math.IsInf(f, -1):
+		return `-inf`
+	}
+	return strconv.FormatFloat(f, 'g', 6, 64)
+}
+
+type String string
+
+func (String) Type() Type { return TypeString }
+
+func (s String) String() string {
+	return string(s)
+}
+
+type Vector []Value
+
+func (Vector) Type() Type { return TypeVector }
+
+func (v Vector) String() string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, elem := range v {
+		if i > 0 {
+			sb.WriteString(`, `)
+		}
+		if s, ok := elem.(String); ok {
+			sb.WriteString(quote(string(s)))
+			continue
+		}
+		sb.WriteString(elem.String())
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+// quote formats s as a string literal
+func quote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			sb.WriteByte('\\')
+			sb.WriteRune(r)
+		case '\n':
+			sb.WriteString(`\n`)
+		case '\t':
+			sb.WriteString(`\t`)
+		case '\r':
+			sb.WriteString(`\r`)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+// maxRangeElements is the largest number of elements that a range may
+// produce when it is iterated over
+const maxRangeElements = 1000000
+
+// Range is a range such as [0:2:10]. Ranges are lazy: their elements are
+// only produced when they are iterated over.
+type Range struct {
+	Begin float64
+	Step  float64
+	End   float64
+}
+
+// NewRange creates the range [begin:step:end]
+func NewRange(begin, step, end float64) Range {
+	return Range{Begin: begin, Step: step, End: end}
+}
+
+func (Range) Type() Type { return TypeRange }
+
+func (r Range) String() string {
+	return fmt.Sprintf(`[%s : %s : %s]`, Number(r.Begin), Number(r.Step), Number(r.End))
+}
+
+// Len returns the number of elements in the range. Ranges whose step
+// points away from the end are empty. A step of zero results in an
+// infinite number of elements, which is reported as math.MaxInt.
+func (r Range) Len() int {
+	if math.IsNaN(r.Begin) || math.IsNaN(r.Step) || math.IsNaN(r.End) {
+		return 0
+	}
+	if r.Step < 0 {
+		if r.Begin < r.End {
+			return 0
+		}
+	} else if r.Begin > r.End {
+		return 0
+	}
+	if r.Begin == r.End || math.IsInf(r.Step, 0) {
+		return 1
+	}
+	if math.IsInf(r.Begin, 0) || math.IsInf(r.End, 0) || r.Step == 0 {
+		return math.MaxInt
+	}
+	steps := math.Abs((r.End - r.Begin) / r.Step)
+	if steps >= math.MaxInt32 {
+		return math.MaxInt
+	}
+	return int(steps) + 1
+}
+
+// At returns the i-th element of the range
+func (r Range) At(i int) float64 {
+	return r.Begin + r.Step*float64(i)
+}
+
+// FunctionValue is a function used as a value, such as the result of a
+// function literal
+type FunctionValue struct {
+	Function
+	// Literal is the code of the function, which is used by String()
+	Literal string
+}
+
+func (*FunctionValue) Type() Type { return TypeFunction }
+
+func (f *FunctionValue) String() string {
+	if f.Literal == `` {
+		return `function`
+	}
+	return f.Literal
+}
+
+// ValueOf converts a Go value to a Value. nil becomes Undef, and slices
+// and arrays become vectors. Values are returned as is.
+func ValueOf(v interface{}) (Value, error) {
+	switch v := v.(type) {
+	case nil:
+		return Undef, nil
+	case Value:
+		return v, nil
+	case bool:
+		return Bool(v), nil
+	case string:
+		return String(v), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Number(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return Number(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		return Number(rv.Float()), nil
+	case reflect.Bool:
+		return Bool(rv.Bool()), nil
+	case reflect.String:
+		return String(rv.String()), nil
+	case reflect.Slice, reflect.Array:
+		list := make(Vector, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			elem, err := ValueOf(rv.Index(i).Interface())
+			if err != nil {
+				return nil, fmt.Errorf(`failed to convert element %d: %w`, i, err)
+			}
+			list[i] = elem
+		}
+		return list, nil
+	}
+	return nil, fmt.Errorf(`can not convert %T to a value`, v)
+}
+
+// Truthy reports whether v is considered true in a condition. undef,
+// false, 0, empty strings and empty vectors are false.
+func Truthy(v Value) bool {
+	switch v := v.(type) {
+	case Bool:
+		return bool(v)
+	case Number:
+		return v != 0
+	case String:
+		return v != ``
+	case Vector:
+		return len(v) > 0
+	case Range, *FunctionValue:
+		return true
+	}
+	return false
+}
+
+// Equal reports whether a and b are equal, as with OpenSCAD's `==`
+// operator. Values of different types are never equal, and vectors are
+// equal when all of their elements are.
+func Equal(a, b Value) bool {
+	switch a := a.(type) {
+	case undef:
+		return b.Type() == TypeUndef
+	case Bool:
+		b, ok := b.(Bool)
+		return ok && a == b
+	case Number:
+		b, ok := b.(Number)
+		return ok && a == b
+	case String:
+		b, ok := b.(String)
+		return ok && a == b
+	case Range:
+		b, ok := b.(Range)
+		return ok && a == b
+	case *FunctionValue:
+		b, ok := b.(*FunctionValue)
+		return ok && a == b
+	case Vector:
+		b, ok := b.(Vector)
+		if !ok || len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !Equal(a[i], b[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
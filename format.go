@@ -0,0 +1,40 @@
+package openscad
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+// Format returns the formatted version of src, as emitted with the given
+// style. It refuses to format code that would not survive the round trip:
+// code with comments, which the parser discards, and code whose formatted
+// version parses into a different tree.
+func Format(src []byte, style ast.FormatStyle) ([]byte, error) {
+	if HasComments(src) {
+		return nil, fmt.Errorf(`refusing to format code with comments, as they would be lost`)
+	}
+
+	stmts, err := Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := ast.Emit(stmts, &buf, ast.WithFormatStyle(style)); err != nil {
+		return nil, fmt.Errorf(`failed to emit: %w`, err)
+	}
+	formatted := []byte(strings.TrimSpace(buf.String()) + "\n")
+
+	reparsed, err := Parse(formatted)
+	if err != nil {
+		return nil, fmt.Errorf(`refusing to format, as the result does not parse: %w`, err)
+	}
+	if !reflect.DeepEqual(stmts, reparsed) {
+		return nil, fmt.Errorf(`refusing to format, as the result does not parse back to the same code`)
+	}
+	return formatted, nil
+}
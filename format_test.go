@@ -0,0 +1,30 @@
+package openscad_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	t.Run("style", func(t *testing.T) {
+		formatted, err := openscad.Format([]byte("module m(){cube(1);}"), ast.FormatStyle{UseTabs: true, Braces: ast.BracesAlways})
+		require.NoError(t, err, `openscad.Format should succeed`)
+		require.Equal(t, "module m()\n{\n\tcube(1);\n}\n", string(formatted))
+	})
+	t.Run("list comprehensions", func(t *testing.T) {
+		formatted, err := openscad.Format([]byte("x=[for(i=v) if(i>0) each [i,i] else -i];"), ast.FormatStyle{})
+		require.NoError(t, err, `openscad.Format should succeed`)
+		require.Equal(t, "x = [for (i=v)if (i > 0)each [i, i] else -i];\n", string(formatted))
+	})
+	t.Run("comments", func(t *testing.T) {
+		_, err := openscad.Format([]byte("// comment\ncube(1);"), ast.FormatStyle{})
+		require.Error(t, err, `code with comments should not be formatted`)
+	})
+	t.Run("parse error", func(t *testing.T) {
+		_, err := openscad.Format([]byte("module ("), ast.FormatStyle{})
+		require.Error(t, err, `invalid code should not be formatted`)
+	})
+}
@@ -0,0 +1,37 @@
+// Package atomicfile implements writing files atomically, so that readers
+// never see a partially written file.
+package atomicfile
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// WriteFile writes data to filename by writing to a temporary file in the
+// same directory and renaming it. The file is given the permissions perm,
+// even if it already exists.
+func WriteFile(filename string, data []byte, perm fs.FileMode) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), `.`+filepath.Base(filename)+`.*`)
+	if err != nil {
+		return fmt.Errorf(`failed to create temporary file for %s: %w`, filename, err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf(`failed to write %s: %w`, filename, err)
+	}
+	if err := f.Chmod(perm); err != nil {
+		f.Close()
+		return fmt.Errorf(`failed to set permissions of %s: %w`, filename, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf(`failed to write %s: %w`, filename, err)
+	}
+	if err := os.Rename(f.Name(), filename); err != nil {
+		return fmt.Errorf(`failed to write %s: %w`, filename, err)
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package atomicfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/internal/atomicfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "out.scad")
+	require.NoError(t, os.WriteFile(filename, []byte("old"), 0o600), `os.WriteFile should succeed`)
+
+	require.NoError(t, atomicfile.WriteFile(filename, []byte("new"), 0o644), `atomicfile.WriteFile should succeed`)
+	buf, err := os.ReadFile(filename)
+	require.NoError(t, err, `os.ReadFile should succeed`)
+	require.Equal(t, "new", string(buf))
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err, `os.Stat should succeed`)
+	require.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+
+	matches, err := filepath.Glob(filepath.Join(dir, ".out.scad.*"))
+	require.NoError(t, err, `filepath.Glob should succeed`)
+	require.Empty(t, matches, `temporary files should be removed`)
+
+	require.Error(t, atomicfile.WriteFile(filepath.Join(dir, "missing", "out.scad"), nil, 0o644), `atomicfile.WriteFile should fail when the directory does not exist`)
+}
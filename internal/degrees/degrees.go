@@ -0,0 +1,115 @@
+// Package degrees implements trigonometric functions that work in degrees,
+// with the same results as OpenSCAD's.
+package degrees
+
+import "math"
+
+// ToRadians converts x degrees to radians
+func ToRadians(x float64) float64 {
+	return x * math.Pi / 180
+}
+
+// Sin computes the sine of x degrees. Like OpenSCAD, it returns exact
+// results for multiples of 30 and 45 degrees, so that Sin(180) is 0 rather
+// than 1.22465e-16.
+func Sin(x float64) float64 {
+	if !(x >= 0 && x < 360) {
+		x = math.Mod(x, 360)
+		if x < 0 {
+			x += 360
+		}
+	}
+	oppose := x >= 180
+	if oppose {
+		x -= 180
+	}
+	if x > 90 {
+		x = 180 - x
+	}
+	switch {
+	case x < 45:
+		if x == 30 {
+			x = 0.5
+		} else {
+			x = math.Sin(ToRadians(x))
+		}
+	case x == 45:
+		x = math.Sqrt2 / 2
+	case x == 60:
+		x = math.Sqrt(3) / 2
+	default:
+		x = math.Cos(ToRadians(90 - x))
+	}
+	if oppose {
+		return -x
+	}
+	return x
+}
+
+// Cos computes the cosine of x degrees, with the same exact results as Sin
+func Cos(x float64) float64 {
+	if !(x >= 0 && x < 360) {
+		x = math.Mod(x, 360)
+		if x < 0 {
+			x += 360
+		}
+	}
+	oppose := x >= 180
+	if oppose {
+		x -= 180
+	}
+	if x > 90 {
+		x = 180 - x
+		oppose = !oppose
+	}
+	switch {
+	case x > 45:
+		if x == 60 {
+			x = 0.5
+		} else {
+			x = math.Sin(ToRadians(90 - x))
+		}
+	case x == 45:
+		x = math.Sqrt2 / 2
+	case x == 30:
+		x = math.Sqrt(3) / 2
+	default:
+		x = math.Cos(ToRadians(x))
+	}
+	if oppose {
+		return -x
+	}
+	return x
+}
+
+// Tan computes the tangent of x degrees, with the same exact results as
+// Sin. Tan(90) is infinite.
+func Tan(x float64) float64 {
+	if !(x >= 0 && x < 180) {
+		x = math.Mod(x, 180)
+		if x < 0 {
+			x += 180
+		}
+	}
+	oppose := x > 90
+	if oppose {
+		x = 180 - x
+	}
+	switch x {
+	case 0:
+	case 30:
+		x = 1 / math.Sqrt(3)
+	case 45:
+		x = 1
+	case 60:
+		x = math.Sqrt(3)
+	case 90:
+		x = math.Inf(1)
+	default:
+		x = math.Tan(ToRadians(x))
+	}
+	if oppose {
+		return -x
+	}
+	return x
+}
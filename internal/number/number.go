@@ -0,0 +1,19 @@
+// Package number implements formatting numbers for the file formats that
+// meshes and trees are written in.
+package number
+
+import "strconv"
+
+// Append appends f with as many digits as are needed to read it back
+// exactly. Negative zero is written as 0.
+func Append(buf []byte, f float64) []byte {
+	if f == 0 {
+		f = 0 // no -0
+	}
+	return strconv.AppendFloat(buf, f, 'g', -1, 64)
+}
+
+// Format formats f in the same way as Append
+func Format(f float64) string {
+	return string(Append(nil, f))
+}
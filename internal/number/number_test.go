@@ -0,0 +1,16 @@
+package number_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/internal/number"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormat(t *testing.T) {
+	require.Equal(t, `0`, number.Format(math.Copysign(0, -1)), `-0 should be written as 0`)
+	require.Equal(t, `0.30000000000000004`, number.Format(math.Nextafter(0.3, 1)), `numbers should be written exactly`)
+	require.Equal(t, `1e+21`, number.Format(1e21), `large numbers should use an exponent`)
+	require.Equal(t, `x -1.5`, string(number.Append([]byte(`x `), -1.5)), `numbers should be appended`)
+}
@@ -0,0 +1,31 @@
+package interp
+
+import (
+	"fmt"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/mesh"
+)
+
+// Bounds evaluates stmt as if it were the contents of a file, and returns
+// the bounding box of the resulting tree. Files that it includes and uses
+// are looked up in the global registry. See mesh.Bounds for how the box is
+// computed, and when it is only approximate.
+//
+// To compute the bounds of a file, or of a tree that has already been
+// evaluated, use mesh.Bounds with the result of Evaluate.
+func Bounds(stmt ast.Stmt) (mesh.Box, error) {
+	p, err := LoadStmt(nil, ``, stmt)
+	if err != nil {
+		return mesh.Box{}, err
+	}
+	root, err := p.Evaluate()
+	if err != nil {
+		return mesh.Box{}, err
+	}
+	b, err := mesh.Bounds(root)
+	if err != nil {
+		return mesh.Box{}, fmt.Errorf(`failed to compute bounds: %w`, err)
+	}
+	return b, nil
+}
@@ -0,0 +1,118 @@
+package interp_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/interp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBounds(t *testing.T) {
+	testcases := []struct {
+		Name        string
+		Source      string
+		Min         csg.Vec3
+		Max         csg.Vec3
+		Approximate bool
+	}{
+		{
+			Name:   `translated cube`,
+			Source: `translate([1, 2, 3]) cube([2, 4, 6]);`,
+			Min:    csg.Vec3{1, 2, 3},
+			Max:    csg.Vec3{3, 6, 9},
+		},
+		{
+			Name:   `rotated cube`,
+			Source: `rotate([0, 0, 90]) cube([2, 1, 1]);`,
+			Min:    csg.Vec3{-1, 0, 0},
+			Max:    csg.Vec3{0, 2, 1},
+		},
+		{
+			Name:   `tessellated sphere`,
+			Source: `sphere(r = 10, $fn = 6);`,
+			Min:    csg.Vec3{-10, -8.660254037844386, -8.660254037844386},
+			Max:    csg.Vec3{10, 8.660254037844386, 8.660254037844386},
+		},
+		{
+			Name:   `infinite $fn`,
+			Source: `sphere(r = 10, $fn = 1/0);`,
+			Min:    csg.Vec3{-10, -9.945218953682733, -9.945218953682733},
+			Max:    csg.Vec3{10, 9.945218953682733, 9.945218953682733},
+		},
+		{
+			Name:   `module`,
+			Source: `module post(h) { cylinder(h = h, r = 1, $fn = 4); } for (x = [0, 10]) translate([x, 0, 0]) post(x + 1);`,
+			Min:    csg.Vec3{-1, -1, 0},
+			Max:    csg.Vec3{11, 1, 11},
+		},
+		{
+			Name:   `background`,
+			Source: `%cube(100); cube(1);`,
+			Min:    csg.Vec3{0, 0, 0},
+			Max:    csg.Vec3{1, 1, 1},
+		},
+		{
+			Name:   `minkowski`,
+			Source: `minkowski() { cube([10, 10, 1]); cylinder(r = 2, h = 1, $fn = 4); }`,
+			Min:    csg.Vec3{-2, -2, 0},
+			Max:    csg.Vec3{12, 12, 2},
+		},
+		{
+			Name:   `resize`,
+			Source: `resize([20, 0, 0], auto = true) cube([10, 5, 2]);`,
+			Min:    csg.Vec3{0, 0, 0},
+			Max:    csg.Vec3{20, 10, 4},
+		},
+		{
+			Name:   `linear_extrude`,
+			Source: `linear_extrude(height = 5, scale = 2) square(2, center = true);`,
+			Min:    csg.Vec3{-2, -2, 0},
+			Max:    csg.Vec3{2, 2, 5},
+		},
+		{
+			Name:        `difference`,
+			Source:      `difference() { cube(10); cube(5); }`,
+			Min:         csg.Vec3{0, 0, 0},
+			Max:         csg.Vec3{10, 10, 10},
+			Approximate: true,
+		},
+		{
+			Name:        `intersection`,
+			Source:      `intersection() { cube(10); translate([5, 5, 5]) cube(10); }`,
+			Min:         csg.Vec3{5, 5, 5},
+			Max:         csg.Vec3{10, 10, 10},
+			Approximate: true,
+		},
+		{
+			Name:        `offset`,
+			Source:      `offset(r = 1) square(4);`,
+			Min:         csg.Vec3{-1, -1, 0},
+			Max:         csg.Vec3{5, 5, 0},
+			Approximate: true,
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			stmt, err := openscad.Parse([]byte(tc.Source))
+			require.NoError(t, err, `openscad.Parse should succeed`)
+			b, err := interp.Bounds(stmt)
+			require.NoError(t, err, `interp.Bounds should succeed`)
+			for i := 0; i < 3; i++ {
+				require.InDelta(t, tc.Min[i], b.Min[i], 1e-9, `min %d should match`, i)
+				require.InDelta(t, tc.Max[i], b.Max[i], 1e-9, `max %d should match`, i)
+			}
+			require.Equal(t, tc.Approximate, b.Approximate, `approximation should be reported`)
+		})
+	}
+
+	t.Run(`import`, func(t *testing.T) {
+		stmt, err := openscad.Parse([]byte(`import("part.stl");`))
+		require.NoError(t, err, `openscad.Parse should succeed`)
+		_, err = interp.Bounds(stmt)
+		require.Error(t, err, `bounds of imports should not be known`)
+	})
+}
@@ -0,0 +1,407 @@
+package interp
+
+import (
+	"fmt"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/eval"
+)
+
+// Evaluate loads file from registry and evaluates it to a CSG tree. If
+// registry is nil, the global registry is used. See (*Program).Evaluate
+// for details.
+func Evaluate(registry *ast.Registry, file string) (csg.Node, error) {
+	p, err := Load(registry, file)
+	if err != nil {
+		return nil, err
+	}
+	return p.Evaluate()
+}
+
+// Evaluate executes the top-level statements of the loaded file, and
+// returns the resulting CSG tree. The root of the tree is a *csg.Group
+// that holds the nodes of the top-level statements, unless a statement is
+// prefixed with the `!` modifier, in which case that statement becomes the
+// root instead.
+//
+// User-defined modules are instantiated as a *csg.Group of the nodes of
+// their bodies, and `for`, `if` and `let` statements as a *csg.Group of
+// the nodes of their children. Built-in modules are resolved to the
+// primitive, boolean and transformation nodes of the csg package, with
+// their arguments converted to concrete numbers. Statements prefixed with
+// `*` are dropped.
+func (p *Program) Evaluate() (csg.Node, error) {
+	x := &executor{
+		program:   p,
+		instances: make(map[*eval.Env]*instance),
+	}
+	nodes, err := x.instantiate(p.stmts, p.env)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to evaluate: %w`, err)
+	}
+	if x.root != nil {
+		return x.root, nil
+	}
+	return &csg.Group{Children: nodes}, nil
+}
+
+// executor holds the state of a single evaluation of a program
+type executor struct {
+	program *Program
+	// root is the node marked with the `!` modifier, if any
+	root csg.Node
+	// instances maps the frames of module instances to the children that
+	// were passed to them, so that children() can find them
+	instances map[*eval.Env]*instance
+}
+
+// instance is an instance of a user-defined module
+type instance struct {
+	children []ast.Stmt
+	// env is the Env of the statement that instantiated the module, which
+	// its children are executed in
+	env *eval.Env
+}
+
+// block executes stmts in a new scope that is a child of env
+func (x *executor) block(stmts []ast.Stmt, env *eval.Env) ([]csg.Node, error) {
+	scope := env.NewChild()
+	rest, err := x.program.declare(scope, stmts)
+	if err != nil {
+		return nil, err
+	}
+	return x.instantiate(rest, scope)
+}
+
+// instantiate executes stmts in env, which is expected to hold their
+// declarations already
+func (x *executor) instantiate(stmts []ast.Stmt, env *eval.Env) ([]csg.Node, error) {
+	var nodes []csg.Node
+	for _, stmt := range stmts {
+		list, err := x.stmt(stmt, env)
+		if err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, list...)
+	}
+	return nodes, nil
+}
+
+func (x *executor) stmt(stmt ast.Stmt, env *eval.Env) ([]csg.Node, error) {
+	switch stmt := stmt.(type) {
+	case ast.Stmts:
+		return x.block(stmt, env)
+	case *ast.BareBlock:
+		return x.block(stmt.Children(), env)
+	case *ast.UnaryOp:
+		return x.modifier(stmt, env)
+	case *ast.IfStmt:
+		return x.ifStmt(stmt, env)
+	case *ast.ForBlock:
+		var nodes []csg.Node
+		err := forEach(stmt.LoopVars(), env, func(scope *eval.Env) error {
+			list, err := x.block(stmt.Children(), scope)
+			if err != nil {
+				return err
+			}
+			nodes = append(nodes, list...)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf(`failed to execute for loop: %w`, err)
+		}
+		return group(nodes), nil
+	case *ast.LetBlock:
+		scope := env.NewChild()
+		for _, v := range stmt.Variables() {
+			value, err := eval.Eval(v.ValueExpr(), scope)
+			if err != nil {
+				return nil, fmt.Errorf(`failed to evaluate %s: %w`, v.Name(), err)
+			}
+			scope.Set(v.Name(), value)
+		}
+		nodes, err := x.block(stmt.Children(), scope)
+		if err != nil {
+			return nil, err
+		}
+		return group(nodes), nil
+	case *ast.Function, *ast.Module, *ast.Variable, *ast.Declare, *ast.Use, *ast.Include:
+		return nil, nil
+	}
+
+	if call, ok := ast.ToCall(stmt); ok {
+		return x.call(call, env)
+	}
+	return nil, nil
+}
+
+// modifier executes a statement prefixed with a modifier character
+func (x *executor) modifier(op *ast.UnaryOp, env *eval.Env) ([]csg.Node, error) {
+	stmt, ok := op.Expr().(ast.Stmt)
+	if !ok {
+		return nil, fmt.Errorf(`unexpected %T after modifier %q`, op.Expr(), op.Op())
+	}
+	if op.Op() == `*` {
+		return nil, nil
+	}
+
+	nodes, err := x.stmt(stmt, env)
+	if err != nil || len(nodes) == 0 {
+		return nil, err
+	}
+	switch op.Op() {
+	case `!`:
+		if x.root == nil {
+			x.root = single(nodes)
+		}
+		return nodes, nil
+	case `%`, `#`:
+		return []csg.Node{&csg.Modifier{Op: op.Op(), Child: single(nodes)}}, nil
+	}
+	return nil, fmt.Errorf(`unknown modifier %q`, op.Op())
+}
+
+func (x *executor) ifStmt(stmt *ast.IfStmt, env *eval.Env) ([]csg.Node, error) {
+	branch, err := selectBranch(stmt, env)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := x.block(branch, env)
+	if err != nil {
+		return nil, err
+	}
+	return group(nodes), nil
+}
+
+// selectBranch returns the statements of the first branch of stmt whose
+// condition is true
+func selectBranch(stmt *ast.IfStmt, env *eval.Env) ([]ast.Stmt, error) {
+	cond, err := eval.Eval(stmt.Condition(), env)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to evaluate if condition: %w`, err)
+	}
+	if eval.Truthy(cond) {
+		return stmt.Children(), nil
+	}
+	for _, elseif := range stmt.ElseIfBlocks() {
+		cond, err := eval.Eval(elseif.Condition(), env)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to evaluate else if condition: %w`, err)
+		}
+		if eval.Truthy(cond) {
+			return elseif.Children(), nil
+		}
+	}
+	return stmt.ElseChildren(), nil
+}
+
+// forEach calls fn with a child of env for each combination of the values
+// of loopVars, with the outermost variable changing slowest
+func forEach(loopVars []*ast.LoopVar, env *eval.Env, fn func(*eval.Env) error) error {
+	if len(loopVars) == 0 {
+		return fn(env)
+	}
+
+	lv := loopVars[0]
+	name := lv.Variable().Name()
+	values, err := eval.Eval(lv.Expr(), env)
+	if err != nil {
+		return fmt.Errorf(`failed to evaluate values of %s: %w`, name, err)
+	}
+	return eval.Iterate(values, func(v eval.Value) error {
+		scope := env.NewChild()
+		scope.Set(name, v)
+		return forEach(loopVars[1:], scope, fn)
+	})
+}
+
+// call instantiates a module
+func (x *executor) call(call *ast.Call, env *eval.Env) ([]csg.Node, error) {
+	name := call.Name()
+	switch name {
+	case `children`:
+		return x.children(call, env)
+	case `intersection_for`:
+		return x.intersectionFor(call, env)
+	}
+
+	args, err := eval.EvalArguments(call, env)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to instantiate %s: %w`, name, err)
+	}
+
+	if m, ok := env.Module(name); ok {
+		frame, err := m.Bind(env, args)
+		if err != nil {
+			return nil, err
+		}
+		frame.Set(`$children`, eval.Number(len(instantiations(call.Children()))))
+		x.instances[frame] = &instance{children: call.Children(), env: env}
+		nodes, err := x.block(m.Body, frame)
+		if err != nil {
+			// errors are not wrapped here, as they would be wrapped once
+			// for each level of recursion
+			return nil, err
+		}
+		return []csg.Node{&csg.Group{Children: nodes}}, nil
+	}
+
+	switch name {
+	case `echo`:
+		return x.block(call.Children(), env)
+	case `assert`:
+		in := newInstantiation([]string{`condition`, `message`}, args, env, nil)
+		if cond, ok := in.value(`condition`); !ok || !eval.Truthy(cond) {
+			if msg, ok := in.value(`message`); ok {
+				return nil, fmt.Errorf(`assertion failed: %s`, msg)
+			}
+			return nil, fmt.Errorf(`assertion failed`)
+		}
+		return x.block(call.Children(), env)
+	}
+
+	builtin, ok := builtinModules[name]
+	if !ok {
+		// OpenSCAD only warns about unknown modules
+		return nil, nil
+	}
+
+	// special variables that are passed as arguments are visible to the
+	// children of built-in modules
+	scope := env.NewChild()
+	for _, arg := range args {
+		if isSpecial(arg.Name) {
+			scope.Set(arg.Name, arg.Value)
+		}
+	}
+	children, err := x.block(call.Children(), scope)
+	if err != nil {
+		return nil, err
+	}
+	node, err := builtin.instantiate(newInstantiation(builtin.params, args, scope, children))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to instantiate %s: %w`, name, err)
+	}
+	if node == nil {
+		return nil, nil
+	}
+	return []csg.Node{node}, nil
+}
+
+// intersectionFor intersects the nodes of each iteration of a loop. The
+// loop variables are parsed as named arguments.
+func (x *executor) intersectionFor(call *ast.Call, env *eval.Env) ([]csg.Node, error) {
+	var loopVars []*ast.LoopVar
+	for _, arg := range call.Arguments() {
+		v, ok := arg.(*ast.Variable)
+		if !ok || !v.HasValue() {
+			return nil, fmt.Errorf(`failed to instantiate intersection_for: expected loop variable, got %T`, arg)
+		}
+		loopVars = append(loopVars, ast.NewLoopVar(ast.NewVariable(v.Name()), v.ValueExpr()))
+	}
+
+	var nodes []csg.Node
+	err := forEach(loopVars, env, func(scope *eval.Env) error {
+		list, err := x.block(call.Children(), scope)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, &csg.Group{Children: list})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf(`failed to instantiate intersection_for: %w`, err)
+	}
+	return []csg.Node{&csg.Intersection{Children: nodes}}, nil
+}
+
+// children instantiates the children of the innermost instance of a
+// user-defined module that env belongs to
+func (x *executor) children(call *ast.Call, env *eval.Env) ([]csg.Node, error) {
+	var inst *instance
+	for cur := env; cur != nil && inst == nil; cur = cur.Parent() {
+		inst = x.instances[cur]
+	}
+	if inst == nil {
+		return nil, nil
+	}
+
+	args, err := eval.EvalArguments(call, env)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to instantiate children: %w`, err)
+	}
+
+	// children are executed where the module was instantiated, but see
+	// the special variables of the module that instantiates them
+	frame, err := inst.env.NewFrame(env)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to instantiate children: %w`, err)
+	}
+	stmts, err := x.program.declare(frame, inst.children)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(args) == 0 {
+		nodes, err := x.instantiate(stmts, frame)
+		if err != nil {
+			return nil, err
+		}
+		return group(nodes), nil
+	}
+
+	if n, ok := args[0].Value.(eval.Number); ok {
+		i := int(n)
+		if i < 0 || i >= len(stmts) {
+			return nil, nil
+		}
+		return x.stmt(stmts[i], frame)
+	}
+
+	var nodes []csg.Node
+	err = eval.Iterate(args[0].Value, func(v eval.Value) error {
+		n, ok := v.(eval.Number)
+		if !ok || int(n) < 0 || int(n) >= len(stmts) {
+			return nil
+		}
+		list, err := x.stmt(stmts[int(n)], frame)
+		if err != nil {
+			return err
+		}
+		nodes = append(nodes, list...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return group(nodes), nil
+}
+
+// instantiations returns the statements of a block that instantiate
+// modules, as opposed to declaring something
+func instantiations(stmts []ast.Stmt) []ast.Stmt {
+	var ret []ast.Stmt
+	for _, stmt := range stmts {
+		switch stmt.(type) {
+		case *ast.Function, *ast.Module, *ast.Variable, *ast.Declare, *ast.Use, *ast.Include:
+		default:
+			ret = append(ret, stmt)
+		}
+	}
+	return ret
+}
+
+// group wraps nodes in a *csg.Group
+func group(nodes []csg.Node) []csg.Node {
+	return []csg.Node{&csg.Group{Children: nodes}}
+}
+
+// single returns the only element of nodes, or a *csg.Group of them if
+// there are several
+func single(nodes []csg.Node) csg.Node {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+	return &csg.Group{Children: nodes}
+}
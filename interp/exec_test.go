@@ -0,0 +1,208 @@
+package interp_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/eval"
+	"github.com/lestrrat-go/openscad/interp"
+	"github.com/stretchr/testify/require"
+)
+
+func evaluate(t *testing.T, src string) csg.Node {
+	t.Helper()
+	registry := newRegistry(t, map[string]string{`main.scad`: src})
+	node, err := interp.Evaluate(registry, `main.scad`)
+	require.NoError(t, err, `interp.Evaluate should succeed`)
+	return node
+}
+
+func defaultFragments(fn float64) csg.Fragments {
+	return csg.Fragments{Fn: fn, Fa: 12, Fs: 2}
+}
+
+func operationFragments(fn float64) *csg.Fragments {
+	f := defaultFragments(fn)
+	return &f
+}
+
+func TestEvaluate(t *testing.T) {
+	testcases := []struct {
+		Name     string
+		Source   string
+		Expected []csg.Node
+	}{
+		{
+			Name:   `primitives`,
+			Source: `cube(2, center=true); sphere(d=4, $fn=8); cylinder(h=5, r1=1, d2=4); square([1, 2]); circle(); polygon([[0, 0], [1, 0], [0, 1]]);`,
+			Expected: []csg.Node{
+				&csg.Cube{Size: csg.Vec3{2, 2, 2}, Center: true},
+				&csg.Sphere{R: 2, Fragments: defaultFragments(8)},
+				&csg.Cylinder{H: 5, R1: 1, R2: 2, Fragments: defaultFragments(0)},
+				&csg.Square{Size: csg.Vec2{1, 2}},
+				&csg.Circle{R: 1, Fragments: defaultFragments(0)},
+				&csg.Polygon{Points: []csg.Vec2{{0, 0}, {1, 0}, {0, 1}}, Convexity: 1},
+			},
+		},
+		{
+			Name:   `diameters take precedence over radii`,
+			Source: `sphere(r=1, d=6); circle(r=1, d=4); cylinder(r=1, d=4, h=1); cylinder(r1=1, d1=6, r2=1, d2=8, h=1);`,
+			Expected: []csg.Node{
+				&csg.Sphere{R: 3, Fragments: defaultFragments(0)},
+				&csg.Circle{R: 2, Fragments: defaultFragments(0)},
+				&csg.Cylinder{H: 1, R1: 2, R2: 2, Fragments: defaultFragments(0)},
+				&csg.Cylinder{H: 1, R1: 3, R2: 4, Fragments: defaultFragments(0)},
+			},
+		},
+		{
+			Name:   `transformations`,
+			Source: `translate([1, 2]) cube(1); rotate(90) cube(1); rotate(a=45, v=[1, 0, 0]) cube(1); scale(2) cube(1); mirror([0, 1, 0]) cube(1);`,
+			Expected: []csg.Node{
+				&csg.Transform{Matrix: csg.Translation(csg.Vec3{1, 2, 0}), Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+				&csg.Transform{Matrix: csg.Rotation(csg.Vec3{0, 0, 90}), Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+				&csg.Transform{Matrix: csg.AxisRotation(45, csg.Vec3{1, 0, 0}), Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+				&csg.Transform{Matrix: csg.Scaling(csg.Vec3{2, 2, 2}), Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+				&csg.Transform{Matrix: csg.Mirroring(csg.Vec3{0, 1, 0}), Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+			},
+		},
+		{
+			Name:   `boolean operations`,
+			Source: `difference() { cube(2); sphere(1); } hull() { circle(1); }`,
+			Expected: []csg.Node{
+				&csg.Difference{Children: []csg.Node{
+					&csg.Cube{Size: csg.Vec3{2, 2, 2}},
+					&csg.Sphere{R: 1, Fragments: defaultFragments(0)},
+				}},
+				&csg.Hull{Children: []csg.Node{&csg.Circle{R: 1, Fragments: defaultFragments(0)}}},
+			},
+		},
+		{
+			Name:   `colors`,
+			Source: `color("Red", 0.5) cube(1); color("#00ff00") cube(1); color([0, 0, 1]) cube(1); color("nosuchcolor") cube(1);`,
+			Expected: []csg.Node{
+				&csg.Color{RGBA: [4]float64{1, 0, 0, 0.5}, Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+				&csg.Color{RGBA: [4]float64{0, 1, 0, 1}, Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+				&csg.Color{RGBA: [4]float64{0, 0, 1, 1}, Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+				&csg.Color{RGBA: [4]float64{-1, -1, -1, -1}, Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+			},
+		},
+		{
+			Name:   `flow control`,
+			Source: `x = 3; if (x > 5) cube(1); else if (x > 2) sphere(1); else cylinder(); let (s = x * 2) cube(s); for (i = [1:2], j = [3]) cube([i, j, 1]);`,
+			Expected: []csg.Node{
+				&csg.Group{Children: []csg.Node{&csg.Sphere{R: 1, Fragments: defaultFragments(0)}}},
+				&csg.Group{Children: []csg.Node{&csg.Cube{Size: csg.Vec3{6, 6, 6}}}},
+				&csg.Group{Children: []csg.Node{
+					&csg.Cube{Size: csg.Vec3{1, 3, 1}},
+					&csg.Cube{Size: csg.Vec3{2, 3, 1}},
+				}},
+			},
+		},
+		{
+			Name:   `intersection_for`,
+			Source: `intersection_for(i = [1, 2]) cube(i);`,
+			Expected: []csg.Node{
+				&csg.Intersection{Children: []csg.Node{
+					&csg.Group{Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+					&csg.Group{Children: []csg.Node{&csg.Cube{Size: csg.Vec3{2, 2, 2}}}},
+				}},
+			},
+		},
+		{
+			Name:   `modifiers`,
+			Source: `%cube(1); *cube(2); #circle(1);`,
+			Expected: []csg.Node{
+				&csg.Modifier{Op: `%`, Child: &csg.Cube{Size: csg.Vec3{1, 1, 1}}},
+				&csg.Modifier{Op: `#`, Child: &csg.Circle{R: 1, Fragments: defaultFragments(0)}},
+			},
+		},
+		{
+			Name:   `modules`,
+			Source: `module box(size = 2) { cube(size); } box(); box(size = 3);`,
+			Expected: []csg.Node{
+				&csg.Group{Children: []csg.Node{&csg.Cube{Size: csg.Vec3{2, 2, 2}}}},
+				&csg.Group{Children: []csg.Node{&csg.Cube{Size: csg.Vec3{3, 3, 3}}}},
+			},
+		},
+		{
+			Name:   `children`,
+			Source: `module second() { children(1); } module count() { cube($children); } second() { cube(1); sphere(1); } count() { cube(1); cube(1); }`,
+			Expected: []csg.Node{
+				&csg.Group{Children: []csg.Node{&csg.Sphere{R: 1, Fragments: defaultFragments(0)}}},
+				&csg.Group{Children: []csg.Node{&csg.Cube{Size: csg.Vec3{2, 2, 2}}}},
+			},
+		},
+		{
+			Name:   `special variables`,
+			Source: `module ring(r) { for (i = [0:1]) translate([r * i, 0, 0]) children(); } ring(10, $fn = 6) circle(1); translate([0, 0, 0], $fn = 5) sphere(1);`,
+			Expected: []csg.Node{
+				&csg.Group{Children: []csg.Node{
+					&csg.Group{Children: []csg.Node{
+						&csg.Transform{Matrix: csg.Translation(csg.Vec3{0, 0, 0}), Children: []csg.Node{
+							&csg.Group{Children: []csg.Node{&csg.Circle{R: 1, Fragments: defaultFragments(6)}}},
+						}},
+						&csg.Transform{Matrix: csg.Translation(csg.Vec3{10, 0, 0}), Children: []csg.Node{
+							&csg.Group{Children: []csg.Node{&csg.Circle{R: 1, Fragments: defaultFragments(6)}}},
+						}},
+					}},
+				}},
+				&csg.Transform{Matrix: csg.Identity(), Children: []csg.Node{&csg.Sphere{R: 1, Fragments: defaultFragments(5)}}},
+			},
+		},
+		{
+			Name:   `operations`,
+			Source: `$fn = 64; offset(r = 1) square(1); text("A", $fn = 8); render(convexity = 2) cube(1);`,
+			Expected: []csg.Node{
+				&csg.Operation{Name: `offset`, Args: []eval.Argument{{Name: `r`, Value: eval.Number(1)}}, Fragments: operationFragments(64), Children: []csg.Node{&csg.Square{Size: csg.Vec2{1, 1}}}},
+				&csg.Operation{Name: `text`, Args: []eval.Argument{{Value: eval.String(`A`)}}, Fragments: operationFragments(8)},
+				&csg.Operation{Name: `render`, Args: []eval.Argument{{Name: `convexity`, Value: eval.Number(2)}}, Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}},
+			},
+		},
+		{
+			Name:   `recursive module`,
+			Source: `module tower(n) { cube(n); if (n > 1) translate([0, 0, n]) tower(n - 1); } tower(2);`,
+			Expected: []csg.Node{
+				&csg.Group{Children: []csg.Node{
+					&csg.Cube{Size: csg.Vec3{2, 2, 2}},
+					&csg.Group{Children: []csg.Node{
+						&csg.Transform{Matrix: csg.Translation(csg.Vec3{0, 0, 2}), Children: []csg.Node{
+							&csg.Group{Children: []csg.Node{
+								&csg.Cube{Size: csg.Vec3{1, 1, 1}},
+								&csg.Group{},
+							}},
+						}},
+					}},
+				}},
+			},
+		},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			node := evaluate(t, tc.Source)
+			require.Equal(t, &csg.Group{Children: tc.Expected}, node, `CSG tree should match`)
+		})
+	}
+}
+
+func TestEvaluateUse(t *testing.T) {
+	registry := newRegistry(t, map[string]string{
+		`lib.scad`:  `module peg(h = 3) { cylinder(h = h, r = 1); } peg();`,
+		`main.scad`: `use <lib.scad> peg(h = 5, $fn = 8);`,
+	})
+	node, err := interp.Evaluate(registry, `main.scad`)
+	require.NoError(t, err, `interp.Evaluate should succeed`)
+	require.Equal(t, &csg.Group{Children: []csg.Node{
+		&csg.Group{Children: []csg.Node{
+			&csg.Cylinder{H: 5, R1: 1, R2: 1, Fragments: defaultFragments(8)},
+		}},
+	}}, node, `only the module of the used file should be instantiated`)
+}
+
+func TestEvaluateAssert(t *testing.T) {
+	registry := newRegistry(t, map[string]string{`main.scad`: `x = 1; assert(x > 2, "x is too small"); cube(x);`})
+	_, err := interp.Evaluate(registry, `main.scad`)
+	require.Error(t, err, `interp.Evaluate should fail`)
+	require.Contains(t, err.Error(), `x is too small`, `error should contain the message of the assertion`)
+}
@@ -0,0 +1,274 @@
+// Package interp runs the functions defined in OpenSCAD libraries, so that
+// they can be called from Go code, and evaluates designs to CSG trees.
+//
+// Files are looked up in an ast.Registry, and are loaded the way OpenSCAD
+// loads them: `include` directives are expanded in place, `use` directives
+// make the functions of another file visible without its variables, and
+// top-level assignments are evaluated in order, with later assignments to
+// the same variable replacing earlier ones.
+//
+// Files are parsed with openscad.Parse, which does not support all of
+// OpenSCAD's syntax yet. Libraries that use any of the following fail to
+// load:
+//
+//   - C-style for loops in list comprehensions, such as
+//     `[for (i = 0; i < n; i = i + 1) i]`
+//   - ranges outside of for loops, such as `r = [0:2];` or `each [0:2]`
+//   - calls to anything but a name, such as `(function(x) x)(1)` or
+//     `make_adder(3)(1)`
+package interp
+
+import (
+	"fmt"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/eval"
+)
+
+// Program is a file that has been loaded, along with all of the files
+// that it includes and uses. Its functions can be called, and its modules
+// evaluated to a CSG tree.
+type Program struct {
+	registry *ast.Registry
+	root     *eval.Env
+	units    map[string]*eval.Env
+	env      *eval.Env
+	// stmts are the top-level statements that instantiate modules
+	stmts []ast.Stmt
+}
+
+// NamedArgument is an argument passed by name, such as `r=2`. Create one
+// using Named()
+type NamedArgument struct {
+	name  string
+	value interface{}
+}
+
+// Named creates an argument that is passed by name. Names starting with
+// `$` set special variables for the duration of the call.
+func Named(name string, value interface{}) NamedArgument {
+	return NamedArgument{name: name, value: value}
+}
+
+// Call loads file from registry and calls the function called name
+// with args. If registry is nil, the global registry is used.
+//
+// Arguments are converted using eval.ValueOf, unless they are created
+// using Named(). See (*Program).Call for details.
+func Call(registry *ast.Registry, file string, name string, args ...interface{}) (eval.Value, error) {
+	p, err := Load(registry, file)
+	if err != nil {
+		return nil, err
+	}
+	return p.Call(name, args...)
+}
+
+// Load loads file from registry. If registry is nil, the global registry
+// is used.
+func Load(registry *ast.Registry, file string) (*Program, error) {
+	var stmt ast.Stmt
+	var ok bool
+	if registry == nil {
+		stmt, ok = ast.Lookup(file)
+	} else {
+		stmt, ok = registry.Lookup(file)
+	}
+	if !ok {
+		return nil, fmt.Errorf(`failed to load %q: source file not found`, file)
+	}
+	return LoadStmt(registry, file, stmt)
+}
+
+// LoadStmt loads stmt as if it were the contents of a file called name.
+// Files that it includes and uses are looked up in registry, or the global
+// registry if registry is nil.
+func LoadStmt(registry *ast.Registry, name string, stmt ast.Stmt) (*Program, error) {
+	p := &Program{
+		registry: registry,
+		root:     eval.NewEnv(),
+		units:    make(map[string]*eval.Env),
+	}
+	env, stmts, err := p.loadStmt(name, stmt)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to load %q: %w`, name, err)
+	}
+	p.env = env
+	p.stmts = stmts
+	return p, nil
+}
+
+// Call calls the function called name, as if it were called from the
+// top level of the loaded file. Functions declared with `function` are
+// looked up first, followed by variables that hold function literals.
+//
+// Calling a function that does not exist is an error, unlike in OpenSCAD
+// where it results in undef.
+func (p *Program) Call(name string, args ...interface{}) (eval.Value, error) {
+	fn, ok := p.env.LookupFunction(name)
+	if !ok {
+		return nil, fmt.Errorf(`failed to call %s: unknown function`, name)
+	}
+
+	list := make([]eval.Argument, len(args))
+	for i, arg := range args {
+		if named, ok := arg.(NamedArgument); ok {
+			v, err := eval.ValueOf(named.value)
+			if err != nil {
+				return nil, fmt.Errorf(`failed to call %s: failed to convert argument %s: %w`, name, named.name, err)
+			}
+			list[i] = eval.Argument{Name: named.name, Value: v}
+			continue
+		}
+		v, err := eval.ValueOf(arg)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to call %s: failed to convert argument %d: %w`, name, i, err)
+		}
+		list[i] = eval.Argument{Value: v}
+	}
+
+	v, err := fn.Call(p.env, list)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to call %s: %w`, name, err)
+	}
+	return v, nil
+}
+
+// Variable returns the value of a top-level variable of the loaded file
+func (p *Program) Variable(name string) (eval.Value, bool) {
+	return p.env.Get(name)
+}
+
+// Env returns the Env that holds the variables and functions of the
+// loaded file, which can be used to evaluate expressions with eval.Eval
+func (p *Program) Env() *eval.Env {
+	return p.env
+}
+
+func (p *Program) lookup(name string) (ast.Stmt, bool) {
+	if p.registry == nil {
+		return ast.Lookup(name)
+	}
+	return p.registry.Lookup(name)
+}
+
+// load loads a file, unless it has already been loaded. Files that use
+// each other share the same Env, so that the cycle ends.
+func (p *Program) load(name string) (*eval.Env, error) {
+	if env, ok := p.units[name]; ok {
+		return env, nil
+	}
+
+	stmt, ok := p.lookup(name)
+	if !ok {
+		return nil, fmt.Errorf(`source file %q not found`, name)
+	}
+	env, _, err := p.loadStmt(name, stmt)
+	return env, err
+}
+
+// loadStmt loads stmt as the contents of the file called name. The
+// statements that instantiate modules are returned, with includes
+// expanded.
+func (p *Program) loadStmt(name string, stmt ast.Stmt) (*eval.Env, []ast.Stmt, error) {
+	env := p.root.NewChild()
+	p.units[name] = env
+
+	stmts, err := p.flattenIncludes(stmt, []string{name})
+	if err != nil {
+		return nil, nil, err
+	}
+	stmts, err = p.declare(env, stmts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return env, stmts, nil
+}
+
+// declare processes the declarations among stmts, which make up a scope
+// whose variables are held by env. Functions and modules are defined,
+// used files are loaded, and assignments are evaluated. The remaining
+// statements are returned.
+func (p *Program) declare(env *eval.Env, stmts []ast.Stmt) ([]ast.Stmt, error) {
+	// OpenSCAD evaluates each variable where it is first assigned, using
+	// the value of its last assignment
+	var order []string
+	var rest []ast.Stmt
+	assignments := make(map[string]interface{})
+	assign := func(v *ast.Variable) {
+		if !v.HasValue() {
+			return
+		}
+		if _, ok := assignments[v.Name()]; !ok {
+			order = append(order, v.Name())
+		}
+		assignments[v.Name()] = v.ValueExpr()
+	}
+
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *ast.Function:
+			env.SetFunction(stmt.Name(), eval.NewClosure(stmt.Name(), stmt.Params(), stmt.BodyExpr(), env))
+		case *ast.Module:
+			env.SetModule(eval.NewModule(stmt, env))
+		case *ast.Variable:
+			assign(stmt)
+		case *ast.Declare:
+			assign(stmt.Variable())
+		case *ast.Use:
+			used, err := p.load(stmt.Name())
+			if err != nil {
+				return nil, fmt.Errorf(`failed to use %q: %w`, stmt.Name(), err)
+			}
+			env.Use(used)
+		case *ast.Include:
+			// includes are only expanded at the top level of a file
+		default:
+			rest = append(rest, stmt)
+		}
+	}
+
+	for _, name := range order {
+		v, err := eval.Eval(assignments[name], env)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to evaluate %s: %w`, name, err)
+		}
+		env.Set(name, v)
+	}
+	return rest, nil
+}
+
+// flattenIncludes returns the top-level statements of stmt, with `include`
+// directives replaced by the statements of the included files. stack holds
+// the files being included, in order to detect cycles.
+func (p *Program) flattenIncludes(stmt ast.Stmt, stack []string) ([]ast.Stmt, error) {
+	list, ok := stmt.(ast.Stmts)
+	if !ok {
+		list = ast.Stmts{stmt}
+	}
+
+	var ret []ast.Stmt
+	for _, child := range list {
+		include, ok := child.(*ast.Include)
+		if !ok {
+			ret = append(ret, child)
+			continue
+		}
+
+		name := include.Name()
+		for _, s := range stack {
+			if s == name {
+				return nil, fmt.Errorf(`failed to include %q: include cycle detected`, name)
+			}
+		}
+		included, ok := p.lookup(name)
+		if !ok {
+			return nil, fmt.Errorf(`failed to include %q: source file not found`, name)
+		}
+		flattened, err := p.flattenIncludes(included, append(stack, name))
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, flattened...)
+	}
+	return ret, nil
+}
@@ -0,0 +1,198 @@
+package interp_test
+
+import (
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/eval"
+	"github.com/lestrrat-go/openscad/interp"
+	"github.com/stretchr/testify/require"
+)
+
+func newRegistry(t *testing.T, files map[string]string) *ast.Registry {
+	t.Helper()
+	registry := ast.NewRegistry()
+	for name, src := range files {
+		stmts, err := openscad.Parse([]byte(src))
+		require.NoError(t, err, `parsing %s should succeed`, name)
+		require.NoError(t, registry.Register(name, stmts), `registering %s should succeed`, name)
+	}
+	return registry
+}
+
+const library = `
+function fact(n) = n <= 1 ? 1 : n * fact(n - 1);
+function sum(n, acc = 0) = n == 0 ? acc : sum(n - 1, acc + n);
+function depth(n) = n == 0 ? 0 : 1 + depth(n - 1);
+function scale(v, factor = 2, offset = 0) = v * factor + offset;
+function hyp(a, b) = let(a2 = a * a, b2 = b * b) sqrt_(a2 + b2);
+function sqrt_(x) = x;
+function squares(n) = [for (i = [1:n]) if (i % 2 == 1) i * i];
+function pairs(n) = [for (i = [0:n - 1], j = [i:n - 1]) [i, j]];
+function square_pairs(n) = [for (i = [1:n]) let(sq = i * i) [i, sq]];
+function map(f, list) = [for (x = list) f(x)];
+function adder(n) = function(x) x + n;
+function compose(f, g) = function(x) f(g(x));
+function segments() = $fn;
+function segments_of(r) = segments();
+double = function(x) x * 2;
+add3 = adder(3);
+`
+
+func TestCall(t *testing.T) {
+	registry := newRegistry(t, map[string]string{`lib.scad`: library + `$fn = 12;`})
+
+	testcases := []struct {
+		Name     string
+		Function string
+		Args     []interface{}
+		Expected string
+	}{
+		{Name: `recursion`, Function: `fact`, Args: []interface{}{5}, Expected: `120`},
+		{Name: `tail recursion`, Function: `sum`, Args: []interface{}{100000}, Expected: `5.00005e+09`},
+		{Name: `default parameter`, Function: `scale`, Args: []interface{}{3}, Expected: `6`},
+		{Name: `vector argument`, Function: `scale`, Args: []interface{}{[]int{1, 2}, 3, []int{0, 1}}, Expected: `[3, 7]`},
+		{Name: `named argument`, Function: `scale`, Args: []interface{}{3, interp.Named(`offset`, 1)}, Expected: `7`},
+		{Name: `named arguments out of order`, Function: `scale`, Args: []interface{}{interp.Named(`offset`, 1), interp.Named(`v`, 2), interp.Named(`factor`, 10)}, Expected: `21`},
+		{Name: `unknown named argument`, Function: `scale`, Args: []interface{}{3, interp.Named(`bogus`, 1)}, Expected: `6`},
+		{Name: `extra positional argument`, Function: `scale`, Args: []interface{}{3, 2, 0, 100}, Expected: `6`},
+		{Name: `missing argument`, Function: `scale`, Expected: `undef`},
+		{Name: `let`, Function: `hyp`, Args: []interface{}{3, 4}, Expected: `25`},
+		{Name: `list comprehension`, Function: `squares`, Args: []interface{}{6}, Expected: `[1, 9, 25]`},
+		{Name: `nested list comprehension`, Function: `pairs`, Args: []interface{}{2}, Expected: `[[0, 0], [0, 1], [1, 1]]`},
+		{Name: `let in list comprehension`, Function: `square_pairs`, Args: []interface{}{2}, Expected: `[[1, 1], [2, 4]]`},
+		{Name: `function literal variable`, Function: `double`, Args: []interface{}{4}, Expected: `8`},
+		{Name: `closure`, Function: `add3`, Args: []interface{}{4}, Expected: `7`},
+		{Name: `special variable`, Function: `segments_of`, Args: []interface{}{1}, Expected: `12`},
+		{Name: `special variable argument`, Function: `segments_of`, Args: []interface{}{1, interp.Named(`$fn`, 64)}, Expected: `64`},
+	}
+
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			v, err := interp.Call(registry, `lib.scad`, tc.Function, tc.Args...)
+			require.NoError(t, err, `interp.Call should succeed`)
+			require.Equal(t, tc.Expected, v.String())
+		})
+	}
+}
+
+func TestFunctionValues(t *testing.T) {
+	registry := newRegistry(t, map[string]string{
+		`main.scad`: library + `
+inc = compose(add3, double);
+doubled = map(double, [1, 2, 3]);
+`,
+	})
+	p, err := interp.Load(registry, `main.scad`)
+	require.NoError(t, err, `interp.Load should succeed`)
+
+	v, ok := p.Variable(`doubled`)
+	require.True(t, ok, `doubled should be defined`)
+	require.Equal(t, `[2, 4, 6]`, v.String())
+
+	v, err = p.Call(`inc`, 5)
+	require.NoError(t, err, `p.Call should succeed`)
+	require.Equal(t, eval.Number(13), v)
+
+	v, ok = p.Variable(`double`)
+	require.True(t, ok, `double should be defined`)
+	require.Equal(t, eval.TypeFunction, v.Type())
+
+	// a function passed as a value can be called by the callee
+	v, err = p.Call(`map`, v, []int{5})
+	require.NoError(t, err, `p.Call should succeed`)
+	require.Equal(t, `[10]`, v.String())
+}
+
+func TestFunctionScopes(t *testing.T) {
+	registry := newRegistry(t, map[string]string{
+		`main.scad`: `
+function f(x) = 100;
+function apply(f, x) = f(x);
+applied = apply(function(v) v * 2, 3);
+shadowed = let(f = function(y) y + 1) f(1);
+declared = f(1);
+`,
+	})
+	p, err := interp.Load(registry, `main.scad`)
+	require.NoError(t, err, `interp.Load should succeed`)
+
+	// a parameter holding a function shadows a function declared outside
+	v, ok := p.Variable(`applied`)
+	require.True(t, ok, `applied should be defined`)
+	require.Equal(t, eval.Number(6), v)
+
+	// so does a variable bound by let()
+	v, ok = p.Variable(`shadowed`)
+	require.True(t, ok, `shadowed should be defined`)
+	require.Equal(t, eval.Number(2), v)
+
+	v, ok = p.Variable(`declared`)
+	require.True(t, ok, `declared should be defined`)
+	require.Equal(t, eval.Number(100), v)
+}
+
+func TestRecursionLimit(t *testing.T) {
+	registry := newRegistry(t, map[string]string{`lib.scad`: library})
+
+	v, err := interp.Call(registry, `lib.scad`, `depth`, 1000)
+	require.NoError(t, err, `moderately deep recursion should succeed`)
+	require.Equal(t, eval.Number(1000), v)
+
+	_, err = interp.Call(registry, `lib.scad`, `depth`, eval.MaxCallDepth+1)
+	require.Error(t, err, `recursion beyond the limit should fail`)
+}
+
+func TestLoad(t *testing.T) {
+	registry := newRegistry(t, map[string]string{
+		`main.scad`: `
+include <consts.scad>
+use <util.scad>
+x = 1;
+y = x + 1;
+x = 10;
+function area(r) = PI_ * sq(r);
+function hidden() = secret;
+`,
+		`consts.scad`: `PI_ = 3;`,
+		`util.scad`: `
+use <main.scad>
+secret = 42;
+function sq(x) = x * x;
+function reveal() = secret;
+`,
+		`cycle.scad`: `include <cycle.scad>`,
+	})
+
+	p, err := interp.Load(registry, `main.scad`)
+	require.NoError(t, err, `interp.Load should succeed`)
+
+	// the last assignment wins, but is evaluated where the variable was
+	// first assigned
+	v, ok := p.Variable(`y`)
+	require.True(t, ok, `y should be defined`)
+	require.Equal(t, eval.Number(11), v)
+
+	v, err = p.Call(`area`, 2)
+	require.NoError(t, err, `calling a function that uses included variables and used functions should succeed`)
+	require.Equal(t, eval.Number(12), v)
+
+	// variables of used files are visible to their own functions only
+	v, err = p.Call(`reveal`)
+	require.NoError(t, err, `calling a used function should succeed`)
+	require.Equal(t, eval.Number(42), v)
+	v, err = p.Call(`hidden`)
+	require.NoError(t, err, `calling hidden() should succeed`)
+	require.Equal(t, eval.Undef, v)
+
+	_, err = p.Call(`nonexistent`)
+	require.Error(t, err, `calling an unknown function should fail`)
+
+	_, err = interp.Load(registry, `cycle.scad`)
+	require.Error(t, err, `include cycles should fail`)
+
+	_, err = interp.Load(registry, `missing.scad`)
+	require.Error(t, err, `loading a missing file should fail`)
+}
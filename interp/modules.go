@@ -0,0 +1,492 @@
+package interp
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/eval"
+)
+
+// builtinModule is one of OpenSCAD's built-in modules. params are the
+// names that positional arguments are bound to, in order.
+type builtinModule struct {
+	params      []string
+	instantiate func(*instantiation) (csg.Node, error)
+}
+
+var builtinModules map[string]builtinModule
+
+func init() {
+	builtinModules = map[string]builtinModule{
+		`cube`:           {params: []string{`size`, `center`}, instantiate: instantiateCube},
+		`sphere`:         {params: []string{`r`}, instantiate: instantiateSphere},
+		`cylinder`:       {params: []string{`h`, `r1`, `r2`, `center`}, instantiate: instantiateCylinder},
+		`polyhedron`:     {params: []string{`points`, `faces`, `convexity`}, instantiate: instantiatePolyhedron},
+		`square`:         {params: []string{`size`, `center`}, instantiate: instantiateSquare},
+		`circle`:         {params: []string{`r`}, instantiate: instantiateCircle},
+		`polygon`:        {params: []string{`points`, `paths`, `convexity`}, instantiate: instantiatePolygon},
+		`translate`:      {params: []string{`v`}, instantiate: instantiateTranslate},
+		`rotate`:         {params: []string{`a`, `v`}, instantiate: instantiateRotate},
+		`scale`:          {params: []string{`v`}, instantiate: instantiateScale},
+		`mirror`:         {params: []string{`v`}, instantiate: instantiateMirror},
+		`multmatrix`:     {params: []string{`m`}, instantiate: instantiateMultmatrix},
+		`color`:          {params: []string{`c`, `alpha`}, instantiate: instantiateColor},
+		`union`:          {instantiate: instantiateUnion},
+		`difference`:     {instantiate: instantiateDifference},
+		`intersection`:   {instantiate: instantiateIntersection},
+		`hull`:           {instantiate: instantiateHull},
+		`group`:          {instantiate: instantiateGroup},
+		`minkowski`:      {params: []string{`convexity`}, instantiate: instantiateMinkowski},
+		`linear_extrude`: {params: []string{`height`, `center`, `convexity`, `twist`, `slices`, `scale`}, instantiate: instantiateLinearExtrude},
+		`rotate_extrude`: {params: []string{`angle`, `convexity`}, instantiate: instantiateRotateExtrude},
+		`render`:         {instantiate: instantiateOperation(`render`, false)},
+		`offset`:         {instantiate: instantiateOperation(`offset`, true)},
+		`projection`:     {instantiate: instantiateOperation(`projection`, false)},
+		`resize`:         {instantiate: instantiateOperation(`resize`, false)},
+		`text`:           {instantiate: instantiateOperation(`text`, true)},
+		`import`:         {instantiate: instantiateOperation(`import`, true)},
+		`surface`:        {instantiate: instantiateOperation(`surface`, false)},
+	}
+}
+
+// instantiation holds the arguments and children of an instance of a
+// built-in module
+type instantiation struct {
+	// args maps the names of parameters to the arguments bound to them
+	args     map[string]eval.Value
+	list     []eval.Argument
+	scope    *eval.Env
+	children []csg.Node
+}
+
+// newInstantiation binds args to params: positional arguments are bound
+// in order, and named arguments by name
+func newInstantiation(params []string, args []eval.Argument, scope *eval.Env, children []csg.Node) *instantiation {
+	bound := make(map[string]eval.Value)
+	var positional int
+	for _, arg := range args {
+		if arg.Name != `` {
+			bound[arg.Name] = arg.Value
+			continue
+		}
+		if positional < len(params) {
+			if _, ok := bound[params[positional]]; !ok {
+				bound[params[positional]] = arg.Value
+			}
+		}
+		positional++
+	}
+	return &instantiation{args: bound, list: args, scope: scope, children: children}
+}
+
+// value returns the argument bound to name, unless it is undef
+func (in *instantiation) value(name string) (eval.Value, bool) {
+	v, ok := in.args[name]
+	if !ok || v.Type() == eval.TypeUndef {
+		return nil, false
+	}
+	return v, true
+}
+
+func (in *instantiation) number(name string, def float64) float64 {
+	if n, ok := in.args[name].(eval.Number); ok {
+		return float64(n)
+	}
+	return def
+}
+
+func (in *instantiation) integer(name string, def int) int {
+	if n, ok := in.args[name].(eval.Number); ok && !math.IsNaN(float64(n)) && !math.IsInf(float64(n), 0) {
+		return int(n)
+	}
+	return def
+}
+
+func (in *instantiation) bool(name string, def bool) bool {
+	v, ok := in.value(name)
+	if !ok {
+		return def
+	}
+	return eval.Truthy(v)
+}
+
+// fragments returns the values of $fn, $fa and $fs. As in OpenSCAD, $fa
+// and $fs can not be smaller than 0.01.
+func (in *instantiation) fragments() csg.Fragments {
+	special := func(name string) float64 {
+		v, _ := in.scope.Get(name)
+		if n, ok := v.(eval.Number); ok {
+			return float64(n)
+		}
+		return 0
+	}
+	return csg.Fragments{
+		Fn: special(`$fn`),
+		Fa: math.Max(special(`$fa`), 0.01),
+		Fs: math.Max(special(`$fs`), 0.01),
+	}
+}
+
+// numbers converts a vector of numbers to a slice
+func numbers(v eval.Value) ([]float64, bool) {
+	vec, ok := v.(eval.Vector)
+	if !ok {
+		return nil, false
+	}
+	ret := make([]float64, len(vec))
+	for i, elem := range vec {
+		n, ok := elem.(eval.Number)
+		if !ok {
+			return nil, false
+		}
+		ret[i] = float64(n)
+	}
+	return ret, true
+}
+
+// toVec3 converts a vector of up to three numbers to a Vec3, with missing
+// components taken from def. If scalar is true, a single number is used
+// for all three components.
+func toVec3(v eval.Value, def csg.Vec3, scalar bool) (csg.Vec3, bool) {
+	if n, ok := v.(eval.Number); ok && scalar {
+		return csg.Vec3{float64(n), float64(n), float64(n)}, true
+	}
+	list, ok := numbers(v)
+	if !ok || len(list) == 0 {
+		return def, false
+	}
+	ret := def
+	for i := 0; i < len(list) && i < 3; i++ {
+		ret[i] = list[i]
+	}
+	return ret, true
+}
+
+// radius returns the radius given by the parameters called r and d. Like
+// OpenSCAD, a diameter takes precedence over a radius.
+func (in *instantiation) radius(r, d string, def float64) float64 {
+	if n, ok := in.args[d].(eval.Number); ok {
+		return float64(n) / 2
+	}
+	if n, ok := in.args[r].(eval.Number); ok {
+		return float64(n)
+	}
+	return def
+}
+
+func instantiateCube(in *instantiation) (csg.Node, error) {
+	size := csg.Vec3{1, 1, 1}
+	if v, ok := in.value(`size`); ok {
+		if n, ok := v.(eval.Number); ok {
+			size = csg.Vec3{float64(n), float64(n), float64(n)}
+		} else if list, ok := numbers(v); ok && len(list) == 3 {
+			size = csg.Vec3{list[0], list[1], list[2]}
+		}
+	}
+	return &csg.Cube{Size: size, Center: in.bool(`center`, false)}, nil
+}
+
+func instantiateSphere(in *instantiation) (csg.Node, error) {
+	return &csg.Sphere{R: in.radius(`r`, `d`, 1), Fragments: in.fragments()}, nil
+}
+
+func instantiateCylinder(in *instantiation) (csg.Node, error) {
+	r := in.radius(`r`, `d`, 1)
+	return &csg.Cylinder{
+		H:         in.number(`h`, 1),
+		R1:        in.radius(`r1`, `d1`, r),
+		R2:        in.radius(`r2`, `d2`, r),
+		Center:    in.bool(`center`, false),
+		Fragments: in.fragments(),
+	}, nil
+}
+
+// indices converts a vector of vectors of numbers to lists of indices
+func indices(v eval.Value) ([][]int, error) {
+	vec, ok := v.(eval.Vector)
+	if !ok {
+		return nil, fmt.Errorf(`expected a vector of index lists, got %s`, v.Type())
+	}
+	ret := make([][]int, len(vec))
+	for i, elem := range vec {
+		list, ok := numbers(elem)
+		if !ok {
+			return nil, fmt.Errorf(`index list %d is not a vector of numbers`, i)
+		}
+		ret[i] = make([]int, len(list))
+		for j, n := range list {
+			ret[i][j] = int(n)
+		}
+	}
+	return ret, nil
+}
+
+func instantiatePolyhedron(in *instantiation) (csg.Node, error) {
+	node := &csg.Polyhedron{Convexity: in.integer(`convexity`, 1)}
+	if v, ok := in.value(`points`); ok {
+		vec, ok := v.(eval.Vector)
+		if !ok {
+			return nil, fmt.Errorf(`points must be a vector, got %s`, v.Type())
+		}
+		for i, elem := range vec {
+			list, ok := numbers(elem)
+			if !ok || len(list) != 3 {
+				return nil, fmt.Errorf(`point %d is not a 3D point`, i)
+			}
+			node.Points = append(node.Points, csg.Vec3{list[0], list[1], list[2]})
+		}
+	}
+
+	faces, ok := in.value(`faces`)
+	if !ok {
+		// triangles is the deprecated name of faces
+		faces, ok = in.value(`triangles`)
+	}
+	if ok {
+		list, err := indices(faces)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid faces: %w`, err)
+		}
+		node.Faces = list
+	}
+	return node, nil
+}
+
+func instantiateSquare(in *instantiation) (csg.Node, error) {
+	size := csg.Vec2{1, 1}
+	if v, ok := in.value(`size`); ok {
+		if n, ok := v.(eval.Number); ok {
+			size = csg.Vec2{float64(n), float64(n)}
+		} else if list, ok := numbers(v); ok && len(list) == 2 {
+			size = csg.Vec2{list[0], list[1]}
+		}
+	}
+	return &csg.Square{Size: size, Center: in.bool(`center`, false)}, nil
+}
+
+func instantiateCircle(in *instantiation) (csg.Node, error) {
+	return &csg.Circle{R: in.radius(`r`, `d`, 1), Fragments: in.fragments()}, nil
+}
+
+func instantiatePolygon(in *instantiation) (csg.Node, error) {
+	node := &csg.Polygon{Convexity: in.integer(`convexity`, 1)}
+	if v, ok := in.value(`points`); ok {
+		vec, ok := v.(eval.Vector)
+		if !ok {
+			return nil, fmt.Errorf(`points must be a vector, got %s`, v.Type())
+		}
+		for i, elem := range vec {
+			list, ok := numbers(elem)
+			if !ok || len(list) < 2 {
+				return nil, fmt.Errorf(`point %d is not a 2D point`, i)
+			}
+			node.Points = append(node.Points, csg.Vec2{list[0], list[1]})
+		}
+	}
+	if v, ok := in.value(`paths`); ok {
+		list, err := indices(v)
+		if err != nil {
+			return nil, fmt.Errorf(`invalid paths: %w`, err)
+		}
+		node.Paths = list
+	}
+	return node, nil
+}
+
+func (in *instantiation) transform(m csg.Matrix) *csg.Transform {
+	return &csg.Transform{Matrix: m, Children: in.children}
+}
+
+func instantiateTranslate(in *instantiation) (csg.Node, error) {
+	v, _ := toVec3(in.args[`v`], csg.Vec3{}, false)
+	return in.transform(csg.Translation(v)), nil
+}
+
+func instantiateRotate(in *instantiation) (csg.Node, error) {
+	a, ok := in.value(`a`)
+	if !ok {
+		return in.transform(csg.Identity()), nil
+	}
+	if _, ok := a.(eval.Vector); ok {
+		angles, _ := toVec3(a, csg.Vec3{}, false)
+		return in.transform(csg.Rotation(angles)), nil
+	}
+
+	angle := in.number(`a`, 0)
+	if axis, ok := toVec3(in.args[`v`], csg.Vec3{}, false); ok {
+		return in.transform(csg.AxisRotation(angle, axis)), nil
+	}
+	return in.transform(csg.Rotation(csg.Vec3{0, 0, angle})), nil
+}
+
+func instantiateScale(in *instantiation) (csg.Node, error) {
+	v, _ := toVec3(in.args[`v`], csg.Vec3{1, 1, 1}, true)
+	return in.transform(csg.Scaling(v)), nil
+}
+
+func instantiateMirror(in *instantiation) (csg.Node, error) {
+	v, _ := toVec3(in.args[`v`], csg.Vec3{1, 0, 0}, false)
+	return in.transform(csg.Mirroring(v)), nil
+}
+
+func instantiateMultmatrix(in *instantiation) (csg.Node, error) {
+	m := csg.Identity()
+	if rows, ok := in.args[`m`].(eval.Vector); ok {
+		for i := 0; i < len(rows) && i < 4; i++ {
+			row, ok := numbers(rows[i])
+			if !ok {
+				continue
+			}
+			for j := 0; j < len(row) && j < 4; j++ {
+				m[i][j] = row[j]
+			}
+		}
+	}
+	return in.transform(m), nil
+}
+
+func instantiateColor(in *instantiation) (csg.Node, error) {
+	node := &csg.Color{RGBA: [4]float64{-1, -1, -1, -1}, Children: in.children}
+	switch c := in.args[`c`].(type) {
+	case eval.Vector:
+		list, ok := numbers(c)
+		if !ok || len(list) < 3 {
+			return node, nil
+		}
+		node.RGBA = [4]float64{list[0], list[1], list[2], 1}
+		if len(list) > 3 {
+			node.RGBA[3] = list[3]
+		}
+	case eval.String:
+		rgba, ok := parseColor(string(c))
+		if !ok {
+			return node, nil
+		}
+		node.RGBA = rgba
+	default:
+		return node, nil
+	}
+	if alpha, ok := in.args[`alpha`].(eval.Number); ok {
+		node.RGBA[3] = float64(alpha)
+	}
+	return node, nil
+}
+
+// parseColor parses a color name, or a hex color such as "#ff0000"
+func parseColor(s string) ([4]float64, bool) {
+	s = strings.ToLower(s)
+	if rgb, ok := webColors[s]; ok {
+		return [4]float64{float64(rgb[0]) / 255, float64(rgb[1]) / 255, float64(rgb[2]) / 255, 1}, true
+	}
+	if s == `transparent` {
+		return [4]float64{0, 0, 0, 0}, true
+	}
+	if !strings.HasPrefix(s, `#`) {
+		return [4]float64{}, false
+	}
+
+	digits := s[1:]
+	var width int
+	switch len(digits) {
+	case 3, 4:
+		width = 1
+	case 6, 8:
+		width = 2
+	default:
+		return [4]float64{}, false
+	}
+	ret := [4]float64{0, 0, 0, 1}
+	max := float64(int(1)<<(4*width) - 1)
+	for i := 0; i*width < len(digits); i++ {
+		var n int
+		for _, r := range digits[i*width : (i+1)*width] {
+			d := strings.IndexRune(`0123456789abcdef`, r)
+			if d < 0 {
+				return [4]float64{}, false
+			}
+			n = n*16 + d
+		}
+		ret[i] = float64(n) / max
+	}
+	return ret, true
+}
+
+func instantiateUnion(in *instantiation) (csg.Node, error) {
+	return &csg.Union{Children: in.children}, nil
+}
+
+func instantiateDifference(in *instantiation) (csg.Node, error) {
+	return &csg.Difference{Children: in.children}, nil
+}
+
+func instantiateIntersection(in *instantiation) (csg.Node, error) {
+	return &csg.Intersection{Children: in.children}, nil
+}
+
+func instantiateHull(in *instantiation) (csg.Node, error) {
+	return &csg.Hull{Children: in.children}, nil
+}
+
+func instantiateGroup(in *instantiation) (csg.Node, error) {
+	return &csg.Group{Children: in.children}, nil
+}
+
+func instantiateMinkowski(in *instantiation) (csg.Node, error) {
+	return &csg.Minkowski{Convexity: in.integer(`convexity`, 0), Children: in.children}, nil
+}
+
+func instantiateLinearExtrude(in *instantiation) (csg.Node, error) {
+	scale := csg.Vec2{1, 1}
+	if v, ok := in.value(`scale`); ok {
+		if n, ok := v.(eval.Number); ok {
+			scale = csg.Vec2{float64(n), float64(n)}
+		} else if list, ok := numbers(v); ok && len(list) == 2 {
+			scale = csg.Vec2{list[0], list[1]}
+		}
+	}
+	return &csg.LinearExtrude{
+		Height:    in.number(`height`, 100),
+		Center:    in.bool(`center`, false),
+		Convexity: in.integer(`convexity`, 1),
+		Twist:     in.number(`twist`, 0),
+		Slices:    in.integer(`slices`, 0),
+		Scale:     scale,
+		Fragments: in.fragments(),
+		Children:  in.children,
+	}, nil
+}
+
+func instantiateRotateExtrude(in *instantiation) (csg.Node, error) {
+	return &csg.RotateExtrude{
+		Angle:     in.number(`angle`, 360),
+		Convexity: in.integer(`convexity`, 2),
+		Fragments: in.fragments(),
+		Children:  in.children,
+	}, nil
+}
+
+// instantiateOperation creates a module that keeps its arguments as they
+// were passed. If the module tessellates curves, the values of $fn, $fa
+// and $fs in effect are recorded in place of the ones that were passed,
+// so that the result does not depend on the scope it is emitted in.
+func instantiateOperation(name string, tessellates bool) func(*instantiation) (csg.Node, error) {
+	return func(in *instantiation) (csg.Node, error) {
+		if !tessellates {
+			return &csg.Operation{Name: name, Args: in.list, Children: in.children}, nil
+		}
+		var args []eval.Argument
+		for _, arg := range in.list {
+			if !isSpecial(arg.Name) {
+				args = append(args, arg)
+			}
+		}
+		fragments := in.fragments()
+		return &csg.Operation{Name: name, Args: args, Fragments: &fragments, Children: in.children}, nil
+	}
+}
+
+func isSpecial(name string) bool {
+	return strings.HasPrefix(name, `$`)
+}
@@ -40,6 +40,8 @@ const (
 	percent      = '%'
 	ampersand    = '&'
 	exclamation  = '!'
+	pipe         = '|'
+	caret        = '^'
 )
 const (
 	EOF = iota
@@ -73,11 +75,19 @@ const (
 	And
 	BitwiseAnd
 	Exclamation
+	NotEqual // !=
+	Or       // ||
+	Caret    // ^
+	Illegal  // a character that can not start any token
 )
 
 type Token struct {
 	Type  int
 	Value string
+	// Line and Column denote the location of the beginning of the token
+	// in the source code. Both start at 1.
+	Line   int
+	Column int
 }
 
 type lexer struct {
@@ -85,6 +95,30 @@ type lexer struct {
 	ch      chan *Token
 	pos     int
 	peekPos []int
+
+	// line and column of src[0], and of the beginning of the current token
+	line, column           int
+	startLine, startColumn int
+}
+
+// consume discards the first n bytes of src, keeping track of the
+// current line and column
+func (l *lexer) consume(n int) {
+	for _, r := range string(l.src[:n]) {
+		if r == '\n' {
+			l.line++
+			l.column = 1
+		} else {
+			l.column++
+		}
+	}
+	l.src = l.src[n:]
+}
+
+// markStart records the current location as the beginning of the next token
+func (l *lexer) markStart() {
+	l.startLine = l.line
+	l.startColumn = l.column
 }
 
 func (l *lexer) skipWhiteSpaces() {
@@ -99,7 +133,7 @@ func (l *lexer) skipWhiteSpaces() {
 }
 
 func (l *lexer) emit(typ int, value string) {
-	l.ch <- &Token{Type: typ, Value: value}
+	l.ch <- &Token{Type: typ, Value: value, Line: l.startLine, Column: l.startColumn}
 }
 
 func (l *lexer) peek() rune {
@@ -118,7 +152,7 @@ func (l *lexer) unread() {
 
 func (l *lexer) advance() {
 	l.peekPos = nil
-	l.src = l.src[l.pos:]
+	l.consume(l.pos)
 	l.pos = 0
 }
 
@@ -129,14 +163,20 @@ func (l *lexer) emitBuffer(typ int) {
 
 func Lex(ch chan *Token, src []byte) {
 	l := lexer{
-		src: src,
-		ch:  ch,
+		src:    src,
+		ch:     ch,
+		line:   1,
+		column: 1,
 	}
 	defer close(ch)
 
 	var inInclude bool
 	for len(l.src) > 0 {
 		l.skipWhiteSpaces()
+		if len(l.src) == 0 {
+			break
+		}
+		l.markStart()
 
 		found := true
 
@@ -183,7 +223,21 @@ func Lex(ch chan *Token, src []byte) {
 		case minus:
 			l.emitBuffer(Minus)
 		case exclamation:
-			l.emitBuffer(Exclamation)
+			if l.peek() == equal {
+				l.emitBuffer(NotEqual)
+			} else {
+				l.unread()
+				l.emitBuffer(Exclamation)
+			}
+		case pipe:
+			if l.peek() == pipe {
+				l.emitBuffer(Or)
+			} else {
+				l.unread()
+				l.emitBuffer(Illegal)
+			}
+		case caret:
+			l.emitBuffer(Caret)
 		case slash:
 			next := l.peek()
 			switch next {
@@ -220,7 +274,8 @@ func Lex(ch chan *Token, src []byte) {
 			if inInclude {
 				l.unread()
 				if err := l.captureLiteral(lessThan, greaterThan); err != nil {
-					return
+					l.emit(Illegal, string(l.src))
+					l.consume(len(l.src))
 				}
 				inInclude = false
 				continue
@@ -319,17 +374,21 @@ func Lex(ch chan *Token, src []byte) {
 		l.unread()
 
 		// it must be an identifier, then
-		_ = l.captureIdent() // TODO: error handling
+		if err := l.captureIdent(); err != nil {
+			l.peek()
+			l.emitBuffer(Illegal)
+		}
 	}
+	l.markStart()
 	l.emit(EOF, "")
 }
 
 func (l *lexer) expect(typ int, v []byte) error {
 	l.skipWhiteSpaces()
-	if !bytes.Equal(v, l.src[l.pos:len(v)]) {
+	if !bytes.HasPrefix(l.src[l.pos:], v) {
 		return fmt.Errorf("expected %q, but was not foud", v)
 	}
-	l.src = l.src[l.pos+len(v):]
+	l.consume(l.pos + len(v))
 	l.pos = 0
 	l.peekPos = nil
 	l.emit(typ, string(v))
@@ -368,6 +427,28 @@ func (l *lexer) captureNumeric() error {
 	} else {
 		l.unread()
 	}
+
+	// an exponent, such as the one in 1e-6, must have at least one digit
+	if r := l.peek(); r == 'e' || r == 'E' {
+		n := 1
+		sign := l.peek()
+		if sign == '+' || sign == '-' {
+			n++
+		} else {
+			l.unread()
+		}
+		if unicode.IsNumber(l.peek()) {
+			l.unread()
+			sb.WriteString(string(l.src[l.pos-n : l.pos]))
+			l.captureNumericLike(&sb)
+		} else {
+			for i := 0; i < n+1; i++ {
+				l.unread()
+			}
+		}
+	} else {
+		l.unread()
+	}
 	l.emit(Numeric, sb.String())
 	l.advance()
 	return nil
@@ -396,9 +477,10 @@ func (l *lexer) captureIdent() error {
 		sb.WriteRune(r)
 	}
 
-	if sb.Len() > 0 {
-		l.emit(Ident, sb.String())
+	if sb.Len() == 0 {
+		return fmt.Errorf("expected identifier, but was not found")
 	}
+	l.emit(Ident, sb.String())
 	return nil
 }
 
@@ -407,9 +489,15 @@ func (l *lexer) captureLiteral(begin, end rune) error {
 	var sb strings.Builder
 
 	if l.peek() != begin {
+		l.unread()
 		return fmt.Errorf("expected %q, but was not found", begin)
 	}
-	for len(l.src) > 0 {
+	for {
+		if l.pos >= len(l.src) {
+			l.pos = 0
+			l.peekPos = nil
+			return fmt.Errorf("expected %q, but was not found", end)
+		}
 		r := l.peek()
 		if r == end {
 			break
@@ -420,3 +508,25 @@ func (l *lexer) captureLiteral(begin, end rune) error {
 	l.emit(Literal, sb.String())
 	return nil
 }
+
+// HasComments returns true if src contains any comments. Comments are
+// discarded by the lexer, so code that is parsed and then emitted loses
+// them.
+func HasComments(src []byte) bool {
+	for i := 0; i < len(src); i++ {
+		switch src[i] {
+		case dquote:
+			// skip string literals, which may contain slashes
+			end := bytes.IndexByte(src[i+1:], dquote)
+			if end < 0 {
+				return false
+			}
+			i += end + 1
+		case slash:
+			if i+1 < len(src) && (src[i+1] == slash || src[i+1] == asterisk) {
+				return true
+			}
+		}
+	}
+	return false
+}
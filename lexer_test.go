@@ -20,3 +20,22 @@ module foo(a, b, c=0) {
 		t.Logf("%#v", tok)
 	}
 }
+
+func TestHasComments(t *testing.T) {
+	testcases := []struct {
+		Src      string
+		Expected bool
+	}{
+		{Src: "cube(1);", Expected: false},
+		{Src: "cube(1); // a cube", Expected: true},
+		{Src: "/* a cube */\ncube(1);", Expected: true},
+		{Src: "x = 4 / 2;", Expected: false},
+		{Src: `echo("http://example.com");`, Expected: false},
+		{Src: `echo("/*"); // done`, Expected: true},
+	}
+	for _, tc := range testcases {
+		if got := openscad.HasComments([]byte(tc.Src)); got != tc.Expected {
+			t.Errorf("HasComments(%q) = %t, expected %t", tc.Src, got, tc.Expected)
+		}
+	}
+}
@@ -0,0 +1,396 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+
+	"github.com/lestrrat-go/openscad/csg"
+)
+
+// Union returns the regions that are covered by any of the given sets of
+// regions. It can also be used with a single set, to merge regions that
+// overlap each other.
+func Union(sets ...[]Region) []Region {
+	return combine(sets, func(w []int) bool {
+		for _, n := range w {
+			if n != 0 {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Difference returns the regions that are covered by the first set of
+// regions, but not by any of the others
+func Difference(sets ...[]Region) []Region {
+	return combine(sets, func(w []int) bool {
+		if len(w) == 0 || w[0] == 0 {
+			return false
+		}
+		for _, n := range w[1:] {
+			if n != 0 {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// Intersection returns the regions that are covered by all of the given
+// sets of regions
+func Intersection(sets ...[]Region) []Region {
+	return combine(sets, func(w []int) bool {
+		for _, n := range w {
+			if n == 0 {
+				return false
+			}
+		}
+		return len(w) > 0
+	})
+}
+
+// segment is a directed edge of a region in one of the sets being
+// combined. The inside of the region is on its left.
+type segment struct {
+	a, b csg.Vec2
+	set  int
+}
+
+// epsilon is the relative tolerance used to decide whether points are on
+// segments, and segments are parallel
+const epsilon = 1e-10
+
+// combine computes a boolean operation on sets of regions. All edges are
+// split where they cross or touch other edges, and each of the resulting
+// pieces is kept if inside reports a different result for the winding
+// numbers of the sets on either side of it. The pieces that are kept are
+// then linked into outlines and holes again.
+func combine(sets [][]Region, inside func([]int) bool) []Region {
+	var segments []segment
+	for k, set := range sets {
+		for _, r := range set {
+			rings := append([][]csg.Vec2{r.Outline}, r.Holes...)
+			for _, ring := range rings {
+				if len(ring) < 3 {
+					continue
+				}
+				for i, a := range ring {
+					if b := ring[(i+1)%len(ring)]; a != b {
+						segments = append(segments, segment{a: a, b: b, set: k})
+					}
+				}
+			}
+		}
+	}
+
+	// points that are computed more than once, such as where three edges
+	// cross, may differ in their last bits, so they are snapped together
+	snap := newSnapper(segments)
+	for _, s := range segments {
+		snap.point(s.a)
+		snap.point(s.b)
+	}
+
+	type piece struct {
+		a, b csg.Vec2
+	}
+	seen := make(map[piece]bool)
+	var edges []piece
+	for i, splits := range splitSegments(segments) {
+		s := segments[i]
+		points := append([]csg.Vec2{s.a}, splits...)
+		points = append(points, s.b)
+		for j := 0; j+1 < len(points); j++ {
+			p := piece{snap.point(points[j]), snap.point(points[j+1])}
+			if p.a == p.b {
+				continue
+			}
+			// pieces are classified once, regardless of their direction
+			key := p
+			if key.b[0] < key.a[0] || key.b[0] == key.a[0] && key.b[1] < key.a[1] {
+				key.a, key.b = key.b, key.a
+			}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			d := csg.Vec2{key.b[0] - key.a[0], key.b[1] - key.a[1]}
+			delta := 1e-6
+			mid := csg.Vec2{(key.a[0] + key.b[0]) / 2, (key.a[1] + key.b[1]) / 2}
+			left := csg.Vec2{mid[0] - d[1]*delta, mid[1] + d[0]*delta}
+			right := csg.Vec2{mid[0] + d[1]*delta, mid[1] - d[0]*delta}
+			inLeft := inside(winding(segments, len(sets), left))
+			inRight := inside(winding(segments, len(sets), right))
+			switch {
+			case inLeft && !inRight:
+				edges = append(edges, key)
+			case inRight && !inLeft:
+				edges = append(edges, piece{key.b, key.a})
+			}
+		}
+	}
+
+	// link the edges into rings, turning as far left as possible where
+	// rings touch each other, so that they are kept apart
+	outgoing := make(map[csg.Vec2][]int)
+	for i, e := range edges {
+		outgoing[e.a] = append(outgoing[e.a], i)
+	}
+	used := make([]bool, len(edges))
+	var outlines, holes [][]csg.Vec2
+	for start := range edges {
+		if used[start] {
+			continue
+		}
+		used[start] = true
+		ring := []csg.Vec2{edges[start].a}
+		cur := start
+		for {
+			e := edges[cur]
+			back := math.Atan2(e.a[1]-e.b[1], e.a[0]-e.b[0])
+			next := -1
+			bestAngle := math.Inf(1)
+			for _, i := range outgoing[e.b] {
+				if used[i] && i != start {
+					continue
+				}
+				angle := back - math.Atan2(edges[i].b[1]-edges[i].a[1], edges[i].b[0]-edges[i].a[0])
+				for angle <= 0 {
+					angle += 2 * math.Pi
+				}
+				if angle < bestAngle {
+					bestAngle = angle
+					next = i
+				}
+			}
+			if next < 0 || next == start {
+				if next == start {
+					ring = simplify(ring)
+					switch area := signedArea(ring); {
+					case area > 0:
+						outlines = append(outlines, ring)
+					case area < 0:
+						holes = append(holes, ring)
+					}
+				}
+				break
+			}
+			used[next] = true
+			ring = append(ring, edges[next].a)
+			cur = next
+		}
+	}
+	return assignHoles(outlines, holes)
+}
+
+// splitSegments returns the points at which each segment crosses or
+// touches other segments, in order from its start to its end
+func splitSegments(segments []segment) [][]csg.Vec2 {
+	splits := make([][]csg.Vec2, len(segments))
+	add := func(i int, p csg.Vec2) {
+		if p != segments[i].a && p != segments[i].b {
+			splits[i] = append(splits[i], p)
+		}
+	}
+	for i := range segments {
+		s1 := segments[i]
+		for j := i + 1; j < len(segments); j++ {
+			s2 := segments[j]
+			if math.Max(s1.a[0], s1.b[0]) < math.Min(s2.a[0], s2.b[0]) ||
+				math.Max(s2.a[0], s2.b[0]) < math.Min(s1.a[0], s1.b[0]) ||
+				math.Max(s1.a[1], s1.b[1]) < math.Min(s2.a[1], s2.b[1]) ||
+				math.Max(s2.a[1], s2.b[1]) < math.Min(s1.a[1], s1.b[1]) {
+				continue
+			}
+
+			d1 := csg.Vec2{s1.b[0] - s1.a[0], s1.b[1] - s1.a[1]}
+			d2 := csg.Vec2{s2.b[0] - s2.a[0], s2.b[1] - s2.a[1]}
+			l1, l2 := math.Hypot(d1[0], d1[1]), math.Hypot(d2[0], d2[1])
+			denom := d1[0]*d2[1] - d1[1]*d2[0]
+			if math.Abs(denom) <= epsilon*l1*l2 {
+				// parallel segments only touch if they overlap, in which
+				// case each is split at the ends of the other
+				for _, p := range []csg.Vec2{s2.a, s2.b} {
+					if onSegment(s1, p) {
+						add(i, p)
+					}
+				}
+				for _, p := range []csg.Vec2{s1.a, s1.b} {
+					if onSegment(s2, p) {
+						add(j, p)
+					}
+				}
+				continue
+			}
+
+			e := csg.Vec2{s2.a[0] - s1.a[0], s2.a[1] - s1.a[1]}
+			t := (e[0]*d2[1] - e[1]*d2[0]) / denom
+			u := (e[0]*d1[1] - e[1]*d1[0]) / denom
+			tt, tu := epsilon*(1+l2/l1), epsilon*(1+l1/l2)
+			if t < -tt || t > 1+tt || u < -tu || u > 1+tu {
+				continue
+			}
+			// points close to the end of either segment are snapped to
+			// it, so that both segments are split at the same point
+			var p csg.Vec2
+			switch {
+			case t <= tt:
+				p = s1.a
+			case t >= 1-tt:
+				p = s1.b
+			case u <= tu:
+				p = s2.a
+			case u >= 1-tu:
+				p = s2.b
+			default:
+				p = csg.Vec2{s1.a[0] + t*d1[0], s1.a[1] + t*d1[1]}
+			}
+			add(i, p)
+			add(j, p)
+		}
+	}
+
+	for i, points := range splits {
+		s := segments[i]
+		d := csg.Vec2{s.b[0] - s.a[0], s.b[1] - s.a[1]}
+		param := func(p csg.Vec2) float64 {
+			return (p[0]-s.a[0])*d[0] + (p[1]-s.a[1])*d[1]
+		}
+		sort.Slice(points, func(a, b int) bool {
+			return param(points[a]) < param(points[b])
+		})
+	}
+	return splits
+}
+
+// snapper replaces points with the first point that was seen within a
+// tolerance of them
+type snapper struct {
+	tolerance float64
+	cells     map[[2]int64][]csg.Vec2
+}
+
+// newSnapper creates a snapper with a tolerance that is relative to the
+// extent of the segments
+func newSnapper(segments []segment) *snapper {
+	var extent float64
+	for _, s := range segments {
+		for _, p := range []csg.Vec2{s.a, s.b} {
+			extent = math.Max(extent, math.Max(math.Abs(p[0]), math.Abs(p[1])))
+		}
+	}
+	return &snapper{
+		tolerance: 1e-9 * math.Max(extent, 1),
+		cells:     make(map[[2]int64][]csg.Vec2),
+	}
+}
+
+func (s *snapper) point(p csg.Vec2) csg.Vec2 {
+	cell := [2]int64{int64(math.Floor(p[0] / s.tolerance)), int64(math.Floor(p[1] / s.tolerance))}
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for _, q := range s.cells[[2]int64{cell[0] + dx, cell[1] + dy}] {
+				if math.Hypot(q[0]-p[0], q[1]-p[1]) <= s.tolerance {
+					return q
+				}
+			}
+		}
+	}
+	s.cells[cell] = append(s.cells[cell], p)
+	return p
+}
+
+// onSegment reports whether p lies on s, between its ends
+func onSegment(s segment, p csg.Vec2) bool {
+	d := csg.Vec2{s.b[0] - s.a[0], s.b[1] - s.a[1]}
+	e := csg.Vec2{p[0] - s.a[0], p[1] - s.a[1]}
+	l2 := d[0]*d[0] + d[1]*d[1]
+	if math.Abs(d[0]*e[1]-d[1]*e[0]) > epsilon*l2 {
+		return false
+	}
+	t := (e[0]*d[0] + e[1]*d[1]) / l2
+	return t > 0 && t < 1
+}
+
+// winding returns the winding number of p for each of the sets that the
+// segments belong to
+func winding(segments []segment, sets int, p csg.Vec2) []int {
+	w := make([]int, sets)
+	for _, s := range segments {
+		if (s.a[1] > p[1]) == (s.b[1] > p[1]) {
+			continue
+		}
+		x := s.a[0] + (p[1]-s.a[1])*(s.b[0]-s.a[0])/(s.b[1]-s.a[1])
+		if x <= p[0] {
+			continue
+		}
+		if s.b[1] > s.a[1] {
+			w[s.set]++
+		} else {
+			w[s.set]--
+		}
+	}
+	return w
+}
+
+// simplify removes the points of a ring that are in the middle of a
+// straight line
+func simplify(ring []csg.Vec2) []csg.Vec2 {
+	for i := 0; i < len(ring) && len(ring) >= 3; {
+		n := len(ring)
+		a, b, c := ring[(i+n-1)%n], ring[i], ring[(i+1)%n]
+		d1 := csg.Vec2{b[0] - a[0], b[1] - a[1]}
+		d2 := csg.Vec2{c[0] - b[0], c[1] - b[1]}
+		if math.Abs(cross2(a, b, c)) <= epsilon*math.Hypot(d1[0], d1[1])*math.Hypot(d2[0], d2[1]) &&
+			d1[0]*d2[0]+d1[1]*d2[1] > 0 {
+			ring = append(ring[:i], ring[i+1:]...)
+			// the previous point may be in a straight line now
+			if i > 0 {
+				i--
+			}
+			continue
+		}
+		i++
+	}
+	return ring
+}
+
+// assignHoles puts each hole in the smallest outline that contains it
+func assignHoles(outlines, holes [][]csg.Vec2) []Region {
+	regions := make([]Region, len(outlines))
+	for i, outline := range outlines {
+		regions[i].Outline = outline
+	}
+	for _, hole := range holes {
+		// a point just outside of the hole, next to its first edge
+		a, b := hole[0], hole[1]
+		p := csg.Vec2{(a[0]+b[0])/2 - (b[1]-a[1])*1e-6, (a[1]+b[1])/2 + (b[0]-a[0])*1e-6}
+		best := -1
+		for i, outline := range outlines {
+			if !containsPoint(outline, p) {
+				continue
+			}
+			if best < 0 || signedArea(outline) < signedArea(outlines[best]) {
+				best = i
+			}
+		}
+		if best >= 0 {
+			regions[best].Holes = append(regions[best].Holes, hole)
+		}
+	}
+	return regions
+}
+
+// containsPoint reports whether p is inside of the polygon
+func containsPoint(points []csg.Vec2, p csg.Vec2) bool {
+	var inside bool
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < a[0]+(p[1]-a[1])*(b[0]-a[0])/(b[1]-a[1]) {
+			inside = !inside
+		}
+	}
+	return inside
+}
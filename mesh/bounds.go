@@ -0,0 +1,542 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/eval"
+	"github.com/lestrrat-go/openscad/internal/degrees"
+)
+
+// Box is an axis-aligned bounding box. Boxes that do not contain
+// anything have Min greater than Max.
+type Box struct {
+	Min csg.Vec3
+	Max csg.Vec3
+	// Approximate is set if the box is only known to contain the shape,
+	// and may be larger than it
+	Approximate bool
+}
+
+func emptyBox() Box {
+	return Box{
+		Min: csg.Vec3{math.Inf(1), math.Inf(1), math.Inf(1)},
+		Max: csg.Vec3{math.Inf(-1), math.Inf(-1), math.Inf(-1)},
+	}
+}
+
+// Empty reports whether the box does not contain anything
+func (b Box) Empty() bool {
+	return !(b.Min[0] <= b.Max[0] && b.Min[1] <= b.Max[1] && b.Min[2] <= b.Max[2])
+}
+
+// Size returns the extent of the box along each axis
+func (b Box) Size() csg.Vec3 {
+	if b.Empty() {
+		return csg.Vec3{}
+	}
+	return sub(b.Max, b.Min)
+}
+
+// Center returns the center of the box
+func (b Box) Center() csg.Vec3 {
+	if b.Empty() {
+		return csg.Vec3{}
+	}
+	return csg.Vec3{(b.Min[0] + b.Max[0]) / 2, (b.Min[1] + b.Max[1]) / 2, (b.Min[2] + b.Max[2]) / 2}
+}
+
+func (b *Box) add(p csg.Vec3) {
+	for i := range p {
+		b.Min[i] = math.Min(b.Min[i], p[i])
+		b.Max[i] = math.Max(b.Max[i], p[i])
+	}
+}
+
+// corners returns the 8 corners of a box that is not empty
+func (b Box) corners() []csg.Vec3 {
+	ret := make([]csg.Vec3, 0, 8)
+	for i := 0; i < 8; i++ {
+		var p csg.Vec3
+		for axis := range p {
+			if i&(1<<axis) == 0 {
+				p[axis] = b.Min[axis]
+			} else {
+				p[axis] = b.Max[axis]
+			}
+		}
+		ret = append(ret, p)
+	}
+	return ret
+}
+
+// Bounds returns the bounding box of m
+func (m *Mesh) Bounds() Box {
+	b := emptyBox()
+	for _, t := range m.Triangles {
+		for _, idx := range t {
+			b.add(m.Vertices[idx])
+		}
+	}
+	return b
+}
+
+// maxMinkowskiPoints limits the number of points that are summed to
+// compute the bounds of a minkowski sum exactly
+const maxMinkowskiPoints = 1 << 20
+
+// Bounds computes the bounding box of the tree rooted at n, without
+// tessellating it into a mesh. The box is computed from the points that
+// make up each primitive, with the transformations above it applied: the
+// corners of cubes and squares, the points of polygons and polyhedra,
+// and the points that circles, spheres and cylinders are tessellated
+// into. The result is the same as the bounds of the mesh that OpenSCAD
+// would create.
+//
+// Unions, groups, hulls and minkowski sums are exact, as are projections
+// and resizes; minkowski sums of very large shapes are approximated by
+// adding up the boxes of the shapes. The box is marked as approximate
+// when what is cut away is not accounted for, as with difference(),
+// intersection() and projection(cut = true), and when only an enclosing
+// shape is known, as with offset(), rotate_extrude(), and
+// linear_extrude() with a twist but without a number of slices. Imports,
+// text and surfaces are reported as errors. Parts marked with the `%`
+// modifier are left out.
+func Bounds(n csg.Node) (Box, error) {
+	b := emptyBox()
+	var bd bounder
+	if err := bd.points(n, csg.Identity(), b.add); err != nil {
+		return Box{}, err
+	}
+	b.Approximate = bd.approximate
+	return b, nil
+}
+
+type bounder struct {
+	approximate bool
+}
+
+// localPoints returns the points of the children, in their own coordinates
+func (bd *bounder) localPoints(list []csg.Node) ([]csg.Vec3, error) {
+	var ret []csg.Vec3
+	add := func(p csg.Vec3) {
+		ret = append(ret, p)
+	}
+	for _, child := range list {
+		if err := bd.points(child, csg.Identity(), add); err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+// box returns the bounding box of the children under mat
+func (bd *bounder) box(list []csg.Node, mat csg.Matrix) (Box, error) {
+	b := emptyBox()
+	for _, child := range list {
+		if err := bd.points(child, mat, b.add); err != nil {
+			return Box{}, err
+		}
+	}
+	return b, nil
+}
+
+// points calls add with points whose convex hull contains the shape of n
+// transformed by mat, and whose bounding box is that of the shape unless
+// the shape is approximated
+func (bd *bounder) points(n csg.Node, mat csg.Matrix, add func(csg.Vec3)) error {
+	emit := func(p csg.Vec3) {
+		add(mat.Apply(p))
+	}
+	children := func(list []csg.Node, mat csg.Matrix) error {
+		for _, child := range list {
+			if err := bd.points(child, mat, add); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch n := n.(type) {
+	case nil:
+		return nil
+	case *csg.Group:
+		return children(n.Children, mat)
+	case *csg.Union:
+		return children(n.Children, mat)
+	case *csg.Color:
+		return children(n.Children, mat)
+	case *csg.Hull:
+		return children(n.Children, mat)
+	case *csg.Transform:
+		return children(n.Children, mat.Mul(n.Matrix))
+	case *csg.Modifier:
+		if n.Op == `%` {
+			return nil
+		}
+		return bd.points(n.Child, mat, add)
+	case *csg.Difference:
+		// the difference is within the first child
+		if len(n.Children) == 0 {
+			return nil
+		}
+		if len(n.Children) > 1 {
+			bd.approximate = true
+		}
+		return bd.points(n.Children[0], mat, add)
+	case *csg.Intersection:
+		if len(n.Children) <= 1 {
+			return children(n.Children, mat)
+		}
+		// the intersection is within the intersection of the boxes of
+		// the children
+		bd.approximate = true
+		b, err := bd.box(n.Children[:1], mat)
+		if err != nil {
+			return err
+		}
+		for _, child := range n.Children[1:] {
+			o, err := bd.box([]csg.Node{child}, mat)
+			if err != nil {
+				return err
+			}
+			for i := range b.Min {
+				b.Min[i] = math.Max(b.Min[i], o.Min[i])
+				b.Max[i] = math.Min(b.Max[i], o.Max[i])
+			}
+		}
+		if !b.Empty() {
+			for _, p := range b.corners() {
+				add(p)
+			}
+		}
+		return nil
+	case *csg.Minkowski:
+		return bd.minkowski(n.Children, emit)
+	case *csg.LinearExtrude:
+		return bd.linearExtrude(n, emit)
+	case *csg.RotateExtrude:
+		return bd.rotateExtrude(n, emit)
+	case *csg.Operation:
+		return bd.operation(n, mat, add, emit)
+	case *csg.Cube:
+		if c := Cube(n); len(c.Vertices) > 0 {
+			for _, p := range c.Vertices {
+				emit(p)
+			}
+		}
+	case *csg.Sphere:
+		if n.R > 0 && !math.IsInf(n.R, 0) {
+			for _, p := range sphereVertices(n.R, n.Count(n.R)) {
+				emit(p)
+			}
+		}
+	case *csg.Cylinder:
+		if !(n.H > 0) || n.R1 < 0 || n.R2 < 0 || (n.R1 <= 0 && n.R2 <= 0) {
+			return nil
+		}
+		z1, z2 := 0.0, n.H
+		if n.Center {
+			z1, z2 = -n.H/2, n.H/2
+		}
+		fragments := n.Count(math.Max(n.R1, n.R2))
+		for _, ring := range []struct{ r, z float64 }{{n.R1, z1}, {n.R2, z2}} {
+			if ring.r <= 0 {
+				emit(csg.Vec3{0, 0, ring.z})
+				continue
+			}
+			for _, p := range circle(ring.r, fragments) {
+				emit(csg.Vec3{p[0], p[1], ring.z})
+			}
+		}
+	case *csg.Polyhedron:
+		for i, face := range n.Faces {
+			for _, idx := range face {
+				if idx < 0 || idx >= len(n.Points) {
+					return fmt.Errorf(`face %d refers to point %d, which does not exist`, i, idx)
+				}
+				emit(n.Points[idx])
+			}
+		}
+	case *csg.Square:
+		for _, p := range Square(n).Outline {
+			emit(csg.Vec3{p[0], p[1], 0})
+		}
+	case *csg.Circle:
+		for _, p := range Circle(n).Outline {
+			emit(csg.Vec3{p[0], p[1], 0})
+		}
+	case *csg.Polygon:
+		r, err := Polygon(n)
+		if err != nil {
+			return err
+		}
+		for _, p := range r.Outline {
+			emit(csg.Vec3{p[0], p[1], 0})
+		}
+	default:
+		return fmt.Errorf(`unknown node %T`, n)
+	}
+	return nil
+}
+
+// minkowski emits the sums of the points of the children, which is exact
+// since the hull of a minkowski sum is the sum of the hulls. If there are
+// too many of them, the sum of the boxes of the children is used instead.
+func (bd *bounder) minkowski(list []csg.Node, emit func(csg.Vec3)) error {
+	var sets [][]csg.Vec3
+	count := 1
+	for _, child := range list {
+		points, err := bd.localPoints([]csg.Node{child})
+		if err != nil {
+			return err
+		}
+		if len(points) == 0 {
+			continue
+		}
+		sets = append(sets, points)
+		count *= len(points)
+		if count > maxMinkowskiPoints {
+			count = -1
+		}
+	}
+	if len(sets) == 0 {
+		return nil
+	}
+
+	if count < 0 {
+		// the box is only exact for the axes of the local coordinates
+		bd.approximate = true
+		sum := Box{}
+		for _, points := range sets {
+			b := emptyBox()
+			for _, p := range points {
+				b.add(p)
+			}
+			for i := range sum.Min {
+				sum.Min[i] += b.Min[i]
+				sum.Max[i] += b.Max[i]
+			}
+		}
+		for _, p := range sum.corners() {
+			emit(p)
+		}
+		return nil
+	}
+
+	sums := sets[0]
+	for _, points := range sets[1:] {
+		next := make([]csg.Vec3, 0, len(sums)*len(points))
+		for _, a := range sums {
+			for _, b := range points {
+				next = append(next, csg.Vec3{a[0] + b[0], a[1] + b[1], a[2] + b[2]})
+			}
+		}
+		sums = next
+	}
+	for _, p := range sums {
+		emit(p)
+	}
+	return nil
+}
+
+func (bd *bounder) linearExtrude(n *csg.LinearExtrude, emit func(csg.Vec3)) error {
+	if !(n.Height > 0) {
+		return nil
+	}
+	points, err := bd.localPoints(n.Children)
+	if err != nil {
+		return err
+	}
+	z := 0.0
+	if n.Center {
+		z = -n.Height / 2
+	}
+
+	if n.Twist == 0 || n.Slices > 0 {
+		// OpenSCAD rotates each slice by -twist, and then scales it
+		slices := n.Slices
+		if n.Twist == 0 {
+			slices = 1
+		}
+		for i := 0; i <= slices; i++ {
+			t := float64(i) / float64(slices)
+			sx, sy := 1+(n.Scale[0]-1)*t, 1+(n.Scale[1]-1)*t
+			cos, sin := degrees.Cos(-n.Twist*t), degrees.Sin(-n.Twist*t)
+			for _, p := range points {
+				x, y := p[0]*cos-p[1]*sin, p[0]*sin+p[1]*cos
+				emit(csg.Vec3{x * sx, y * sy, z + n.Height*t})
+			}
+		}
+		return nil
+	}
+
+	// without the number of slices, the shape is only known to be within
+	// the cone that the twisted outline sweeps
+	bd.approximate = true
+	var radius float64
+	for _, p := range points {
+		radius = math.Max(radius, math.Hypot(p[0], p[1]))
+	}
+	for i, r := range []float64{radius, radius * math.Max(n.Scale[0], n.Scale[1])} {
+		for _, p := range []csg.Vec2{{-r, -r}, {r, -r}, {r, r}, {-r, r}} {
+			emit(csg.Vec3{p[0], p[1], z + n.Height*float64(i)})
+		}
+	}
+	return nil
+}
+
+// rotateExtrude emits the corners of the boxes of the arcs that the points
+// of the children sweep, which contain the shape but do not account for
+// the fragments that the arcs are made of
+func (bd *bounder) rotateExtrude(n *csg.RotateExtrude, emit func(csg.Vec3)) error {
+	points, err := bd.localPoints(n.Children)
+	if err != nil {
+		return err
+	}
+	bd.approximate = true
+
+	from, to := 0.0, n.Angle
+	if to < 0 {
+		from, to = to, 0
+	}
+	if to-from >= 360 {
+		from, to = 0, 360
+	}
+	for _, p := range points {
+		r := math.Abs(p[0])
+		arc := emptyBox()
+		arc.add(csg.Vec3{r * degrees.Cos(from), r * degrees.Sin(from)})
+		arc.add(csg.Vec3{r * degrees.Cos(to), r * degrees.Sin(to)})
+		for a := math.Ceil(from/90) * 90; a <= to; a += 90 {
+			arc.add(csg.Vec3{r * degrees.Cos(a), r * degrees.Sin(a)})
+		}
+		for _, q := range []csg.Vec2{{arc.Min[0], arc.Min[1]}, {arc.Max[0], arc.Min[1]}, {arc.Max[0], arc.Max[1]}, {arc.Min[0], arc.Max[1]}} {
+			emit(csg.Vec3{q[0], q[1], p[1]})
+		}
+	}
+	return nil
+}
+
+func (bd *bounder) operation(n *csg.Operation, mat csg.Matrix, add, emit func(csg.Vec3)) error {
+	if n.Name == `render` {
+		for _, child := range n.Children {
+			if err := bd.points(child, mat, add); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch n.Name {
+	case `offset`, `projection`, `resize`:
+	default:
+		return fmt.Errorf(`the bounds of %s() are not known`, n.Name)
+	}
+	points, err := bd.localPoints(n.Children)
+	if err != nil || len(points) == 0 {
+		return err
+	}
+	b := emptyBox()
+	for _, p := range points {
+		b.add(p)
+	}
+
+	switch n.Name {
+	case `offset`:
+		// rounded and mitered corners stay within the box grown by the
+		// offset, and negative offsets only shrink the shape
+		bd.approximate = true
+		v, ok := argument(n.Args, 0, `r`)
+		if !ok {
+			v, _ = argument(n.Args, -1, `delta`)
+		}
+		if d, ok := v.(eval.Number); ok && d > 0 {
+			for i := 0; i < 2; i++ {
+				b.Min[i] -= float64(d)
+				b.Max[i] += float64(d)
+			}
+		}
+		for _, p := range []csg.Vec2{{b.Min[0], b.Min[1]}, {b.Max[0], b.Min[1]}, {b.Max[0], b.Max[1]}, {b.Min[0], b.Max[1]}} {
+			emit(csg.Vec3{p[0], p[1], 0})
+		}
+	case `projection`:
+		// a cut only keeps part of the shape
+		if v, ok := argument(n.Args, 0, `cut`); ok && eval.Truthy(v) {
+			bd.approximate = true
+		}
+		for _, p := range points {
+			emit(csg.Vec3{p[0], p[1], 0})
+		}
+	case `resize`:
+		scale := resizeScale(n.Args, b.Size())
+		for _, p := range points {
+			emit(csg.Vec3{p[0] * scale[0], p[1] * scale[1], p[2] * scale[2]})
+		}
+	}
+	return nil
+}
+
+// resizeScale returns the factors that resize() scales a shape of the
+// given size by. As in OpenSCAD, axes whose new size is zero are scaled
+// by the factor of the axis with the largest new size if they are set to
+// be scaled automatically, and are left alone otherwise.
+func resizeScale(args []eval.Argument, size csg.Vec3) csg.Vec3 {
+	var newsize csg.Vec3
+	if v, ok := argument(args, 0, `newsize`); ok {
+		if vec, ok := v.(eval.Vector); ok {
+			for i := 0; i < len(vec) && i < 3; i++ {
+				if f, ok := vec[i].(eval.Number); ok {
+					newsize[i] = float64(f)
+				}
+			}
+		}
+	}
+	var auto [3]bool
+	if v, ok := argument(args, 1, `auto`); ok {
+		if vec, ok := v.(eval.Vector); ok {
+			for i := 0; i < len(vec) && i < 3; i++ {
+				auto[i] = eval.Truthy(vec[i])
+			}
+		} else {
+			auto = [3]bool{eval.Truthy(v), eval.Truthy(v), eval.Truthy(v)}
+		}
+	}
+
+	scale := csg.Vec3{1, 1, 1}
+	largest := 0
+	for i := range newsize {
+		if newsize[i] > 0 && size[i] > 0 {
+			scale[i] = newsize[i] / size[i]
+		}
+		if newsize[i] > newsize[largest] {
+			largest = i
+		}
+	}
+	ret := scale
+	for i := range ret {
+		if auto[i] && !(newsize[i] > 0) {
+			ret[i] = scale[largest]
+		}
+	}
+	return ret
+}
+
+// argument returns the argument called name, or the index-th positional
+// argument. A negative index only matches by name.
+func argument(args []eval.Argument, index int, name string) (eval.Value, bool) {
+	var positional int
+	for _, arg := range args {
+		switch arg.Name {
+		case name:
+			return arg.Value, true
+		case ``:
+			if positional == index {
+				return arg.Value, true
+			}
+			positional++
+		}
+	}
+	return nil, false
+}
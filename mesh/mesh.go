@@ -0,0 +1,144 @@
+// Package mesh tessellates the primitives of a CSG tree into indexed
+// triangle meshes, the same way that OpenSCAD does: circles, spheres and
+// cylinders have the number of fragments that $fn, $fa and $fs call for,
+// and their vertices are in the same places as OpenSCAD's.
+//
+// 2D shapes are represented as Regions, which can be combined with
+// boolean operations, and triangulated into meshes that lie in the XY
+// plane.
+package mesh
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lestrrat-go/openscad/csg"
+)
+
+// Mesh is an indexed triangle mesh. The vertices of each triangle are
+// indices into Vertices, in counter-clockwise order when the triangle is
+// seen from outside of the shape.
+type Mesh struct {
+	Vertices  []csg.Vec3
+	Triangles [][3]int
+}
+
+// Primitive tessellates a primitive node: one of *csg.Cube, *csg.Sphere,
+// *csg.Cylinder, *csg.Polyhedron, *csg.Square, *csg.Circle or
+// *csg.Polygon. 2D shapes are triangulated in the XY plane, facing +Z.
+func Primitive(n csg.Node) (*Mesh, error) {
+	switch n := n.(type) {
+	case *csg.Cube:
+		return Cube(n), nil
+	case *csg.Sphere:
+		return Sphere(n), nil
+	case *csg.Cylinder:
+		return Cylinder(n), nil
+	case *csg.Polyhedron:
+		return Polyhedron(n)
+	case *csg.Square:
+		return Triangulate(Square(n))
+	case *csg.Circle:
+		return Triangulate(Circle(n))
+	case *csg.Polygon:
+		r, err := Polygon(n)
+		if err != nil {
+			return nil, err
+		}
+		return Triangulate(r)
+	}
+	return nil, fmt.Errorf(`%T is not a primitive`, n)
+}
+
+// Append adds the vertices and triangles of o to m
+func (m *Mesh) Append(o *Mesh) {
+	offset := len(m.Vertices)
+	m.Vertices = append(m.Vertices, o.Vertices...)
+	for _, t := range o.Triangles {
+		m.Triangles = append(m.Triangles, [3]int{t[0] + offset, t[1] + offset, t[2] + offset})
+	}
+}
+
+// Transform returns a copy of m with mat applied to its vertices.
+// Matrices that mirror the mesh reverse the order of the vertices of each
+// triangle, so that they still face outwards.
+func (m *Mesh) Transform(mat csg.Matrix) *Mesh {
+	ret := &Mesh{
+		Vertices:  make([]csg.Vec3, len(m.Vertices)),
+		Triangles: make([][3]int, len(m.Triangles)),
+	}
+	for i, v := range m.Vertices {
+		ret.Vertices[i] = mat.Apply(v)
+	}
+	flip := determinant(mat) < 0
+	for i, t := range m.Triangles {
+		if flip {
+			t[1], t[2] = t[2], t[1]
+		}
+		ret.Triangles[i] = t
+	}
+	return ret
+}
+
+// determinant returns the determinant of the linear part of m
+func determinant(m csg.Matrix) float64 {
+	return m[0][0]*(m[1][1]*m[2][2]-m[1][2]*m[2][1]) -
+		m[0][1]*(m[1][0]*m[2][2]-m[1][2]*m[2][0]) +
+		m[0][2]*(m[1][0]*m[2][1]-m[1][1]*m[2][0])
+}
+
+// Normal returns the unit normal of the i-th triangle, or the zero vector
+// if the triangle is degenerate
+func (m *Mesh) Normal(i int) csg.Vec3 {
+	t := m.Triangles[i]
+	return normalize(cross(sub(m.Vertices[t[1]], m.Vertices[t[0]]), sub(m.Vertices[t[2]], m.Vertices[t[0]])))
+}
+
+// Volume returns the volume enclosed by m, which is only meaningful if m
+// is closed. It is negative if the triangles face inwards.
+func (m *Mesh) Volume() float64 {
+	var sum float64
+	for _, t := range m.Triangles {
+		a, b, c := m.Vertices[t[0]], m.Vertices[t[1]], m.Vertices[t[2]]
+		sum += dot(a, cross(b, c))
+	}
+	return sum / 6
+}
+
+// Area returns the total area of the triangles of m
+func (m *Mesh) Area() float64 {
+	var sum float64
+	for _, t := range m.Triangles {
+		a, b, c := m.Vertices[t[0]], m.Vertices[t[1]], m.Vertices[t[2]]
+		sum += length(cross(sub(b, a), sub(c, a))) / 2
+	}
+	return sum
+}
+
+func sub(a, b csg.Vec3) csg.Vec3 {
+	return csg.Vec3{a[0] - b[0], a[1] - b[1], a[2] - b[2]}
+}
+
+func dot(a, b csg.Vec3) float64 {
+	return a[0]*b[0] + a[1]*b[1] + a[2]*b[2]
+}
+
+func cross(a, b csg.Vec3) csg.Vec3 {
+	return csg.Vec3{
+		a[1]*b[2] - a[2]*b[1],
+		a[2]*b[0] - a[0]*b[2],
+		a[0]*b[1] - a[1]*b[0],
+	}
+}
+
+func length(v csg.Vec3) float64 {
+	return math.Sqrt(dot(v, v))
+}
+
+func normalize(v csg.Vec3) csg.Vec3 {
+	l := length(v)
+	if l == 0 {
+		return csg.Vec3{}
+	}
+	return csg.Vec3{v[0] / l, v[1] / l, v[2] / l}
+}
@@ -0,0 +1,359 @@
+package mesh_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/internal/degrees"
+	"github.com/lestrrat-go/openscad/mesh"
+	"github.com/stretchr/testify/require"
+)
+
+var defaultFragments = csg.Fragments{Fa: 12, Fs: 2}
+
+// requireClosed checks that every edge of m is shared by exactly two
+// triangles, which traverse it in opposite directions
+func requireClosed(t *testing.T, m *mesh.Mesh) {
+	t.Helper()
+	edges := make(map[[2]int]int)
+	for _, tri := range m.Triangles {
+		for i := 0; i < 3; i++ {
+			edges[[2]int{tri[i], tri[(i+1)%3]}]++
+		}
+	}
+	for edge, count := range edges {
+		require.Equal(t, 1, count, `edge %v should be used once`, edge)
+		require.Equal(t, 1, edges[[2]int{edge[1], edge[0]}], `edge %v should have a reverse`, edge)
+	}
+}
+
+func TestCube(t *testing.T) {
+	m := mesh.Cube(&csg.Cube{Size: csg.Vec3{1, 2, 3}, Center: true})
+	require.Len(t, m.Vertices, 8, `cube should have 8 vertices`)
+	require.Len(t, m.Triangles, 12, `cube should have 12 triangles`)
+	require.InDelta(t, 6, m.Volume(), 1e-9, `volume should match`)
+	require.InDelta(t, 22, m.Area(), 1e-9, `area should match`)
+	requireClosed(t, m)
+
+	require.Empty(t, mesh.Cube(&csg.Cube{Size: csg.Vec3{1, 0, 1}}).Triangles, `degenerate cube should be empty`)
+}
+
+func TestSphere(t *testing.T) {
+	t.Run(`ring layout`, func(t *testing.T) {
+		m := mesh.Sphere(&csg.Sphere{R: 2, Fragments: csg.Fragments{Fn: 6, Fa: 12, Fs: 2}})
+		// 6 fragments make 3 rings of 6 points, at 30, 90 and 150 degrees
+		// from the top
+		require.Len(t, m.Vertices, 18, `sphere should have 3 rings of 6 points`)
+		for i, z := range []float64{2 * degrees.Cos(30), 0, -2 * degrees.Cos(30)} {
+			for j := 0; j < 6; j++ {
+				v := m.Vertices[i*6+j]
+				r := 2 * degrees.Sin(30+60*float64(i))
+				require.InDelta(t, z, v[2], 1e-12, `z of ring %d should match`, i)
+				require.InDelta(t, r*degrees.Cos(60*float64(j)), v[0], 1e-12, `x of point %d of ring %d should match`, j, i)
+				require.InDelta(t, r*degrees.Sin(60*float64(j)), v[1], 1e-12, `y of point %d of ring %d should match`, j, i)
+			}
+		}
+		require.Len(t, m.Triangles, 4+24+4, `sphere should have two caps and two bands`)
+		requireClosed(t, m)
+		require.Greater(t, m.Volume(), 0.0, `triangles should face outwards`)
+	})
+	t.Run(`default fragments`, func(t *testing.T) {
+		m := mesh.Sphere(&csg.Sphere{R: 10, Fragments: defaultFragments})
+		require.Len(t, m.Vertices, 15*30, `$fa = 12 should result in 30 fragments and 15 rings`)
+		requireClosed(t, m)
+	})
+	t.Run(`odd fragments`, func(t *testing.T) {
+		m := mesh.Sphere(&csg.Sphere{R: 1, Fragments: csg.Fragments{Fn: 5}})
+		require.Len(t, m.Vertices, 3*5, `5 fragments should result in 3 rings`)
+		requireClosed(t, m)
+	})
+	t.Run(`zero fragments`, func(t *testing.T) {
+		m := mesh.Sphere(&csg.Sphere{R: 1})
+		require.Len(t, m.Vertices, 315*629, `$fa and $fs should be raised to 0.01`)
+	})
+	t.Run(`infinite $fn`, func(t *testing.T) {
+		m := mesh.Sphere(&csg.Sphere{R: 10, Fragments: csg.Fragments{Fn: math.Inf(1), Fa: 12, Fs: 2}})
+		require.Len(t, m.Vertices, 15*30, `$fn should be treated as unset`)
+	})
+}
+
+func TestCylinder(t *testing.T) {
+	t.Run(`cylinder`, func(t *testing.T) {
+		m := mesh.Cylinder(&csg.Cylinder{H: 3, R1: 1, R2: 1, Fragments: csg.Fragments{Fn: 4}})
+		require.Len(t, m.Vertices, 8, `cylinder should have two rings of 4 points`)
+		require.InDelta(t, 2*3, m.Volume(), 1e-9, `volume should be that of a square prism`)
+		require.Equal(t, csg.Vec3{1, 0, 0}, m.Vertices[0], `first point should be on the X axis`)
+		requireClosed(t, m)
+	})
+	t.Run(`cone`, func(t *testing.T) {
+		m := mesh.Cylinder(&csg.Cylinder{H: 3, R1: 1, R2: 0, Center: true, Fragments: csg.Fragments{Fn: 4}})
+		require.Len(t, m.Vertices, 5, `cone should have a single apex`)
+		require.Len(t, m.Triangles, 4+2, `cone should have 4 sides and a base`)
+		require.InDelta(t, 2.0, m.Volume(), 1e-9, `volume should be that of a square pyramid`)
+		require.Equal(t, csg.Vec3{0, 0, 1.5}, m.Vertices[4], `apex should be at the top`)
+		requireClosed(t, m)
+	})
+	t.Run(`fragments`, func(t *testing.T) {
+		m := mesh.Cylinder(&csg.Cylinder{H: 1, R1: 1, R2: 10, Fragments: defaultFragments})
+		require.Len(t, m.Vertices, 60, `fragments should be based on the larger radius`)
+	})
+}
+
+func TestPolyhedron(t *testing.T) {
+	// the faces of a polyhedron are clockwise when seen from outside
+	m, err := mesh.Polyhedron(&csg.Polyhedron{
+		Points: []csg.Vec3{{0, 0, 0}, {2, 0, 0}, {2, 2, 0}, {0, 2, 0}, {1, 1, 3}},
+		Faces:  [][]int{{0, 4, 1}, {1, 4, 2}, {2, 4, 3}, {3, 4, 0}, {0, 1, 2, 3}},
+	})
+	require.NoError(t, err, `mesh.Polyhedron should succeed`)
+	require.Len(t, m.Triangles, 6, `square base should be split in two`)
+	require.InDelta(t, 4.0, m.Volume(), 1e-9, `volume should be that of the pyramid`)
+	requireClosed(t, m)
+
+	_, err = mesh.Polyhedron(&csg.Polyhedron{Points: []csg.Vec3{{0, 0, 0}}, Faces: [][]int{{0, 1, 2}}})
+	require.Error(t, err, `faces with unknown points should be rejected`)
+}
+
+func TestRegions(t *testing.T) {
+	t.Run(`circle`, func(t *testing.T) {
+		r := mesh.Circle(&csg.Circle{R: 1, Fragments: defaultFragments})
+		require.Len(t, r.Outline, 5, `small circles should have 5 fragments`)
+		require.Equal(t, csg.Vec2{1, 0}, r.Outline[0], `first point should be on the X axis`)
+	})
+	t.Run(`hole`, func(t *testing.T) {
+		r, err := mesh.Polygon(&csg.Polygon{
+			Points: []csg.Vec2{{0, 0}, {10, 0}, {10, 10}, {0, 10}, {3, 3}, {3, 7}, {7, 7}, {7, 3}},
+			Paths:  [][]int{{0, 1, 2, 3}, {4, 5, 6, 7}},
+		})
+		require.NoError(t, err, `mesh.Polygon should succeed`)
+		require.InDelta(t, 84.0, r.Area(), 1e-9, `area should exclude the hole`)
+
+		m, err := mesh.Triangulate(r)
+		require.NoError(t, err, `mesh.Triangulate should succeed`)
+		require.Len(t, m.Triangles, 8, `square with a hole should have 8 triangles`)
+		require.InDelta(t, 84.0, m.Area(), 1e-9, `triangles should cover the region`)
+		for i := range m.Triangles {
+			require.Equal(t, csg.Vec3{0, 0, 1}, m.Normal(i), `triangle %d should face +Z`, i)
+		}
+	})
+	t.Run(`concave`, func(t *testing.T) {
+		// clockwise points are reoriented
+		r, err := mesh.Polygon(&csg.Polygon{Points: []csg.Vec2{{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}}})
+		require.NoError(t, err, `mesh.Polygon should succeed`)
+		m, err := mesh.Triangulate(r)
+		require.NoError(t, err, `mesh.Triangulate should succeed`)
+		require.Len(t, m.Triangles, 4, `L shape should have 4 triangles`)
+		require.InDelta(t, 3.0, m.Area(), 1e-9, `triangles should cover the region`)
+	})
+	t.Run(`many holes`, func(t *testing.T) {
+		outline := mesh.Circle(&csg.Circle{R: 20, Fragments: csg.Fragments{Fn: 64}})
+		r := mesh.Region{Outline: outline.Outline}
+		area := outline.Area()
+		for x := -10.0; x <= 10; x += 5 {
+			hole := mesh.Circle(&csg.Circle{R: 1, Fragments: csg.Fragments{Fn: 12}})
+			points := make([]csg.Vec2, len(hole.Outline))
+			for i, p := range hole.Outline {
+				points[len(points)-1-i] = csg.Vec2{p[0] + x, p[1] + x/2}
+			}
+			r.Holes = append(r.Holes, points)
+			area -= hole.Area()
+		}
+		m, err := mesh.Triangulate(r)
+		require.NoError(t, err, `mesh.Triangulate should succeed`)
+		require.InDelta(t, area, m.Area(), 1e-9, `triangles should cover the region`)
+		require.Len(t, m.Triangles, 64+5*12+5*2-2, `triangulation should not have overlapping triangles`)
+	})
+}
+
+func TestTransform(t *testing.T) {
+	m := mesh.Cube(&csg.Cube{Size: csg.Vec3{1, 1, 1}})
+	mirrored := m.Transform(csg.Mirroring(csg.Vec3{1, 0, 0}))
+	require.InDelta(t, 1.0, mirrored.Volume(), 1e-9, `mirrored triangles should still face outwards`)
+	require.Equal(t, csg.Vec3{-1, 0, 0}, mirrored.Vertices[1], `vertices should be mirrored`)
+
+	scaled := m.Transform(csg.Scaling(csg.Vec3{2, 3, 4}))
+	require.InDelta(t, 24.0, scaled.Volume(), 1e-9, `scaled volume should match`)
+	require.False(t, math.IsNaN(scaled.Area()), `area should be a number`)
+
+	m.Append(scaled)
+	require.Len(t, m.Vertices, 16, `appended vertices should be added`)
+	require.InDelta(t, 25.0, m.Volume(), 1e-9, `volume of both should add up`)
+}
+
+func TestFromNode(t *testing.T) {
+	cube := &csg.Cube{Size: csg.Vec3{1, 1, 1}}
+	m, err := mesh.FromNode(&csg.Group{Children: []csg.Node{
+		cube,
+		&csg.Transform{
+			Matrix:   csg.Translation(csg.Vec3{5, 0, 0}).Mul(csg.Scaling(csg.Vec3{2, 2, 2})),
+			Children: []csg.Node{&csg.Color{RGBA: [4]float64{1, 0, 0, 1}, Children: []csg.Node{cube}}},
+		},
+		&csg.Modifier{Op: `%`, Child: cube},
+		&csg.Modifier{Op: `#`, Child: cube},
+	}})
+	require.NoError(t, err, `mesh.FromNode should succeed`)
+	require.Len(t, m.Triangles, 36, `all but the background cube should be added`)
+	require.InDelta(t, 10.0, m.Volume(), 1e-9, `volumes should add up`)
+	require.Equal(t, csg.Vec3{7, 2, 2}, m.Vertices[8+6], `transform should be applied`)
+
+	_, err = mesh.FromNode(&csg.Difference{Children: []csg.Node{cube, cube}})
+	require.Error(t, err, `difference of two children should be rejected`)
+	_, err = mesh.FromNode(&csg.Difference{Children: []csg.Node{cube}})
+	require.NoError(t, err, `difference of a single child should succeed`)
+}
+
+func TestWeld(t *testing.T) {
+	m := &mesh.Mesh{
+		Vertices: []csg.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1, 1e-7, 0}, {1, 1, 0}, {0, 1, 0}, {5, 5, 5}},
+		Triangles: [][3]int{
+			{0, 1, 2},
+			{3, 4, 5},
+			{1, 3, 4}, // degenerate once 1 and 3 are welded
+		},
+	}
+
+	exact := m.Weld(0)
+	require.Len(t, exact.Vertices, 5, `only identical vertices should be joined`)
+	require.Len(t, exact.Triangles, 3, `no triangle should be dropped`)
+
+	welded := m.Weld(1e-6)
+	require.Len(t, welded.Vertices, 4, `close vertices should be joined`)
+	require.Equal(t, [][3]int{{0, 1, 2}, {1, 3, 2}}, welded.Triangles, `degenerate triangle should be dropped`)
+	require.Equal(t, csg.Vec3{1, 0, 0}, welded.Vertices[1], `first vertex should be kept`)
+}
+
+func TestParts(t *testing.T) {
+	cube := &csg.Cube{Size: csg.Vec3{1, 1, 1}}
+	parts, err := mesh.Parts(&csg.Group{Children: []csg.Node{
+		cube,
+		&csg.Modifier{Op: `%`, Child: cube},
+		&csg.Union{Children: []csg.Node{cube, &csg.Sphere{R: 1, Fragments: defaultFragments}}},
+	}})
+	require.NoError(t, err, `mesh.Parts should succeed`)
+	require.Len(t, parts, 2, `background part should be left out`)
+	require.Len(t, parts[0].Triangles, 12, `first part should be the cube`)
+
+	parts, err = mesh.Parts(cube)
+	require.NoError(t, err, `mesh.Parts should succeed`)
+	require.Len(t, parts, 1, `a single node should be a single part`)
+}
+
+func square(x, y, size float64) []mesh.Region {
+	return []mesh.Region{mesh.Square(&csg.Square{Size: csg.Vec2{size, size}}).Transform(csg.Translation(csg.Vec3{x, y, 0}))}
+}
+
+func totalArea(regions []mesh.Region) float64 {
+	var sum float64
+	for _, r := range regions {
+		sum += r.Area()
+	}
+	return sum
+}
+
+func TestBooleans(t *testing.T) {
+	t.Run(`union`, func(t *testing.T) {
+		got := mesh.Union(square(0, 0, 2), square(1, 1, 2))
+		require.Len(t, got, 1, `overlapping squares should be merged`)
+		require.Len(t, got[0].Outline, 8, `outline should have 8 corners`)
+		require.InDelta(t, 7.0, got[0].Area(), 1e-9, `area should match`)
+	})
+	t.Run(`union of touching squares`, func(t *testing.T) {
+		got := mesh.Union(square(0, 0, 1), square(1, 0, 1))
+		require.Len(t, got, 1, `squares sharing an edge should be merged`)
+		require.Len(t, got[0].Outline, 4, `points in straight lines should be removed`)
+
+		got = mesh.Union(square(0, 0, 1), square(1, 1, 1))
+		require.Len(t, got, 2, `squares sharing a corner should be kept apart`)
+	})
+	t.Run(`difference`, func(t *testing.T) {
+		got := mesh.Difference(square(0, 0, 10), square(2, 2, 2), square(6, 6, 2))
+		require.Len(t, got, 1, `difference should be a single region`)
+		require.Len(t, got[0].Holes, 2, `region should have two holes`)
+		require.InDelta(t, 92.0, got[0].Area(), 1e-9, `area should match`)
+
+		got = mesh.Difference(square(0, 0, 3), square(1, -0.5, 1))
+		require.InDelta(t, 8.5, totalArea(got), 1e-9, `notch should be cut out`)
+		require.Len(t, got[0].Outline, 8, `notch should be cut into the outline`)
+	})
+	t.Run(`intersection`, func(t *testing.T) {
+		got := mesh.Intersection(square(0, 0, 2), square(1, 1, 2))
+		require.Len(t, got, 1, `intersection should be a single region`)
+		require.InDelta(t, 1.0, got[0].Area(), 1e-9, `area should match`)
+
+		require.Empty(t, mesh.Intersection(square(0, 0, 1), square(5, 5, 1)), `disjoint squares should not intersect`)
+	})
+	t.Run(`circles`, func(t *testing.T) {
+		disc := func(x float64) []mesh.Region {
+			return []mesh.Region{mesh.Circle(&csg.Circle{R: 1, Fragments: csg.Fragments{Fn: 30}}).Transform(csg.Translation(csg.Vec3{x, 0, 0}))}
+		}
+		a := disc(0)[0].Area()
+		union := mesh.Union(disc(0), disc(1))
+		intersection := mesh.Intersection(disc(0), disc(1))
+		require.Len(t, union, 1, `union should be a single region`)
+		require.InDelta(t, 2*a, totalArea(union)+totalArea(intersection), 1e-9, `areas should add up`)
+
+		m, err := mesh.Triangulate(union...)
+		require.NoError(t, err, `mesh.Triangulate should succeed`)
+		require.InDelta(t, totalArea(union), m.Area(), 1e-9, `union should be triangulated`)
+	})
+}
+
+func TestHull(t *testing.T) {
+	r := mesh.Hull(append(square(0, 0, 1), square(3, 0, 1)...)...)
+	require.Len(t, r.Outline, 4, `hull of two squares should have 4 corners`)
+	require.InDelta(t, 4.0, r.Area(), 1e-9, `area should match`)
+}
+
+func TestRegionsFromNode(t *testing.T) {
+	plate := &csg.Difference{Children: []csg.Node{
+		&csg.Square{Size: csg.Vec2{40, 20}},
+		&csg.Transform{
+			Matrix:   csg.Translation(csg.Vec3{10, 10, 0}),
+			Children: []csg.Node{&csg.Circle{R: 3, Fragments: csg.Fragments{Fn: 24}}},
+		},
+		&csg.Transform{
+			Matrix:   csg.Translation(csg.Vec3{40, 10, 0}).Mul(csg.Mirroring(csg.Vec3{1, 0, 0})),
+			Children: []csg.Node{&csg.Square{Size: csg.Vec2{5, 5}, Center: true}},
+		},
+	}}
+	got, err := mesh.Regions(&csg.Group{Children: []csg.Node{plate, &csg.Modifier{Op: `%`, Child: &csg.Square{Size: csg.Vec2{100, 100}}}}})
+	require.NoError(t, err, `mesh.Regions should succeed`)
+	require.Len(t, got, 1, `plate should be a single region`)
+	require.Len(t, got[0].Holes, 1, `plate should have a hole`)
+	require.Len(t, got[0].Outline, 8, `notch should be cut into the outline`)
+	hole := mesh.Circle(&csg.Circle{R: 3, Fragments: csg.Fragments{Fn: 24}}).Area()
+	require.InDelta(t, 800-hole-12.5, got[0].Area(), 1e-9, `area should match`)
+
+	_, err = mesh.Regions(&csg.Group{Children: []csg.Node{&csg.Cube{Size: csg.Vec3{1, 1, 1}}}})
+	require.Error(t, err, `3D shapes should be rejected`)
+}
+
+func TestBounds(t *testing.T) {
+	tree := &csg.Group{Children: []csg.Node{
+		&csg.Transform{
+			Matrix:   csg.Rotation(csg.Vec3{30, 45, 60}).Mul(csg.Scaling(csg.Vec3{1, 2, 3})),
+			Children: []csg.Node{&csg.Sphere{R: 5, Fragments: defaultFragments}},
+		},
+		&csg.Transform{
+			Matrix:   csg.Translation(csg.Vec3{10, 0, 0}).Mul(csg.Rotation(csg.Vec3{10, 20, 0})),
+			Children: []csg.Node{&csg.Cylinder{H: 4, R1: 2, R2: 0, Fragments: defaultFragments}},
+		},
+	}}
+	b, err := mesh.Bounds(tree)
+	require.NoError(t, err, `mesh.Bounds should succeed`)
+	require.False(t, b.Approximate, `bounds should be exact`)
+
+	m, err := mesh.FromNode(tree)
+	require.NoError(t, err, `mesh.FromNode should succeed`)
+	expected := m.Bounds()
+	for i := 0; i < 3; i++ {
+		require.InDelta(t, expected.Min[i], b.Min[i], 1e-12, `min %d should be that of the mesh`, i)
+		require.InDelta(t, expected.Max[i], b.Max[i], 1e-12, `max %d should be that of the mesh`, i)
+	}
+
+	empty, err := mesh.Bounds(&csg.Group{})
+	require.NoError(t, err, `mesh.Bounds should succeed`)
+	require.True(t, empty.Empty(), `empty tree should have an empty box`)
+	require.Equal(t, csg.Vec3{}, empty.Size(), `empty box should have no size`)
+}
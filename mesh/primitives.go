@@ -0,0 +1,242 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/internal/degrees"
+)
+
+// Cube tessellates a cube into 12 triangles. Cubes with a size that is not
+// positive result in an empty mesh.
+func Cube(c *csg.Cube) *Mesh {
+	if !(c.Size[0] > 0 && c.Size[1] > 0 && c.Size[2] > 0) {
+		return &Mesh{}
+	}
+	x1, y1, z1 := 0.0, 0.0, 0.0
+	x2, y2, z2 := c.Size[0], c.Size[1], c.Size[2]
+	if c.Center {
+		x1, y1, z1 = -x2/2, -y2/2, -z2/2
+		x2, y2, z2 = x2/2, y2/2, z2/2
+	}
+
+	m := &Mesh{Vertices: []csg.Vec3{
+		{x1, y1, z1}, {x2, y1, z1}, {x2, y2, z1}, {x1, y2, z1},
+		{x1, y1, z2}, {x2, y1, z2}, {x2, y2, z2}, {x1, y2, z2},
+	}}
+	// the faces are the same as OpenSCAD's: top, bottom, then the sides
+	for _, face := range [][]int{
+		{4, 5, 6, 7},
+		{3, 2, 1, 0},
+		{0, 1, 5, 4},
+		{1, 2, 6, 5},
+		{2, 3, 7, 6},
+		{3, 0, 4, 7},
+	} {
+		m.addFace(face)
+	}
+	return m
+}
+
+// addFace adds a convex face as a fan of triangles
+func (m *Mesh) addFace(face []int) {
+	for i := 1; i+1 < len(face); i++ {
+		m.Triangles = append(m.Triangles, [3]int{face[0], face[i], face[i+1]})
+	}
+}
+
+// circle returns the points of a circle with the given number of
+// fragments, starting at the positive X axis
+func circle(r float64, fragments int) []csg.Vec2 {
+	points := make([]csg.Vec2, fragments)
+	for i := range points {
+		phi := 360 * float64(i) / float64(fragments)
+		points[i] = csg.Vec2{r * degrees.Cos(phi), r * degrees.Sin(phi)}
+	}
+	return points
+}
+
+// Sphere tessellates a sphere. Like OpenSCAD, the sphere is made of
+// (fragments+1)/2 rings, none of which is at the poles: the i-th ring is
+// at an angle of 180*(i+0.5)/rings degrees from the top. The top and
+// bottom rings are closed by flat caps.
+func Sphere(s *csg.Sphere) *Mesh {
+	if !(s.R > 0) || math.IsInf(s.R, 0) {
+		return &Mesh{}
+	}
+	fragments := s.Count(s.R)
+	rings := (fragments + 1) / 2
+	m := &Mesh{Vertices: sphereVertices(s.R, fragments)}
+
+	ring := func(i, j int) int {
+		return i*fragments + j%fragments
+	}
+	top := make([]int, fragments)
+	for j := range top {
+		top[j] = ring(0, j)
+	}
+	m.addFace(top)
+
+	for i := 0; i+1 < rings; i++ {
+		// the triangles between two rings alternate between pointing up
+		// and down, in the same order as OpenSCAD's
+		var r1, r2 int
+		for r1 < fragments || r2 < fragments {
+			if r2 >= fragments || (r1 < fragments && r1 < r2) {
+				m.Triangles = append(m.Triangles, [3]int{ring(i+1, r2), ring(i, r1+1), ring(i, r1)})
+				r1++
+			} else {
+				m.Triangles = append(m.Triangles, [3]int{ring(i+1, r2), ring(i+1, r2+1), ring(i, r1)})
+				r2++
+			}
+		}
+	}
+
+	bottom := make([]int, fragments)
+	for j := range bottom {
+		bottom[j] = ring(rings-1, fragments-1-j)
+	}
+	m.addFace(bottom)
+	return m
+}
+
+// sphereVertices returns the points of the rings of a sphere, from the
+// top ring to the bottom one
+func sphereVertices(r float64, fragments int) []csg.Vec3 {
+	rings := (fragments + 1) / 2
+	ret := make([]csg.Vec3, 0, rings*fragments)
+	for i := 0; i < rings; i++ {
+		phi := 180 * (float64(i) + 0.5) / float64(rings)
+		z := r * degrees.Cos(phi)
+		for _, p := range circle(r*degrees.Sin(phi), fragments) {
+			ret = append(ret, csg.Vec3{p[0], p[1], z})
+		}
+	}
+	return ret
+}
+
+// Cylinder tessellates a cylinder or a cone. The number of fragments is
+// based on the larger of the two radii. A radius of zero results in a
+// single vertex at the apex.
+func Cylinder(c *csg.Cylinder) *Mesh {
+	if !(c.H > 0) || c.R1 < 0 || c.R2 < 0 || (c.R1 <= 0 && c.R2 <= 0) {
+		return &Mesh{}
+	}
+	z1, z2 := 0.0, c.H
+	if c.Center {
+		z1, z2 = -c.H/2, c.H/2
+	}
+	fragments := c.Count(math.Max(c.R1, c.R2))
+
+	m := &Mesh{}
+	ring := func(r, z float64) []int {
+		if r <= 0 {
+			m.Vertices = append(m.Vertices, csg.Vec3{0, 0, z})
+			apex := make([]int, fragments)
+			for i := range apex {
+				apex[i] = len(m.Vertices) - 1
+			}
+			return apex
+		}
+		ret := make([]int, fragments)
+		for i, p := range circle(r, fragments) {
+			ret[i] = len(m.Vertices)
+			m.Vertices = append(m.Vertices, csg.Vec3{p[0], p[1], z})
+		}
+		return ret
+	}
+	bottom := ring(c.R1, z1)
+	top := ring(c.R2, z2)
+
+	for i := 0; i < fragments; i++ {
+		j := (i + 1) % fragments
+		if c.R1 > 0 {
+			m.Triangles = append(m.Triangles, [3]int{bottom[j], top[i], bottom[i]})
+		}
+		if c.R2 > 0 {
+			m.Triangles = append(m.Triangles, [3]int{bottom[j], top[j], top[i]})
+		}
+	}
+
+	if c.R1 > 0 {
+		face := make([]int, fragments)
+		for i := range face {
+			face[i] = bottom[fragments-1-i]
+		}
+		m.addFace(face)
+	}
+	if c.R2 > 0 {
+		m.addFace(top)
+	}
+	return m
+}
+
+// Polyhedron converts a polyhedron to a mesh. As in OpenSCAD, the points
+// of each face are expected to be in clockwise order when seen from
+// outside. Faces with more than three points are triangulated.
+func Polyhedron(p *csg.Polyhedron) (*Mesh, error) {
+	m := &Mesh{Vertices: append([]csg.Vec3(nil), p.Points...)}
+	for i, face := range p.Faces {
+		for _, idx := range face {
+			if idx < 0 || idx >= len(p.Points) {
+				return nil, fmt.Errorf(`face %d refers to point %d, which does not exist`, i, idx)
+			}
+		}
+		reversed := make([]int, len(face))
+		for j, idx := range face {
+			reversed[len(face)-1-j] = idx
+		}
+		if err := m.addPolygonFace(reversed); err != nil {
+			return nil, fmt.Errorf(`failed to triangulate face %d: %w`, i, err)
+		}
+	}
+	return m, nil
+}
+
+// addPolygonFace adds a planar face that may not be convex, by
+// triangulating it in the plane that it is the most parallel to
+func (m *Mesh) addPolygonFace(face []int) error {
+	switch {
+	case len(face) < 3:
+		return nil
+	case len(face) == 3:
+		m.Triangles = append(m.Triangles, [3]int{face[0], face[1], face[2]})
+		return nil
+	}
+
+	// Newell's method gives the normal of non-convex polygons too
+	var normal csg.Vec3
+	for i, idx := range face {
+		a, b := m.Vertices[idx], m.Vertices[face[(i+1)%len(face)]]
+		normal[0] += (a[1] - b[1]) * (a[2] + b[2])
+		normal[1] += (a[2] - b[2]) * (a[0] + b[0])
+		normal[2] += (a[0] - b[0]) * (a[1] + b[1])
+	}
+	axis := 2
+	if math.Abs(normal[0]) > math.Abs(normal[axis]) {
+		axis = 0
+	}
+	if math.Abs(normal[1]) > math.Abs(normal[axis]) {
+		axis = 1
+	}
+	u, v := (axis+1)%3, (axis+2)%3
+
+	points := make([]csg.Vec2, len(face))
+	for i, idx := range face {
+		points[i] = csg.Vec2{m.Vertices[idx][u], m.Vertices[idx][v]}
+	}
+	triangles, err := triangulate(points, nil)
+	if err != nil {
+		return err
+	}
+	for _, t := range triangles {
+		tri := [3]int{face[t[0]], face[t[1]], face[t[2]]}
+		a, b, c := m.Vertices[tri[0]], m.Vertices[tri[1]], m.Vertices[tri[2]]
+		if dot(cross(sub(b, a), sub(c, a)), normal) < 0 {
+			tri[1], tri[2] = tri[2], tri[1]
+		}
+		m.Triangles = append(m.Triangles, tri)
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package mesh
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/lestrrat-go/openscad/csg"
+)
+
+// Region is a 2D shape: an outline, with holes in it. Outlines are
+// counter-clockwise and holes clockwise.
+type Region struct {
+	Outline []csg.Vec2
+	Holes   [][]csg.Vec2
+}
+
+// Square returns the outline of a square. Squares with a size that is not
+// positive result in an empty region.
+func Square(s *csg.Square) Region {
+	if !(s.Size[0] > 0 && s.Size[1] > 0) {
+		return Region{}
+	}
+	x1, y1 := 0.0, 0.0
+	x2, y2 := s.Size[0], s.Size[1]
+	if s.Center {
+		x1, y1 = -x2/2, -y2/2
+		x2, y2 = x2/2, y2/2
+	}
+	return Region{Outline: []csg.Vec2{{x1, y1}, {x2, y1}, {x2, y2}, {x1, y2}}}
+}
+
+// Circle returns the outline of a circle, with as many fragments as
+// OpenSCAD uses. The first point is on the positive X axis.
+func Circle(c *csg.Circle) Region {
+	if !(c.R > 0) {
+		return Region{}
+	}
+	return Region{Outline: circle(c.R, c.Count(c.R))}
+}
+
+// Polygon returns the region of a polygon. The first path is the outline,
+// and the rest are holes. Without paths, all of the points make up the
+// outline. Paths are reoriented as necessary.
+func Polygon(p *csg.Polygon) (Region, error) {
+	if len(p.Paths) == 0 {
+		return Region{Outline: orient(append([]csg.Vec2(nil), p.Points...), true)}, nil
+	}
+
+	var r Region
+	for i, path := range p.Paths {
+		points := make([]csg.Vec2, len(path))
+		for j, idx := range path {
+			if idx < 0 || idx >= len(p.Points) {
+				return Region{}, fmt.Errorf(`path %d refers to point %d, which does not exist`, i, idx)
+			}
+			points[j] = p.Points[idx]
+		}
+		if i == 0 {
+			r.Outline = orient(points, true)
+		} else {
+			r.Holes = append(r.Holes, orient(points, false))
+		}
+	}
+	return r, nil
+}
+
+// Area returns the area of the region
+func (r Region) Area() float64 {
+	area := signedArea(r.Outline)
+	for _, hole := range r.Holes {
+		area += signedArea(hole)
+	}
+	return area
+}
+
+// Triangulate triangulates regions into a mesh in the XY plane, whose
+// triangles face +Z
+func Triangulate(regions ...Region) (*Mesh, error) {
+	m := &Mesh{}
+	for i, r := range regions {
+		if len(r.Outline) < 3 {
+			continue
+		}
+		triangles, err := triangulate(r.Outline, r.Holes)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to triangulate region %d: %w`, i, err)
+		}
+
+		offset := len(m.Vertices)
+		for _, p := range r.Outline {
+			m.Vertices = append(m.Vertices, csg.Vec3{p[0], p[1], 0})
+		}
+		for _, hole := range r.Holes {
+			for _, p := range hole {
+				m.Vertices = append(m.Vertices, csg.Vec3{p[0], p[1], 0})
+			}
+		}
+		for _, t := range triangles {
+			m.Triangles = append(m.Triangles, [3]int{t[0] + offset, t[1] + offset, t[2] + offset})
+		}
+	}
+	return m, nil
+}
+
+// signedArea returns the area of a polygon, which is positive if it is
+// counter-clockwise
+func signedArea(points []csg.Vec2) float64 {
+	var sum float64
+	for i, a := range points {
+		b := points[(i+1)%len(points)]
+		sum += a[0]*b[1] - b[0]*a[1]
+	}
+	return sum / 2
+}
+
+// orient reverses points in place if necessary, so that they are
+// counter-clockwise if ccw is true, and clockwise otherwise
+func orient(points []csg.Vec2, ccw bool) []csg.Vec2 {
+	if (signedArea(points) > 0) != ccw {
+		for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+			points[i], points[j] = points[j], points[i]
+		}
+	}
+	return points
+}
+
+// Transform returns a copy of r with the 2D part of mat applied to its
+// points. The points are reordered if necessary, so that the outline is
+// still counter-clockwise and the holes clockwise.
+func (r Region) Transform(mat csg.Matrix) Region {
+	apply := func(points []csg.Vec2, ccw bool) []csg.Vec2 {
+		ret := make([]csg.Vec2, len(points))
+		for i, p := range points {
+			v := mat.Apply(csg.Vec3{p[0], p[1], 0})
+			ret[i] = csg.Vec2{v[0], v[1]}
+		}
+		return orient(ret, ccw)
+	}
+	ret := Region{Outline: apply(r.Outline, true)}
+	for _, hole := range r.Holes {
+		ret.Holes = append(ret.Holes, apply(hole, false))
+	}
+	return ret
+}
+
+// Hull returns the convex hull of the outlines of regions
+func Hull(regions ...Region) Region {
+	var points []csg.Vec2
+	for _, r := range regions {
+		points = append(points, r.Outline...)
+	}
+	sort.Slice(points, func(i, j int) bool {
+		return points[i][0] < points[j][0] || points[i][0] == points[j][0] && points[i][1] < points[j][1]
+	})
+	if len(points) < 3 {
+		return Region{}
+	}
+
+	// Andrew's monotone chain: the lower half from left to right, then the
+	// upper half from right to left
+	var hull []csg.Vec2
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+		for _, p := range points {
+			for len(hull) >= start+2 && cross2(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+			hull = append(hull, p)
+		}
+		// the last point is the first of the other half
+		hull = hull[:len(hull)-1]
+		for i, j := 0, len(points)-1; i < j; i, j = i+1, j-1 {
+			points[i], points[j] = points[j], points[i]
+		}
+	}
+	if len(hull) < 3 {
+		return Region{}
+	}
+	return Region{Outline: hull}
+}
@@ -0,0 +1,201 @@
+package mesh
+
+import (
+	"fmt"
+
+	"github.com/lestrrat-go/openscad/csg"
+)
+
+// FromNode tessellates the tree rooted at n into a single mesh, with the
+// transformations applied to the primitives.
+//
+// Boolean operations are not computed: the children of groups and unions
+// are added to the mesh as they are, which is enough for parts made of
+// shapes that do not overlap, and which most slicers accept even when they
+// do. Differences and intersections of more than one child, hulls,
+// minkowski sums, extrusions and other operations are reported as errors.
+// Parts marked with the `%` modifier are not part of the model, and are
+// left out.
+func FromNode(n csg.Node) (*Mesh, error) {
+	m := &Mesh{}
+	if err := m.addNode(n, csg.Identity()); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Mesh) addNode(n csg.Node, mat csg.Matrix) error {
+	switch n := n.(type) {
+	case nil:
+		return nil
+	case *csg.Group:
+		return m.addNodes(n.Children, mat)
+	case *csg.Union:
+		return m.addNodes(n.Children, mat)
+	case *csg.Color:
+		return m.addNodes(n.Children, mat)
+	case *csg.Difference:
+		if len(n.Children) > 1 {
+			return fmt.Errorf(`difference() is not supported`)
+		}
+		return m.addNodes(n.Children, mat)
+	case *csg.Intersection:
+		if len(n.Children) > 1 {
+			return fmt.Errorf(`intersection() is not supported`)
+		}
+		return m.addNodes(n.Children, mat)
+	case *csg.Transform:
+		return m.addNodes(n.Children, mat.Mul(n.Matrix))
+	case *csg.Modifier:
+		if n.Op == `%` {
+			return nil
+		}
+		return m.addNode(n.Child, mat)
+	case *csg.Hull:
+		return fmt.Errorf(`hull() is not supported`)
+	case *csg.Minkowski:
+		return fmt.Errorf(`minkowski() is not supported`)
+	case *csg.LinearExtrude:
+		return fmt.Errorf(`linear_extrude() is not supported`)
+	case *csg.RotateExtrude:
+		return fmt.Errorf(`rotate_extrude() is not supported`)
+	case *csg.Operation:
+		return fmt.Errorf(`%s() is not supported`, n.Name)
+	}
+
+	p, err := Primitive(n)
+	if err != nil {
+		return err
+	}
+	if !mat.IsIdentity() {
+		p = p.Transform(mat)
+	}
+	m.Append(p)
+	return nil
+}
+
+func (m *Mesh) addNodes(list []csg.Node, mat csg.Matrix) error {
+	for _, child := range list {
+		if err := m.addNode(child, mat); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Parts tessellates each of the top-level parts of the tree rooted at n
+// into a mesh of its own, in the same way as FromNode. The parts are the
+// children of n if it is a group, such as the root of an evaluated
+// design, or n itself otherwise. Parts that are empty, such as those
+// marked with the `%` modifier, are left out.
+func Parts(n csg.Node) ([]*Mesh, error) {
+	list := []csg.Node{n}
+	if g, ok := n.(*csg.Group); ok {
+		list = g.Children
+	}
+
+	var ret []*Mesh
+	for i, part := range list {
+		m, err := FromNode(part)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to tessellate part %d: %w`, i, err)
+		}
+		if len(m.Triangles) > 0 {
+			ret = append(ret, m)
+		}
+	}
+	return ret, nil
+}
+
+// Regions evaluates the 2D tree rooted at n into the regions that it
+// covers, with transformations and boolean operations applied. Like
+// OpenSCAD, the children of groups are joined together.
+//
+// Squares, circles and polygons can be combined with union(),
+// difference(), intersection() and hull(). 3D shapes, and operations such
+// as offset() and projection(), are reported as errors. Parts marked with
+// the `%` modifier are left out.
+func Regions(n csg.Node) ([]Region, error) {
+	return regions(n, csg.Identity())
+}
+
+func regions(n csg.Node, mat csg.Matrix) ([]Region, error) {
+	var r Region
+	switch n := n.(type) {
+	case nil:
+		return nil, nil
+	case *csg.Group:
+		return combineChildren(Union, n.Children, mat)
+	case *csg.Union:
+		return combineChildren(Union, n.Children, mat)
+	case *csg.Color:
+		return combineChildren(Union, n.Children, mat)
+	case *csg.Difference:
+		return combineChildren(Difference, n.Children, mat)
+	case *csg.Intersection:
+		return combineChildren(Intersection, n.Children, mat)
+	case *csg.Hull:
+		sets, err := childRegions(n.Children, mat)
+		if err != nil {
+			return nil, err
+		}
+		var list []Region
+		for _, set := range sets {
+			list = append(list, set...)
+		}
+		if hull := Hull(list...); len(hull.Outline) >= 3 {
+			return []Region{hull}, nil
+		}
+		return nil, nil
+	case *csg.Transform:
+		return combineChildren(Union, n.Children, mat.Mul(n.Matrix))
+	case *csg.Modifier:
+		if n.Op == `%` {
+			return nil, nil
+		}
+		return regions(n.Child, mat)
+	case *csg.Minkowski:
+		return nil, fmt.Errorf(`minkowski() is not supported`)
+	case *csg.Operation:
+		return nil, fmt.Errorf(`%s() is not supported`, n.Name)
+	case *csg.Square:
+		r = Square(n)
+	case *csg.Circle:
+		r = Circle(n)
+	case *csg.Polygon:
+		var err error
+		if r, err = Polygon(n); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf(`%T is not a 2D shape`, n)
+	}
+
+	if len(r.Outline) < 3 {
+		return nil, nil
+	}
+	if !mat.IsIdentity() {
+		r = r.Transform(mat)
+	}
+	return []Region{r}, nil
+}
+
+func childRegions(list []csg.Node, mat csg.Matrix) ([][]Region, error) {
+	var sets [][]Region
+	for _, child := range list {
+		set, err := regions(child, mat)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, set)
+	}
+	return sets, nil
+}
+
+func combineChildren(op func(...[]Region) []Region, list []csg.Node, mat csg.Matrix) ([]Region, error) {
+	sets, err := childRegions(list, mat)
+	if err != nil {
+		return nil, err
+	}
+	return op(sets...), nil
+}
@@ -0,0 +1,223 @@
+package mesh
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/lestrrat-go/openscad/csg"
+)
+
+// triangulate triangulates a polygon with holes by ear clipping. The
+// returned triangles are counter-clockwise, and refer to the points of
+// outline followed by the points of each hole, in order.
+func triangulate(outline []csg.Vec2, holes [][]csg.Vec2) ([][3]int, error) {
+	var points []csg.Vec2
+	ring := func(list []csg.Vec2, ccw bool) []int {
+		idx := make([]int, len(list))
+		for i, p := range list {
+			idx[i] = len(points)
+			points = append(points, p)
+		}
+		if (signedArea(list) > 0) != ccw {
+			for i, j := 0, len(idx)-1; i < j; i, j = i+1, j-1 {
+				idx[i], idx[j] = idx[j], idx[i]
+			}
+		}
+		return idx
+	}
+
+	poly := ring(outline, true)
+	var rings [][]int
+	for _, hole := range holes {
+		idx := ring(hole, false)
+		if len(idx) >= 3 {
+			rings = append(rings, idx)
+		}
+	}
+
+	// holes are joined to the outline from right to left, so that each
+	// bridge can not cross a hole that has not been joined yet
+	rightmost := func(idx []int) int {
+		best := 0
+		for i := range idx {
+			if points[idx[i]][0] > points[idx[best]][0] {
+				best = i
+			}
+		}
+		return best
+	}
+	sort.SliceStable(rings, func(i, j int) bool {
+		return points[rings[i][rightmost(rings[i])]][0] > points[rings[j][rightmost(rings[j])]][0]
+	})
+	for i, hole := range rings {
+		var err error
+		poly, err = bridge(points, poly, hole, rightmost(hole))
+		if err != nil {
+			return nil, fmt.Errorf(`failed to join hole %d: %w`, i, err)
+		}
+	}
+	return earClip(points, poly), nil
+}
+
+// bridge joins a clockwise hole to a counter-clockwise polygon, with a
+// pair of edges between the m-th point of the hole, which must be its
+// rightmost point, and a point of the polygon that it can see
+func bridge(points []csg.Vec2, poly, hole []int, m int) ([]int, error) {
+	hp := points[hole[m]]
+
+	// cast a ray from hp towards +X, and find the closest edge that it hits
+	best := -1
+	bestX := math.Inf(1)
+	for i := range poly {
+		a, b := points[poly[i]], points[poly[(i+1)%len(poly)]]
+		if (a[1] > hp[1]) == (b[1] > hp[1]) && a[1] != hp[1] && b[1] != hp[1] {
+			continue
+		}
+		var x float64
+		var candidate int
+		switch {
+		case a[1] == b[1]:
+			if a[1] != hp[1] {
+				continue
+			}
+			x = math.Min(a[0], b[0])
+			if x < hp[0] {
+				x = math.Max(a[0], b[0])
+			}
+			candidate = i
+			if b[0] == x {
+				candidate = i + 1
+			}
+		default:
+			x = a[0] + (hp[1]-a[1])*(b[0]-a[0])/(b[1]-a[1])
+			candidate = i
+			if b[0] > a[0] {
+				candidate = i + 1
+			}
+		}
+		if x >= hp[0] && x < bestX {
+			bestX = x
+			best = candidate % len(poly)
+		}
+	}
+	if best < 0 {
+		return nil, fmt.Errorf(`hole is not inside the outline`)
+	}
+
+	// the endpoint of the edge may be hidden behind other points of the
+	// polygon, in which case the one closest in angle to the ray is used
+	intersection := csg.Vec2{bestX, hp[1]}
+	p := points[poly[best]]
+	if p != intersection {
+		bestTan := math.Inf(1)
+		for i, idx := range poly {
+			q := points[idx]
+			if q[0] < hp[0] || !isReflex(points, poly, i) && i != best {
+				continue
+			}
+			if !insideTriangle(hp, intersection, p, q) && !insideTriangle(hp, p, intersection, q) {
+				continue
+			}
+			tan := math.Abs(q[1]-hp[1]) / (q[0] - hp[0])
+			if tan < bestTan || (tan == bestTan && q[0] < points[poly[best]][0]) {
+				bestTan = tan
+				best = i
+			}
+		}
+	}
+
+	ret := make([]int, 0, len(poly)+len(hole)+2)
+	ret = append(ret, poly[:best+1]...)
+	ret = append(ret, hole[m:]...)
+	ret = append(ret, hole[:m+1]...)
+	ret = append(ret, poly[best:]...)
+	return ret, nil
+}
+
+// earClip triangulates a simple counter-clockwise polygon, which may touch
+// itself at the bridges to its holes
+func earClip(points []csg.Vec2, poly []int) [][3]int {
+	idx := append([]int(nil), poly...)
+	var ret [][3]int
+	var start int
+	for len(idx) > 3 {
+		n := len(idx)
+		ear := -1
+		for k := 0; k < n; k++ {
+			i := (start + k) % n
+			if isEar(points, idx, i) {
+				ear = i
+				break
+			}
+		}
+
+		if ear < 0 {
+			// only degenerate corners are left, so clip the flattest one
+			// to make progress
+			smallest := math.Inf(1)
+			for i := range idx {
+				a, b, c := corner(points, idx, i)
+				if area := math.Abs(cross2(a, b, c)); area < smallest {
+					smallest = area
+					ear = i
+				}
+			}
+		}
+
+		a, b, c := idx[(ear+n-1)%n], idx[ear], idx[(ear+1)%n]
+		if cross2(points[a], points[b], points[c]) > 0 {
+			ret = append(ret, [3]int{a, b, c})
+		}
+		idx = append(idx[:ear], idx[ear+1:]...)
+		start = ear % len(idx)
+	}
+	if len(idx) == 3 && cross2(points[idx[0]], points[idx[1]], points[idx[2]]) > 0 {
+		ret = append(ret, [3]int{idx[0], idx[1], idx[2]})
+	}
+	return ret
+}
+
+func corner(points []csg.Vec2, idx []int, i int) (csg.Vec2, csg.Vec2, csg.Vec2) {
+	n := len(idx)
+	return points[idx[(i+n-1)%n]], points[idx[i]], points[idx[(i+1)%n]]
+}
+
+func isReflex(points []csg.Vec2, idx []int, i int) bool {
+	a, b, c := corner(points, idx, i)
+	return cross2(a, b, c) <= 0
+}
+
+// isEar reports whether the i-th corner of the polygon can be clipped: it
+// must be convex, and no other corner may be inside of it
+func isEar(points []csg.Vec2, idx []int, i int) bool {
+	a, b, c := corner(points, idx, i)
+	if cross2(a, b, c) <= 0 {
+		return false
+	}
+	n := len(idx)
+	for j := range idx {
+		if j == i || j == (i+n-1)%n || j == (i+1)%n {
+			continue
+		}
+		p := points[idx[j]]
+		if p == a || p == b || p == c || !isReflex(points, idx, j) {
+			continue
+		}
+		if insideTriangle(a, b, c, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// cross2 returns twice the signed area of the triangle abc
+func cross2(a, b, c csg.Vec2) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+// insideTriangle reports whether p is inside or on the boundary of the
+// counter-clockwise triangle abc
+func insideTriangle(a, b, c, p csg.Vec2) bool {
+	return cross2(a, b, p) >= 0 && cross2(b, c, p) >= 0 && cross2(c, a, p) >= 0
+}
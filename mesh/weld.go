@@ -0,0 +1,110 @@
+package mesh
+
+import (
+	"math"
+
+	"github.com/lestrrat-go/openscad/csg"
+)
+
+// Weld returns a copy of m in which vertices that are at most tolerance
+// apart are merged into the one that is used first by Triangles.
+// A tolerance of zero only merges vertices that are exactly the same.
+// Triangles that lose an edge as a result are dropped, and so are vertices
+// that are no longer used.
+func (m *Mesh) Weld(tolerance float64) *Mesh {
+	w := NewWelder(tolerance)
+	remap := make([]int, len(m.Vertices))
+	for i := range remap {
+		remap[i] = -1
+	}
+
+	ret := &Mesh{}
+	vertex := func(i int) int {
+		if remap[i] < 0 {
+			remap[i] = w.Add(m.Vertices[i])
+		}
+		return remap[i]
+	}
+	for _, t := range m.Triangles {
+		tri := [3]int{vertex(t[0]), vertex(t[1]), vertex(t[2])}
+		if tri[0] == tri[1] || tri[1] == tri[2] || tri[2] == tri[0] {
+			continue
+		}
+		ret.Triangles = append(ret.Triangles, tri)
+	}
+	ret.Vertices = w.Vertices()
+	return ret
+}
+
+// Welder builds a list of vertices in which vertices that are at most a
+// given distance apart are only stored once. It is used to build meshes
+// from formats such as STL, which do not share vertices between
+// triangles.
+type Welder struct {
+	tolerance float64
+	vertices  []csg.Vec3
+	exact     map[csg.Vec3]int
+	cells     map[[3]int64][]int
+}
+
+// NewWelder creates a Welder that merges vertices that are at most
+// tolerance apart. A tolerance of zero only merges vertices that are
+// exactly the same.
+func NewWelder(tolerance float64) *Welder {
+	w := &Welder{tolerance: tolerance}
+	if tolerance > 0 {
+		w.cells = make(map[[3]int64][]int)
+	} else {
+		w.exact = make(map[csg.Vec3]int)
+	}
+	return w
+}
+
+// Add returns the index of v in the list of vertices, adding it if there
+// is no vertex close enough to it yet. If there are several, the one that
+// was added first is used.
+func (w *Welder) Add(v csg.Vec3) int {
+	if w.cells == nil {
+		if i, ok := w.exact[v]; ok {
+			return i
+		}
+		w.exact[v] = len(w.vertices)
+		w.vertices = append(w.vertices, v)
+		return len(w.vertices) - 1
+	}
+
+	// vertices are stored in a grid of cells as large as the tolerance, so
+	// that the vertices that are close enough are in neighboring cells
+	cell := w.cell(v)
+	best := -1
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for dz := int64(-1); dz <= 1; dz++ {
+				for _, i := range w.cells[[3]int64{cell[0] + dx, cell[1] + dy, cell[2] + dz}] {
+					if (best < 0 || i < best) && length(sub(w.vertices[i], v)) <= w.tolerance {
+						best = i
+					}
+				}
+			}
+		}
+	}
+	if best >= 0 {
+		return best
+	}
+	w.cells[cell] = append(w.cells[cell], len(w.vertices))
+	w.vertices = append(w.vertices, v)
+	return len(w.vertices) - 1
+}
+
+func (w *Welder) cell(v csg.Vec3) [3]int64 {
+	return [3]int64{
+		int64(math.Floor(v[0] / w.tolerance)),
+		int64(math.Floor(v[1] / w.tolerance)),
+		int64(math.Floor(v[2] / w.tolerance)),
+	}
+}
+
+// Vertices returns the vertices that have been added so far
+func (w *Welder) Vertices() []csg.Vec3 {
+	return w.vertices
+}
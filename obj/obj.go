@@ -0,0 +1,137 @@
+// Package obj reads and writes meshes in the Wavefront OBJ format.
+//
+// Only the geometry is supported: vertices, and faces that refer to them.
+// Texture coordinates, normals, materials and groups are skipped when
+// reading.
+package obj
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/internal/number"
+	"github.com/lestrrat-go/openscad/mesh"
+)
+
+// Write writes m to w as an OBJ file, with one face per triangle. The
+// vertices of each face are in counter-clockwise order when seen from
+// outside. The output is written as it is encoded, without holding the
+// whole file in memory.
+func Write(w io.Writer, m *mesh.Mesh) error {
+	bw := bufio.NewWriter(w)
+	var buf []byte
+	for _, v := range m.Vertices {
+		buf = append(buf[:0], 'v')
+		for _, f := range v {
+			buf = append(buf, ' ')
+			buf = number.Append(buf, f)
+		}
+		buf = append(buf, '\n')
+		bw.Write(buf)
+	}
+	for _, t := range m.Triangles {
+		buf = append(buf[:0], 'f')
+		for _, idx := range t {
+			// indices are 1-based
+			buf = append(buf, ' ')
+			buf = strconv.AppendInt(buf, int64(idx+1), 10)
+		}
+		buf = append(buf, '\n')
+		bw.Write(buf)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf(`failed to write OBJ: %w`, err)
+	}
+	return nil
+}
+
+// Read reads an OBJ file into a mesh. Faces with more than three vertices
+// are split into triangles, which assumes that they are convex. Vertex
+// references may be negative, in which case they count back from the last
+// vertex that was read.
+func Read(r io.Reader) (*mesh.Mesh, error) {
+	m := &mesh.Mesh{}
+	s := bufio.NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	for line := 1; s.Scan(); line++ {
+		if err := readLine(m, s.Bytes()); err != nil {
+			return nil, fmt.Errorf(`failed to read OBJ: line %d: %w`, line, err)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf(`failed to read OBJ: %w`, err)
+	}
+	return m, nil
+}
+
+func readLine(m *mesh.Mesh, line []byte) error {
+	fields := splitFields(line)
+	if len(fields) == 0 {
+		return nil
+	}
+	switch fields[0] {
+	case `v`:
+		// a fourth coordinate (w) or a color may follow, and are ignored
+		if len(fields) < 4 {
+			return fmt.Errorf(`expected 3 coordinates, found %d`, len(fields)-1)
+		}
+		var v csg.Vec3
+		for i := range v {
+			f, err := strconv.ParseFloat(fields[i+1], 64)
+			if err != nil {
+				return fmt.Errorf(`failed to parse coordinate: %w`, err)
+			}
+			v[i] = f
+		}
+		m.Vertices = append(m.Vertices, v)
+	case `f`:
+		if len(fields) < 4 {
+			return fmt.Errorf(`expected at least 3 vertices, found %d`, len(fields)-1)
+		}
+		face := make([]int, len(fields)-1)
+		for i, field := range fields[1:] {
+			idx, err := vertexIndex(field, len(m.Vertices))
+			if err != nil {
+				return err
+			}
+			face[i] = idx
+		}
+		for i := 1; i+1 < len(face); i++ {
+			m.Triangles = append(m.Triangles, [3]int{face[0], face[i], face[i+1]})
+		}
+	}
+	return nil
+}
+
+// vertexIndex parses a reference to a vertex, which may be followed by
+// references to texture coordinates and normals, as in "1/2/3" or "1//3"
+func vertexIndex(field string, count int) (int, error) {
+	field, _, _ = strings.Cut(field, `/`)
+	idx, err := strconv.Atoi(field)
+	if err != nil {
+		return 0, fmt.Errorf(`failed to parse vertex reference: %w`, err)
+	}
+	if idx < 0 {
+		idx += count
+	} else {
+		idx--
+	}
+	if idx < 0 || idx >= count {
+		return 0, fmt.Errorf(`vertex %s does not exist`, field)
+	}
+	return idx, nil
+}
+
+// splitFields splits a line into fields separated by whitespace, up to a
+// comment
+func splitFields(line []byte) []string {
+	if i := bytes.IndexByte(line, '#'); i >= 0 {
+		line = line[:i]
+	}
+	return strings.Fields(string(line))
+}
@@ -0,0 +1,91 @@
+package obj_test
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/mesh"
+	"github.com/lestrrat-go/openscad/obj"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	m := &mesh.Mesh{
+		Vertices:  []csg.Vec3{{math.Copysign(0, -1), 0, 0}, {math.Nextafter(0.3, 1), 0, 0}, {0, 0.25, -1}},
+		Triangles: [][3]int{{0, 1, 2}},
+	}
+	var sb strings.Builder
+	require.NoError(t, obj.Write(&sb, m), `obj.Write should succeed`)
+	require.Equal(t, "v 0 0 0\nv 0.30000000000000004 0 0\nv 0 0.25 -1\nf 1 2 3\n", sb.String(), `indices should be 1-based and -0 written as 0`)
+
+	got, err := obj.Read(strings.NewReader(sb.String()))
+	require.NoError(t, err, `obj.Read should succeed`)
+	require.Equal(t, m.Vertices[1], got.Vertices[1], `coordinates should be read back exactly`)
+}
+
+func TestRead(t *testing.T) {
+	t.Run(`vertex references`, func(t *testing.T) {
+		m, err := obj.Read(strings.NewReader(`v 0 0 0
+v 1 0 0
+v 1 1 0
+v 0 1 0
+vt 0 0
+vn 0 0 1
+f 1/1 2/1/1 3//1
+f -4 -2 -1
+`))
+		require.NoError(t, err, `obj.Read should succeed`)
+		require.Equal(t, [][3]int{{0, 1, 2}, {0, 2, 3}}, m.Triangles, `texture and normal references should be skipped, and negative references count back`)
+	})
+	t.Run(`skipped statements`, func(t *testing.T) {
+		m, err := obj.Read(strings.NewReader(`# a square
+mtllib square.mtl
+o square
+g side
+usemtl red
+s off
+v 0 0 0 1
+v 1 0 0 1 0 0
+v 1 1 0 # top right
+v 0 1 0
+f 1 2 3 4
+`))
+		require.NoError(t, err, `obj.Read should succeed`)
+		require.Equal(t, []csg.Vec3{{0, 0, 0}, {1, 0, 0}, {1, 1, 0}, {0, 1, 0}}, m.Vertices, `extra coordinates should be ignored`)
+		require.Equal(t, [][3]int{{0, 1, 2}, {0, 2, 3}}, m.Triangles, `quad should be split in two`)
+	})
+	t.Run(`errors`, func(t *testing.T) {
+		testcases := []struct {
+			Name   string
+			Source string
+		}{
+			{Name: `index 0`, Source: "v 0 0 0\nv 1 0 0\nv 0 1 0\nf 0 1 2\n"},
+			{Name: `unknown vertex`, Source: "v 0 0 0\nf 1 2 3\n"},
+			{Name: `negative reference before the first vertex`, Source: "v 0 0 0\nv 1 0 0\nv 0 1 0\nf -4 1 2\n"},
+			{Name: `vertex defined after the face`, Source: "v 0 0 0\nv 1 0 0\nf 1 2 3\nv 0 1 0\n"},
+			{Name: `too few coordinates`, Source: "v 0 0\n"},
+			{Name: `too few vertices`, Source: "v 0 0 0\nv 1 0 0\nf 1 2\n"},
+			{Name: `malformed coordinate`, Source: "v 0 zero 0\n"},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, err := obj.Read(strings.NewReader(tc.Source))
+				require.Error(t, err, `obj.Read should fail`)
+			})
+		}
+	})
+}
+
+func TestRoundTrip(t *testing.T) {
+	m := mesh.Cylinder(&csg.Cylinder{H: 2, R1: 1, R2: 0.5, Center: true, Fragments: csg.Fragments{Fn: 7}})
+	var buf bytes.Buffer
+	require.NoError(t, obj.Write(&buf, m), `obj.Write should succeed`)
+
+	got, err := obj.Read(&buf)
+	require.NoError(t, err, `obj.Read should succeed`)
+	require.Equal(t, m, got, `mesh should be read back exactly`)
+}
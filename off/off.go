@@ -0,0 +1,168 @@
+// Package off reads and writes meshes in the Object File Format (OFF),
+// which lists the vertices of a mesh followed by its faces.
+package off
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/internal/number"
+	"github.com/lestrrat-go/openscad/mesh"
+)
+
+// Write writes m to w as an OFF file, with one face per triangle. The
+// vertices of each face are in counter-clockwise order when seen from
+// outside, as in the OFF files that OpenSCAD exports. The output is
+// written as it is encoded, without holding the whole file in memory.
+func Write(w io.Writer, m *mesh.Mesh) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "OFF\n%d %d 0\n", len(m.Vertices), len(m.Triangles))
+
+	var buf []byte
+	for _, v := range m.Vertices {
+		buf = buf[:0]
+		for i, f := range v {
+			if i > 0 {
+				buf = append(buf, ' ')
+			}
+			buf = number.Append(buf, f)
+		}
+		buf = append(buf, '\n')
+		bw.Write(buf)
+	}
+	for _, t := range m.Triangles {
+		buf = append(buf[:0], '3')
+		for _, idx := range t {
+			buf = append(buf, ' ')
+			buf = strconv.AppendInt(buf, int64(idx), 10)
+		}
+		buf = append(buf, '\n')
+		bw.Write(buf)
+	}
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf(`failed to write OFF: %w`, err)
+	}
+	return nil
+}
+
+// Read reads an OFF file into a mesh. Faces with more than three vertices
+// are split into triangles, which assumes that they are convex. Colors
+// that follow the vertices of a face are ignored.
+func Read(r io.Reader) (*mesh.Mesh, error) {
+	m, err := read(r)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read OFF: %w`, err)
+	}
+	return m, nil
+}
+
+func read(r io.Reader) (*mesh.Mesh, error) {
+	l := &lines{Scanner: bufio.NewScanner(r)}
+	l.Buffer(nil, 1<<20)
+
+	fields, err := l.next()
+	if err != nil {
+		return nil, err
+	}
+	if fields[0] != `OFF` {
+		return nil, fmt.Errorf(`line %d: expected "OFF", found %q`, l.line, fields[0])
+	}
+	// the counts may be on the same line as the keyword
+	fields = fields[1:]
+	if len(fields) == 0 {
+		if fields, err = l.next(); err != nil {
+			return nil, err
+		}
+	}
+	if len(fields) < 2 {
+		return nil, fmt.Errorf(`line %d: expected the number of vertices and faces`, l.line)
+	}
+	vertexCount, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf(`line %d: failed to parse number of vertices: %w`, l.line, err)
+	}
+	faceCount, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf(`line %d: failed to parse number of faces: %w`, l.line, err)
+	}
+	if vertexCount < 0 || faceCount < 0 {
+		return nil, fmt.Errorf(`line %d: the number of vertices and faces can not be negative`, l.line)
+	}
+
+	m := &mesh.Mesh{}
+	for i := 0; i < vertexCount; i++ {
+		fields, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf(`line %d: expected 3 coordinates, found %d`, l.line, len(fields))
+		}
+		var v csg.Vec3
+		for j := range v {
+			f, err := strconv.ParseFloat(fields[j], 64)
+			if err != nil {
+				return nil, fmt.Errorf(`line %d: failed to parse coordinate: %w`, l.line, err)
+			}
+			v[j] = f
+		}
+		m.Vertices = append(m.Vertices, v)
+	}
+
+	for i := 0; i < faceCount; i++ {
+		fields, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf(`line %d: failed to parse number of vertices: %w`, l.line, err)
+		}
+		if n < 3 || len(fields) < n+1 {
+			return nil, fmt.Errorf(`line %d: expected at least 3 vertices`, l.line)
+		}
+		face := make([]int, n)
+		for j := range face {
+			idx, err := strconv.Atoi(fields[j+1])
+			if err != nil {
+				return nil, fmt.Errorf(`line %d: failed to parse vertex index: %w`, l.line, err)
+			}
+			if idx < 0 || idx >= len(m.Vertices) {
+				return nil, fmt.Errorf(`line %d: vertex %d does not exist`, l.line, idx)
+			}
+			face[j] = idx
+		}
+		for j := 1; j+1 < len(face); j++ {
+			m.Triangles = append(m.Triangles, [3]int{face[0], face[j], face[j+1]})
+		}
+	}
+	return m, nil
+}
+
+// lines reads the lines of a file that are not empty or comments
+type lines struct {
+	*bufio.Scanner
+	line int
+}
+
+func (l *lines) next() ([]string, error) {
+	for l.Scan() {
+		l.line++
+		line := l.Bytes()
+		if i := bytes.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if fields := strings.Fields(string(line)); len(fields) > 0 {
+			return fields, nil
+		}
+	}
+	if err := l.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.ErrUnexpectedEOF
+}
@@ -0,0 +1,89 @@
+package off_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/mesh"
+	"github.com/lestrrat-go/openscad/off"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	t.Run(`header and indices`, func(t *testing.T) {
+		m := &mesh.Mesh{
+			Vertices:  []csg.Vec3{{math.Copysign(0, -1), 0, 0}, {1, 0, 0}, {0, 0.25, -1}},
+			Triangles: [][3]int{{0, 1, 2}},
+		}
+		var sb strings.Builder
+		require.NoError(t, off.Write(&sb, m), `off.Write should succeed`)
+		require.Equal(t, "OFF\n3 1 0\n0 0 0\n1 0 0\n0 0.25 -1\n3 0 1 2\n", sb.String(), `indices should be 0-based and -0 written as 0`)
+	})
+	t.Run(`empty mesh`, func(t *testing.T) {
+		var sb strings.Builder
+		require.NoError(t, off.Write(&sb, &mesh.Mesh{}), `off.Write should succeed`)
+		require.Equal(t, "OFF\n0 0 0\n", sb.String(), `output should match`)
+
+		m, err := off.Read(strings.NewReader(sb.String()))
+		require.NoError(t, err, `off.Read should succeed`)
+		require.Empty(t, m.Vertices, `mesh should not have vertices`)
+		require.Empty(t, m.Triangles, `mesh should not have triangles`)
+	})
+	t.Run(`round trip`, func(t *testing.T) {
+		m := mesh.Sphere(&csg.Sphere{R: 3, Fragments: csg.Fragments{Fn: 9}})
+		var sb strings.Builder
+		require.NoError(t, off.Write(&sb, m), `off.Write should succeed`)
+
+		got, err := off.Read(strings.NewReader(sb.String()))
+		require.NoError(t, err, `off.Read should succeed`)
+		require.Equal(t, m, got, `mesh should be read back exactly`)
+	})
+}
+
+func TestRead(t *testing.T) {
+	t.Run(`counts on the keyword line`, func(t *testing.T) {
+		m, err := off.Read(strings.NewReader(`OFF 4 1 0
+# a square
+0 0 0
+1 0 0
+1 1 0
+
+0 1 0
+4 0 1 2 3
+`))
+		require.NoError(t, err, `off.Read should succeed`)
+		require.Equal(t, [][3]int{{0, 1, 2}, {0, 2, 3}}, m.Triangles, `quad should be split in two`)
+	})
+	t.Run(`face colors`, func(t *testing.T) {
+		m, err := off.Read(strings.NewReader("OFF\n3 2 0\n0 0 0\n1 0 0\n0 1 0\n3 0 1 2 255 0 0\n3 2 1 0 0.5 0.5 0.5 1\n"))
+		require.NoError(t, err, `off.Read should succeed`)
+		require.Equal(t, [][3]int{{0, 1, 2}, {2, 1, 0}}, m.Triangles, `colors should be ignored`)
+	})
+	t.Run(`errors`, func(t *testing.T) {
+		testcases := []struct {
+			Name   string
+			Source string
+		}{
+			{Name: `missing keyword`, Source: "3 1 0\n0 0 0\n1 0 0\n0 1 0\n3 0 1 2\n"},
+			{Name: `missing counts`, Source: "OFF\n3\n"},
+			{Name: `negative vertex count`, Source: "OFF\n-1 0 0\n"},
+			{Name: `negative face count`, Source: "OFF\n0 -1 0\n"},
+			{Name: `negative counts`, Source: "OFF\n-1 -1 0\n"},
+			{Name: `truncated vertices`, Source: "OFF\n3 1 0\n0 0 0\n1 0 0\n"},
+			{Name: `truncated faces`, Source: "OFF\n3 2 0\n0 0 0\n1 0 0\n0 1 0\n3 0 1 2\n"},
+			{Name: `too few vertices in a face`, Source: "OFF\n3 1 0\n0 0 0\n1 0 0\n0 1 0\n2 0 1\n"},
+			{Name: `fewer indices than declared`, Source: "OFF\n3 1 0\n0 0 0\n1 0 0\n0 1 0\n4 0 1 2\n"},
+			{Name: `unknown vertex`, Source: "OFF\n3 1 0\n0 0 0\n1 0 0\n0 1 0\n3 0 1 3\n"},
+			{Name: `negative vertex`, Source: "OFF\n3 1 0\n0 0 0\n1 0 0\n0 1 0\n3 0 1 -1\n"},
+		}
+		for _, tc := range testcases {
+			tc := tc
+			t.Run(tc.Name, func(t *testing.T) {
+				_, err := off.Read(strings.NewReader(tc.Source))
+				require.Error(t, err, `off.Read should fail`)
+			})
+		}
+	})
+}
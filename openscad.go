@@ -42,11 +42,16 @@ func RegisterFile(filename string, options ...RegisterFileOption) error {
 func ParseFile(filename string, options ...ParseFileOption) (ast.Stmt, error) {
 	srcfs := os.DirFS(".")
 
+	var parseOptions []ParseOption
 	//nolint:forcetypeassert
 	for _, option := range options {
 		switch option.Ident() {
 		case optFSKey{}:
 			srcfs = option.Value().(fs.FS)
+		default:
+			if po, ok := option.(ParseOption); ok {
+				parseOptions = append(parseOptions, po)
+			}
 		}
 	}
 
@@ -55,7 +60,7 @@ func ParseFile(filename string, options ...ParseFileOption) (ast.Stmt, error) {
 		return nil, fmt.Errorf("failed to read %q: %w", filename, err)
 	}
 
-	stmts, err := Parse(code)
+	stmts, err := Parse(code, parseOptions...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse %q: %w", filename, err)
 	}
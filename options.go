@@ -2,12 +2,55 @@ package openscad
 
 import (
 	"io/fs"
+	"time"
 
+	"github.com/lestrrat-go/openscad/ast"
 	"github.com/lestrrat-go/option"
 )
 
 type optLookupNameKey struct{}
 type optFSKey struct{}
+type optPositionsKey struct{}
+type optSearchPathsKey struct{}
+type optRegistryKey struct{}
+type optPollIntervalKey struct{}
+type optEmitOptionsKey struct{}
+type optErrorHandlerKey struct{}
+type optUpdateHandlerKey struct{}
+
+// ParseOption is an option that can be passed to Parse(), ParseFile(),
+// RegisterFile() and RegisterTree()
+type ParseOption interface {
+	parseOption()
+	ParseFileOption
+	RegisterTreeOption
+}
+
+// RegisterTreeOption is an option that can be passed to RegisterTree()
+// and Watch()
+type RegisterTreeOption interface {
+	registerTreeOption()
+	WatchOption
+}
+
+type registerTreeOption struct {
+	option.Interface
+}
+
+func (registerTreeOption) registerTreeOption() {}
+func (registerTreeOption) watchOption()        {}
+
+// WatchOption is an option that can be passed to Watch()
+type WatchOption interface {
+	watchOption()
+	option.Interface
+}
+
+type watchOption struct {
+	option.Interface
+}
+
+func (watchOption) watchOption() {}
 
 type ParseFileOption interface {
 	parseFileOption()
@@ -33,6 +76,16 @@ func (registerFileOption) registerFileOption() {}
 func (parseFileOption) parseFileOption()    {}
 func (parseFileOption) registerFileOption() {}
 
+type parseOption struct {
+	option.Interface
+}
+
+func (parseOption) parseOption()        {}
+func (parseOption) parseFileOption()    {}
+func (parseOption) registerFileOption() {}
+func (parseOption) registerTreeOption() {}
+func (parseOption) watchOption()        {}
+
 func WithLookupName(name string) RegisterFileOption {
 	return &registerFileOption{option.New(optLookupNameKey{}, name)}
 }
@@ -40,3 +93,54 @@ func WithLookupName(name string) RegisterFileOption {
 func WithFS(src fs.FS) ParseFileOption {
 	return &parseFileOption{option.New(optFSKey{}, src)}
 }
+
+// WithPositions makes the parser record the location of each statement
+// (as well as variable references and function calls) in the source code.
+// The locations can be retrieved via the Pos() method of the nodes, and are
+// used to generate source maps (see ast.WithSourceMap).
+//
+// Positions are not recorded by default, so that parsed trees can be
+// compared against trees that are constructed programmatically.
+func WithPositions() ParseOption {
+	return &parseOption{option.New(optPositionsKey{}, true)}
+}
+
+// WithSearchPaths specifies the directories that RegisterTree() looks in
+// for files that can not be found relative to the file that includes or
+// uses them, in the order given. This is the equivalent of OPENSCADPATH.
+func WithSearchPaths(paths ...string) RegisterTreeOption {
+	return &registerTreeOption{option.New(optSearchPathsKey{}, paths)}
+}
+
+// WithRegistry specifies the registry that RegisterTree() registers
+// files to. By default the global registry is used.
+func WithRegistry(r *ast.Registry) RegisterTreeOption {
+	return &registerTreeOption{option.New(optRegistryKey{}, r)}
+}
+
+// WithPollInterval specifies how often Watch() checks the files for
+// changes. The default is 500ms, and the interval must be positive.
+func WithPollInterval(d time.Duration) WatchOption {
+	return &watchOption{option.New(optPollIntervalKey{}, d)}
+}
+
+// WithEmitOptions specifies the options that Watch() uses when emitting
+// the amalgamated code, in addition to ast.WithAmalgamation() and
+// ast.WithRegistry().
+func WithEmitOptions(options ...ast.EmitFileOption) WatchOption {
+	return &watchOption{option.New(optEmitOptionsKey{}, options)}
+}
+
+// WithErrorHandler specifies a function that Watch() calls when the files
+// can not be read, parsed, or amalgamated. Watch() keeps going after
+// reporting the error, and an error that keeps happening is only reported
+// once. By default errors are ignored.
+func WithErrorHandler(fn func(error)) WatchOption {
+	return &watchOption{option.New(optErrorHandlerKey{}, fn)}
+}
+
+// WithUpdateHandler specifies a function that Watch() calls every time
+// the output has been written.
+func WithUpdateHandler(fn func()) WatchOption {
+	return &watchOption{option.New(optUpdateHandlerKey{}, fn)}
+}
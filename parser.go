@@ -8,9 +8,28 @@ import (
 )
 
 type parser struct {
-	ch      chan *Token
-	peeked  []*Token
-	readPos int
+	ch        chan *Token
+	peeked    []*Token
+	readPos   int
+	positions bool
+	last      *Token // the last token returned by Peek
+}
+
+// ParseError is the error returned by Parse when the source code can not
+// be parsed. Line and Column point to the token that the parser was
+// looking at when it gave up, and start at 1.
+type ParseError struct {
+	Line   int
+	Column int
+	err    error
+}
+
+func (e *ParseError) Error() string {
+	return `failed to parse: ` + e.err.Error()
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.err
 }
 
 // Parse parses an OpenSCAD source code, and turns it into an internal
@@ -18,18 +37,39 @@ type parser struct {
 // afterwrads, programmatically.
 //
 // Currently comments are out of scope of this implementation.
-func Parse(src []byte) (ast.Stmts, error) {
+func Parse(src []byte, options ...ParseOption) (ast.Stmts, error) {
+	var positions bool
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case optPositionsKey{}:
+			positions = option.Value().(bool)
+		}
+	}
+
 	ch := make(chan *Token, 1)
 
 	go Lex(ch, src)
 
 	p := &parser{
-		ch:      ch,
-		readPos: -1,
+		ch:        ch,
+		readPos:   -1,
+		positions: positions,
 	}
 	stmts, err := p.handleStatements()
+
+	// let the lexer run to completion, so that it does not block forever
+	// when the parser bails out early
+	for range ch {
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf(`failed to parse: %w`, err)
+		perr := &ParseError{err: err}
+		if p.last != nil {
+			perr.Line = p.last.Line
+			perr.Column = p.last.Column
+		}
+		return nil, perr
 	}
 
 	return stmts, nil
@@ -45,21 +85,27 @@ func (p *parser) handleStatement() (ast.Stmt, error) {
 		if err != nil {
 			return nil, err
 		}
-		return ast.NewUnaryOp("%", stmt), nil
+		modifier := ast.NewUnaryOp("%", stmt)
+		p.mark(modifier, tok)
+		return modifier, nil
 	case Sharp:
 		p.Advance()
 		stmt, err := p.handleStatement()
 		if err != nil {
 			return nil, err
 		}
-		return ast.NewUnaryOp("#", stmt), nil
+		modifier := ast.NewUnaryOp("#", stmt)
+		p.mark(modifier, tok)
+		return modifier, nil
 	case Asterisk:
 		p.Advance()
 		stmt, err := p.handleStatement()
 		if err != nil {
 			return nil, err
 		}
-		return ast.NewUnaryOp("*", stmt), nil
+		modifier := ast.NewUnaryOp("*", stmt)
+		p.mark(modifier, tok)
+		return modifier, nil
 	default:
 		p.Unread()
 	}
@@ -144,6 +190,14 @@ func (p *parser) handleStatements() (ast.Stmts, error) {
 	}
 }
 
+// mark records the location of tok as the location of node, if
+// the parser was asked to record positions
+func (p *parser) mark(node ast.Positioner, tok *Token) {
+	if p.positions {
+		node.SetPos(ast.Position{Line: tok.Line, Column: tok.Column})
+	}
+}
+
 // Peek obtains the next token, but retains it in a buffer so that we can backtrack it.
 // If we peek and then unread, we would be peeking the previously read, cached token
 func (p *parser) Peek() *Token {
@@ -151,12 +205,18 @@ func (p *parser) Peek() *Token {
 	if len(p.peeked)-1 == p.readPos {
 		tok := <-p.ch
 		if tok == nil {
-			return nil
+			// the lexer is done: keep returning EOF
+			tok = &Token{Type: EOF}
+			if p.last != nil {
+				tok.Line = p.last.Line
+				tok.Column = p.last.Column
+			}
 		}
 		p.peeked = append(p.peeked, tok)
 	}
 	p.readPos++
-	return p.peeked[p.readPos]
+	p.last = p.peeked[p.readPos]
+	return p.last
 }
 
 // Advance is akin to committing the previously peeked reads, effectively
@@ -180,11 +240,8 @@ func (p *parser) Unread() {
 
 func (p *parser) Next() *Token {
 	tok := p.Peek()
-	if tok != nil {
-		p.Advance()
-		return tok
-	}
-	panic("failed to peek")
+	p.Advance()
+	return tok
 }
 
 func (p *parser) handleModule() (*ast.Module, error) {
@@ -194,9 +251,11 @@ func (p *parser) handleModule() (*ast.Module, error) {
 		return nil, fmt.Errorf(`expected module`)
 	}
 
+	start := tok
 	tok = p.Next()
 	moduleName := tok.Value
 	module := ast.NewModule(moduleName)
+	p.mark(module, start)
 
 	params, err := p.handleParameterList()
 	if err != nil {
@@ -255,6 +314,7 @@ func (p *parser) handleParamDecl() (*ast.Variable, error) {
 	name := tok.Value
 
 	v := ast.NewVariable(name)
+	p.mark(v, tok)
 
 	tok = p.Peek()
 	if tok.Type != Equal {
@@ -302,6 +362,7 @@ func (p *parser) handleAssignment() (*ast.Variable, error) {
 	}
 	varName := tok.Value
 	v := ast.NewVariable(varName)
+	p.mark(v, tok)
 
 	tok = p.Next()
 	if tok.Type != Equal {
@@ -325,6 +386,7 @@ func (p *parser) handleCall() (*ast.Call, bool, error) {
 
 	callName := tok.Value
 	call := ast.NewCall(callName)
+	p.mark(call, tok)
 
 	tok = p.Next()
 	if tok.Type != OpenParen {
@@ -418,7 +480,86 @@ func (p *parser) handleParenExpr() (ret interface{}, reterr error) {
 	return ast.NewGroup(expr), nil
 }
 
+// binaryOperators maps tokens to the binary operators that they denote
+var binaryOperators = map[int]string{
+	Or:               "||",
+	And:              "&&",
+	Equality:         "==",
+	NotEqual:         "!=",
+	LessThan:         "<",
+	LessThanEqual:    "<=",
+	GreaterThan:      ">",
+	GreaterThanEqual: ">=",
+	Plus:             "+",
+	Minus:            "-",
+	Asterisk:         "*",
+	Slash:            "/",
+	Percent:          "%",
+	Caret:            "^",
+}
+
+// exponentPrecedence is how tightly `^` binds: tighter than unary
+// operators, so that `-2 ^ 2` is `-(2 ^ 2)`
+var exponentPrecedence = ast.NewBinaryOp("^", nil, nil).BindPrecedence()
+
 func (p *parser) handleExpr() (ret interface{}, reterr error) {
+	expr, err := p.handleBinaryExpr(1)
+	if err != nil {
+		return nil, err
+	}
+
+	// the ternary operator binds loosest of all
+	tok := p.Peek()
+	p.Unread()
+	if tok.Type != Question {
+		return expr, nil
+	}
+	ternary, err := p.handleTernary(expr)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse ternary expression: %w`, err)
+	}
+	return ternary, nil
+}
+
+// handleBinaryExpr parses operands joined by binary operators that bind
+// at least as tightly as minPrecedence. Operators that bind equally
+// tightly are grouped from the left, so that `1 - 2 - 3` is parsed as
+// `(1 - 2) - 3`, except for `^`, which is grouped from the right, as
+// OpenSCAD does.
+func (p *parser) handleBinaryExpr(minPrecedence int) (interface{}, error) {
+	left, err := p.handleOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		tok := p.Peek()
+		op, ok := binaryOperators[tok.Type]
+		if !ok {
+			p.Unread()
+			return left, nil
+		}
+		precedence := ast.NewBinaryOp(op, nil, nil).BindPrecedence()
+		if precedence < minPrecedence {
+			p.Unread()
+			return left, nil
+		}
+
+		next := precedence + 1
+		if op == "^" {
+			next = precedence
+		}
+		right, err := p.handleBinaryExpr(next)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to parse right hand expression of '%s': %w`, op, err)
+		}
+		left = ast.NewBinaryOp(op, left, right)
+	}
+}
+
+// handleOperand parses an operand of a binary operator, along with the
+// unary operators before it and the indices after it
+func (p *parser) handleOperand() (interface{}, error) {
 	var expr interface{}
 
 	tok := p.Next()
@@ -438,6 +579,12 @@ func (p *parser) handleExpr() (ret interface{}, reterr error) {
 				return nil, fmt.Errorf(`failed to parse expression: %w`, err)
 			}
 			expr = fe
+		case "function":
+			fl, err := p.handleFunctionLiteral()
+			if err != nil {
+				return nil, fmt.Errorf(`failed to parse function literal: %w`, err)
+			}
+			expr = fl
 		case "if":
 			ie, err := p.handleIfExpr()
 			if err != nil {
@@ -454,13 +601,13 @@ func (p *parser) handleExpr() (ret interface{}, reterr error) {
 			return nil, fmt.Errorf(`failed to parse parenthesized expression: %w`, err)
 		}
 		expr = pe
-	case Minus, Exclamation:
-		p.Unread()
-		um, err := p.handleUnary(tok)
+	case Minus, Plus, Exclamation:
+		// unary operators bind tighter than any binary operator but `^`
+		operand, err := p.handleBinaryExpr(exponentPrecedence)
 		if err != nil {
-			return nil, fmt.Errorf(`failed to parse unary operator: %w`, err)
+			return nil, fmt.Errorf(`failed to parse operand of unary '%s': %w`, tok.Value, err)
 		}
-		expr = um
+		return ast.NewUnaryOp(tok.Value, operand), nil
 	case Literal:
 		expr = tok.Value
 	case Numeric:
@@ -470,13 +617,24 @@ func (p *parser) handleExpr() (ret interface{}, reterr error) {
 		}
 		expr = f
 	case Ident:
+		if tok.Value == "each" {
+			// each is only allowed in list comprehensions, and extends
+			// as far as possible
+			each, err := p.handleExpr()
+			if err != nil {
+				return nil, fmt.Errorf(`failed to parse each expression: %w`, err)
+			}
+			return ast.NewEachExpr(each), nil
+		}
 		p.Unread()
 		// could be a function call, or just a variable
 		stmt, _, err := p.handleAssignmentOrFunctionCall(true)
 		if err == nil {
 			expr = stmt
 		} else {
-			expr = ast.NewVariable(tok.Value)
+			v := ast.NewVariable(tok.Value)
+			p.mark(v, tok)
+			expr = v
 		}
 	case OpenBracket:
 		// This is a list or a loop range
@@ -490,12 +648,14 @@ func (p *parser) handleExpr() (ret interface{}, reterr error) {
 		return nil, fmt.Errorf("unhandled expr %#v", tok)
 	}
 
-	if op, err := p.tryOperator(expr); op != nil && err == nil {
-		expr = op
-	}
-
-	if expr == nil {
-		panic(`nil expr!`)
+	tok = p.Peek()
+	p.Unread()
+	if tok.Type == OpenBracket {
+		index, err := p.handleIndex(expr)
+		if err != nil {
+			return nil, fmt.Errorf(`failed to parse index operator: %w`, err)
+		}
+		expr = index
 	}
 	return expr, nil
 }
@@ -530,6 +690,7 @@ func (p *parser) handleAssignmentOrFunctionCall(eval bool) (ast.Stmt, bool, erro
 		return nil, false, fmt.Errorf(`expected ident, got %q`, tok.Value)
 	}
 	name := tok.Value
+	nameTok := tok
 
 	tok = p.Peek()
 	switch tok.Type {
@@ -554,7 +715,9 @@ func (p *parser) handleAssignmentOrFunctionCall(eval bool) (ast.Stmt, bool, erro
 		if eval {
 			// if we're in the middle of some expression to evaluate the value of a var,
 			// we can have a standalone ident
-			return ast.NewVariable(name), false, nil
+			v := ast.NewVariable(name)
+			p.mark(v, nameTok)
+			return v, false, nil
 		}
 		return nil, false, fmt.Errorf(`expected assignment or function call, got %q after ident`, tok.Value)
 	}
@@ -598,6 +761,7 @@ func (p *parser) handleFunction() (*ast.Function, error) {
 	if tok.Type != Keyword && tok.Value != "function" {
 		return nil, fmt.Errorf(`expected function, got %q`, tok.Value)
 	}
+	start := tok
 
 	tok = p.Next()
 	if tok.Type != Ident {
@@ -606,8 +770,52 @@ func (p *parser) handleFunction() (*ast.Function, error) {
 
 	name := tok.Value
 	fn := ast.NewFunction(name)
+	p.mark(fn, start)
+
+	params, err := p.handleFunctionParameters()
+	if err != nil {
+		return nil, err
+	}
+	fn.Parameters(params...)
 
 	tok = p.Next()
+	if tok.Type != Equal {
+		return nil, fmt.Errorf(`expected equal, got %q`, tok.Value)
+	}
+
+	expr, err := p.handleExpr()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse function expression: %w`, err)
+	}
+
+	fn.Body(expr)
+	return fn, nil
+}
+
+// handleFunctionLiteral parses an anonymous function such as
+// `function(x) x * 2`
+func (p *parser) handleFunctionLiteral() (*ast.FunctionLiteral, error) {
+	tok := p.Next()
+	if tok.Type != Keyword || tok.Value != "function" {
+		return nil, fmt.Errorf(`expected function, got %q`, tok.Value)
+	}
+
+	params, err := p.handleFunctionParameters()
+	if err != nil {
+		return nil, err
+	}
+
+	expr, err := p.handleExpr()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse function literal expression: %w`, err)
+	}
+	return ast.NewFunctionLiteral(params...).Body(expr), nil
+}
+
+// handleFunctionParameters parses the parenthesized parameter list of
+// a function
+func (p *parser) handleFunctionParameters() ([]*ast.Variable, error) {
+	tok := p.Next()
 	if tok.Type != OpenParen {
 		return nil, fmt.Errorf(`expected open paren, got %q`, tok.Value)
 	}
@@ -636,7 +844,6 @@ func (p *parser) handleFunction() (*ast.Function, error) {
 		}
 	}
 
-	// TODO for functions, parameters need to be *Variable
 	vparams := make([]*ast.Variable, len(parameters))
 	for i, p := range parameters {
 		switch v := p.(type) {
@@ -646,143 +853,7 @@ func (p *parser) handleFunction() (*ast.Function, error) {
 			return nil, fmt.Errorf(`expected variable in function parameter, got %T`, p)
 		}
 	}
-
-	fn.Parameters(vparams...)
-
-	tok = p.Next()
-	if tok.Type != Equal {
-		return nil, fmt.Errorf(`expected equal, got %q`, tok.Value)
-	}
-
-	expr, err := p.handleExpr()
-	if err != nil {
-		return nil, fmt.Errorf(`failed to parse function expression: %w`, err)
-	}
-
-	fn.Body(expr)
-	return fn, nil
-}
-
-func (p *parser) mungeOperatorPrecedence(expr interface{}) interface{} {
-	bop, ok := expr.(*ast.BinaryOp)
-	if !ok {
-		return expr
-	}
-
-	switch rop := bop.Right().(type) {
-	case *ast.BinaryOp:
-		return bop.Rearrange(rop)
-	case *ast.TernaryOp:
-		// Take the condition of the ternary op, and make it the right hand side
-		return ast.NewTernaryOp(
-			ast.NewBinaryOp(bop.Op(), bop.Left(), rop.Condition()),
-			rop.TrueExpr(),
-			rop.FalseExpr(),
-		)
-	default:
-		return expr
-	}
-
-}
-
-func (p *parser) tryOperator(left interface{}) (interface{}, error) {
-	tok := p.Peek()
-	var ret interface{}
-	switch tok.Type {
-	case And:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '&&': %w`, err)
-		}
-		ret = ast.NewBinaryOp("&&", left, expr)
-	case Question:
-		p.Unread()
-		ternary, err := p.handleTernary(left)
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse ternary expression: %w`, err)
-		}
-		ret = ternary
-	case Equality:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '==': %w`, err)
-		}
-		ret = ast.NewBinaryOp("==", left, expr)
-	case LessThan:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '<': %w`, err)
-		}
-		ret = ast.NewBinaryOp("<", left, expr)
-	case LessThanEqual:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '<=': %w`, err)
-		}
-		ret = ast.NewBinaryOp("<=", left, expr)
-	case GreaterThan:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '>': %w`, err)
-		}
-		ret = ast.NewBinaryOp(">", left, expr)
-	case GreaterThanEqual:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '>=': %w`, err)
-		}
-		ret = ast.NewBinaryOp(">=", left, expr)
-	case Asterisk:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '*': %w`, err)
-		}
-		ret = ast.NewBinaryOp("*", left, expr)
-	case Slash:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '/': %w`, err)
-		}
-		ret = ast.NewBinaryOp("/", left, expr)
-	case Plus:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '+': %w`, err)
-		}
-		ret = ast.NewBinaryOp("+", left, expr)
-	case Minus:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '-': %w`, err)
-		}
-		ret = ast.NewBinaryOp("-", left, expr)
-	case Percent:
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse right hand expression of '%%': %w`, err)
-		}
-		ret = ast.NewBinaryOp("%", left, expr)
-	case OpenBracket:
-		p.Unread()
-		index, err := p.handleIndex(left)
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse index operator: %w`, err)
-		}
-		// ONLY in the case of an index operator, try one more time to
-		// match the next operator
-		nextop, err := p.tryOperator(index)
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse next operator after index: %w`, err)
-		}
-		ret = nextop
-	default:
-		p.Unread()
-		return left, nil
-	}
-
-	ret = p.mungeOperatorPrecedence(ret)
-
-	return ret, nil
+	return vparams, nil
 }
 
 func (p *parser) handleIndex(left interface{}) (interface{}, error) {
@@ -827,13 +898,17 @@ func (p *parser) handleForExpr() (*ast.ForExpr, error) {
 }
 
 func (p *parser) handleForBlock() (*ast.ForBlock, error) {
+	start := p.Peek()
+	p.Unread()
+
 	loopVars, err := p.handleForPreamble()
 	if err != nil {
 		return nil, fmt.Errorf(`failed to parse for loop preamble: %w`, err)
 	}
 
 	forStmt := ast.NewFor(loopVars)
-	stmts, err := p.handleBlock()
+	p.mark(forStmt, start)
+	stmts, err := p.handleChildBlock()
 	if err != nil {
 		return nil, fmt.Errorf(`failed to parse for block: %w`, err)
 	}
@@ -956,6 +1031,7 @@ func (p *parser) handleForLoopVariable() (*ast.LoopVar, error) {
 	}
 
 	variable := ast.NewVariable(tok.Value)
+	p.mark(variable, tok)
 
 	tok = p.Next()
 	if tok.Type != Equal {
@@ -992,12 +1068,16 @@ func (p *parser) handleLetExpr() (*ast.LetExpr, error) {
 }
 
 func (p *parser) handleLetBlock() (*ast.LetBlock, error) {
+	start := p.Peek()
+	p.Unread()
+
 	vars, err := p.handleLetPreamble()
 	if err != nil {
 		return nil, fmt.Errorf(`failed to parse let preamble: %w`, err)
 	}
 	letBlock := ast.NewLetBlock(vars...)
-	stmts, err := p.handleBlock()
+	p.mark(letBlock, start)
+	stmts, err := p.handleChildBlock()
 	if err != nil {
 		return nil, fmt.Errorf(`failed to parse let block: %w`, err)
 	}
@@ -1046,13 +1126,16 @@ func (p *parser) handleInclude() (*ast.Include, error) {
 	if tok.Type != Keyword || tok.Value != "include" {
 		return nil, fmt.Errorf(`expected include, got %q`, tok.Value)
 	}
+	start := tok
 
 	tok = p.Next()
 	if tok.Type != Literal {
 		return nil, fmt.Errorf(`expected string, got %q`, tok.Value)
 	}
 
-	return ast.NewInclude(tok.Value), nil
+	directive := ast.NewInclude(tok.Value)
+	p.mark(directive, start)
+	return directive, nil
 }
 
 func (p *parser) handleUse() (*ast.Use, error) {
@@ -1060,103 +1143,16 @@ func (p *parser) handleUse() (*ast.Use, error) {
 	if tok.Type != Keyword || tok.Value != "use" {
 		return nil, fmt.Errorf(`expected use, got %q`, tok.Value)
 	}
+	start := tok
 
 	tok = p.Next()
 	if tok.Type != Literal {
 		return nil, fmt.Errorf(`expected string, got %q`, tok.Value)
 	}
 
-	return ast.NewUse(tok.Value), nil
-}
-
-func (p *parser) handleUnary(unary *Token) (interface{}, error) {
-	tok := p.Next()
-	if tok.Type != unary.Type {
-		return nil, fmt.Errorf(`expected '%s', got %q`, unary.Value, tok.Value)
-	}
-
-	// -1, -var, -func(), -list[x]
-	tok = p.Peek()
-	switch tok.Type {
-	case Numeric:
-		// Only allowed for unary minus
-		if unary.Type != Minus {
-			return nil, fmt.Errorf(`unexpected numeric literal %q`, tok.Value)
-		}
-
-		p.Advance()
-		f, err := strconv.ParseFloat(tok.Value, 64)
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse numeric literal %q: %w`, tok.Value, err)
-		}
-		return ast.NewUnaryOp(unary.Value, f), nil
-	case OpenParen:
-		// -(expr)
-		p.Advance()
-		expr, err := p.handleExpr()
-		if err != nil {
-			return nil, fmt.Errorf(`failed to parse group expression after unary minus: %w`, err)
-		}
-		tok = p.Next()
-		if tok.Type != CloseParen {
-			return nil, fmt.Errorf(`expected ')', got %q`, tok.Value)
-		}
-		return ast.NewUnaryOp(unary.Value, ast.NewGroup(expr)), nil
-	case Ident:
-		tok = p.Peek()
-		switch tok.Type {
-		case OpenParen:
-			p.Unread() // (
-			p.Unread() // ident
-			fn, _, err := p.handleCall()
-			if err != nil {
-				return nil, fmt.Errorf(`failed to parse function call after unary minus: %w`, err)
-			}
-			return ast.NewUnaryOp(unary.Value, fn), nil
-		case OpenBracket:
-			p.Unread() // [
-			p.Unread() // ident
-			tok = p.Next()
-
-			index, err := p.handleIndex(ast.NewVariable(tok.Value))
-			if err != nil {
-				return nil, fmt.Errorf(`failed to parse index after unary minus: %w`, err)
-			}
-			return ast.NewUnaryOp(unary.Value, index), nil
-		default:
-			p.Unread()
-			p.Unread()
-			expr, err := p.handleExpr()
-			if err != nil {
-				return nil, fmt.Errorf(`failed to parse expression after unary minus: %w`, err)
-			}
-
-			return p.bindUnaryToFirstTerm(unary, expr)
-		}
-	default:
-		return nil, fmt.Errorf(`unexpected token %q after unary minus`, tok.Value)
-	}
-}
-
-func (p *parser) bindUnaryToFirstTerm(unary *Token, expr interface{}) (interface{}, error) {
-	// if the expression is either a binary or ternary operator
-	// take the first argument and bind to it
-	switch expr := expr.(type) {
-	case *ast.BinaryOp:
-		left, err := p.bindUnaryToFirstTerm(unary, expr.Left())
-		if err != nil {
-			return nil, err
-		}
-		return ast.NewBinaryOp(expr.Op(), left, expr.Right()), nil
-	case *ast.TernaryOp:
-		cond, err := p.bindUnaryToFirstTerm(unary, expr.Condition())
-		if err != nil {
-			return nil, err
-		}
-		return ast.NewTernaryOp(cond, expr.TrueExpr(), expr.FalseExpr()), nil
-	default:
-		return ast.NewUnaryOp(unary.Value, expr), nil
-	}
+	directive := ast.NewUse(tok.Value)
+	p.mark(directive, start)
+	return directive, nil
 }
 
 func (p *parser) handleIfPreamble() (interface{}, error) {
@@ -1195,36 +1191,53 @@ func (p *parser) handleIfExpr() (*ast.IfExpr, error) {
 		return nil, fmt.Errorf(`failed to parse if expression: %w`, err)
 	}
 	ifBlock.Body(expr)
+
+	tok := p.Peek()
+	if tok.Type != Keyword || tok.Value != "else" {
+		p.Unread()
+		return ifBlock, nil
+	}
+	elseExpr, err := p.handleExpr()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse else expression: %w`, err)
+	}
+	ifBlock.Else(elseExpr)
 	return ifBlock, nil
 }
 
-func (p *parser) handleIfChildBlock() ([]ast.Stmt, error) {
+// handleChildBlock parses the children of a statement such as if(), for()
+// or let(), which is either a block or a single statement
+func (p *parser) handleChildBlock() ([]ast.Stmt, error) {
 	tok := p.Peek()
 	p.Unread()
 
 	if tok.Type == OpenBrace {
 		block, err := p.handleBlock()
 		if err != nil {
-			return nil, fmt.Errorf(`failed to parse if block: %w`, err)
+			return nil, fmt.Errorf(`failed to parse block: %w`, err)
 		}
 		return block, nil
 	}
 
 	stmt, err := p.handleStatement()
 	if err != nil {
-		return nil, fmt.Errorf(`failed to parse if statement: %w`, err)
+		return nil, fmt.Errorf(`failed to parse statement: %w`, err)
 	}
 	return []ast.Stmt{stmt}, nil
 }
 
 func (p *parser) handleIfStmt() (ast.Stmt, error) {
+	start := p.Peek()
+	p.Unread()
+
 	cond, err := p.handleIfPreamble()
 	if err != nil {
 		return nil, fmt.Errorf(`failed to parse if preamble: %w`, err)
 	}
 	ifBlock := ast.NewIfStmt(cond)
+	p.mark(ifBlock, start)
 
-	stmts, err := p.handleIfChildBlock()
+	stmts, err := p.handleChildBlock()
 	if err != nil {
 		return nil, fmt.Errorf(`failed to parse if block: %w`, err)
 	}
@@ -1256,14 +1269,19 @@ OUTER:
 				return nil, fmt.Errorf(`failed to parse else if condition: %w`, err)
 			}
 
-			block, err := p.handleIfChildBlock()
+			tok = p.Next()
+			if tok.Type != CloseParen {
+				return nil, fmt.Errorf(`expected close paren, got %q`, tok.Value)
+			}
+
+			block, err := p.handleChildBlock()
 			if err != nil {
 				return nil, fmt.Errorf(`failed to parse else if block: %w`, err)
 			}
 			ifBlock.AddElseIf(cond, block...)
 		} else {
 			p.Unread()
-			block, err := p.handleIfChildBlock()
+			block, err := p.handleChildBlock()
 			if err != nil {
 				return nil, fmt.Errorf(`failed to parse else if block: %w`, err)
 			}
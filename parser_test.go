@@ -34,12 +34,17 @@ func TestParser(t *testing.T) {
 		{
 			Name:     "assign unary minus variable",
 			Src:      "bar = -foo*3/2;",
-			Expected: dsl.Stmts(dsl.Variable("bar").Value(dsl.Mul(dsl.Negative(dsl.Variable("foo")), dsl.Div(3.0, 2.0)))),
+			Expected: dsl.Stmts(dsl.Variable("bar").Value(dsl.Div(dsl.Mul(dsl.Negative(dsl.Variable("foo")), 3.0), 2.0))),
 		},
 		{
 			Name:     "function declaration",
 			Src:      "function double(x) = x * x + 2 * x - 1;",
-			Expected: dsl.Stmts(dsl.Function("double").Parameters(dsl.Variable("x")).Body(dsl.Add(dsl.Mul(dsl.Variable("x"), dsl.Variable("x")), dsl.Sub(dsl.Mul(2.0, dsl.Variable("x")), 1.0)))),
+			Expected: dsl.Stmts(dsl.Function("double").Parameters(dsl.Variable("x")).Body(dsl.Sub(dsl.Add(dsl.Mul(dsl.Variable("x"), dsl.Variable("x")), dsl.Mul(2.0, dsl.Variable("x"))), 1.0))),
+		},
+		{
+			Name:     "function literal",
+			Src:      "f = function(x, y=2) x * y;",
+			Expected: dsl.Stmts(dsl.Variable("f").Value(ast.NewFunctionLiteral(dsl.Variable("x"), dsl.Variable("y").Value(2.0)).Body(dsl.Mul(dsl.Variable("x"), dsl.Variable("y"))))),
 		},
 		{
 			Name: "recursive function declaration",
@@ -152,6 +157,42 @@ func TestParser(t *testing.T) {
 				),
 			),
 		},
+		{
+			Name: "else if",
+			Src:  "if (a) x(); else if (b) y(); else z();",
+			Expected: dsl.Stmts(
+				ast.NewIfStmt(dsl.Variable("a")).
+					Body(dsl.Call("x")).
+					AddElseIf(dsl.Variable("b"), dsl.Call("y")).
+					Else(dsl.Call("z")),
+			),
+		},
+		{
+			Name:  "else if without close paren",
+			Src:   "if (a) x(); else if (b y();",
+			Error: true,
+		},
+		{
+			Name: "each and else in list comprehensions",
+			Src:  "x = [for (i = v) if (i > 0) each i else -i];",
+			Expected: dsl.Stmts(dsl.Variable("x").Value(dsl.List(
+				dsl.ForExpr(dsl.LoopVar(dsl.Variable("i"), dsl.Variable("v"))).Body(
+					ast.NewIfExpr(dsl.GT(dsl.Variable("i"), 0.0)).
+						Body(ast.NewEachExpr(dsl.Variable("i"))).
+						Else(dsl.Negative(dsl.Variable("i"))),
+				),
+			))),
+		},
+		{
+			Name: "logical, inequality and exponent operators",
+			Src:  "x = !a || -b ^ 2 != 1e3;",
+			Expected: dsl.Stmts(dsl.Variable("x").Value(
+				ast.NewBinaryOp("||",
+					ast.NewUnaryOp("!", dsl.Variable("a")),
+					ast.NewBinaryOp("!=", dsl.Negative(ast.NewBinaryOp("^", dsl.Variable("b"), 2.0)), 1000.0),
+				),
+			)),
+		},
 	}
 
 	for _, tc := range testcases {
@@ -212,3 +253,67 @@ func TestParser(t *testing.T) {
 			ast.Emit(stmts, os.Stdout)
 	*/
 }
+
+func TestParserPositions(t *testing.T) {
+	src := "x = 1;\nmodule foo() {\n  cube(x);\n}\n\n#foo();\nif (x > 0)\n  for (i = [0:1]) { sphere(i); }\n"
+
+	stmts, err := openscad.Parse([]byte(src))
+	require.NoError(t, err, `openscad.Parse should succeed`)
+	for _, stmt := range stmts {
+		p, ok := stmt.(ast.Positioner)
+		require.True(t, ok, `statement should implement ast.Positioner`)
+		require.False(t, p.Pos().IsValid(), `positions should not be recorded by default`)
+	}
+
+	stmts, err = openscad.Parse([]byte(src), openscad.WithPositions())
+	require.NoError(t, err, `openscad.Parse should succeed`)
+
+	var positions []string
+	ast.Walk(stmts, func(node interface{}) bool {
+		if p, ok := node.(ast.Positioner); ok {
+			positions = append(positions, p.Pos().String())
+		}
+		return true
+	})
+	require.Equal(t, []string{
+		"1:1",  // x = 1
+		"2:1",  // module foo
+		"3:3",  // cube
+		"3:8",  // x
+		"6:1",  // #
+		"6:2",  // foo
+		"7:1",  // if
+		"7:5",  // x
+		"8:3",  // for
+		"8:8",  // i
+		"8:21", // sphere
+		"8:28", // i
+	}, positions)
+}
+
+func TestParseError(t *testing.T) {
+	testcases := []struct {
+		Name   string
+		Src    string
+		Line   int
+		Column int
+	}{
+		{Name: "missing expression", Src: "a = 1;\nb = ;", Line: 2, Column: 5},
+		{Name: "unexpected end of input", Src: "cube(", Line: 1, Column: 6},
+		{Name: "unknown operator", Src: "x = a | b;", Line: 1, Column: 7},
+		{Name: "unterminated string", Src: "echo(\"foo);", Line: 1, Column: 6},
+		{Name: "incomplete module", Src: "module", Line: 1, Column: 7},
+	}
+	for _, tc := range testcases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			_, err := openscad.Parse([]byte(tc.Src))
+			require.Error(t, err, `openscad.Parse should fail`)
+
+			var perr *openscad.ParseError
+			require.ErrorAs(t, err, &perr, `error should be a *openscad.ParseError`)
+			require.Equal(t, tc.Line, perr.Line, `line should match`)
+			require.Equal(t, tc.Column, perr.Column, `column should match`)
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package render
+
+import (
+	"time"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/option"
+)
+
+type optExecutableKey struct{}
+type optRegistryKey struct{}
+type optDefineKey struct{}
+type optParameterFileKey struct{}
+type optParameterSetKey struct{}
+type optCameraKey struct{}
+type optImageSizeKey struct{}
+type optExportFormatKey struct{}
+type optTimeoutKey struct{}
+type optArgsKey struct{}
+
+// Option is an option that can be passed to Render() and RenderFile()
+type Option interface {
+	renderOption()
+	option.Interface
+}
+
+type renderOption struct {
+	option.Interface
+}
+
+func (renderOption) renderOption() {}
+
+// define is a single -D override
+type define struct {
+	name  string
+	value interface{}
+}
+
+// WithExecutable specifies the OpenSCAD executable to run. The default is
+// "openscad", looked up in PATH.
+func WithExecutable(path string) Option {
+	return &renderOption{option.New(optExecutableKey{}, path)}
+}
+
+// WithRegistry specifies the registry that files are looked up in, both
+// by RenderFile() and when amalgamating. The default is the global
+// registry.
+func WithRegistry(r *ast.Registry) Option {
+	return &renderOption{option.New(optRegistryKey{}, r)}
+}
+
+// WithDefine overrides the value of a file-level variable, as with
+// `openscad -D name=value`. The value is written as OpenSCAD code: Go
+// strings become string literals, slices become vectors, and ast
+// expressions are emitted as is. May be specified multiple times.
+func WithDefine(name string, value interface{}) Option {
+	return &renderOption{option.New(optDefineKey{}, &define{name: name, value: value})}
+}
+
+// WithParameterFile specifies a customizer parameter file, as with
+// `openscad -p`. Use along with WithParameterSet.
+func WithParameterFile(path string) Option {
+	return &renderOption{option.New(optParameterFileKey{}, path)}
+}
+
+// WithParameterSet selects a parameter set from the parameter file, as
+// with `openscad -P`.
+func WithParameterSet(name string) Option {
+	return &renderOption{option.New(optParameterSetKey{}, name)}
+}
+
+// WithCamera sets the camera used for image output, as with
+// `openscad --camera`. It takes either 7 values (translate x, y, z,
+// rotate x, y, z, and distance), or 6 values (eye x, y, z, and center
+// x, y, z).
+func WithCamera(values ...float64) Option {
+	return &renderOption{option.New(optCameraKey{}, values)}
+}
+
+// WithImageSize sets the size of image output in pixels, as with
+// `openscad --imgsize`.
+func WithImageSize(width, height int) Option {
+	return &renderOption{option.New(optImageSizeKey{}, [2]int{width, height})}
+}
+
+// WithExportFormat overrides the format that is otherwise deduced from
+// the extension of the output file, as with `openscad --export-format`.
+// For example, "binstl" writes binary STL files.
+func WithExportFormat(format string) Option {
+	return &renderOption{option.New(optExportFormatKey{}, format)}
+}
+
+// WithTimeout limits how long OpenSCAD may run. The process is killed
+// when it runs out of time.
+func WithTimeout(d time.Duration) Option {
+	return &renderOption{option.New(optTimeoutKey{}, d)}
+}
+
+// WithArgs passes additional command line arguments to OpenSCAD, such as
+// "--autocenter" or "--viewall". May be specified multiple times.
+func WithArgs(args ...string) Option {
+	return &renderOption{option.New(optArgsKey{}, args)}
+}
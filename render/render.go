@@ -0,0 +1,294 @@
+// Package render runs the OpenSCAD executable on code built with this
+// module, in order to produce STL, 3MF, PNG and other output files.
+package render
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+// MessageKind is the kind of a message printed by OpenSCAD
+type MessageKind int
+
+const (
+	MessageEcho MessageKind = iota + 1
+	MessageWarning
+	MessageError
+	MessageTrace
+	MessageDeprecated
+)
+
+var messagePrefixes = []struct {
+	prefix string
+	kind   MessageKind
+}{
+	{`ECHO: `, MessageEcho},
+	{`WARNING: `, MessageWarning},
+	{`ERROR: `, MessageError},
+	{`TRACE: `, MessageTrace},
+	{`DEPRECATED: `, MessageDeprecated},
+}
+
+func (k MessageKind) String() string {
+	for _, p := range messagePrefixes {
+		if p.kind == k {
+			return strings.TrimSuffix(p.prefix, `: `)
+		}
+	}
+	return `UNKNOWN`
+}
+
+// Message is a single ECHO, WARNING, ERROR, TRACE or DEPRECATED line
+// printed by OpenSCAD.
+type Message struct {
+	Kind MessageKind
+	// Text is the message without its prefix. Locations in the
+	// amalgamated code are translated back to the files that the code
+	// came from, where possible.
+	Text string
+	// File and Line are the location that the message refers to, if any
+	File string
+	Line int
+}
+
+func (m *Message) String() string {
+	return m.Kind.String() + `: ` + m.Text
+}
+
+// Result holds the output of a single run of OpenSCAD
+type Result struct {
+	Messages []*Message
+	// Log is everything that OpenSCAD printed to stdout and stderr
+	Log []byte
+}
+
+type renderer struct {
+	executable string
+	registry   *ast.Registry
+	timeout    time.Duration
+	args       []string
+}
+
+func newRenderer(options []Option) (*renderer, error) {
+	r := &renderer{
+		executable: `openscad`,
+	}
+
+	var args []string
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case optExecutableKey{}:
+			r.executable = option.Value().(string)
+		case optRegistryKey{}:
+			r.registry = option.Value().(*ast.Registry)
+		case optTimeoutKey{}:
+			r.timeout = option.Value().(time.Duration)
+		case optDefineKey{}:
+			d := option.Value().(*define)
+			value, err := ast.EmitExprString(d.value)
+			if err != nil {
+				return nil, fmt.Errorf(`failed to emit value of %s: %w`, d.name, err)
+			}
+			args = append(args, `-D`, d.name+`=`+value)
+		case optParameterFileKey{}:
+			args = append(args, `-p`, option.Value().(string))
+		case optParameterSetKey{}:
+			args = append(args, `-P`, option.Value().(string))
+		case optCameraKey{}:
+			values := option.Value().([]float64)
+			if len(values) != 6 && len(values) != 7 {
+				return nil, fmt.Errorf(`camera requires 6 or 7 values, got %d`, len(values))
+			}
+			args = append(args, `--camera=`+joinFloats(values))
+		case optImageSizeKey{}:
+			size := option.Value().([2]int)
+			args = append(args, fmt.Sprintf(`--imgsize=%d,%d`, size[0], size[1]))
+		case optExportFormatKey{}:
+			args = append(args, `--export-format`, option.Value().(string))
+		case optArgsKey{}:
+			args = append(args, option.Value().([]string)...)
+		}
+	}
+	r.args = args
+	return r, nil
+}
+
+func joinFloats(values []float64) string {
+	list := make([]string, len(values))
+	for i, v := range values {
+		list[i] = strconv.FormatFloat(v, 'g', -1, 64)
+	}
+	return strings.Join(list, `,`)
+}
+
+// Render amalgamates stmt along with everything that it includes or uses,
+// and runs OpenSCAD on the result to create output. The format of the
+// output is deduced from its extension, unless WithExportFormat is used.
+//
+// The returned Result is non-nil even when OpenSCAD fails, so that its
+// messages can be inspected. If ctx is canceled or the timeout expires,
+// OpenSCAD is killed, and the error wraps ctx.Err().
+func Render(ctx context.Context, stmt ast.Stmt, output string, options ...Option) (*Result, error) {
+	return run(ctx, `main.scad`, output, options, func(w io.Writer, emitOptions []ast.EmitFileOption) error {
+		list := make([]ast.EmitOption, len(emitOptions))
+		for i, option := range emitOptions {
+			list[i] = option
+		}
+		return ast.Emit(stmt, w, list...)
+	})
+}
+
+// RenderFile works like Render, but renders the file registered under
+// name (see WithRegistry).
+func RenderFile(ctx context.Context, name, output string, options ...Option) (*Result, error) {
+	return run(ctx, filepath.Base(name), output, options, func(w io.Writer, emitOptions []ast.EmitFileOption) error {
+		return ast.EmitFile(name, w, emitOptions...)
+	})
+}
+
+func run(ctx context.Context, name, output string, options []Option, emit func(io.Writer, []ast.EmitFileOption) error) (*Result, error) {
+	r, err := newRenderer(options)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to render %s: %w`, name, err)
+	}
+
+	var src bytes.Buffer
+	// the temporary file is given the same name, so that messages that
+	// refer to it can be translated
+	var sourceMap ast.SourceMap
+	emitOptions := []ast.EmitFileOption{ast.WithAmalgamation(), ast.WithSourceMap(&sourceMap, name)}
+	if r.registry != nil {
+		emitOptions = append(emitOptions, ast.WithRegistry(r.registry))
+	}
+	if err := emit(&src, emitOptions); err != nil {
+		return nil, fmt.Errorf(`failed to render %s: %w`, name, err)
+	}
+	for _, mapping := range sourceMap.Mappings {
+		if mapping.Source == `` {
+			mapping.Source = name
+		}
+	}
+
+	dir, err := os.MkdirTemp(``, `openscad-render-`)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to create temporary directory: %w`, err)
+	}
+	defer os.RemoveAll(dir)
+
+	input := filepath.Join(dir, name)
+	if err := os.WriteFile(input, src.Bytes(), 0o644); err != nil {
+		return nil, fmt.Errorf(`failed to write %s: %w`, input, err)
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	args := append(append([]string(nil), r.args...), `-o`, output, input)
+	log, err := runCommand(ctx, exec.Command(r.executable, args...))
+
+	res := &Result{
+		Messages: parseMessages(log, &sourceMap),
+		Log:      log,
+	}
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return res, fmt.Errorf(`failed to render %s: %w`, name, ctxErr)
+	}
+	if err != nil {
+		for _, msg := range res.Messages {
+			if msg.Kind == MessageError {
+				return res, fmt.Errorf(`failed to render %s: %w: %s`, name, err, msg.Text)
+			}
+		}
+		return res, fmt.Errorf(`failed to render %s: %w`, name, err)
+	}
+	return res, nil
+}
+
+// runCommand runs cmd until it exits, or kills it once ctx is done, and returns
+// what it wrote to stdout and stderr. The output is read through a pipe of
+// our own rather than one that cmd manages, so that processes that
+// OpenSCAD may have started, and that keep the pipe open, do not hold up
+// returning for more than a second.
+func runCommand(ctx context.Context, cmd *exec.Cmd) ([]byte, error) {
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf(`failed to create pipe: %w`, err)
+	}
+	defer pr.Close()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	err = cmd.Start()
+	pw.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	var log bytes.Buffer
+	copied := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(&log, pr)
+		close(copied)
+	}()
+	waited := make(chan error, 1)
+	go func() {
+		waited <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-waited:
+	case <-ctx.Done():
+		_ = cmd.Process.Kill()
+		err = <-waited
+	}
+	select {
+	case <-copied:
+	case <-time.After(time.Second):
+		pr.Close()
+		<-copied
+	}
+	return log.Bytes(), err
+}
+
+var locationPattern = regexp.MustCompile(`in file "?([^",]+)"?, line (\d+)`)
+
+// parseMessages extracts the messages from the output of OpenSCAD
+func parseMessages(log []byte, sourceMap *ast.SourceMap) []*Message {
+	var messages []*Message
+	scanner := bufio.NewScanner(bytes.NewReader(log))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		for _, p := range messagePrefixes {
+			if !strings.HasPrefix(line, p.prefix) {
+				continue
+			}
+			msg := &Message{
+				Kind: p.kind,
+				Text: sourceMap.TranslateMessage(strings.TrimPrefix(line, p.prefix)),
+			}
+			if m := locationPattern.FindStringSubmatch(msg.Text); m != nil {
+				msg.File = m[1]
+				msg.Line, _ = strconv.Atoi(m[2])
+			}
+			messages = append(messages, msg)
+			break
+		}
+	}
+	return messages
+}
@@ -0,0 +1,184 @@
+package render_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/render"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeOpenSCAD stands in for the real executable. It writes its arguments
+// and the input file to the output file, and prints messages that depend
+// on the contents of the input.
+const fakeOpenSCAD = `#!/bin/sh
+out=
+for arg in "$@"; do
+	if [ "$prev" = "-o" ]; then out="$arg"; fi
+	prev="$arg"
+	input="$arg"
+done
+if grep -q sleep "$input"; then exec sleep 10; fi
+if grep -q background "$input"; then sleep 10 & exec sleep 10; fi
+echo "Compiling design (CSG Tree generation)..."
+echo 'ECHO: "hello"' >&2
+line=$(grep -n cylinder "$input" | head -n 1 | cut -d: -f1)
+if [ -n "$line" ]; then
+	echo "WARNING: too few facets in file $(basename "$input"), line $line" >&2
+fi
+if grep -q fail "$input"; then
+	echo "ERROR: Parser error: fail in file $(basename "$input"), line 1" >&2
+	exit 1
+fi
+for arg in "$@"; do echo "$arg"; done > "$out"
+echo --- >> "$out"
+cat "$input" >> "$out"
+`
+
+func setup(t *testing.T) (string, string) {
+	t.Helper()
+	if runtime.GOOS == `windows` {
+		t.Skip(`the fake executable is a shell script`)
+	}
+	dir := t.TempDir()
+	executable := filepath.Join(dir, `openscad`)
+	require.NoError(t, os.WriteFile(executable, []byte(fakeOpenSCAD), 0o755), `writing the fake executable should succeed`)
+	return executable, filepath.Join(dir, `out.stl`)
+}
+
+func parse(t *testing.T, src string) ast.Stmt {
+	t.Helper()
+	stmts, err := openscad.Parse([]byte(src), openscad.WithPositions())
+	require.NoError(t, err, `parsing should succeed`)
+	return stmts
+}
+
+func TestRender(t *testing.T) {
+	t.Run("arguments and messages", func(t *testing.T) {
+		executable, output := setup(t)
+		res, err := render.Render(context.Background(), parse(t, "size = 1;\ncube(size);"), output,
+			render.WithExecutable(executable),
+			render.WithDefine(`size`, 10),
+			render.WithDefine(`label`, `top`),
+			render.WithDefine(`offset`, []interface{}{1, 2.5, 0}),
+			render.WithParameterFile(`params.json`),
+			render.WithParameterSet(`large`),
+			render.WithCamera(0, 0, 0, 55, 0, 25, 140),
+			render.WithImageSize(800, 600),
+			render.WithExportFormat(`binstl`),
+			render.WithArgs(`--autocenter`),
+		)
+		require.NoError(t, err, `Render should succeed`)
+
+		data, err := os.ReadFile(output)
+		require.NoError(t, err, `output should be written`)
+		args, src, ok := strings.Cut(string(data), "---\n")
+		require.True(t, ok, `output should contain the arguments and the input`)
+
+		list := strings.Split(strings.TrimSuffix(args, "\n"), "\n")
+		require.Equal(t, []string{
+			`-D`, `size=10`,
+			`-D`, `label="top"`,
+			`-D`, `offset=[1, 2.5, 0]`,
+			`-p`, `params.json`,
+			`-P`, `large`,
+			`--camera=0,0,0,55,0,25,140`,
+			`--imgsize=800,600`,
+			`--export-format`, `binstl`,
+			`--autocenter`,
+			`-o`, output,
+		}, list[:len(list)-1], `arguments should match`)
+		require.Equal(t, `main.scad`, filepath.Base(list[len(list)-1]), `input should be named main.scad`)
+		require.NoFileExists(t, list[len(list)-1], `input should be removed`)
+		require.Contains(t, src, `cube(size);`, `input should contain the code`)
+
+		require.Len(t, res.Messages, 1, `there should be one message`)
+		require.Equal(t, render.MessageEcho, res.Messages[0].Kind)
+		require.Equal(t, `"hello"`, res.Messages[0].Text)
+		require.Equal(t, `ECHO: "hello"`, res.Messages[0].String())
+		require.Contains(t, string(res.Log), `Compiling design`, `log should contain stdout`)
+	})
+	t.Run("registry entry", func(t *testing.T) {
+		executable, output := setup(t)
+		registry := ast.NewRegistry()
+		require.NoError(t, registry.Register(`lib.scad`, parse(t, "module a() {\n  cube(1);\n}\n\nmodule b() {\n  cylinder(r=1, h=2);\n}")))
+		require.NoError(t, registry.Register(`parts/main.scad`, parse(t, "include <lib.scad>\na();\nb();")))
+
+		res, err := render.RenderFile(context.Background(), `parts/main.scad`, output,
+			render.WithExecutable(executable),
+			render.WithRegistry(registry),
+		)
+		require.NoError(t, err, `RenderFile should succeed`)
+
+		data, err := os.ReadFile(output)
+		require.NoError(t, err, `output should be written`)
+		require.NotContains(t, string(data), `include <`, `input should be amalgamated`)
+		require.Contains(t, string(data), `/main.scad`, `input should be named after the registry entry`)
+
+		require.Len(t, res.Messages, 2, `there should be two messages`)
+		msg := res.Messages[1]
+		require.Equal(t, render.MessageWarning, msg.Kind)
+		require.Equal(t, `too few facets in file lib.scad, line 6`, msg.Text, `location should be translated`)
+		require.Equal(t, `lib.scad`, msg.File)
+		require.Equal(t, 6, msg.Line)
+	})
+	t.Run("unknown registry entry", func(t *testing.T) {
+		executable, output := setup(t)
+		_, err := render.RenderFile(context.Background(), `missing.scad`, output,
+			render.WithExecutable(executable),
+			render.WithRegistry(ast.NewRegistry()),
+		)
+		require.Error(t, err, `RenderFile should fail`)
+	})
+	t.Run("errors", func(t *testing.T) {
+		executable, output := setup(t)
+		res, err := render.Render(context.Background(), parse(t, `fail();`), output, render.WithExecutable(executable))
+		require.Error(t, err, `Render should fail`)
+		require.Contains(t, err.Error(), `Parser error: fail`, `error should contain the message`)
+		require.NotNil(t, res, `result should be returned`)
+		require.Equal(t, render.MessageError, res.Messages[len(res.Messages)-1].Kind)
+		require.NoFileExists(t, output, `output should not be written`)
+	})
+	t.Run("invalid camera", func(t *testing.T) {
+		executable, output := setup(t)
+		_, err := render.Render(context.Background(), parse(t, `cube(1);`), output,
+			render.WithExecutable(executable),
+			render.WithCamera(1, 2, 3),
+		)
+		require.Error(t, err, `Render should fail`)
+	})
+	t.Run("timeout", func(t *testing.T) {
+		executable, output := setup(t)
+		start := time.Now()
+		_, err := render.Render(context.Background(), parse(t, `sleep();`), output,
+			render.WithExecutable(executable),
+			render.WithTimeout(100*time.Millisecond),
+		)
+		require.True(t, errors.Is(err, context.DeadlineExceeded), `error should be a timeout, got %v`, err)
+		require.Less(t, time.Since(start), 5*time.Second, `process should be killed`)
+	})
+	t.Run("cancel", func(t *testing.T) {
+		executable, output := setup(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		_, err := render.Render(ctx, parse(t, `sleep();`), output, render.WithExecutable(executable))
+		require.True(t, errors.Is(err, context.Canceled), `error should be a cancellation, got %v`, err)
+	})
+	t.Run("cancel with a process that keeps the output open", func(t *testing.T) {
+		executable, output := setup(t)
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := render.Render(ctx, parse(t, `background();`), output, render.WithExecutable(executable))
+		require.True(t, errors.Is(err, context.Canceled), `error should be a cancellation, got %v`, err)
+		require.Less(t, time.Since(start), 5*time.Second, `Render should not wait for the output to be closed`)
+	})
+}
@@ -0,0 +1,59 @@
+package stl
+
+import "github.com/lestrrat-go/option"
+
+type optFormatKey struct{}
+type optNameKey struct{}
+type optToleranceKey struct{}
+
+// WriteOption is an option that can be passed to Write()
+type WriteOption interface {
+	writeOption()
+	option.Interface
+}
+
+// ReadOption is an option that can be passed to Read()
+type ReadOption interface {
+	readOption()
+	option.Interface
+}
+
+// ReadWriteOption is an option that can be passed to Read() and Write()
+type ReadWriteOption interface {
+	ReadOption
+	WriteOption
+}
+
+type writeOption struct {
+	option.Interface
+}
+
+func (writeOption) writeOption() {}
+
+type readWriteOption struct {
+	option.Interface
+}
+
+func (readWriteOption) readOption()  {}
+func (readWriteOption) writeOption() {}
+
+// WithFormat specifies the format that Write() uses. The default is
+// Binary.
+func WithFormat(f Format) WriteOption {
+	return &writeOption{option.New(optFormatKey{}, f)}
+}
+
+// WithName sets the name of the solid in ASCII files, and the text of the
+// header of binary files. The default is "openscad".
+func WithName(name string) WriteOption {
+	return &writeOption{option.New(optNameKey{}, name)}
+}
+
+// WithTolerance welds vertices that are at most the given distance apart
+// (see mesh.Mesh.Weld). When writing, this removes the slivers left by
+// vertices that almost coincide. When reading, it joins the triangles of
+// files that were written with less precision than they were computed
+// with. Vertices that are exactly the same are always joined when reading.
+func WithTolerance(tolerance float64) ReadWriteOption {
+	return &readWriteOption{option.New(optToleranceKey{}, tolerance)}
+}
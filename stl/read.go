@@ -0,0 +1,208 @@
+package stl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/mesh"
+)
+
+// Read reads an STL file into a mesh. The format is detected from the
+// contents: files that start with "solid" followed by text are ASCII, and
+// all others are binary.
+//
+// The normals that are stored in the file are ignored, and the order of
+// the vertices of each triangle is trusted instead. Vertices that are
+// shared by triangles are joined, and triangles that use the same vertex
+// twice are dropped. ASCII facets with more than three vertices are split
+// into triangles.
+func Read(r io.Reader, options ...ReadOption) (*mesh.Mesh, error) {
+	var tolerance float64
+	for _, option := range options {
+		switch option.Ident() {
+		case optToleranceKey{}:
+			tolerance = option.Value().(float64)
+		}
+	}
+
+	br := bufio.NewReader(r)
+	rd := &reader{welder: mesh.NewWelder(tolerance)}
+	var err error
+	if isASCII(br) {
+		err = rd.readASCII(br)
+	} else {
+		err = rd.readBinary(br)
+	}
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read STL: %w`, err)
+	}
+	rd.mesh.Vertices = rd.welder.Vertices()
+	return &rd.mesh, nil
+}
+
+// isASCII reports whether the file starts with "solid", and what follows
+// is text. The header of binary files may start with "solid" too, but the
+// triangles that follow it are not text.
+func isASCII(br *bufio.Reader) bool {
+	buf, _ := br.Peek(512)
+	buf = bytes.TrimLeft(buf, " \t\r\n")
+	if !bytes.HasPrefix(buf, []byte(`solid`)) {
+		return false
+	}
+	for _, c := range buf {
+		if (c < 0x20 || c > 0x7e) && c != '\t' && c != '\r' && c != '\n' {
+			return false
+		}
+	}
+	return true
+}
+
+type reader struct {
+	mesh   mesh.Mesh
+	welder *mesh.Welder
+}
+
+func (rd *reader) addTriangle(a, b, c csg.Vec3) {
+	t := [3]int{rd.welder.Add(a), rd.welder.Add(b), rd.welder.Add(c)}
+	if t[0] == t[1] || t[1] == t[2] || t[2] == t[0] {
+		return
+	}
+	rd.mesh.Triangles = append(rd.mesh.Triangles, t)
+}
+
+func (rd *reader) readBinary(r io.Reader) error {
+	var header [headerSize + 4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return fmt.Errorf(`failed to read header: %w`, err)
+	}
+	count := binary.LittleEndian.Uint32(header[headerSize:])
+
+	// the count may be wrong, so it is only trusted so much
+	rd.mesh.Triangles = make([][3]int, 0, minInt(int(count), 1<<20))
+	var buf [triangleSize]byte
+	for i := uint32(0); i < count; i++ {
+		if _, err := io.ReadFull(r, buf[:]); err != nil {
+			return fmt.Errorf(`failed to read triangle %d of %d: %w`, i, count, err)
+		}
+		rd.addTriangle(getVec3(buf[12:]), getVec3(buf[24:]), getVec3(buf[36:]))
+	}
+	return nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func getVec3(buf []byte) csg.Vec3 {
+	var v csg.Vec3
+	for i := range v {
+		v[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:])))
+	}
+	return v
+}
+
+func (rd *reader) readASCII(r io.Reader) error {
+	s := &scanner{Scanner: bufio.NewScanner(r)}
+	s.Split(bufio.ScanWords)
+
+	var facets int
+	for s.Scan() {
+		// "solid", "endsolid" and the names of solids are skipped
+		if s.Text() != `facet` {
+			continue
+		}
+		if err := rd.readFacet(s); err != nil {
+			return fmt.Errorf(`failed to read facet %d: %w`, facets, err)
+		}
+		facets++
+	}
+	return s.Err()
+}
+
+func (rd *reader) readFacet(s *scanner) error {
+	if err := s.expect(`normal`); err != nil {
+		return err
+	}
+	if _, err := s.vec3(); err != nil {
+		return err
+	}
+	if err := s.expect(`outer`); err != nil {
+		return err
+	}
+	if err := s.expect(`loop`); err != nil {
+		return err
+	}
+
+	var points []csg.Vec3
+	for {
+		if !s.Scan() {
+			return s.unexpectedEOF()
+		}
+		if s.Text() == `endloop` {
+			break
+		}
+		if s.Text() != `vertex` {
+			return fmt.Errorf(`expected "vertex" or "endloop", found %q`, s.Text())
+		}
+		v, err := s.vec3()
+		if err != nil {
+			return err
+		}
+		points = append(points, v)
+	}
+	if err := s.expect(`endfacet`); err != nil {
+		return err
+	}
+	if len(points) < 3 {
+		return fmt.Errorf(`expected at least 3 vertices, found %d`, len(points))
+	}
+	for i := 1; i+1 < len(points); i++ {
+		rd.addTriangle(points[0], points[i], points[i+1])
+	}
+	return nil
+}
+
+type scanner struct {
+	*bufio.Scanner
+}
+
+func (s *scanner) unexpectedEOF() error {
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return io.ErrUnexpectedEOF
+}
+
+func (s *scanner) expect(word string) error {
+	if !s.Scan() {
+		return s.unexpectedEOF()
+	}
+	if s.Text() != word {
+		return fmt.Errorf(`expected %q, found %q`, word, s.Text())
+	}
+	return nil
+}
+
+func (s *scanner) vec3() (csg.Vec3, error) {
+	var v csg.Vec3
+	for i := range v {
+		if !s.Scan() {
+			return v, s.unexpectedEOF()
+		}
+		f, err := strconv.ParseFloat(s.Text(), 64)
+		if err != nil {
+			return v, fmt.Errorf(`failed to parse coordinate: %w`, err)
+		}
+		v[i] = f
+	}
+	return v, nil
+}
@@ -0,0 +1,155 @@
+// Package stl reads and writes meshes in the STL format, both ASCII and
+// binary.
+//
+// STL files store each triangle on its own, with a normal and the
+// coordinates of its three vertices. Write() streams the triangles of a
+// mesh as they are encoded, and Read() joins the vertices that the
+// triangles share, so that the result is an indexed mesh again.
+package stl
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/internal/number"
+	"github.com/lestrrat-go/openscad/mesh"
+)
+
+// Format is the encoding of an STL file
+type Format int
+
+const (
+	Binary Format = iota
+	ASCII
+)
+
+func (f Format) String() string {
+	switch f {
+	case Binary:
+		return `binary`
+	case ASCII:
+		return `ascii`
+	}
+	return `Format(` + strconv.Itoa(int(f)) + `)`
+}
+
+const headerSize = 80
+const triangleSize = 50
+
+// Write writes m to w as an STL file. The normal of each triangle is
+// computed from its vertices, which are in counter-clockwise order when
+// seen from outside as STL requires. Degenerate triangles have a zero
+// normal.
+//
+// The output is written as it is encoded, without holding the whole file
+// in memory.
+func Write(w io.Writer, m *mesh.Mesh, options ...WriteOption) error {
+	format := Binary
+	name := `openscad`
+	tolerance := -1.0
+	for _, option := range options {
+		switch option.Ident() {
+		case optFormatKey{}:
+			format = option.Value().(Format)
+		case optNameKey{}:
+			name = option.Value().(string)
+		case optToleranceKey{}:
+			tolerance = option.Value().(float64)
+		}
+	}
+	if tolerance >= 0 {
+		m = m.Weld(tolerance)
+	}
+
+	bw := bufio.NewWriter(w)
+	var err error
+	switch format {
+	case Binary:
+		err = writeBinary(bw, m, name)
+	case ASCII:
+		err = writeASCII(bw, m, name)
+	default:
+		return fmt.Errorf(`unknown format %s`, format)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return fmt.Errorf(`failed to write STL: %w`, err)
+	}
+	return nil
+}
+
+func writeBinary(w *bufio.Writer, m *mesh.Mesh, name string) error {
+	if uint64(len(m.Triangles)) > math.MaxUint32 {
+		return fmt.Errorf(`too many triangles (%d)`, len(m.Triangles))
+	}
+
+	var header [headerSize + 4]byte
+	copy(header[:headerSize], name)
+	binary.LittleEndian.PutUint32(header[headerSize:], uint32(len(m.Triangles)))
+	if _, err := w.Write(header[:]); err != nil {
+		return err
+	}
+
+	var buf [triangleSize]byte
+	for i, t := range m.Triangles {
+		putVec3(buf[0:], m.Normal(i))
+		putVec3(buf[12:], m.Vertices[t[0]])
+		putVec3(buf[24:], m.Vertices[t[1]])
+		putVec3(buf[36:], m.Vertices[t[2]])
+		// the attribute byte count is left at zero
+		if _, err := w.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putVec3(buf []byte, v csg.Vec3) {
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(float32(f)))
+	}
+}
+
+func writeASCII(w *bufio.Writer, m *mesh.Mesh, name string) error {
+	w.WriteString(`solid `)
+	w.WriteString(name)
+	w.WriteByte('\n')
+
+	var buf []byte
+	for i, t := range m.Triangles {
+		buf = append(buf[:0], "  facet normal"...)
+		buf = appendVec3(buf, m.Normal(i))
+		buf = append(buf, "\n    outer loop\n"...)
+		for _, idx := range t {
+			buf = append(buf, "      vertex"...)
+			buf = appendVec3(buf, m.Vertices[idx])
+			buf = append(buf, '\n')
+		}
+		buf = append(buf, "    endloop\n  endfacet\n"...)
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+
+	w.WriteString(`endsolid `)
+	w.WriteString(name)
+	_, err := w.WriteString("\n")
+	return err
+}
+
+// appendVec3 appends the coordinates of v, each preceded by a space, with
+// as many digits as are needed to read them back exactly
+func appendVec3(buf []byte, v csg.Vec3) []byte {
+	for _, f := range v {
+		buf = append(buf, ' ')
+		buf = number.Append(buf, f)
+	}
+	return buf
+}
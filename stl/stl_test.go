@@ -0,0 +1,96 @@
+package stl_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/mesh"
+	"github.com/lestrrat-go/openscad/stl"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	m := mesh.Sphere(&csg.Sphere{R: 10, Fragments: csg.Fragments{Fn: 24}})
+	for _, format := range []stl.Format{stl.Binary, stl.ASCII} {
+		format := format
+		t.Run(format.String(), func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, stl.Write(&buf, m, stl.WithFormat(format), stl.WithName(`solid sphere`)), `stl.Write should succeed`)
+			if format == stl.Binary {
+				require.Equal(t, 84+50*len(m.Triangles), buf.Len(), `binary size should match`)
+			}
+
+			got, err := stl.Read(&buf)
+			require.NoError(t, err, `stl.Read should succeed`)
+			require.Len(t, got.Vertices, len(m.Vertices), `shared vertices should be joined`)
+			require.Len(t, got.Triangles, len(m.Triangles), `all triangles should be read`)
+			require.InDelta(t, m.Volume(), got.Volume(), 1e-3, `volume should match`)
+		})
+	}
+}
+
+func TestWriteASCII(t *testing.T) {
+	m := &mesh.Mesh{
+		Vertices:  []csg.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 0.5, 0}},
+		Triangles: [][3]int{{0, 1, 2}},
+	}
+	var sb strings.Builder
+	require.NoError(t, stl.Write(&sb, m, stl.WithFormat(stl.ASCII), stl.WithName(`part`)), `stl.Write should succeed`)
+	require.Equal(t, `solid part
+  facet normal 0 0 1
+    outer loop
+      vertex 0 0 0
+      vertex 1 0 0
+      vertex 0 0.5 0
+    endloop
+  endfacet
+endsolid part
+`, sb.String(), `output should match`)
+}
+
+func TestRead(t *testing.T) {
+	t.Run(`polygon facets`, func(t *testing.T) {
+		m, err := stl.Read(strings.NewReader(`solid square
+facet normal 0 0 1
+  outer loop
+    vertex 0 0 0
+    vertex 1 0 0
+    vertex 1 1 0
+    vertex 0 1 0
+  endloop
+endfacet
+endsolid square`))
+		require.NoError(t, err, `stl.Read should succeed`)
+		require.Len(t, m.Triangles, 2, `quad should be split in two`)
+		require.InDelta(t, 1.0, m.Area(), 1e-9, `area should match`)
+	})
+	t.Run(`tolerance`, func(t *testing.T) {
+		m, err := stl.Read(strings.NewReader(`solid
+facet normal 0 0 1 outer loop vertex 0 0 0 vertex 1 0 0 vertex 0 1 0 endloop endfacet
+facet normal 0 0 1 outer loop vertex 1.0000001 0 0 vertex 1 1 0 vertex 0 1 0 endloop endfacet
+endsolid`), stl.WithTolerance(1e-6))
+		require.NoError(t, err, `stl.Read should succeed`)
+		require.Len(t, m.Vertices, 4, `close vertices should be joined`)
+	})
+	t.Run(`errors`, func(t *testing.T) {
+		_, err := stl.Read(strings.NewReader("solid x\nfacet normal 0 0 1\nouter loop\nvertex 0 0\n"))
+		require.Error(t, err, `truncated ASCII file should be rejected`)
+
+		var buf bytes.Buffer
+		require.NoError(t, stl.Write(&buf, mesh.Cube(&csg.Cube{Size: csg.Vec3{1, 1, 1}})), `stl.Write should succeed`)
+		_, err = stl.Read(bytes.NewReader(buf.Bytes()[:buf.Len()-10]))
+		require.Error(t, err, `truncated binary file should be rejected`)
+	})
+}
+
+func TestWriteTolerance(t *testing.T) {
+	m := &mesh.Mesh{
+		Vertices:  []csg.Vec3{{0, 0, 0}, {1, 0, 0}, {0, 1, 0}, {1e-9, 0, 0}},
+		Triangles: [][3]int{{0, 1, 2}, {0, 3, 2}},
+	}
+	var buf bytes.Buffer
+	require.NoError(t, stl.Write(&buf, m, stl.WithTolerance(1e-6)), `stl.Write should succeed`)
+	require.Equal(t, 84+50, buf.Len(), `sliver should be dropped`)
+}
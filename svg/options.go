@@ -0,0 +1,23 @@
+package svg
+
+import "github.com/lestrrat-go/option"
+
+type optUnitKey struct{}
+
+// WriteOption is an option that can be passed to Write()
+type WriteOption interface {
+	writeOption()
+	option.Interface
+}
+
+type writeOption struct {
+	option.Interface
+}
+
+func (writeOption) writeOption() {}
+
+// WithUnit sets the unit that coordinates are in: one of "mm", "cm",
+// "in", "pt", "pc" or "px". The default is "mm".
+func WithUnit(unit string) WriteOption {
+	return &writeOption{option.New(optUnitKey{}, unit)}
+}
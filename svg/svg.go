@@ -0,0 +1,91 @@
+// Package svg writes 2D regions as SVG files, in the same layout as
+// OpenSCAD's SVG export.
+package svg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/internal/number"
+	"github.com/lestrrat-go/openscad/mesh"
+)
+
+// Write writes regions to w as an SVG document. Each region is a path,
+// made of its outline followed by its holes. The width and height of the
+// document are those of the bounding box of the regions, in the given
+// unit, and one unit of the view box is one unit of the model.
+//
+// SVG's Y axis points down, so Y coordinates are negated. Outlines and
+// holes are wound in opposite directions, which keeps the holes empty
+// under either fill rule; outlines appear counter-clockwise on screen.
+func Write(w io.Writer, regions []mesh.Region, options ...WriteOption) error {
+	unit := `mm`
+	for _, option := range options {
+		switch option.Ident() {
+		case optUnitKey{}:
+			unit = option.Value().(string)
+		}
+	}
+	switch unit {
+	case `mm`, `cm`, `in`, `pt`, `pc`, `px`:
+	default:
+		return fmt.Errorf(`unknown unit %q`, unit)
+	}
+
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, r := range regions {
+		for _, p := range r.Outline {
+			minX, maxX = math.Min(minX, p[0]), math.Max(maxX, p[0])
+			minY, maxY = math.Min(minY, p[1]), math.Max(maxY, p[1])
+		}
+	}
+	if minX > maxX {
+		minX, minY, maxX, maxY = 0, 0, 0, 0
+	}
+	width, height := maxX-minX, maxY-minY
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString(`<?xml version="1.0" standalone="no"?>` + "\n")
+	bw.WriteString(`<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">` + "\n")
+	fmt.Fprintf(bw, `<svg width="%s%s" height="%s%s" viewBox="%s %s %s %s" xmlns="http://www.w3.org/2000/svg" version="1.1">`+"\n",
+		number.Format(width), unit, number.Format(height), unit,
+		number.Format(minX), number.Format(-maxY), number.Format(width), number.Format(height))
+
+	var buf []byte
+	for _, r := range regions {
+		if len(r.Outline) < 3 {
+			continue
+		}
+		buf = append(buf[:0], `<path d="`...)
+		buf = appendPath(buf, r.Outline)
+		for _, hole := range r.Holes {
+			buf = append(buf, ' ')
+			buf = appendPath(buf, hole)
+		}
+		buf = append(buf, "\" stroke=\"black\" fill=\"lightgray\" stroke-width=\"0.5\"/>\n"...)
+		bw.Write(buf)
+	}
+	bw.WriteString("</svg>\n")
+	if err := bw.Flush(); err != nil {
+		return fmt.Errorf(`failed to write SVG: %w`, err)
+	}
+	return nil
+}
+
+func appendPath(buf []byte, points []csg.Vec2) []byte {
+	for i, p := range points {
+		if i == 0 {
+			buf = append(buf, `M `...)
+		} else {
+			buf = append(buf, ` L `...)
+		}
+		buf = append(buf, number.Format(p[0])...)
+		buf = append(buf, ',')
+		buf = append(buf, number.Format(-p[1])...)
+	}
+	return append(buf, ` z`...)
+}
@@ -0,0 +1,34 @@
+package svg_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/mesh"
+	"github.com/lestrrat-go/openscad/svg"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrite(t *testing.T) {
+	regions, err := mesh.Regions(&csg.Difference{Children: []csg.Node{
+		&csg.Square{Size: csg.Vec2{4, 2}},
+		&csg.Transform{
+			Matrix:   csg.Translation(csg.Vec3{1, 0.5, 0}),
+			Children: []csg.Node{&csg.Square{Size: csg.Vec2{1, 1}}},
+		},
+	}})
+	require.NoError(t, err, `mesh.Regions should succeed`)
+
+	var sb strings.Builder
+	require.NoError(t, svg.Write(&sb, regions, svg.WithUnit(`in`)), `svg.Write should succeed`)
+	require.Equal(t, `<?xml version="1.0" standalone="no"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg width="4in" height="2in" viewBox="0 -2 4 2" xmlns="http://www.w3.org/2000/svg" version="1.1">
+<path d="M 0,0 L 4,0 L 4,-2 L 0,-2 z M 2,-0.5 L 1,-0.5 L 1,-1.5 L 2,-1.5 z" stroke="black" fill="lightgray" stroke-width="0.5"/>
+</svg>
+`, sb.String(), `output should match`)
+
+	require.Error(t, svg.Write(io.Discard, regions, svg.WithUnit(`m`)), `unknown units should be rejected`)
+}
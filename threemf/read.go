@@ -0,0 +1,172 @@
+package threemf
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/mesh"
+)
+
+type xmlRelationships struct {
+	Relationships []struct {
+		Target string `xml:"Target,attr"`
+		Type   string `xml:"Type,attr"`
+	} `xml:"Relationship"`
+}
+
+type xmlModel struct {
+	Unit    Unit        `xml:"unit,attr"`
+	Objects []xmlObject `xml:"resources>object"`
+	Items   []xmlItem   `xml:"build>item"`
+}
+
+type xmlObject struct {
+	ID   int      `xml:"id,attr"`
+	Name string   `xml:"name,attr"`
+	Mesh *xmlMesh `xml:"mesh"`
+}
+
+type xmlMesh struct {
+	Vertices []struct {
+		X float64 `xml:"x,attr"`
+		Y float64 `xml:"y,attr"`
+		Z float64 `xml:"z,attr"`
+	} `xml:"vertices>vertex"`
+	Triangles []struct {
+		V1 int `xml:"v1,attr"`
+		V2 int `xml:"v2,attr"`
+		V3 int `xml:"v3,attr"`
+	} `xml:"triangles>triangle"`
+}
+
+type xmlItem struct {
+	ObjectID  int    `xml:"objectid,attr"`
+	Transform string `xml:"transform,attr"`
+}
+
+// Read reads a 3MF file of the given size. The model document is located
+// through the relationships of the package.
+//
+// The objects of the model are those of the build, in order, with the
+// transforms of the build items applied to their meshes. Objects that are
+// made of other objects (components) are not supported.
+func Read(r io.ReaderAt, size int64) (*Model, error) {
+	model, err := read(r, size)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read 3MF: %w`, err)
+	}
+	return model, nil
+}
+
+func read(r io.ReaderAt, size int64) (*Model, error) {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var rels xmlRelationships
+	if err := decode(zr, `_rels/.rels`, &rels); err != nil {
+		return nil, err
+	}
+	path := ``
+	for _, rel := range rels.Relationships {
+		if rel.Type == relModel {
+			path = strings.TrimPrefix(rel.Target, `/`)
+			break
+		}
+	}
+	if path == `` {
+		return nil, fmt.Errorf(`package does not have a 3D model`)
+	}
+
+	var doc xmlModel
+	if err := decode(zr, path, &doc); err != nil {
+		return nil, err
+	}
+
+	model := &Model{Unit: doc.Unit}
+	if model.Unit == `` {
+		model.Unit = Millimeter
+	}
+	objects := make(map[int]*xmlObject)
+	for i := range doc.Objects {
+		objects[doc.Objects[i].ID] = &doc.Objects[i]
+	}
+	for i, item := range doc.Items {
+		o, ok := objects[item.ObjectID]
+		if !ok {
+			return nil, fmt.Errorf(`build item %d refers to object %d, which does not exist`, i, item.ObjectID)
+		}
+		if o.Mesh == nil {
+			return nil, fmt.Errorf(`object %d does not have a mesh, and components are not supported`, o.ID)
+		}
+		m, err := o.Mesh.mesh()
+		if err != nil {
+			return nil, fmt.Errorf(`invalid mesh in object %d: %w`, o.ID, err)
+		}
+		if item.Transform != `` {
+			mat, err := parseTransform(item.Transform)
+			if err != nil {
+				return nil, fmt.Errorf(`invalid transform in build item %d: %w`, i, err)
+			}
+			m = m.Transform(mat)
+		}
+		model.Objects = append(model.Objects, Object{Name: o.Name, Mesh: m})
+	}
+	return model, nil
+}
+
+func decode(zr *zip.Reader, name string, v interface{}) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := xml.NewDecoder(f).Decode(v); err != nil {
+		return fmt.Errorf(`failed to decode %s: %w`, name, err)
+	}
+	return nil
+}
+
+func (x *xmlMesh) mesh() (*mesh.Mesh, error) {
+	m := &mesh.Mesh{
+		Vertices:  make([]csg.Vec3, len(x.Vertices)),
+		Triangles: make([][3]int, len(x.Triangles)),
+	}
+	for i, v := range x.Vertices {
+		m.Vertices[i] = csg.Vec3{v.X, v.Y, v.Z}
+	}
+	for i, t := range x.Triangles {
+		m.Triangles[i] = [3]int{t.V1, t.V2, t.V3}
+		for _, idx := range m.Triangles[i] {
+			if idx < 0 || idx >= len(m.Vertices) {
+				return nil, fmt.Errorf(`triangle %d refers to vertex %d, which does not exist`, i, idx)
+			}
+		}
+	}
+	return m, nil
+}
+
+// parseTransform parses the 12 values of a transform: the rows of a 4x3
+// matrix that row vectors are multiplied with
+func parseTransform(s string) (csg.Matrix, error) {
+	fields := strings.Fields(s)
+	if len(fields) != 12 {
+		return csg.Matrix{}, fmt.Errorf(`expected 12 values, found %d`, len(fields))
+	}
+	mat := csg.Identity()
+	for i, field := range fields {
+		f, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return csg.Matrix{}, err
+		}
+		// ours is transposed, since it is multiplied with column vectors
+		mat[i%3][i/3] = f
+	}
+	return mat, nil
+}
@@ -0,0 +1,188 @@
+// Package threemf reads and writes meshes in the 3D Manufacturing Format
+// (3MF).
+//
+// A 3MF file is a zip archive laid out according to the Open Packaging
+// Conventions (OPC): the meshes are described by an XML document, which
+// is found through the relationships of the package. Unlike STL, it states
+// the unit that coordinates are in, and can hold several objects.
+package threemf
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/lestrrat-go/openscad/internal/number"
+	"github.com/lestrrat-go/openscad/mesh"
+)
+
+// Unit is the unit that the coordinates of a model are in
+type Unit string
+
+const (
+	Micron     Unit = `micron`
+	Millimeter Unit = `millimeter`
+	Centimeter Unit = `centimeter`
+	Inch       Unit = `inch`
+	Foot       Unit = `foot`
+	Meter      Unit = `meter`
+)
+
+func (u Unit) valid() bool {
+	switch u {
+	case Micron, Millimeter, Centimeter, Inch, Foot, Meter:
+		return true
+	}
+	return false
+}
+
+// Model is the content of a 3MF file
+type Model struct {
+	// Unit is the unit of the coordinates. The default is Millimeter.
+	Unit    Unit
+	Objects []Object
+}
+
+// Object is a mesh that is part of a model. Each object is placed on the
+// build plate as a separate part.
+type Object struct {
+	Name string
+	Mesh *mesh.Mesh
+}
+
+const modelPath = `3D/3dmodel.model`
+
+const (
+	nsContentTypes  = `http://schemas.openxmlformats.org/package/2006/content-types`
+	nsRelationships = `http://schemas.openxmlformats.org/package/2006/relationships`
+	nsModel         = `http://schemas.microsoft.com/3dmanufacturing/core/2015/02`
+	relModel        = `http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel`
+)
+
+const contentTypes = `<?xml version="1.0" encoding="UTF-8"?>
+<Types xmlns="` + nsContentTypes + `">
+ <Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+ <Default Extension="model" ContentType="application/vnd.ms-package.3dmanufacturing-3dmodel+xml"/>
+</Types>
+`
+
+const relationships = `<?xml version="1.0" encoding="UTF-8"?>
+<Relationships xmlns="` + nsRelationships + `">
+ <Relationship Target="/` + modelPath + `" Id="rel0" Type="` + relModel + `"/>
+</Relationships>
+`
+
+// Write writes model to w as a 3MF file. Each object is a separate
+// resource, with an item of its own in the build. The triangles of the
+// meshes must be in counter-clockwise order when seen from outside, which
+// is what the mesh package produces.
+//
+// The model is compressed as it is encoded, without holding the whole
+// document in memory.
+func Write(w io.Writer, model *Model) error {
+	if err := write(w, model); err != nil {
+		return fmt.Errorf(`failed to write 3MF: %w`, err)
+	}
+	return nil
+}
+
+func write(w io.Writer, model *Model) error {
+	unit := model.Unit
+	if unit == `` {
+		unit = Millimeter
+	}
+	if !unit.valid() {
+		return fmt.Errorf(`unknown unit %q`, unit)
+	}
+	for i, o := range model.Objects {
+		if o.Mesh == nil {
+			return fmt.Errorf(`object %d does not have a mesh`, i)
+		}
+	}
+
+	zw := zip.NewWriter(w)
+	for _, part := range []struct {
+		name    string
+		content string
+	}{
+		{`[Content_Types].xml`, contentTypes},
+		{`_rels/.rels`, relationships},
+	} {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.Create(modelPath)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(f)
+	writeModel(bw, unit, model.Objects)
+	if err := bw.Flush(); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeModel writes the model document. Errors are left for the caller to
+// pick up when flushing w.
+func writeModel(w *bufio.Writer, unit Unit, objects []Object) {
+	w.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	w.WriteString(`<model unit="` + string(unit) + `" xml:lang="en-US" xmlns="` + nsModel + `">` + "\n")
+	w.WriteString(" <resources>\n")
+
+	var buf []byte
+	for i, o := range objects {
+		buf = append(buf[:0], `  <object id="`...)
+		buf = strconv.AppendInt(buf, int64(i+1), 10)
+		buf = append(buf, `" type="model"`...)
+		w.Write(buf)
+		if o.Name != `` {
+			w.WriteString(` name="`)
+			xml.EscapeText(w, []byte(o.Name))
+			w.WriteString(`"`)
+		}
+		w.WriteString(">\n   <mesh>\n    <vertices>\n")
+		for _, v := range o.Mesh.Vertices {
+			buf = append(buf[:0], `     <vertex`...)
+			for j, f := range v {
+				buf = append(buf, ' ', 'x'+byte(j), '=', '"')
+				buf = number.Append(buf, f)
+				buf = append(buf, '"')
+			}
+			buf = append(buf, "/>\n"...)
+			w.Write(buf)
+		}
+		w.WriteString("    </vertices>\n    <triangles>\n")
+		for _, t := range o.Mesh.Triangles {
+			buf = append(buf[:0], `     <triangle`...)
+			for j, idx := range t {
+				buf = append(buf, ` v`...)
+				buf = strconv.AppendInt(buf, int64(j+1), 10)
+				buf = append(buf, '=', '"')
+				buf = strconv.AppendInt(buf, int64(idx), 10)
+				buf = append(buf, '"')
+			}
+			buf = append(buf, "/>\n"...)
+			w.Write(buf)
+		}
+		w.WriteString("    </triangles>\n   </mesh>\n  </object>\n")
+	}
+
+	w.WriteString(" </resources>\n <build>\n")
+	for i := range objects {
+		buf = append(buf[:0], `  <item objectid="`...)
+		buf = strconv.AppendInt(buf, int64(i+1), 10)
+		buf = append(buf, "\"/>\n"...)
+		w.Write(buf)
+	}
+	w.WriteString(" </build>\n</model>\n")
+}
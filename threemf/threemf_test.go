@@ -0,0 +1,86 @@
+package threemf_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/lestrrat-go/openscad/csg"
+	"github.com/lestrrat-go/openscad/mesh"
+	"github.com/lestrrat-go/openscad/threemf"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoundTrip(t *testing.T) {
+	parts, err := mesh.Parts(&csg.Group{Children: []csg.Node{
+		&csg.Cube{Size: csg.Vec3{1, 2, 3}},
+		&csg.Transform{
+			Matrix:   csg.Translation(csg.Vec3{10, 0, 0}),
+			Children: []csg.Node{&csg.Sphere{R: 2, Fragments: csg.Fragments{Fn: 10}}},
+		},
+	}})
+	require.NoError(t, err, `mesh.Parts should succeed`)
+
+	model := &threemf.Model{Unit: threemf.Inch}
+	for i, p := range parts {
+		model.Objects = append(model.Objects, threemf.Object{Name: []string{`block`, `ball & socket`}[i], Mesh: p})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, threemf.Write(&buf, model), `threemf.Write should succeed`)
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err, `output should be a zip file`)
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	require.Equal(t, []string{`[Content_Types].xml`, `_rels/.rels`, `3D/3dmodel.model`}, names, `package should have the OPC parts`)
+
+	got, err := threemf.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err, `threemf.Read should succeed`)
+	require.Equal(t, model, got, `model should be read back exactly`)
+}
+
+func TestRead(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{
+		`_rels/.rels`: `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Target="/3D/part.model" Id="r" Type="http://schemas.microsoft.com/3dmanufacturing/2013/01/3dmodel"/>
+</Relationships>`,
+		`3D/part.model`: `<model xmlns="http://schemas.microsoft.com/3dmanufacturing/core/2015/02">
+<resources>
+<object id="7" type="model"><mesh>
+<vertices><vertex x="0" y="0" z="0"/><vertex x="1" y="0" z="0"/><vertex x="0" y="1" z="0"/></vertices>
+<triangles><triangle v1="0" v2="1" v3="2"/></triangles>
+</mesh></object>
+</resources>
+<build><item objectid="7" transform="2 0 0 0 1 0 0 0 1 5 6 7"/></build>
+</model>`,
+	} {
+		f, err := zw.Create(name)
+		require.NoError(t, err, `zw.Create should succeed`)
+		_, err = io.WriteString(f, content)
+		require.NoError(t, err, `io.WriteString should succeed`)
+	}
+	require.NoError(t, zw.Close(), `zw.Close should succeed`)
+
+	model, err := threemf.Read(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err, `threemf.Read should succeed`)
+	require.Equal(t, threemf.Millimeter, model.Unit, `unit should default to millimeters`)
+	require.Len(t, model.Objects, 1, `model should have one object`)
+	require.Equal(t, []csg.Vec3{{5, 6, 7}, {7, 6, 7}, {5, 7, 7}}, model.Objects[0].Mesh.Vertices, `transform should be applied`)
+}
+
+func TestWriteErrors(t *testing.T) {
+	t.Run(`unknown unit`, func(t *testing.T) {
+		err := threemf.Write(io.Discard, &threemf.Model{Unit: `furlong`})
+		require.Error(t, err, `unknown units should be rejected`)
+		require.Contains(t, err.Error(), `furlong`, `error should mention the unit`)
+	})
+	t.Run(`missing mesh`, func(t *testing.T) {
+		err := threemf.Write(io.Discard, &threemf.Model{Objects: []threemf.Object{{Name: `part`}}})
+		require.Error(t, err, `objects without a mesh should be rejected`)
+	})
+}
@@ -0,0 +1,233 @@
+package openscad
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/lestrrat-go/openscad/ast"
+)
+
+// RegisterTree parses filename along with all of the files that it
+// (transitively) includes or uses, and registers each of them, so that
+// filename can be amalgamated without registering anything by hand.
+// It returns the name that filename was registered under.
+//
+// As with OpenSCAD, the files named in `include` and `use` directives
+// are first looked up relative to the directory of the file that contains
+// the directive, and then in each of the search paths (see WithSearchPaths).
+//
+// Files are registered under names relative to the directory of filename,
+// or relative to the search path that they were found in. Directives are
+// rewritten to refer to these names, so that files with the same name in
+// different directories do not get mixed up.
+func RegisterTree(filename string, options ...RegisterTreeOption) (string, error) {
+	var registry *ast.Registry
+	var searchPaths []string
+	var parseOptions []ParseOption
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case optSearchPathsKey{}:
+			searchPaths = append(searchPaths, option.Value().([]string)...)
+		case optRegistryKey{}:
+			registry = option.Value().(*ast.Registry)
+		default:
+			if po, ok := option.(ParseOption); ok {
+				parseOptions = append(parseOptions, po)
+			}
+		}
+	}
+
+	l := newTreeLoader(searchPaths, parseOptions, nil)
+	name := filepath.Base(filename)
+	if err := l.load(name, filename); err != nil {
+		return "", err
+	}
+
+	// only register once everything has been loaded successfully
+	if err := l.register(registry); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+type treeLoader struct {
+	searchPaths  []string
+	parseOptions []ParseOption
+	// files maps registered names to the files they were loaded from
+	files  map[string]string
+	parsed map[string]ast.Stmts
+	// cache, if non-nil, holds the results of parsing files previously.
+	// Files that have not changed since then are not parsed again.
+	cache map[string]*parsedFile
+	// states holds the state of each file that was read, as observed
+	// before reading it, and of each location that was probed while
+	// looking for files
+	states map[string]fileState
+}
+
+// fileState is used to detect changes to a file
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func statFile(filename string) fileState {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return fileState{}
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+}
+
+type parsedFile struct {
+	state fileState
+	stmts ast.Stmts
+}
+
+func newTreeLoader(searchPaths []string, parseOptions []ParseOption, cache map[string]*parsedFile) *treeLoader {
+	return &treeLoader{
+		searchPaths:  searchPaths,
+		parseOptions: parseOptions,
+		files:        make(map[string]string),
+		parsed:       make(map[string]ast.Stmts),
+		cache:        cache,
+		states:       make(map[string]fileState),
+	}
+}
+
+// register registers all of the loaded files. A nil registry means the
+// global registry.
+func (l *treeLoader) register(registry *ast.Registry) error {
+	for name, stmts := range l.parsed {
+		var err error
+		if registry == nil {
+			err = ast.Register(name, stmts)
+		} else {
+			err = registry.Register(name, stmts)
+		}
+		if err != nil {
+			return fmt.Errorf(`failed to register %q: %w`, name, err)
+		}
+	}
+	return nil
+}
+
+// parse parses filename, unless it has not changed since it was cached.
+// The returned statements must not be modified.
+func (l *treeLoader) parse(filename string) (ast.Stmts, error) {
+	state := statFile(filename)
+	l.states[filename] = state
+	if cached, ok := l.cache[filename]; ok && state.exists && cached.state == state {
+		return cached.stmts, nil
+	}
+
+	src, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to read %q: %w`, filename, err)
+	}
+	stmts, err := Parse(src, l.parseOptions...)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to parse %q: %w`, filename, err)
+	}
+	if l.cache != nil {
+		l.cache[filename] = &parsedFile{state: state, stmts: stmts}
+	}
+	return stmts, nil
+}
+
+func (l *treeLoader) load(name, filename string) error {
+	if loaded, ok := l.files[name]; ok {
+		if filepath.Clean(loaded) != filepath.Clean(filename) {
+			return fmt.Errorf(`both %q and %q would be registered as %q`, loaded, filename, name)
+		}
+		return nil
+	}
+	l.files[name] = filename
+
+	stmts, err := l.parse(filename)
+	if err != nil {
+		return err
+	}
+
+	copied := false
+	for i, stmt := range stmts {
+		var directive string
+		switch v := stmt.(type) {
+		case *ast.Include:
+			directive = v.Name()
+		case *ast.Use:
+			directive = v.Name()
+		default:
+			continue
+		}
+
+		depName, depFile, err := l.resolve(name, filename, directive)
+		if err != nil {
+			return err
+		}
+		if depName != directive {
+			var replacement interface {
+				ast.Stmt
+				ast.Positioner
+			}
+			if _, ok := stmt.(*ast.Include); ok {
+				replacement = ast.NewInclude(depName)
+			} else {
+				replacement = ast.NewUse(depName)
+			}
+			//nolint:forcetypeassert
+			replacement.SetPos(stmt.(ast.Positioner).Pos())
+			if !copied {
+				// the parsed statements may be cached, so leave them alone
+				stmts = append(ast.Stmts(nil), stmts...)
+				copied = true
+			}
+			stmts[i] = replacement
+		}
+
+		if err := l.load(depName, depFile); err != nil {
+			return err
+		}
+	}
+
+	l.parsed[name] = stmts
+	return nil
+}
+
+// resolve finds the file that a directive in the file registered as name
+// refers to, and returns the name to register it under along with its path
+func (l *treeLoader) resolve(name, filename, directive string) (string, string, error) {
+	if filepath.IsAbs(directive) {
+		return directive, directive, nil
+	}
+
+	candidate := filepath.Join(filepath.Dir(filename), filepath.FromSlash(directive))
+	if l.probe(candidate) {
+		return path.Join(path.Dir(name), filepath.ToSlash(directive)), candidate, nil
+	}
+
+	for _, dir := range l.searchPaths {
+		candidate := filepath.Join(dir, filepath.FromSlash(directive))
+		if l.probe(candidate) {
+			return path.Clean(filepath.ToSlash(directive)), candidate, nil
+		}
+	}
+	return "", "", fmt.Errorf(`file %q referenced from %q not found`, directive, filename)
+}
+
+// probe reports whether filename exists. Its state is recorded, so that
+// a file created later at a location that takes precedence is noticed.
+func (l *treeLoader) probe(filename string) bool {
+	l.states[filename] = statFile(filename)
+	return exists(filename)
+}
+
+func exists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,56 @@
+package openscad_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755), `os.MkdirAll should succeed`)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644), `os.WriteFile should succeed`)
+	}
+}
+
+func TestRegisterTree(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	libraries := filepath.Join(root, "libraries")
+	writeFiles(t, project, map[string]string{
+		"main.scad":  "include <lib/a.scad>\nuse <shared/util.scad>\na();",
+		"lib/a.scad": "include <b.scad>\nmodule a() { b(); }",
+		"lib/b.scad": "module b() { cube(1); }",
+		"b.scad":     "module b() { sphere(1); }",
+	})
+	writeFiles(t, libraries, map[string]string{
+		"shared/util.scad": "function twice(x) = x * 2;",
+	})
+
+	t.Run("resolve files", func(t *testing.T) {
+		registry := ast.NewRegistry()
+		name, err := openscad.RegisterTree(filepath.Join(project, "main.scad"), openscad.WithRegistry(registry), openscad.WithSearchPaths(libraries))
+		require.NoError(t, err, `openscad.RegisterTree should succeed`)
+		require.Equal(t, "main.scad", name)
+		require.Equal(t, []string{"lib/a.scad", "lib/b.scad", "main.scad", "shared/util.scad"}, registry.Names())
+
+		var buf strings.Builder
+		require.NoError(t, ast.EmitFile(name, &buf, ast.WithRegistry(registry), ast.WithAmalgamation()), `ast.EmitFile should succeed`)
+		require.Contains(t, buf.String(), "// START include lib/b.scad")
+		require.Contains(t, buf.String(), "cube(1);", `lib/b.scad should be used, not b.scad`)
+		require.NotContains(t, buf.String(), "sphere(1);")
+		require.Contains(t, buf.String(), "function twice(x)")
+	})
+	t.Run("missing files", func(t *testing.T) {
+		_, err := openscad.RegisterTree(filepath.Join(project, "main.scad"), openscad.WithRegistry(ast.NewRegistry()))
+		require.Error(t, err, `openscad.RegisterTree should fail without the search path`)
+		require.Contains(t, err.Error(), `shared/util.scad`)
+	})
+}
@@ -0,0 +1,156 @@
+package openscad
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/lestrrat-go/openscad/internal/atomicfile"
+)
+
+// Watch amalgamates filename into output, and does it again every time
+// filename or any of the files that it (transitively) includes or uses
+// changes, until ctx is canceled. Files are looked up in the same way
+// as RegisterTree() does, and the options for RegisterTree() can be
+// passed to Watch() as well.
+//
+// Files are polled for changes (see WithPollInterval), and only the files
+// that have changed are parsed again. The output is replaced atomically,
+// so that programs that reload it, such as OpenSCAD with "Automatic
+// Reload" turned on, never see a partially written file.
+//
+// Errors do not stop Watch(). They are reported through the function
+// given with WithErrorHandler(), and the output is left as it is until
+// the problem is fixed.
+func Watch(ctx context.Context, filename, output string, options ...WatchOption) error {
+	registry := ast.NewRegistry()
+	var searchPaths []string
+	var parseOptions []ParseOption
+	var emitOptions []ast.EmitFileOption
+	interval := 500 * time.Millisecond
+	onError := func(error) {}
+	onUpdate := func() {}
+	//nolint:forcetypeassert
+	for _, option := range options {
+		switch option.Ident() {
+		case optSearchPathsKey{}:
+			searchPaths = append(searchPaths, option.Value().([]string)...)
+		case optRegistryKey{}:
+			registry = option.Value().(*ast.Registry)
+		case optPollIntervalKey{}:
+			interval = option.Value().(time.Duration)
+		case optEmitOptionsKey{}:
+			emitOptions = append(emitOptions, option.Value().([]ast.EmitFileOption)...)
+		case optErrorHandlerKey{}:
+			onError = option.Value().(func(error))
+		case optUpdateHandlerKey{}:
+			onUpdate = option.Value().(func())
+		default:
+			if po, ok := option.(ParseOption); ok {
+				parseOptions = append(parseOptions, po)
+			}
+		}
+	}
+	if interval <= 0 {
+		return fmt.Errorf(`failed to watch %s: poll interval must be positive, got %s`, filename, interval)
+	}
+
+	w := &watcher{
+		filename:     filename,
+		output:       output,
+		registry:     registry,
+		searchPaths:  searchPaths,
+		parseOptions: parseOptions,
+		emitOptions:  emitOptions,
+		cache:        make(map[string]*parsedFile),
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	// the last error that was reported, so that a build that keeps
+	// failing in the same way is not reported over and over again
+	var lastError string
+	for {
+		// failed builds are retried, as they may be caused by files that
+		// do not exist yet, which are not being watched
+		if lastError != "" || w.changed() {
+			if err := w.build(); err != nil {
+				if err.Error() != lastError {
+					onError(err)
+				}
+				lastError = err.Error()
+			} else {
+				lastError = ""
+				onUpdate()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+type watcher struct {
+	filename     string
+	output       string
+	registry     *ast.Registry
+	searchPaths  []string
+	parseOptions []ParseOption
+	emitOptions  []ast.EmitFileOption
+	cache        map[string]*parsedFile
+	// states holds the state of the files that were read, and of the
+	// locations that were probed, during the last build. It is nil
+	// before the first build.
+	states map[string]fileState
+}
+
+// changed returns true if any of the files read during the last build
+// has changed since, or if a file has appeared at a location that was
+// probed while looking for a file
+func (w *watcher) changed() bool {
+	if w.states == nil {
+		return true
+	}
+	for filename, state := range w.states {
+		if statFile(filename) != state {
+			return true
+		}
+	}
+	return false
+}
+
+func (w *watcher) build() error {
+	l := newTreeLoader(w.searchPaths, w.parseOptions, w.cache)
+	name := filepath.Base(w.filename)
+	err := l.load(name, w.filename)
+
+	// watch every file that was looked at, including the one that failed
+	// and the locations that were probed
+	w.states = l.states
+	for filename := range w.cache {
+		if _, ok := l.states[filename]; !ok {
+			delete(w.cache, filename)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := l.register(w.registry); err != nil {
+		return err
+	}
+
+	options := append([]ast.EmitFileOption{ast.WithRegistry(w.registry), ast.WithAmalgamation()}, w.emitOptions...)
+	var buf bytes.Buffer
+	if err := ast.EmitFile(name, &buf, options...); err != nil {
+		return fmt.Errorf(`failed to amalgamate %q: %w`, w.filename, err)
+	}
+	buf.WriteByte('\n')
+	return atomicfile.WriteFile(w.output, buf.Bytes(), 0o644)
+}
@@ -0,0 +1,178 @@
+package openscad_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/openscad"
+	"github.com/lestrrat-go/openscad/ast"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"main.scad":      "use <lib/parts.scad>\npart();",
+		"lib/parts.scad": "module part() {\n  cube(1);\n}",
+	})
+	entry := filepath.Join(dir, "main.scad")
+	output := filepath.Join(dir, "out.scad")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	registry := ast.NewRegistry()
+	updates := make(chan struct{}, 16)
+	errors := make(chan error, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- openscad.Watch(ctx, entry, output,
+			openscad.WithRegistry(registry),
+			openscad.WithPollInterval(10*time.Millisecond),
+			openscad.WithEmitOptions(ast.WithTreeShaking()),
+			openscad.WithErrorHandler(func(err error) { errors <- err }),
+			openscad.WithUpdateHandler(func() { updates <- struct{}{} }),
+		)
+	}()
+
+	waitUpdate := func(t *testing.T) {
+		t.Helper()
+		select {
+		case <-updates:
+		case err := <-errors:
+			require.NoError(t, err, `no errors should be reported`)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, `timed out waiting for an update`)
+		}
+	}
+	// modify rewrites a file, making sure that its modification time changes
+	modify := func(t *testing.T, name, content string) {
+		t.Helper()
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		info, err := os.Stat(path)
+		require.NoError(t, err, `os.Stat should succeed`)
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644), `os.WriteFile should succeed`)
+		mtime := info.ModTime().Add(time.Second)
+		require.NoError(t, os.Chtimes(path, mtime, mtime), `os.Chtimes should succeed`)
+	}
+	readOutput := func(t *testing.T) string {
+		t.Helper()
+		buf, err := os.ReadFile(output)
+		require.NoError(t, err, `os.ReadFile should succeed`)
+		return string(buf)
+	}
+	firstNode := func(t *testing.T, name string) ast.Stmt {
+		t.Helper()
+		stmt, ok := registry.Lookup(name)
+		require.True(t, ok, `%s should be registered`, name)
+		return stmt.(ast.Stmts)[0]
+	}
+
+	waitUpdate(t)
+	require.Contains(t, readOutput(t), "cube(1);")
+	part := firstNode(t, "lib/parts.scad")
+
+	// only the file that changed is parsed again
+	modify(t, "main.scad", "use <lib/parts.scad>\npart();\npart();")
+	waitUpdate(t)
+	require.Contains(t, readOutput(t), "part();\npart();")
+	require.True(t, part == firstNode(t, "lib/parts.scad"), `unchanged files should not be parsed again`)
+
+	modify(t, "lib/parts.scad", "module part() {\n  sphere(2);\n}")
+	waitUpdate(t)
+	require.Contains(t, readOutput(t), "sphere(2);")
+
+	// a parse error is reported, and the output is left alone
+	previous := readOutput(t)
+	modify(t, "lib/parts.scad", "module part( {")
+	select {
+	case err := <-errors:
+		require.Contains(t, err.Error(), "parts.scad")
+	case <-updates:
+		require.Fail(t, `the output should not be updated`)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, `timed out waiting for an error`)
+	}
+	require.Equal(t, previous, readOutput(t))
+
+	// once the file is fixed, the watcher picks up again
+	modify(t, "lib/parts.scad", "module part() {\n  cylinder(h=1, r=3);\n}")
+	waitUpdate(t)
+	require.Contains(t, readOutput(t), "cylinder(h=1, r=3);")
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err, `openscad.Watch should return without an error`)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, `timed out waiting for openscad.Watch to return`)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, ".out.scad.*"))
+	require.NoError(t, err, `filepath.Glob should succeed`)
+	require.Empty(t, matches, `temporary files should be removed`)
+}
+
+func TestWatchInterval(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"main.scad": "cube(1);"})
+	for _, interval := range []time.Duration{0, -time.Second} {
+		err := openscad.Watch(context.Background(), filepath.Join(dir, "main.scad"), filepath.Join(dir, "out.scad"),
+			openscad.WithPollInterval(interval),
+		)
+		require.Error(t, err, `openscad.Watch should fail for an interval of %s`, interval)
+	}
+}
+
+func TestWatchSearchPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"project/main.scad":  "use <parts.scad>\npart();",
+		"library/parts.scad": "module part() {\n  cube(1);\n}",
+	})
+	entry := filepath.Join(dir, "project", "main.scad")
+	output := filepath.Join(dir, "out.scad")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	updates := make(chan struct{}, 16)
+	errors := make(chan error, 16)
+	go func() {
+		_ = openscad.Watch(ctx, entry, output,
+			openscad.WithRegistry(ast.NewRegistry()),
+			openscad.WithSearchPaths(filepath.Join(dir, "library")),
+			openscad.WithPollInterval(10*time.Millisecond),
+			openscad.WithErrorHandler(func(err error) { errors <- err }),
+			openscad.WithUpdateHandler(func() { updates <- struct{}{} }),
+		)
+	}()
+
+	waitUpdate := func(t *testing.T) {
+		t.Helper()
+		select {
+		case <-updates:
+		case err := <-errors:
+			require.NoError(t, err, `no errors should be reported`)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, `timed out waiting for an update`)
+		}
+	}
+
+	waitUpdate(t)
+	buf, err := os.ReadFile(output)
+	require.NoError(t, err, `os.ReadFile should succeed`)
+	require.Contains(t, string(buf), "cube(1);")
+
+	// a file next to main.scad takes precedence over the search path
+	writeFiles(t, dir, map[string]string{
+		"project/parts.scad": "module part() {\n  sphere(2);\n}",
+	})
+	waitUpdate(t)
+	buf, err = os.ReadFile(output)
+	require.NoError(t, err, `os.ReadFile should succeed`)
+	require.Contains(t, string(buf), "sphere(2);")
+}